@@ -0,0 +1,190 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// The positional accessors read and write single integers at absolute
+// offsets without moving the offset of the buffer. Unlike ReadData and
+// WriteData they decode in place, with no reflection or allocation.
+
+// fixed returns the n bytes at off, read into scratch for buffers which
+// are not backed by memory.
+func (b *BufferIO) fixed(off int64, n int, scratch []byte) ([]byte, error) {
+	if b.from == nil {
+		return b.slice(off, int64(n))
+	}
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+	if off < 0 || int64(n) > b.fromSize-off {
+		return nil, ErrOverrun
+	}
+	if _, err := b.readFrom(scratch[:n], off); err != nil {
+		return nil, err
+	}
+	return scratch[:n], nil
+}
+
+// putFixed writes all of p at off.
+func (b *BufferIO) putFixed(off int64, p []byte) error {
+	if off < 0 {
+		return ErrOverrun
+	}
+	_, err := b.writeAt(p, off)
+	if err == io.ErrShortWrite {
+		err = ErrOverrun
+	}
+	return err
+}
+
+// Uint8At returns the byte at off.
+func (b *BufferIO) Uint8At(off int64) (uint8, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var scratch [1]byte
+	p, err := b.fixed(off, 1, scratch[:])
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// PutUint8At sets the byte at off to v.
+func (b *BufferIO) PutUint8At(off int64, v uint8) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := [1]byte{v}
+	return b.putFixed(off, p[:])
+}
+
+// Uint16LEAt returns the little endian uint16 at off.
+func (b *BufferIO) Uint16LEAt(off int64) (uint16, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var scratch [2]byte
+	p, err := b.fixed(off, 2, scratch[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(p), nil
+}
+
+// PutUint16LEAt stores v at off as a little endian uint16.
+func (b *BufferIO) PutUint16LEAt(off int64, v uint16) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var p [2]byte
+	binary.LittleEndian.PutUint16(p[:], v)
+	return b.putFixed(off, p[:])
+}
+
+// Uint16BEAt returns the big endian uint16 at off.
+func (b *BufferIO) Uint16BEAt(off int64) (uint16, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var scratch [2]byte
+	p, err := b.fixed(off, 2, scratch[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(p), nil
+}
+
+// PutUint16BEAt stores v at off as a big endian uint16.
+func (b *BufferIO) PutUint16BEAt(off int64, v uint16) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var p [2]byte
+	binary.BigEndian.PutUint16(p[:], v)
+	return b.putFixed(off, p[:])
+}
+
+// Uint32LEAt returns the little endian uint32 at off.
+func (b *BufferIO) Uint32LEAt(off int64) (uint32, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var scratch [4]byte
+	p, err := b.fixed(off, 4, scratch[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(p), nil
+}
+
+// PutUint32LEAt stores v at off as a little endian uint32.
+func (b *BufferIO) PutUint32LEAt(off int64, v uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var p [4]byte
+	binary.LittleEndian.PutUint32(p[:], v)
+	return b.putFixed(off, p[:])
+}
+
+// Uint32BEAt returns the big endian uint32 at off.
+func (b *BufferIO) Uint32BEAt(off int64) (uint32, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var scratch [4]byte
+	p, err := b.fixed(off, 4, scratch[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(p), nil
+}
+
+// PutUint32BEAt stores v at off as a big endian uint32.
+func (b *BufferIO) PutUint32BEAt(off int64, v uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var p [4]byte
+	binary.BigEndian.PutUint32(p[:], v)
+	return b.putFixed(off, p[:])
+}
+
+// Uint64LEAt returns the little endian uint64 at off.
+func (b *BufferIO) Uint64LEAt(off int64) (uint64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var scratch [8]byte
+	p, err := b.fixed(off, 8, scratch[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(p), nil
+}
+
+// PutUint64LEAt stores v at off as a little endian uint64.
+func (b *BufferIO) PutUint64LEAt(off int64, v uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var p [8]byte
+	binary.LittleEndian.PutUint64(p[:], v)
+	return b.putFixed(off, p[:])
+}
+
+// Uint64BEAt returns the big endian uint64 at off.
+func (b *BufferIO) Uint64BEAt(off int64) (uint64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var scratch [8]byte
+	p, err := b.fixed(off, 8, scratch[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(p), nil
+}
+
+// PutUint64BEAt stores v at off as a big endian uint64.
+func (b *BufferIO) PutUint64BEAt(off int64, v uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], v)
+	return b.putFixed(off, p[:])
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestAccessors(t *testing.T) {
+	bio := NewBufferIOMake(16)
+	assert(t, bio.PutUint8At(0, 0xab) == nil)
+	assert(t, bio.PutUint16BEAt(1, 0x0102) == nil)
+	assert(t, bio.PutUint32LEAt(3, 0x03040506) == nil)
+	assert(t, bio.PutUint64BEAt(8, 0x0708090a0b0c0d0e) == nil)
+
+	p := bio.Bytes()
+	assert(t, p[0] == 0xab)
+	assert(t, binary.BigEndian.Uint16(p[1:]) == 0x0102)
+	assert(t, binary.LittleEndian.Uint32(p[3:]) == 0x03040506)
+	assert(t, binary.BigEndian.Uint64(p[8:]) == 0x0708090a0b0c0d0e)
+
+	v8, err := bio.Uint8At(0)
+	assert(t, err == nil && v8 == 0xab)
+	v16, err := bio.Uint16LEAt(1)
+	assert(t, err == nil && v16 == 0x0201)
+	v32, err := bio.Uint32BEAt(3)
+	assert(t, err == nil && v32 == 0x06050403)
+	v64, err := bio.Uint64LEAt(8)
+	assert(t, err == nil && v64 == 0x0e0d0c0b0a090807)
+
+	// The offset does not move
+	pos, _ := bio.Seek(0, 1)
+	assert(t, pos == 0)
+}
+
+func TestAccessorsBounds(t *testing.T) {
+	bio := NewBufferIOMake(8)
+	_, err := bio.Uint64LEAt(1)
+	assert(t, err == ErrOverrun)
+	_, err = bio.Uint16BEAt(-1)
+	assert(t, err == ErrOverrun)
+	assert(t, bio.PutUint32BEAt(6, 1) == ErrOverrun)
+	assert(t, bio.PutUint16LEAt(-2, 1) == ErrOverrun)
+	assert(t, bio.PutUint64LEAt(0, 1) == nil)
+
+	assert(t, bio.Close() == nil)
+	_, err = bio.Uint8At(0)
+	assert(t, err == ErrClosed)
+	assert(t, bio.PutUint8At(0, 1) == ErrClosed)
+}
+
+func TestAccessorsBacked(t *testing.T) {
+	bio := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	v, err := bio.Uint32BEAt(4)
+	assert(t, err == nil && v == binary.BigEndian.Uint32(big[4:]))
+	_, err = bio.Uint64LEAt(int64(len(big)) - 4)
+	assert(t, err == ErrOverrun)
+	assert(t, bio.PutUint8At(0, 1) == ErrReadOnly)
+}
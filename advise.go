@@ -0,0 +1,63 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"os"
+)
+
+// Advice describes the expected access pattern of a region of a mapped
+// buffer.
+type Advice int
+
+const (
+	AdviseNormal     Advice = iota // no special treatment
+	AdviseSequential               // read ahead aggressively, free pages after use
+	AdviseRandom                   // do not read ahead
+	AdviseWillNeed                 // start reading the region in
+	AdviseDontNeed                 // drop the region from memory
+)
+
+// Advise passes an access pattern hint for the n bytes at off to the
+// kernel with madvise. It is only supported by mapped backings.
+//
+// The kernel works on whole pages. Hints are widened to the pages the
+// region touches, except AdviseDontNeed, which is narrowed to the pages
+// lying entirely inside it, since dropping pages of an anonymous mapping
+// discards their contents: they read back as zeros. Pages of a file
+// mapping are read from the file again.
+func (b *BufferIO) Advise(off, n int64, advice Advice) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.memory(); err != nil {
+		return err
+	}
+	if _, err := b.slice(off, n); err != nil {
+		return err
+	}
+	switch b.backing {
+	case BackingMmap, BackingHugePages, BackingFile:
+	default:
+		return ErrUnsupported
+	}
+
+	// Mappings start on a page boundary, so offsets line up with pages
+	page := int64(os.Getpagesize())
+	start, end := off, off+n
+	if advice == AdviseDontNeed {
+		start = (start + page - 1) / page * page
+		end = end / page * page
+	} else {
+		start = start / page * page
+		end = (end + page - 1) / page * page
+		if end > b.size() {
+			end = b.size()
+		}
+	}
+	if start >= end {
+		return nil
+	}
+	return madvise(b.buf[start:end], advice)
+}
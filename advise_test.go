@@ -0,0 +1,37 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAdvise(t *testing.T) {
+	page := os.Getpagesize()
+	bio, err := NewBufferIOOptions(4*page, Options{Backing: BackingMmap})
+	if err == ErrUnsupported {
+		t.Skip("mappings not supported")
+	}
+	assert(t, err == nil)
+	defer bio.Close()
+
+	assert(t, bio.Advise(0, bio.Size(), AdviseSequential) == nil)
+	assert(t, bio.Advise(10, 100, AdviseWillNeed) == nil)
+	assert(t, bio.Advise(0, bio.Size(), AdviseNormal) == nil)
+	assert(t, bio.Advise(0, bio.Size()+1, AdviseRandom) == ErrOverrun)
+
+	// Only whole pages inside the region are dropped
+	for i := range bio.buf {
+		bio.buf[i] = 0xaa
+	}
+	assert(t, bio.Advise(10, int64(2*page), AdviseDontNeed) == nil)
+	assert(t, bio.buf[page-1] == 0xaa)
+	assert(t, bio.buf[page] == 0)
+	assert(t, bio.buf[2*page-1] == 0)
+	assert(t, bio.buf[2*page] == 0xaa)
+
+	assert(t, NewBufferIOMake(10).Advise(0, 10, AdviseRandom) == ErrUnsupported)
+}
@@ -0,0 +1,34 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package bufferio
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+var adviceFlags = [...]int{
+	AdviseNormal:     syscall.MADV_NORMAL,
+	AdviseSequential: syscall.MADV_SEQUENTIAL,
+	AdviseRandom:     syscall.MADV_RANDOM,
+	AdviseWillNeed:   syscall.MADV_WILLNEED,
+	AdviseDontNeed:   syscall.MADV_DONTNEED,
+}
+
+func madvise(b []byte, advice Advice) error {
+	if advice < 0 || int(advice) >= len(adviceFlags) {
+		return errors.New("invalid advice")
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MADVISE,
+		uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), uintptr(adviceFlags[advice]))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// AlignmentError reports a range whose address is not a multiple of the
+// alignment required.
+type AlignmentError struct {
+	Off   int64
+	Align int
+	Addr  uintptr
+}
+
+func (e *AlignmentError) Error() string {
+	return fmt.Sprintf("offset %d (address %#x) is not aligned to %d bytes",
+		e.Off, e.Addr, e.Align)
+}
+
+// RequireAlignment checks that the memory at off is aligned to align
+// bytes, which must be a power of two. Overlaying a typed value on
+// misaligned memory only faults on some architectures; checking first
+// catches the mistake everywhere.
+func (b *BufferIO) RequireAlignment(off int64, align int) error {
+	if align <= 0 || align&(align-1) != 0 {
+		return fmt.Errorf("alignment %d is not a power of two", align)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.memory(); err != nil {
+		return err
+	}
+	if off < 0 || off >= b.size() {
+		return ErrOverrun
+	}
+	addr := uintptr(unsafe.Pointer(&b.buf[off]))
+	if addr&uintptr(align-1) != 0 {
+		return &AlignmentError{Off: off, Align: align, Addr: addr}
+	}
+	return nil
+}
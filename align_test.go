@@ -0,0 +1,39 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestRequireAlignment(t *testing.T) {
+	b := NewBufferIOMake(64)
+
+	// Find an 8 byte aligned offset regardless of where the slice landed
+	base := int64(-uintptr(unsafe.Pointer(&b.Bytes()[0])) & 7)
+
+	assert(t, b.RequireAlignment(base, 8) == nil)
+	assert(t, b.RequireAlignment(base+4, 4) == nil)
+	assert(t, b.RequireAlignment(base+3, 1) == nil)
+
+	err := b.RequireAlignment(base+4, 8)
+	aerr, ok := err.(*AlignmentError)
+	assert(t, ok)
+	assert(t, aerr.Off == base+4)
+	assert(t, aerr.Align == 8)
+	assert(t, aerr.Addr&7 == 4)
+
+	_, ok = b.RequireAlignment(base+2, 4).(*AlignmentError)
+	assert(t, ok)
+
+	assert(t, b.RequireAlignment(base, 3) != nil)
+	assert(t, b.RequireAlignment(base, 0) != nil)
+	assert(t, b.RequireAlignment(64, 1) == ErrOverrun)
+	assert(t, b.RequireAlignment(-1, 1) == ErrOverrun)
+
+	assert(t, b.Close() == nil)
+	assert(t, b.RequireAlignment(0, 1) == ErrClosed)
+}
@@ -0,0 +1,152 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+)
+
+// Allocator is a source of memory for buffers, set with
+// Options.Allocator. NewBufferIOOptions serves each Backing with one of
+// the allocators below, and embedders can supply their own, such as
+// memory from C; the other constructors and growth of their buffers
+// take memory directly. Alloc returns exactly n bytes, used as they
+// come, and Free is given them back when the buffer is closed. Buffers
+// of a custom allocator are resized by allocating the new size,
+// copying, and freeing the old memory.
+type Allocator interface {
+	Alloc(n int) ([]byte, error)
+	Free(p []byte) error
+}
+
+// HeapAllocator allocates from the Go heap, leaving the memory to the
+// garbage collector.
+type HeapAllocator struct{}
+
+func (HeapAllocator) Alloc(n int) ([]byte, error) {
+	return make([]byte, n), nil
+}
+
+func (HeapAllocator) Free(p []byte) error {
+	return nil
+}
+
+// MmapAllocator allocates anonymous memory mappings, backed by huge
+// pages if HugePages is set, and bound to NUMANode if NUMA is set.
+type MmapAllocator struct {
+	HugePages bool
+	NUMA      bool
+	NUMANode  int
+}
+
+func (m MmapAllocator) Alloc(n int) ([]byte, error) {
+	buf, err := mmapAnon(n)
+	if err != nil {
+		return nil, err
+	}
+	if m.HugePages {
+		if err := adviseHugePages(buf); err != nil {
+			munmapAnon(buf)
+			return nil, err
+		}
+	}
+	if m.NUMA {
+		if err := bindNUMA(buf, m.NUMANode); err != nil {
+			munmapAnon(buf)
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func (m MmapAllocator) Free(p []byte) error {
+	return munmapAnon(p)
+}
+
+// PoolAllocator recycles memory through Pool, or DefaultPool when nil,
+// clearing it as Zero says.
+type PoolAllocator struct {
+	Pool *Pool
+	Zero ZeroPolicy
+}
+
+func (a PoolAllocator) pool() *Pool {
+	if a.Pool == nil {
+		return DefaultPool
+	}
+	return a.Pool
+}
+
+func (a PoolAllocator) Alloc(n int) ([]byte, error) {
+	buf := a.pool().Get(n)
+	if a.Zero == ZeroOnAlloc {
+		zero(buf)
+	}
+	return buf, nil
+}
+
+func (a PoolAllocator) Free(p []byte) error {
+	if a.Zero == ZeroOnClose {
+		zero(p)
+	}
+	a.pool().Put(p)
+	return nil
+}
+
+// ArenaAllocator carves memory out of Arena, clearing it on allocation
+// with ZeroOnAlloc. Free only clears it with ZeroOnClose, as the arena
+// is reclaimed as a whole by Reset.
+type ArenaAllocator struct {
+	Arena *Arena
+	Zero  ZeroPolicy
+}
+
+func (a ArenaAllocator) Alloc(n int) ([]byte, error) {
+	if a.Arena == nil {
+		return nil, ErrNoArena
+	}
+	buf, err := a.Arena.Alloc(n)
+	if err != nil {
+		return nil, err
+	}
+	if a.Zero == ZeroOnAlloc {
+		zero(buf)
+	}
+	return buf, nil
+}
+
+func (a ArenaAllocator) Free(p []byte) error {
+	if a.Zero == ZeroOnClose {
+		zero(p)
+	}
+	return nil
+}
+
+// allocator returns the allocator of the options, and whether the
+// memory it hands out has to be given back
+func (opts Options) allocator() (Allocator, bool, error) {
+	if opts.Allocator != nil {
+		return opts.Allocator, true, nil
+	}
+	switch opts.Backing {
+	case BackingHeap:
+		return HeapAllocator{}, false, nil
+	case BackingMmap, BackingHugePages:
+		return MmapAllocator{
+			HugePages: opts.Backing == BackingHugePages,
+			NUMA:      opts.NUMA,
+			NUMANode:  opts.NUMANode,
+		}, true, nil
+	case BackingPool:
+		return PoolAllocator{Pool: opts.Pool, Zero: opts.Zero}, true, nil
+	case BackingArena:
+		if opts.Arena == nil {
+			return nil, false, ErrNoArena
+		}
+		// Arena memory only needs giving back to be cleared
+		return ArenaAllocator{Arena: opts.Arena, Zero: opts.Zero}, opts.Zero == ZeroOnClose, nil
+	}
+	return nil, false, errors.New("invalid backing")
+}
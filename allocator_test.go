@@ -0,0 +1,115 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// countingAllocator allocates from the heap and counts what is live
+type countingAllocator struct {
+	live  int
+	fail  bool
+	freed [][]byte
+}
+
+func (c *countingAllocator) Alloc(n int) ([]byte, error) {
+	if c.fail {
+		return nil, errors.New("out of memory")
+	}
+	c.live++
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = 0xee
+	}
+	return p, nil
+}
+
+func (c *countingAllocator) Free(p []byte) error {
+	c.live--
+	c.freed = append(c.freed, p)
+	return nil
+}
+
+func TestAllocatorOptions(t *testing.T) {
+	c := &countingAllocator{}
+	testBacking(t, Options{Allocator: HeapAllocator{}})
+
+	b, err := NewBufferIOOptions(16, Options{Allocator: c})
+	assert(t, err == nil && c.live == 1 && b.Size() == 16)
+	assert(t, b.Close() == nil && c.live == 0 && len(c.freed[0]) == 16)
+
+	c.fail = true
+	_, err = NewBufferIOOptions(16, Options{Allocator: c})
+	assert(t, err != nil)
+
+	_, err = NewBufferIOOptions(16, Options{Allocator: c, NUMA: true})
+	assert(t, err == ErrUnsupported)
+}
+
+func TestAllocatorResize(t *testing.T) {
+	c := &countingAllocator{}
+	b, _ := NewBufferIOOptions(8, Options{Allocator: c})
+	copy(b.Bytes(), src)
+
+	// Growth copies into a new allocation and zero fills the rest
+	assert(t, b.Resize(16) == nil)
+	assert(t, c.live == 1 && len(c.freed) == 1)
+	assert(t, bytes.Equal(b.Bytes()[:8], src))
+	assert(t, isZero(b.Bytes()[8:]))
+
+	assert(t, b.Resize(4) == nil)
+	assert(t, bytes.Equal(b.Bytes(), src[:4]))
+
+	b.SetGrowable(true)
+	_, err := b.WriteAt(src, 10)
+	assert(t, err == nil && b.Size() == 18)
+	assert(t, isZero(b.Bytes()[4:10]))
+	b.Close()
+	assert(t, c.live == 0)
+
+	// Debug buffers poison their memory before freeing it
+	c = &countingAllocator{}
+	b, _ = NewBufferIOOptions(8, Options{Allocator: c, Debug: true})
+	assert(t, b.Resize(16) == nil)
+	assert(t, c.live == 1 && len(c.freed) == 1)
+	assert(t, bytes.Equal(c.freed[0], bytes.Repeat([]byte{PoisonByte}, 8)))
+	b.Close()
+	assert(t, c.live == 0 && len(c.freed) == 2)
+	assert(t, bytes.Equal(c.freed[1], bytes.Repeat([]byte{PoisonByte}, 16)))
+}
+
+func TestAllocators(t *testing.T) {
+	for _, a := range []Allocator{
+		HeapAllocator{},
+		PoolAllocator{Pool: NewPool()},
+		ArenaAllocator{Arena: NewArena(128)},
+		MmapAllocator{},
+	} {
+		p, err := a.Alloc(64)
+		if err == ErrUnsupported {
+			continue
+		}
+		assert(t, err == nil && len(p) == 64 && isZero(p))
+		assert(t, a.Free(p) == nil)
+	}
+
+	_, err := ArenaAllocator{}.Alloc(8)
+	assert(t, err == ErrNoArena)
+
+	// Pool memory is cleared according to the policy
+	pool := NewPool()
+	a := PoolAllocator{Pool: pool, Zero: ZeroNever}
+	p, _ := a.Alloc(64)
+	p[0] = 1
+	a.Free(p)
+	p, _ = a.Alloc(64)
+	assert(t, p[0] == 1)
+	a.Zero = ZeroOnClose
+	a.Free(p)
+	assert(t, p[0] == 0)
+}
@@ -0,0 +1,131 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	appendLogHeader    = 4
+	appendLogAlign     = 4
+	appendLogCommitted = 1 << 31
+	AppendLogMaxRecord = appendLogCommitted - 1
+)
+
+var ErrRecordTooLarge = errors.New("record too large")
+
+// AppendLog lets many goroutines append records to a buffer concurrently
+// without locking. Space is reserved by atomically moving the tail, and a
+// record becomes visible to readers once its header is committed.
+//
+// Each record is stored as a 4 byte header holding the payload length
+// followed by the payload, padded to a multiple of 4 bytes.
+type AppendLog struct {
+	// tail is accessed with 64 bit atomics, which need 8 byte alignment
+	// on 32 bit platforms. Only the first word of an allocated struct is
+	// guaranteed to have it, so tail must stay the first field.
+	tail int64
+	buf  []byte
+}
+
+// NewAppendLog takes over the memory of b and clears it. b should not be
+// written through other means while the log is in use. Bytes needed to
+// align the records to 4 bytes are left unused at either end.
+func NewAppendLog(b *BufferIO) *AppendLog {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	buf := b.buf
+	if len(buf) > 0 {
+		pad := int(-uintptr(unsafe.Pointer(&buf[0])) & (appendLogAlign - 1))
+		if pad > len(buf) {
+			pad = len(buf)
+		}
+		buf = buf[pad:]
+	}
+	buf = buf[:len(buf)&^(appendLogAlign-1)]
+	zero(buf)
+	return &AppendLog{buf: buf}
+}
+
+func appendLogSize(n int) int64 {
+	return int64(appendLogHeader+n+appendLogAlign-1) &^ (appendLogAlign - 1)
+}
+
+func (l *AppendLog) header(off int64) *uint32 {
+	return (*uint32)(unsafe.Pointer(&l.buf[off]))
+}
+
+// Append copies p into the log and returns the offset of its record.
+// ErrOverrun is returned once the log is full.
+func (l *AppendLog) Append(p []byte) (int64, error) {
+	if len(p) > AppendLogMaxRecord {
+		return 0, ErrRecordTooLarge
+	}
+
+	size := appendLogSize(len(p))
+	off, ok := l.reserve(size)
+	if !ok {
+		return 0, ErrOverrun
+	}
+
+	copy(l.buf[off+appendLogHeader:], p)
+	atomic.StoreUint32(l.header(off), appendLogCommitted|uint32(len(p)))
+	return off, nil
+}
+
+// reserve claims size bytes at the tail. The tail only moves forward,
+// and only for records which fit, so a failed reservation never hands
+// out space another writer already holds.
+func (l *AppendLog) reserve(size int64) (int64, bool) {
+	for {
+		off := atomic.LoadInt64(&l.tail)
+		if size > int64(len(l.buf))-off {
+			return 0, false
+		}
+		if atomic.CompareAndSwapInt64(&l.tail, off, off+size) {
+			return off, true
+		}
+	}
+}
+
+// Scan calls fn with every committed record from offset from onwards
+// until fn returns false, the end of the log, or a record which is still
+// being written. It returns the offset at which a later Scan should
+// resume. The record slices share memory with the log.
+func (l *AppendLog) Scan(from int64, fn func(off int64, record []byte) bool) int64 {
+	off := from
+	for off+appendLogHeader <= int64(len(l.buf)) {
+		h := atomic.LoadUint32(l.header(off))
+		if h&appendLogCommitted == 0 {
+			break
+		}
+		n := int64(h &^ appendLogCommitted)
+		start := off + appendLogHeader
+		next := off + appendLogSize(int(n))
+		if !fn(off, l.buf[start:start+n:start+n]) {
+			return next
+		}
+		off = next
+	}
+	return off
+}
+
+// Reserved returns the number of bytes claimed by writers so far,
+// including records which are not committed yet.
+func (l *AppendLog) Reserved() int64 {
+	r := atomic.LoadInt64(&l.tail)
+	if r > int64(len(l.buf)) {
+		r = int64(len(l.buf))
+	}
+	return r
+}
+
+func (l *AppendLog) Size() int64 {
+	return int64(len(l.buf))
+}
@@ -0,0 +1,169 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"testing"
+)
+
+func TestAppendLog(t *testing.T) {
+	bio := NewBufferIOMake(32)
+	bio.buf[0] = 0xff
+	log := NewAppendLog(bio)
+	assert(t, log.Size() == 32)
+	assert(t, bio.buf[0] == 0)
+
+	off, err := log.Append([]byte{1, 2, 3})
+	assert(t, off == 0)
+	assert(t, err == nil)
+
+	off, err = log.Append(nil)
+	assert(t, off == 8)
+	assert(t, err == nil)
+
+	off, err = log.Append(src)
+	assert(t, off == 12)
+	assert(t, err == nil)
+	assert(t, log.Reserved() == 24)
+
+	// Does not fit
+	_, err = log.Append(src)
+	assert(t, err == ErrOverrun)
+	assert(t, log.Reserved() == 24)
+
+	var records [][]byte
+	next := log.Scan(0, func(off int64, record []byte) bool {
+		records = append(records, record)
+		return true
+	})
+	assert(t, next == 24)
+	assert(t, len(records) == 3)
+	assert(t, bytes.Equal(records[0], []byte{1, 2, 3}))
+	assert(t, len(records[1]) == 0)
+	assert(t, bytes.Equal(records[2], src))
+
+	// Resume from a previous position
+	count := 0
+	next = log.Scan(8, func(off int64, record []byte) bool {
+		count++
+		return false
+	})
+	assert(t, count == 1)
+	assert(t, next == 12)
+
+	// Memory is trimmed to the record alignment
+	log = NewAppendLog(NewBufferIO(make([]byte, 64)[1:12]))
+	assert(t, log.Size() == 8)
+	_, err = log.Append(src[:4])
+	assert(t, err == nil)
+
+	log = NewAppendLog(NewBufferIO(make([]byte, 64)[1:3]))
+	assert(t, log.Size() == 0)
+}
+
+func TestAppendLogFailedReservations(t *testing.T) {
+	log := NewAppendLog(NewBufferIOMake(32))
+	off, err := log.Append(src[:4])
+	assert(t, err == nil && off == 0)
+
+	// Writers A and B fail to reserve between the appends of C and D,
+	// in the order which used to hand C and D overlapping space
+	_, ok := log.reserve(appendLogSize(28))
+	assert(t, !ok)
+	_, ok = log.reserve(appendLogSize(24))
+	assert(t, !ok)
+	assert(t, log.Reserved() == 8)
+	c, err := log.Append(src)
+	assert(t, err == nil && c == 8)
+	_, ok = log.reserve(appendLogSize(28))
+	assert(t, !ok)
+	d, err := log.Append(src)
+	assert(t, err == nil && d == 20)
+	assert(t, log.Reserved() == 32)
+
+	count := 0
+	next := log.Scan(0, func(off int64, record []byte) bool {
+		count++
+		return true
+	})
+	assert(t, count == 3 && next == 32)
+
+	_, err = log.Append(nil)
+	assert(t, err == ErrOverrun)
+	assert(t, log.Reserved() == 32)
+}
+
+func TestAppendLogUncommitted(t *testing.T) {
+	log := NewAppendLog(NewBufferIOMake(64))
+	log.Append(src)
+
+	// Simulate a writer which reserved space but did not commit yet
+	log.tail += appendLogSize(4)
+	log.Append(src)
+
+	count := 0
+	next := log.Scan(0, func(off int64, record []byte) bool {
+		count++
+		return true
+	})
+	assert(t, count == 1)
+	assert(t, next == 12)
+}
+
+func TestAppendLogConcurrent(t *testing.T) {
+	const writers = 8
+	const perWriter = 200
+
+	log := NewAppendLog(NewBufferIOMake(writers * perWriter * 12))
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rec := make([]byte, 8)
+			for i := 0; i < perWriter; i++ {
+				binary.BigEndian.PutUint32(rec, uint32(w))
+				binary.BigEndian.PutUint32(rec[4:], uint32(i))
+				if _, err := log.Append(rec); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	// Read while writers are appending
+	seen := 0
+	var next int64
+	for seen < writers*perWriter {
+		next = log.Scan(next, func(off int64, record []byte) bool {
+			seen++
+			return len(record) == 8
+		})
+	}
+	wg.Wait()
+
+	last := make([]int, writers)
+	for i := range last {
+		last[i] = -1
+	}
+	log.Scan(0, func(off int64, record []byte) bool {
+		w := binary.BigEndian.Uint32(record)
+		i := int(binary.BigEndian.Uint32(record[4:]))
+		assert(t, i == last[w]+1)
+		last[w] = i
+		return true
+	})
+	for _, l := range last {
+		assert(t, l == perWriter-1)
+	}
+
+	_, err := log.Append(nil)
+	assert(t, err == ErrOverrun)
+}
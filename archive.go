@@ -0,0 +1,80 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"time"
+)
+
+// WriteToTar writes the whole buffer to tw as a regular file member
+// called name, with its size taken from the buffer.
+func (b *BufferIO) WriteToTar(tw *tar.Writer, name string, mode int64, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     mode,
+		Size:     b.Size(),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := b.Export(tw)
+	return err
+}
+
+// zipChunk is the memory FromZipEntry starts reading an entry into
+const zipChunk = 64 << 10
+
+// FromZipEntry returns a buffer holding the uncompressed contents of f,
+// read straight from the archive. The checksum of the entry is verified.
+// Memory grows with the data actually read, up to the size declared in
+// the archive, so a header claiming more than the entry holds cannot
+// make it allocate that much, and an entry holding more than it declares
+// is rejected.
+func FromZipEntry(f *zip.File) (*BufferIO, error) {
+	if f.UncompressedSize64 > uint64(^uint(0)>>1) {
+		return nil, ErrOverrun
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	size := int(f.UncompressedSize64)
+	next := zipChunk
+	var buf []byte
+	for len(buf) < size {
+		if len(buf) == cap(buf) {
+			if next > size {
+				next = size
+			}
+			grown := make([]byte, len(buf), next)
+			copy(grown, buf)
+			buf = grown
+			next *= 2
+		}
+		n, err := io.ReadFull(rc, buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Reading to the end lets the zip reader check the CRC.
+	var extra [1]byte
+	if n, err := rc.Read(extra[:]); n != 0 {
+		return nil, ErrCorrupt
+	} else if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return NewBufferIO(buf), nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestWriteToTar(t *testing.T) {
+	var out bytes.Buffer
+	tw := tar.NewWriter(&out)
+	now := time.Unix(1400000000, 0)
+	assert(t, NewBufferIO(src).WriteToTar(tw, "data.bin", 0644, now) == nil)
+	assert(t, tw.Close() == nil)
+
+	tr := tar.NewReader(&out)
+	hdr, err := tr.Next()
+	assert(t, err == nil)
+	assert(t, hdr.Name == "data.bin" && hdr.Size == int64(len(src)))
+	assert(t, hdr.Mode == 0644 && hdr.ModTime.Equal(now))
+	data, err := ioutil.ReadAll(tr)
+	assert(t, err == nil)
+	assert(t, bytes.Equal(data, src))
+}
+
+func TestFromZipEntry(t *testing.T) {
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	w, err := zw.Create("data.bin")
+	assert(t, err == nil)
+	_, err = w.Write(big)
+	assert(t, err == nil)
+	assert(t, zw.Close() == nil)
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	assert(t, err == nil)
+	bio, err := FromZipEntry(zr.File[0])
+	assert(t, err == nil)
+	assert(t, bytes.Equal(bio.Bytes(), big))
+}
+
+func TestFromZipEntrySize(t *testing.T) {
+	entry := func(declared uint64, data []byte) *zip.File {
+		var out bytes.Buffer
+		zw := zip.NewWriter(&out)
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               "data.bin",
+			Method:             zip.Store,
+			CRC32:              crc32.ChecksumIEEE(data),
+			CompressedSize64:   uint64(len(data)),
+			UncompressedSize64: declared,
+		})
+		assert(t, err == nil)
+		w.Write(data)
+		assert(t, zw.Close() == nil)
+		zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		assert(t, err == nil)
+		return zr.File[0]
+	}
+
+	// Larger than one chunk, so the buffer grows while reading
+	data := bytes.Repeat(big, (3*zipChunk)/len(big)+1)
+	bio, err := FromZipEntry(entry(uint64(len(data)), data))
+	assert(t, err == nil && bytes.Equal(bio.Bytes(), data))
+
+	// A header claiming far more than the entry holds fails once the
+	// data runs out, without allocating the claimed size
+	_, err = FromZipEntry(entry(1<<40, src))
+	assert(t, err != nil)
+
+	// Extra bytes are rejected rather than read
+	_, err = FromZipEntry(entry(4, src))
+	assert(t, err == zip.ErrFormat)
+
+	bio, err = FromZipEntry(entry(0, nil))
+	assert(t, err == nil && bio.Size() == 0)
+}
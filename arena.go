@@ -0,0 +1,62 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrArenaFull = errors.New("arena exhausted")
+
+const arenaAlign = 8
+
+// Arena hands out buffers from one preallocated slab. Memory is only
+// reclaimed all at once with Reset.
+type Arena struct {
+	mu  sync.Mutex
+	buf []byte
+	off int
+}
+
+func NewArena(size int) *Arena {
+	return &Arena{buf: make([]byte, size)}
+}
+
+// Alloc returns n bytes from the arena, aligned to 8 bytes.
+func (a *Arena) Alloc(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("negative size")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	off := (a.off + arenaAlign - 1) &^ (arenaAlign - 1)
+	if off > len(a.buf) || n > len(a.buf)-off {
+		return nil, ErrArenaFull
+	}
+	a.off = off + n
+	return a.buf[off : off+n : off+n], nil
+}
+
+// Reset makes the whole arena available again. Buffers previously
+// allocated from it must no longer be used.
+func (a *Arena) Reset() {
+	a.mu.Lock()
+	a.off = 0
+	a.mu.Unlock()
+}
+
+// Used returns the number of bytes handed out, including alignment.
+func (a *Arena) Used() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.off
+}
+
+func (a *Arena) Size() int {
+	return len(a.buf)
+}
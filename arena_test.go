@@ -0,0 +1,38 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+)
+
+func TestArenaAlloc(t *testing.T) {
+	arena := NewArena(32)
+	assert(t, arena.Size() == 32)
+
+	a, err := arena.Alloc(3)
+	assert(t, err == nil)
+	assert(t, len(a) == 3)
+	assert(t, cap(a) == 3)
+	assert(t, arena.Used() == 3)
+
+	// Next allocation is aligned
+	b, err := arena.Alloc(8)
+	assert(t, err == nil)
+	assert(t, &b[0] == &arena.buf[8])
+	assert(t, arena.Used() == 16)
+
+	_, err = arena.Alloc(17)
+	assert(t, err == ErrArenaFull)
+
+	_, err = arena.Alloc(-1)
+	assert(t, err != nil)
+
+	arena.Reset()
+	assert(t, arena.Used() == 0)
+	c, err := arena.Alloc(32)
+	assert(t, err == nil)
+	assert(t, &c[0] == &a[0])
+}
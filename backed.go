@@ -0,0 +1,134 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+)
+
+var ErrReadOnly = errors.New("buffer is read only")
+
+// NewBufferIOFrom returns a buffer of size bytes backed by r instead of
+// memory, such as an *os.File or a block device. Read, ReadAt, Seek,
+// ReadData and Export go through r. Writes go through r as well when it
+// is also an io.WriterAt, and fail with ErrReadOnly otherwise; they
+// never extend the buffer past size.
+//
+// Nothing is loaded into memory, so methods which hand out or work on
+// the memory directly, such as the views, Peek and the in-buffer
+// structures, return ErrUnsupported, and Bytes returns nil. Closing the
+// buffer does not close r.
+func NewBufferIOFrom(r io.ReaderAt, size int64) *BufferIO {
+	return &BufferIO{from: r, fromSize: size}
+}
+
+// length is the size of the buffer as seen through Read, Write and Seek,
+// which for a buffer backed by a reader is not the size of buf.
+func (b *BufferIO) length() int64 {
+	if b.from != nil {
+		return b.fromSize
+	}
+	return b.size()
+}
+
+func (b *BufferIO) readFrom(p []byte, off int64) (int, error) {
+	if off >= b.fromSize {
+		return 0, io.EOF
+	}
+	short := int64(len(p)) > b.fromSize-off
+	if short {
+		p = p[:b.fromSize-off]
+	}
+	n, err := b.from.ReadAt(p, off)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	if err == nil && short {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (b *BufferIO) writeFrom(p []byte, off int64) (int, error) {
+	w, ok := b.from.(io.WriterAt)
+	if !ok {
+		return 0, ErrReadOnly
+	}
+	if off >= b.fromSize {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.ErrShortWrite
+	}
+	short := int64(len(p)) > b.fromSize-off
+	if short {
+		p = p[:b.fromSize-off]
+	}
+	n, err := w.WriteAt(p, off)
+	if err == nil && short {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// section returns the part of the backing reader from off to the end of
+// the buffer.
+func (b *BufferIO) section(off int64) *io.SectionReader {
+	return io.NewSectionReader(b.from, off, b.fromSize-off)
+}
+
+func (b *BufferIO) readDataFrom(order binary.ByteOrder, off int64, data interface{}, plan *structPlan) (int64, error) {
+	if plan == nil {
+		if err := binary.Read(b.section(off), order, data); err != nil {
+			return 0, err
+		}
+		return int64(binary.Size(data)), nil
+	}
+	// The encoded size of a planned struct may depend on its length
+	// fields, so when it is not fixed a prefix is read, doubling until
+	// decode finds all it needs.
+	want := int64(plan.size)
+	if want < 0 {
+		want = taggedPrefix
+	}
+	for {
+		if rest := b.fromSize - off; want > rest {
+			want = rest
+		}
+		if want < 0 {
+			want = 0
+		}
+		enc := make([]byte, want)
+		n, err := b.from.ReadAt(enc, off)
+		if err != nil && (err != io.EOF || int64(n) < want) {
+			return 0, err
+		}
+		used, err := plan.decode(enc, order, reflect.ValueOf(data))
+		if err == io.ErrUnexpectedEOF && want > 0 && want < b.fromSize-off {
+			want *= 2
+			continue
+		}
+		return int64(used), err
+	}
+}
+
+// taggedPrefix is how much of a buffer backed by a reader is read first
+// to decode a tagged struct of variable size
+const taggedPrefix = 512
+
+// memory returns why the buffer has no memory of its own to work on, if
+// it has none
+func (b *BufferIO) memory() error {
+	if err := b.usable(); err != nil {
+		return err
+	}
+	if b.from != nil {
+		return ErrUnsupported
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestBufferIOFromFile(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+	_, err := f.Write(src)
+	assert(t, err == nil)
+
+	bio := NewBufferIOFrom(f, int64(len(src)))
+	assert(t, bio.Size() == int64(len(src)))
+	assert(t, len(bio.Bytes()) == 0)
+
+	p := make([]byte, 4)
+	n, err := bio.ReadAt(p, 2)
+	assert(t, err == nil && n == 4)
+	assert(t, bytes.Equal(p, src[2:6]))
+
+	pos, err := bio.Seek(-3, os.SEEK_END)
+	assert(t, err == nil && pos == int64(len(src))-3)
+	n, err = bio.Read(p)
+	assert(t, err == nil && n == 3)
+	assert(t, bytes.Equal(p[:n], src[len(src)-3:]))
+	_, err = bio.Read(p)
+	assert(t, err == io.EOF)
+	_, err = bio.Seek(1, os.SEEK_END)
+	assert(t, err == nil)
+	_, err = bio.Read(p)
+	assert(t, err == io.EOF)
+}
+
+func TestBufferIOFromWrite(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+	assert(t, f.Truncate(16) == nil)
+
+	bio := NewBufferIOFrom(f, 16)
+	assert(t, bio.WriteDataLE(uint32(0xdeadbeef)) == nil)
+	n, err := bio.WriteAt([]byte("abcdef"), 12)
+	assert(t, err == io.ErrShortWrite && n == 4)
+	_, err = bio.WriteAt([]byte("a"), 16)
+	assert(t, err == io.ErrShortWrite)
+
+	var out [16]byte
+	_, err = f.ReadAt(out[:], 0)
+	assert(t, err == nil)
+	assert(t, binary.LittleEndian.Uint32(out[:]) == 0xdeadbeef)
+	assert(t, string(out[12:]) == "abcd")
+
+	bio.Reset()
+	var v uint32
+	assert(t, bio.ReadDataLE(&v) == nil)
+	assert(t, v == 0xdeadbeef)
+}
+
+func TestBufferIOFromReadOnly(t *testing.T) {
+	bio := NewBufferIOFrom(bytes.NewReader(src), int64(len(src)))
+	_, err := bio.WriteAt([]byte("x"), 0)
+	assert(t, err == ErrReadOnly)
+
+	var out bytes.Buffer
+	n, err := bio.Export(&out)
+	assert(t, err == nil && n == int64(len(src)))
+	assert(t, bytes.Equal(out.Bytes(), src))
+
+	assert(t, bio.Close() == nil)
+	_, err = bio.ReadAt(make([]byte, 1), 0)
+	assert(t, err == ErrClosed)
+}
+
+func TestBufferIOFromTagged(t *testing.T) {
+	type rec struct {
+		N    uint8 `bufferio:"lenof=Data"`
+		Data []byte
+	}
+	mem := NewBufferIOMake(16)
+	assert(t, mem.WriteDataLE(&rec{Data: []byte("hey")}) == nil)
+
+	bio := NewBufferIOFrom(bytes.NewReader(mem.Bytes()), 16)
+	var r rec
+	assert(t, bio.ReadDataLE(&r) == nil)
+	assert(t, string(r.Data) == "hey")
+}
+
+// countingReaderAt counts the bytes and calls read through it
+type countingReaderAt struct {
+	r     io.ReaderAt
+	n     int64
+	calls int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += int64(n)
+	c.calls++
+	return n, err
+}
+
+func TestBufferIOFromTaggedBounded(t *testing.T) {
+	type rec struct {
+		N    uint16 `bufferio:"lenof=Data"`
+		Data []byte
+	}
+	mem := NewBufferIOMake(1 << 20)
+	assert(t, mem.WriteDataLE(&rec{Data: bytes.Repeat([]byte("x"), 2000)}) == nil)
+	assert(t, mem.WriteDataLE(&taggedHashRecord{B: 7}) == nil)
+
+	// Only a prefix around the record is read, not the rest of the file
+	c := &countingReaderAt{r: bytes.NewReader(mem.Bytes())}
+	bio := NewBufferIOFrom(c, 1<<20)
+	var r rec
+	assert(t, bio.ReadDataLE(&r) == nil && len(r.Data) == 2000)
+	assert(t, c.n < 8192)
+
+	c.n = 0
+	var h taggedHashRecord
+	assert(t, bio.ReadDataLE(&h) == nil && h.B == 7)
+	assert(t, c.n == 10)
+
+	// Records cut short by the end are still reported
+	short := NewBufferIOFrom(bytes.NewReader(mem.Bytes()[:100]), 100)
+	assert(t, short.ReadDataLE(&r) == io.ErrUnexpectedEOF)
+}
+
+func TestBufferIOFromMemoryMethods(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789abcdef"), 64)
+	bio := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	_, err := bio.FindData(binary.LittleEndian, uint8(1), 0)
+	assert(t, err == ErrUnsupported)
+	assert(t, bio.RequireAlignment(0, 1) == ErrUnsupported)
+	assert(t, bio.Advise(0, 1, AdviseNormal) == ErrUnsupported)
+	_, err = bio.Import(bytes.NewReader(big))
+	assert(t, err == ErrUnsupported)
+	assert(t, bio.AppendRecord([]byte("rec")) == ErrUnsupported)
+	assert(t, bio.ApplyPatchesAtomic(nil) == ErrUnsupported)
+	_, err = bio.ReadDocument()
+	assert(t, err == ErrUnsupported)
+	assert(t, bio.Iterate(8, func(int64, []byte) bool { return true }) == ErrUnsupported)
+	assert(t, bio.Resize(10) == ErrUnsupported)
+	assert(t, bio.Resize(int64(len(big))*2) == ErrUnsupported)
+	assert(t, bio.Truncate(10) == ErrUnsupported)
+	assert(t, bio.Size() == int64(len(big)) && bio.buf == nil)
+
+	// Methods which copy read through the reader
+	assert(t, bytes.Equal(bio.BytesCopy(), big))
+	assert(t, bio.CompareAt(4, big[4:12]) == 0)
+	assert(t, bio.EqualAt(4, big[4:12]))
+	assert(t, !bio.EqualAt(int64(len(big))-2, big[:4]))
+
+	var snap bytes.Buffer
+	assert(t, bio.ExportCompressed(&snap, Gzip{}) == nil)
+	mem := NewBufferIOMake(len(big))
+	n, err := mem.ImportCompressed(&snap, nil)
+	assert(t, err == nil && n == int64(len(big)))
+	assert(t, bytes.Equal(mem.Bytes(), big))
+
+	snap.Reset()
+	assert(t, bio.ExportSeekable(&snap, Gzip{}, 7) == nil)
+	mem = NewBufferIOMake(len(big))
+	n, err = mem.ImportSeekable(bytes.NewReader(snap.Bytes()), int64(snap.Len()), nil)
+	assert(t, err == nil && n == int64(len(big)))
+	assert(t, bytes.Equal(mem.Bytes(), big))
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+	"sort"
+)
+
+// BatchOp is one positional read or write of a batch. N and Err receive
+// the results ReadAt or WriteAt would have returned.
+type BatchOp struct {
+	Off  int64
+	Data []byte
+	N    int
+	Err  error
+}
+
+// ReadAtBatch performs every read in ops under a single acquisition of
+// the lock, so the reads see one consistent state of the buffer. It
+// returns the first error, and the results of each op are stored in it.
+// For a buffer backed by a ReaderAt, ops whose ranges touch or overlap
+// are merged into one ReadAt of the backing reader.
+func (b *BufferIO) ReadAtBatch(ops []BatchOp) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.from != nil && b.usable() == nil {
+		return b.readBatchFrom(ops)
+	}
+	return b.batch(ops, b.readAt)
+}
+
+// WriteAtBatch performs every write in ops under a single acquisition
+// of the lock, so readers observe all of them or none. Writes continue
+// after one fails. They are not merged: a buffer backed by a ReaderAt
+// makes one WriteAt per op.
+func (b *BufferIO) WriteAtBatch(ops []BatchOp) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.batch(ops, b.writeAt)
+}
+
+func (b *BufferIO) batch(ops []BatchOp, fn func(p []byte, off int64) (int, error)) error {
+	var err error
+	for i := range ops {
+		op := &ops[i]
+		if op.Off < 0 {
+			op.N, op.Err = 0, ErrOverrun
+		} else {
+			op.N, op.Err = fn(op.Data, op.Off)
+		}
+		if err == nil {
+			err = op.Err
+		}
+	}
+	return err
+}
+
+// readBatchFrom performs the reads of ReadAtBatch on a buffer backed by
+// a ReaderAt, one ReadAt per run of touching or overlapping ranges.
+func (b *BufferIO) readBatchFrom(ops []BatchOp) error {
+	var runs []int
+	for i := range ops {
+		op := &ops[i]
+		switch {
+		case op.Off < 0:
+			op.N, op.Err = 0, ErrOverrun
+		case len(op.Data) == 0 || op.Off >= b.fromSize:
+			op.N, op.Err = b.readAt(op.Data, op.Off)
+		default:
+			runs = append(runs, i)
+		}
+	}
+	sort.Sort(&batchOrder{ops, runs})
+
+	var p []byte
+	for len(runs) > 0 {
+		start, end := ops[runs[0]].Off, ops[runs[0]].end(b.fromSize)
+		k := 1
+		for ; k < len(runs) && ops[runs[k]].Off <= end; k++ {
+			if e := ops[runs[k]].end(b.fromSize); e > end {
+				end = e
+			}
+		}
+		if int64(cap(p)) < end-start {
+			p = make([]byte, end-start)
+		}
+		n, err := b.readFrom(p[:end-start], start)
+		if err == nil {
+			err = io.EOF
+		}
+
+		for _, i := range runs[:k] {
+			op := &ops[i]
+			rel := op.Off - start
+			avail := int64(n) - rel
+			if avail < 0 {
+				avail = 0
+			}
+			op.N = copy(op.Data, p[rel:rel+avail])
+			op.Err = nil
+			if op.N < len(op.Data) {
+				op.Err = err
+			}
+		}
+		runs = runs[k:]
+	}
+
+	for i := range ops {
+		if ops[i].Err != nil {
+			return ops[i].Err
+		}
+	}
+	return nil
+}
+
+// batchOrder sorts indexes into ops by the offset of their op
+type batchOrder struct {
+	ops []BatchOp
+	idx []int
+}
+
+func (o *batchOrder) Len() int {
+	return len(o.idx)
+}
+
+func (o *batchOrder) Less(i, j int) bool {
+	return o.ops[o.idx[i]].Off < o.ops[o.idx[j]].Off
+}
+
+func (o *batchOrder) Swap(i, j int) {
+	o.idx[i], o.idx[j] = o.idx[j], o.idx[i]
+}
+
+// end returns where the range of op ends, at most at size
+func (op *BatchOp) end(size int64) int64 {
+	if int64(len(op.Data)) > size-op.Off {
+		return size
+	}
+	return op.Off + int64(len(op.Data))
+}
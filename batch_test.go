@@ -0,0 +1,83 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriteAtBatch(t *testing.T) {
+	b := NewBufferIOMake(16)
+
+	ops := []BatchOp{
+		{Off: 0, Data: []byte("ab")},
+		{Off: 14, Data: []byte("xyz")},
+		{Off: 8, Data: []byte("cd")},
+	}
+	assert(t, b.WriteAtBatch(ops) == io.ErrShortWrite)
+	assert(t, ops[0].N == 2 && ops[1].N == 2 && ops[2].N == 2)
+	assert(t, ops[1].Err == io.ErrShortWrite)
+	assert(t, bytes.Equal(b.Bytes(), []byte("ab\x00\x00\x00\x00\x00\x00cd\x00\x00\x00\x00xy")))
+
+	// Failed writes are reported without stopping the batch
+	ops = []BatchOp{
+		{Off: 16, Data: []byte("a")},
+		{Off: 2, Data: []byte("e")},
+		{Off: -1, Data: []byte("f")},
+	}
+	assert(t, b.WriteAtBatch(ops) == io.ErrShortWrite)
+	assert(t, ops[0].Err == io.ErrShortWrite && ops[0].N == 0)
+	assert(t, ops[1].Err == nil && ops[1].N == 1)
+	assert(t, ops[2].Err == ErrOverrun)
+	assert(t, b.Bytes()[2] == 'e')
+}
+
+func TestReadAtBatch(t *testing.T) {
+	b := NewBufferIO(append([]byte(nil), big...))
+
+	ops := make([]BatchOp, 4)
+	for i := range ops {
+		ops[i] = BatchOp{Off: int64(i * 8), Data: make([]byte, 4)}
+	}
+	ops[3].Off = b.Size()
+	assert(t, b.ReadAtBatch(ops) == ErrEOF)
+	for i := 0; i < 3; i++ {
+		assert(t, ops[i].Err == nil)
+		assert(t, bytes.Equal(ops[i].Data, big[i*8:i*8+4]))
+	}
+	assert(t, ops[3].N == 0)
+
+	b.Close()
+	assert(t, b.ReadAtBatch(ops[:1]) == ErrClosed)
+}
+
+func TestReadAtBatchBacked(t *testing.T) {
+	r := &countingReaderAt{r: bytes.NewReader(big)}
+	b := NewBufferIOFrom(r, int64(len(big)))
+
+	// Touching and overlapping ranges, out of order, are read at once
+	ops := []BatchOp{
+		{Off: 8, Data: make([]byte, 4)},
+		{Off: 0, Data: make([]byte, 8)},
+		{Off: 10, Data: make([]byte, 4)},
+		{Off: 40, Data: make([]byte, 2)},
+		{Off: int64(len(big)) - 2, Data: make([]byte, 4)},
+		{Off: -1, Data: make([]byte, 1)},
+	}
+	assert(t, b.ReadAtBatch(ops) == ErrEOF)
+	assert(t, r.calls == 3)
+	for _, op := range ops[:4] {
+		assert(t, op.Err == nil && op.N == len(op.Data))
+		assert(t, bytes.Equal(op.Data, big[op.Off:op.Off+int64(op.N)]))
+	}
+	assert(t, ops[4].N == 2 && ops[4].Err == ErrEOF)
+	assert(t, bytes.Equal(ops[4].Data[:2], big[len(big)-2:]))
+	assert(t, ops[5].Err == ErrOverrun)
+
+	b.Close()
+	assert(t, b.ReadAtBatch(ops[:1]) == ErrClosed)
+}
@@ -0,0 +1,122 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package bench runs synthetic workloads against buffer backends, so
+// that heap, mapped and pooled buffers, files, or any other positional
+// storage can be compared on the machine they will run on.
+//
+// A run looks like
+//
+//	buf, _ := bufferio.NewBufferIOOptions(64<<20, bufferio.Options{Backing: bufferio.BackingMmap})
+//	r, err := bench.Run(buf, bench.RandomIO(64<<20, 4096, 70, 1), bench.Config{Ops: 100000})
+//	fmt.Println(r)
+package bench
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"time"
+)
+
+// Backend is the positional I/O a workload runs against. It is
+// satisfied by bufferio.BufferIO, bufferio.BufferReaderWriter and
+// *os.File.
+type Backend interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// DataBackend is a Backend which encodes values itself, like
+// bufferio.BufferIO. Struct workloads use it when available and encode
+// through encoding/binary otherwise.
+type DataBackend interface {
+	Backend
+	ReadDataAt(order binary.ByteOrder, off int64, data interface{}) error
+	WriteDataAt(order binary.ByteOrder, off int64, data interface{}) error
+}
+
+// Workload generates operations. Workloads are not safe for concurrent
+// use.
+type Workload interface {
+	Name() string
+
+	// Step performs operation i against b and returns the number of
+	// bytes it transferred.
+	Step(b Backend, i int) (int, error)
+}
+
+// Config controls a run.
+type Config struct {
+	// Ops is the number of operations to run, or the minimum when
+	// Duration is set.
+	Ops int
+
+	// Duration runs operations until at least this much time passed.
+	Duration time.Duration
+
+	// CPUProfile receives a CPU profile of the run when set.
+	CPUProfile io.Writer
+}
+
+// Result reports a run.
+type Result struct {
+	Workload string
+	Ops      int
+	Bytes    int64
+	Elapsed  time.Duration
+}
+
+// NsPerOp returns the mean time of an operation in nanoseconds.
+func (r Result) NsPerOp() float64 {
+	if r.Ops == 0 {
+		return 0
+	}
+	return float64(r.Elapsed.Nanoseconds()) / float64(r.Ops)
+}
+
+// MBPerSec returns the throughput in megabytes per second.
+func (r Result) MBPerSec() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / 1e6 / r.Elapsed.Seconds()
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%s\t%d ops\t%.0f ns/op\t%.2f MB/s", r.Workload, r.Ops, r.NsPerOp(), r.MBPerSec())
+}
+
+// Run runs w against b as configured by c. Operations stop at the first
+// error, which is returned along with the result so far.
+func Run(b Backend, w Workload, c Config) (Result, error) {
+	if c.Ops <= 0 && c.Duration <= 0 {
+		return Result{}, errors.New("bench: no operation count or duration")
+	}
+	if c.CPUProfile != nil {
+		if err := pprof.StartCPUProfile(c.CPUProfile); err != nil {
+			return Result{}, err
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	r := Result{Workload: w.Name()}
+	start := time.Now()
+	for i := 0; ; i++ {
+		if i >= c.Ops && (c.Duration <= 0 || time.Since(start) >= c.Duration) {
+			break
+		}
+		n, err := w.Step(b, i)
+		r.Bytes += int64(n)
+		if err != nil {
+			r.Elapsed = time.Since(start)
+			return r, fmt.Errorf("bench: %s operation %d: %v", w.Name(), i, err)
+		}
+		r.Ops++
+	}
+	r.Elapsed = time.Since(start)
+	return r, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func assert(t *testing.T, b bool) {
+	if !b {
+		_, file, line, _ := runtime.Caller(1)
+		t.Errorf("ASSERT: %s:%d", file, line)
+	}
+}
+
+// memBackend is a minimal Backend over a slice
+type memBackend []byte
+
+func (m memBackend) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m memBackend) WriteAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m)) {
+		return 0, io.ErrShortWrite
+	}
+	n := copy(m[off:], p)
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+func TestRun(t *testing.T) {
+	m := make(memBackend, 1<<16)
+	r, err := Run(m, SequentialWrite(1<<16, 4096), Config{Ops: 32})
+	assert(t, err == nil)
+	assert(t, r.Ops == 32 && r.Bytes == 32*4096 && r.Workload == "seqwrite/4096")
+	assert(t, r.Elapsed > 0 && r.NsPerOp() > 0 && r.MBPerSec() > 0)
+	assert(t, strings.HasPrefix(r.String(), "seqwrite/4096\t32 ops"))
+
+	r, err = Run(m, SequentialRead(1<<16, 512), Config{Duration: 10 * time.Millisecond})
+	assert(t, err == nil && r.Elapsed >= 10*time.Millisecond && r.Ops > 0)
+
+	_, err = Run(m, SequentialRead(1<<16, 512), Config{})
+	assert(t, err != nil)
+}
+
+func TestRunError(t *testing.T) {
+	// Blocks past the end of the backend fail
+	m := make(memBackend, 100)
+	r, err := Run(m, SequentialWrite(1000, 64), Config{Ops: 10})
+	assert(t, err != nil && r.Ops == 1 && r.Bytes == 64+36)
+}
+
+func TestRunProfile(t *testing.T) {
+	var prof bytes.Buffer
+	m := make(memBackend, 1<<16)
+	_, err := Run(m, Random4K(1<<16, 50, 1), Config{Ops: 100, CPUProfile: &prof})
+	assert(t, err == nil && prof.Len() > 0)
+}
@@ -0,0 +1,167 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+)
+
+// blocks returns the number of whole blocks of a workload, at least one
+func blocks(size int64, block int) int64 {
+	if n := size / int64(block); n > 0 {
+		return n
+	}
+	return 1
+}
+
+type sequential struct {
+	write bool
+	block int
+	n     int64
+	p     []byte
+}
+
+// SequentialWrite writes blocks of block bytes one after the other over
+// the first size bytes of the backend, wrapping around at the end.
+func SequentialWrite(size int64, block int) Workload {
+	p := make([]byte, block)
+	for i := range p {
+		p[i] = byte(i)
+	}
+	return &sequential{write: true, block: block, n: blocks(size, block), p: p}
+}
+
+// SequentialRead reads like SequentialWrite writes.
+func SequentialRead(size int64, block int) Workload {
+	return &sequential{block: block, n: blocks(size, block), p: make([]byte, block)}
+}
+
+func (s *sequential) Name() string {
+	if s.write {
+		return fmt.Sprintf("seqwrite/%d", s.block)
+	}
+	return fmt.Sprintf("seqread/%d", s.block)
+}
+
+func (s *sequential) Step(b Backend, i int) (int, error) {
+	off := int64(i) % s.n * int64(s.block)
+	if s.write {
+		return b.WriteAt(s.p, off)
+	}
+	return b.ReadAt(s.p, off)
+}
+
+type random struct {
+	block   int
+	n       int64
+	percent int
+	rng     *rand.Rand
+	p       []byte
+}
+
+// RandomIO reads and writes blocks of block bytes at random block
+// aligned offsets within size bytes, readPercent of them reads. The
+// sequence is determined by seed, so runs against different backends
+// perform the same operations.
+func RandomIO(size int64, block, readPercent int, seed int64) Workload {
+	return &random{
+		block:   block,
+		n:       blocks(size, block),
+		percent: readPercent,
+		rng:     rand.New(rand.NewSource(seed)),
+		p:       make([]byte, block),
+	}
+}
+
+// Random4K is RandomIO with 4KiB blocks, the classic storage workload.
+func Random4K(size int64, readPercent int, seed int64) Workload {
+	return RandomIO(size, 4096, readPercent, seed)
+}
+
+func (r *random) Name() string {
+	return fmt.Sprintf("random/%d/%d%%read", r.block, r.percent)
+}
+
+func (r *random) Step(b Backend, i int) (int, error) {
+	off := r.rng.Int63n(r.n) * int64(r.block)
+	if r.rng.Intn(100) < r.percent {
+		return b.ReadAt(r.p, off)
+	}
+	return b.WriteAt(r.p, off)
+}
+
+// Record is the value encoded by StructMix, a typical fixed size header.
+type Record struct {
+	Magic   [4]byte
+	Version uint16
+	Flags   uint16
+	ID      uint64
+	Size    uint32
+	Offset  int64
+	CRC     uint32
+}
+
+// recordSize is the encoded size of a Record
+var recordSize = binary.Size(Record{})
+
+type structMix struct {
+	n       int64
+	percent int
+	order   binary.ByteOrder
+	rng     *rand.Rand
+	rec     Record
+	buf     bytes.Buffer
+	p       []byte
+}
+
+// StructMix encodes and decodes Records at random record slots within
+// size bytes, encodePercent of them encodes, in order. Backends which
+// are DataBackends encode themselves.
+func StructMix(size int64, encodePercent int, order binary.ByteOrder, seed int64) Workload {
+	return &structMix{
+		n:       blocks(size, recordSize),
+		percent: encodePercent,
+		order:   order,
+		rng:     rand.New(rand.NewSource(seed)),
+		rec:     Record{Magic: [4]byte{'B', 'N', 'C', 'H'}, Version: 1},
+		p:       make([]byte, recordSize),
+	}
+}
+
+func (s *structMix) Name() string {
+	return fmt.Sprintf("struct/%d%%encode", s.percent)
+}
+
+func (s *structMix) Step(b Backend, i int) (int, error) {
+	off := s.rng.Int63n(s.n) * int64(recordSize)
+	encode := s.rng.Intn(100) < s.percent
+	d, direct := b.(DataBackend)
+
+	if encode {
+		s.rec.ID = uint64(i)
+		s.rec.Offset = off
+		if direct {
+			return recordSize, d.WriteDataAt(s.order, off, &s.rec)
+		}
+		s.buf.Reset()
+		if err := binary.Write(&s.buf, s.order, &s.rec); err != nil {
+			return 0, err
+		}
+		return b.WriteAt(s.buf.Bytes(), off)
+	}
+
+	var rec Record
+	if direct {
+		return recordSize, d.ReadDataAt(s.order, off, &rec)
+	}
+	n, err := b.ReadAt(s.p, off)
+	if err != nil {
+		return n, err
+	}
+	return n, binary.Read(bytes.NewReader(s.p), s.order, &rec)
+}
@@ -0,0 +1,89 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSequential(t *testing.T) {
+	m := make(memBackend, 100)
+	w := SequentialWrite(100, 32)
+	for i := 0; i < 4; i++ {
+		n, err := w.Step(m, i)
+		assert(t, n == 32 && err == nil)
+	}
+	// Three whole blocks fit, so the fourth write wraps around
+	assert(t, m[31] == 31 && m[95] == 31 && m[96] == 0)
+
+	r := SequentialRead(100, 32)
+	n, err := r.Step(m, 2)
+	assert(t, n == 32 && err == nil)
+}
+
+func TestRandomDeterministic(t *testing.T) {
+	a, b := make(memBackend, 1<<16), make(memBackend, 1<<16)
+	wa, wb := RandomIO(1<<16, 512, 0, 7), RandomIO(1<<16, 512, 0, 7)
+	for i := 0; i < 50; i++ {
+		copy(wa.(*random).p, []byte{byte(i)})
+		copy(wb.(*random).p, []byte{byte(i)})
+		wa.Step(a, i)
+		wb.Step(b, i)
+	}
+	assert(t, string(a) == string(b))
+	assert(t, Random4K(1<<20, 70, 1).Name() == "random/4096/70%read")
+}
+
+// dataBackend counts the values it is asked to encode
+type dataBackend struct {
+	memBackend
+	writes, reads int
+}
+
+func (d *dataBackend) WriteDataAt(order binary.ByteOrder, off int64, data interface{}) error {
+	d.writes++
+	return nil
+}
+
+func (d *dataBackend) ReadDataAt(order binary.ByteOrder, off int64, data interface{}) error {
+	d.reads++
+	return nil
+}
+
+func TestStructMix(t *testing.T) {
+	m := make(memBackend, 10*recordSize)
+	w := StructMix(int64(len(m)), 100, binary.LittleEndian, 1)
+	for i := 0; i < 20; i++ {
+		n, err := w.Step(m, i)
+		assert(t, n == recordSize && err == nil)
+	}
+	assert(t, string(m[:4]) == "BNCH" || string(m[recordSize:recordSize+4]) == "BNCH")
+
+	r := StructMix(int64(len(m)), 0, binary.LittleEndian, 1)
+	_, err := r.Step(m, 0)
+	assert(t, err == nil)
+
+	d := &dataBackend{memBackend: m}
+	w = StructMix(int64(len(m)), 50, binary.BigEndian, 1)
+	for i := 0; i < 20; i++ {
+		w.Step(d, i)
+	}
+	assert(t, d.writes+d.reads == 20 && d.writes > 0 && d.reads > 0)
+}
+
+func TestFileBackend(t *testing.T) {
+	f, err := ioutil.TempFile("", "bench")
+	assert(t, err == nil)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = Run(f, SequentialWrite(1<<16, 4096), Config{Ops: 16})
+	assert(t, err == nil)
+	r, err := Run(f, Random4K(1<<16, 100, 1), Config{Ops: 16})
+	assert(t, err == nil && r.Bytes == 16*4096)
+}
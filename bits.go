@@ -0,0 +1,105 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+// The bit operations treat n bytes at off as one big endian bit string:
+// the most significant bit of the first byte comes first. Shifting left
+// moves bits towards the start of the range.
+
+// ShiftLeft shifts the range left by bits, filling in zeros at the end.
+func (b *BufferIO) ShiftLeft(off, n int64, bits uint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.slice(off, n)
+	if err != nil {
+		return err
+	}
+	if uint64(bits) >= uint64(n)*8 {
+		zero(r)
+		return nil
+	}
+
+	bs, s := int64(bits/8), bits%8
+	for i := int64(0); i < n; i++ {
+		var v byte
+		j := i + bs
+		if j < n {
+			v = r[j] << s
+			if s > 0 && j+1 < n {
+				v |= r[j+1] >> (8 - s)
+			}
+		}
+		r[i] = v
+	}
+	return nil
+}
+
+// ShiftRight shifts the range right by bits, filling in zeros at the
+// start.
+func (b *BufferIO) ShiftRight(off, n int64, bits uint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.slice(off, n)
+	if err != nil {
+		return err
+	}
+	if uint64(bits) >= uint64(n)*8 {
+		zero(r)
+		return nil
+	}
+
+	bs, s := int64(bits/8), bits%8
+	for i := n - 1; i >= 0; i-- {
+		var v byte
+		j := i - bs
+		if j >= 0 {
+			v = r[j] >> s
+			if s > 0 && j > 0 {
+				v |= r[j-1] << (8 - s)
+			}
+		}
+		r[i] = v
+	}
+	return nil
+}
+
+// RotateRange rotates the range left by bits. Bits shifted out of the
+// start come back in at the end. Rotate right by n*8-bits.
+func (b *BufferIO) RotateRange(off, n int64, bits uint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.slice(off, n)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	bits = uint(uint64(bits) % (uint64(n) * 8))
+	bs, s := int64(bits/8), bits%8
+	if bs > 0 {
+		reverseBytes(r[:bs])
+		reverseBytes(r[bs:])
+		reverseBytes(r)
+	}
+	if s > 0 {
+		first := r[0]
+		for i := int64(0); i < n-1; i++ {
+			r[i] = r[i]<<s | r[i+1]>>(8-s)
+		}
+		r[n-1] = r[n-1]<<s | first>>(8-s)
+	}
+	return nil
+}
+
+func reverseBytes(p []byte) {
+	for i, j := 0, len(p)-1; i < j; i, j = i+1, j-1 {
+		p[i], p[j] = p[j], p[i]
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	mathbig "math/big"
+	"testing"
+)
+
+// Reference implementations using arbitrary precision integers
+
+func refShiftLeft(p []byte, bits uint) []byte {
+	v := new(mathbig.Int).SetBytes(p)
+	v.Lsh(v, bits)
+	return fixedBytes(v, len(p))
+}
+
+func refShiftRight(p []byte, bits uint) []byte {
+	v := new(mathbig.Int).SetBytes(p)
+	v.Rsh(v, bits)
+	return fixedBytes(v, len(p))
+}
+
+func refRotate(p []byte, bits uint) []byte {
+	total := uint(len(p) * 8)
+	bits %= total
+	l := refShiftLeft(p, bits)
+	r := refShiftRight(p, total-bits)
+	for i := range l {
+		l[i] |= r[i]
+	}
+	return l
+}
+
+func fixedBytes(v *mathbig.Int, n int) []byte {
+	mask := new(mathbig.Int).Lsh(mathbig.NewInt(1), uint(n*8))
+	mask.Sub(mask, mathbig.NewInt(1))
+	v.And(v, mask)
+	b := v.Bytes()
+	return append(make([]byte, n-len(b)), b...)
+}
+
+func TestBitOperations(t *testing.T) {
+	pattern := []byte{0x81, 0x42, 0xf0, 0x0f, 0xaa, 0x55, 0x3c}
+
+	ops := []struct {
+		name string
+		op   func(*BufferIO, int64, int64, uint) error
+		ref  func([]byte, uint) []byte
+	}{
+		{"ShiftLeft", (*BufferIO).ShiftLeft, refShiftLeft},
+		{"ShiftRight", (*BufferIO).ShiftRight, refShiftRight},
+		{"RotateRange", (*BufferIO).RotateRange, refRotate},
+	}
+
+	for _, o := range ops {
+		for bits := uint(0); bits <= 70; bits++ {
+			buf := append([]byte{0xee}, pattern...)
+			buf = append(buf, 0xee)
+			bio := NewBufferIO(buf)
+
+			err := o.op(bio, 1, int64(len(pattern)), bits)
+			assert(t, err == nil)
+
+			want := o.ref(pattern, bits)
+			if !bytes.Equal(buf[1:len(buf)-1], want) {
+				t.Errorf("%s by %d:\n\thave %x\n\twant %x",
+					o.name, bits, buf[1:len(buf)-1], want)
+			}
+
+			// Bytes outside the range are untouched
+			assert(t, buf[0] == 0xee)
+			assert(t, buf[len(buf)-1] == 0xee)
+		}
+
+		bio := NewBufferIOMake(4)
+		assert(t, o.op(bio, 2, 3, 1) == ErrOverrun)
+		assert(t, o.op(bio, -1, 2, 1) == ErrOverrun)
+		assert(t, o.op(bio, 4, 0, 1) == nil)
+	}
+}
@@ -6,19 +6,67 @@ package bufferio
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/binary"
 	"errors"
+	"io"
 	"os"
+	"reflect"
 )
 
 var (
 	ErrOverrun = errors.New("buffer overrun")
-	ErrEOF     = errors.New("end of file")
+	ErrClosed  = errors.New("buffer is closed")
+
+	// ErrEOF is io.EOF, kept for callers which compare against it.
+	ErrEOF = io.EOF
 )
 
+// BufferIO is safe for concurrent use. Every method holds an internal
+// lock for its duration. The lock is shared by methods which only read,
+// such as ReadAt and Size, so positional reads at independent offsets
+// proceed in parallel, while writes and anything moving the offset are
+// exclusive.
+//
+// It implements io.ReadWriteSeeker, io.ReaderAt and io.WriterAt with the
+// semantics of the standard library: reads at the end return io.EOF, and
+// writes which do not fit write what they can and return
+// io.ErrShortWrite. Other behaviour can be chosen with SetSemantics.
 type BufferIO struct {
-	buf []byte
-	off int64
+	mu      bufferLock
+	buf     []byte
+	off     int64
+	backing Backing
+	release func([]byte) error
+	alloc   Allocator // of BackingAllocator
+	file    *os.File  // of BackingFile
+	closed  bool
+	grow    bool
+
+	debug       bool
+	copyBytes   bool
+	strictData  bool
+	semantics   Semantics
+	order       binary.ByteOrder // of ReadDataDefault and WriteDataDefault
+	frame       FrameFormat      // of WriteFrame and ReadFrame
+	scratch     []byte           // reused by writeData for intermediate encodings
+	unsafeStack []byte           // where BytesUnsafe was last called, in debug mode
+
+	ownerCheck bool
+	owner      uint64 // goroutine last using the offset, with owner checks
+	ownerStack []byte
+
+	// from backs the buffer instead of buf when set
+	from     io.ReaderAt
+	fromSize int64
+
+	// gen changes whenever buf moves, invalidating views
+	gen uint32
+
+	// parent is the buffer whose memory a window or nested buffer
+	// shares, as of its gen parentGen
+	parent    *BufferIO
+	parentGen uint32
 }
 
 func NewBufferIO(b []byte) *BufferIO {
@@ -30,31 +78,159 @@ func NewBufferIOMake(nbytes int) *BufferIO {
 }
 
 func (b *BufferIO) WriteAt(p []byte, off int64) (n int, err error) {
-	if off >= b.Size() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.writeAt(p, off)
+}
+
+func (b *BufferIO) writeAt(p []byte, off int64) (n int, err error) {
+	if err := b.usable(); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, ErrOverrun
+	}
+	if b.from != nil {
+		return b.writeFrom(p, off)
+	}
+	end := off + int64(len(p))
+	if b.semantics == SemanticsStrict && (off > b.size() || !b.grow && end > b.size()) {
 		return 0, ErrOverrun
 	}
+	if b.grow && len(p) > 0 && end > b.size() {
+		if err := b.growTo(end); err != nil {
+			return 0, err
+		}
+	}
+	if off >= b.size() {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if b.semantics == SemanticsLegacy {
+			return 0, ErrOverrun
+		}
+		return 0, io.ErrShortWrite
+	}
 	bytes_copied := copy(b.buf[off:], p)
+	if bytes_copied < len(p) && b.semantics != SemanticsLegacy {
+		return bytes_copied, io.ErrShortWrite
+	}
 	return bytes_copied, nil
 }
 
 func (b *BufferIO) Write(p []byte) (n int, err error) {
-	n, err = b.WriteAt(p, b.off)
-	if err == nil {
-		b.off += int64(n)
-	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	n, err = b.writeAt(p, b.off)
+	b.off += int64(n)
 	return n, err
 }
 
-func (b *BufferIO) WriteData(order binary.ByteOrder, data interface{}) error {
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, order, data)
-	if err != nil {
+// writeWhole writes p at the offset and moves past it, writing nothing
+// and returning io.ErrShortWrite if p does not fit
+func (b *BufferIO) writeWhole(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	return b.writeNext(p)
+}
+
+// writeNext is writeWhole for callers already holding the lock
+func (b *BufferIO) writeNext(p []byte) error {
+	if err := b.usable(); err != nil {
 		return err
 	}
-	_, err = b.Write(buf.Bytes())
+	if !b.grow && b.from == nil && b.off >= 0 && int64(len(p)) > b.size()-b.off {
+		return io.ErrShortWrite
+	}
+	n, err := b.writeAt(p, b.off)
+	b.off += int64(n)
 	return err
 }
 
+func (b *BufferIO) WriteData(order binary.ByteOrder, data interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	n, err := b.writeData(order, b.off, data)
+	if err == nil {
+		b.off += n
+	}
+	return err
+}
+
+// writeData encodes data at off and returns the number of bytes written
+func (b *BufferIO) writeData(order binary.ByteOrder, off int64, data interface{}) (int64, error) {
+	if err := b.usable(); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, ErrOverrun
+	}
+	if enc, ok, err := marshalData(data); ok {
+		if err != nil {
+			return 0, err
+		}
+		return b.writeEncoded(enc, off)
+	}
+	plan, err := planOf(data)
+	if err != nil {
+		return 0, err
+	}
+	if plan != nil {
+		enc, err := plan.encode(b.scratch, order, reflect.Indirect(reflect.ValueOf(data)))
+		if err != nil {
+			return 0, err
+		}
+		b.keepScratch(enc)
+		return b.writeEncoded(enc, off)
+	}
+
+	if n := int64(dataSize(data)); n >= 0 && b.from == nil {
+		end := off + n
+		if b.grow && end > b.size() {
+			if err := b.growTo(end); err != nil {
+				return 0, err
+			}
+		}
+		if end <= b.size() {
+			putData(b.buf[off:end], order, data)
+			return n, nil
+		}
+	}
+
+	buf := bytes.NewBuffer(b.scratch[:0])
+	err = binary.Write(buf, order, data)
+	if err != nil {
+		return 0, err
+	}
+	b.keepScratch(buf.Bytes())
+	return b.writeEncoded(buf.Bytes(), off)
+}
+
+// maxScratch bounds the encoding memory a buffer keeps between writes
+const maxScratch = 64 << 10
+
+// keepScratch keeps the memory of an encoding for the next one. The
+// write lock is held throughout writeData, so one scratch per buffer is
+// enough however many goroutines write.
+func (b *BufferIO) keepScratch(enc []byte) {
+	if cap(enc) <= maxScratch {
+		b.scratch = enc[:0]
+	}
+}
+
+// writeEncoded writes the encoding of some data at off, refusing it
+// outright in strict mode if it does not fit
+func (b *BufferIO) writeEncoded(enc []byte, off int64) (int64, error) {
+	if b.strictData && !b.grow && b.from == nil && int64(len(enc)) > b.size()-off {
+		return 0, io.ErrShortWrite
+	}
+	n, err := b.writeAt(enc, off)
+	return int64(n), err
+}
+
 func (b *BufferIO) WriteDataLE(data interface{}) error {
 	return b.WriteData(binary.LittleEndian, data)
 }
@@ -64,24 +240,99 @@ func (b *BufferIO) WriteDataBE(data interface{}) error {
 }
 
 func (b *BufferIO) ReadAt(p []byte, off int64) (n int, err error) {
-	if off >= b.Size() {
-		return 0, ErrEOF
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.readAt(p, off)
+}
+
+func (b *BufferIO) readAt(p []byte, off int64) (n int, err error) {
+	if err := b.usable(); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, ErrOverrun
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if b.from != nil {
+		return b.readFrom(p, off)
+	}
+	if off >= b.size() {
+		return 0, io.EOF
 	}
 	bytes_copied := copy(p, b.buf[off:])
+	if bytes_copied < len(p) && b.semantics != SemanticsLegacy {
+		return bytes_copied, io.EOF
+	}
 	return bytes_copied, nil
 }
 
 func (b *BufferIO) Read(p []byte) (n int, err error) {
-	n, err = b.ReadAt(p, b.off)
-	if err == nil {
-		b.off += int64(n)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	n, err = b.readAt(p, b.off)
+	if err == io.EOF && n > 0 {
+		err = nil
 	}
+	b.off += int64(n)
 	return n, err
 }
 
+// ReadData decodes data from the current offset and advances past it,
+// so that successive calls read successive values. On error the offset
+// does not move.
 func (b *BufferIO) ReadData(order binary.ByteOrder, data interface{}) error {
-	buf := bytes.NewReader(b.buf[b.off:]) // this can probably be done with BufferIO
-	return binary.Read(buf, order, data)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	n, err := b.readData(order, b.off, data)
+	if err == nil {
+		b.off += n
+	}
+	return err
+}
+
+// readData decodes data from off and returns the number of bytes used
+func (b *BufferIO) readData(order binary.ByteOrder, off int64, data interface{}) (int64, error) {
+	if err := b.usable(); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, ErrOverrun
+	}
+	if off > b.length() {
+		return 0, io.EOF
+	}
+	if n, ok, err := unmarshalSize(data); ok {
+		if err != nil {
+			return 0, err
+		}
+		p := make([]byte, n)
+		if err := b.readWhole(p, off, false); err != nil {
+			return 0, err
+		}
+		return int64(n), data.(encoding.BinaryUnmarshaler).UnmarshalBinary(p)
+	}
+	plan, err := planOf(data)
+	if err != nil {
+		return 0, err
+	}
+	if b.from != nil {
+		return b.readDataFrom(order, off, data, plan)
+	}
+	if plan != nil {
+		n, err := plan.decode(b.buf[off:], order, reflect.ValueOf(data))
+		return int64(n), err
+	}
+	if n := int64(dataSize(data)); n >= 0 && n <= b.size()-off {
+		if getData(b.buf[off:off+n], order, data) {
+			return n, nil
+		}
+	}
+	// Short data and invalid types are left to encoding/binary to report
+	return 0, binary.Read(bytes.NewReader(b.buf[off:]), order, data)
 }
 
 func (b *BufferIO) ReadDataLE(data interface{}) error {
@@ -91,38 +342,83 @@ func (b *BufferIO) ReadDataBE(data interface{}) error {
 	return b.ReadData(binary.BigEndian, data)
 }
 
+// Seek sets the offset for the next Read or Write like Seek of os.File.
+// The os.SEEK_* constants are the same as io.SeekStart, io.SeekCurrent
+// and io.SeekEnd, and work as well. Seeking beyond the end is allowed:
+// reads there return io.EOF, and writes fail with io.ErrShortWrite
+// unless the buffer is growable, in which case the gap is zero filled.
+// A growable buffer thereby stands in for a file opened for reading and
+// writing.
 func (b *BufferIO) Seek(offset int64, whence int) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	if err := b.usable(); err != nil {
+		return 0, err
+	}
+
 	var position int64
 	switch whence {
-	case os.SEEK_SET:
+	case io.SeekStart:
 		position = offset
-	case os.SEEK_CUR:
+	case io.SeekCurrent:
 		position = b.off + offset
-	case os.SEEK_END:
-		position = b.Size() + offset
+	case io.SeekEnd:
+		position = b.length() + offset
 	default:
 		return 0, errors.New("invalid whence")
 	}
 
-	if position >= b.Size() {
-		return 0, ErrOverrun
-	}
 	if position < 0 {
 		return 0, errors.New("negative position")
 	}
+	switch {
+	case b.semantics == SemanticsStrict && position > b.length(),
+		b.semantics == SemanticsLegacy && position >= b.length():
+		return 0, ErrOverrun
+	}
 
 	b.off = position
 	return position, nil
 }
 
+// Bytes returns the memory of the buffer, or a copy of it when the
+// buffer was created with Options.CopyBytes. Prefer BytesCopy or
+// BytesUnsafe, which make the choice explicit.
 func (b *BufferIO) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.usable() != nil {
+		return nil
+	}
+	if b.copyBytes {
+		return b.bytesCopy()
+	}
 	return b.buf
 }
 
 func (b *BufferIO) Reset() {
+	b.mu.Lock()
 	b.off = 0
+	b.mu.Unlock()
 }
 
 func (b *BufferIO) Size() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.length()
+}
+
+func (b *BufferIO) size() int64 {
 	return int64(len(b.buf))
 }
+
+func (b *BufferIO) slice(off, n int64) ([]byte, error) {
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+	if off < 0 || n < 0 || off > b.size() || n > b.size()-off {
+		return nil, ErrOverrun
+	}
+	return b.buf[off : off+n], nil
+}
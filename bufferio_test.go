@@ -5,12 +5,19 @@
 package bufferio
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"reflect"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
 )
 
 type Struct struct {
@@ -145,7 +152,7 @@ func TestWriteAt(t *testing.T) {
 	// Test small write
 	n, err = bio.WriteAt(src, 8)
 	assert(t, n == 2)
-	assert(t, err == nil)
+	assert(t, err == io.ErrShortWrite)
 	assert(t, bio.buf[7] == 8)
 	assert(t, bio.buf[8] == 1)
 	assert(t, bio.buf[9] == 2)
@@ -153,7 +160,7 @@ func TestWriteAt(t *testing.T) {
 	// Test overrun
 	n, err = bio.WriteAt(src, 10)
 	assert(t, n == 0)
-	assert(t, err == ErrOverrun)
+	assert(t, err == io.ErrShortWrite)
 }
 
 func TestWrite(t *testing.T) {
@@ -190,13 +197,13 @@ func TestWrite(t *testing.T) {
 	// Write big again
 	n, err = bio.Write(big)
 	assert(t, n == (len(big)-len(src)))
-	assert(t, err == nil)
+	assert(t, err == io.ErrShortWrite)
 	assert(t, bio.off == int64(len(bio.buf)))
 
 	// Write again, we should be at the end
 	n, err = bio.Write(big)
 	assert(t, n == 0)
-	assert(t, err == ErrOverrun)
+	assert(t, err == io.ErrShortWrite)
 	assert(t, bio.off == int64(len(bio.buf)))
 }
 
@@ -245,7 +252,7 @@ func TestReadAt(t *testing.T) {
 	// Test small read
 	n, err = bio.ReadAt(buf, int64(len(big)-2))
 	assert(t, n == 2)
-	assert(t, err == nil)
+	assert(t, err == io.EOF)
 	assert(t, buf[0] == big[len(big)-2])
 	assert(t, buf[1] == big[len(big)-1])
 
@@ -379,8 +386,158 @@ func TestReadSlice(t *testing.T) {
 	checkResult(t, "ReadSlice", binary.BigEndian, err, slice, res)
 }
 
+func TestReadDataAdvances(t *testing.T) {
+	bio := NewBufferIO(src)
+	var a, b2 uint16
+	assert(t, bio.ReadDataBE(&a) == nil)
+	assert(t, bio.ReadDataBE(&b2) == nil)
+	assert(t, a == binary.BigEndian.Uint16(src) && b2 == binary.BigEndian.Uint16(src[2:]))
+	pos, _ := bio.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 4)
+
+	// Failed reads leave the offset alone
+	rest := make([]byte, len(src))
+	assert(t, bio.ReadDataBE(rest) != nil)
+	pos, _ = bio.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 4)
+}
+
 func TestWriteSlice(t *testing.T) {
 	buf := NewBufferIOMake(len(src))
 	err := buf.WriteDataBE(res)
 	checkResult(t, "WriteSlice", binary.BigEndian, err, buf.Bytes(), src)
 }
+
+func TestStandardSemantics(t *testing.T) {
+	var _ io.ReadWriteSeeker = NewBufferIOMake(1)
+	var _ io.ReaderAt = NewBufferIOMake(1)
+	var _ io.WriterAt = NewBufferIOMake(1)
+
+	// io.Copy and friends stop cleanly at the end
+	var out bytes.Buffer
+	n, err := io.Copy(&out, NewBufferIO(big))
+	assert(t, err == nil && n == int64(len(big)))
+	assert(t, bytes.Equal(out.Bytes(), big))
+
+	data, err := ioutil.ReadAll(bufio.NewReader(NewBufferIO(big)))
+	assert(t, err == nil && bytes.Equal(data, big))
+
+	// Copying into a buffer which is too small
+	dst := NewBufferIOMake(4)
+	n, err = io.Copy(dst, bytes.NewReader(big))
+	assert(t, n == 4 && err == io.ErrShortWrite)
+
+	// Seeking to the end is allowed, and reads there see io.EOF
+	bio := NewBufferIO(big)
+	pos, err := bio.Seek(0, io.SeekEnd)
+	assert(t, err == nil && pos == int64(len(big)))
+	_, err = bio.Read(make([]byte, 1))
+	assert(t, err == io.EOF)
+	assert(t, ErrEOF == io.EOF)
+}
+
+func TestSharedReadLock(t *testing.T) {
+	b := NewBufferIO(make([]byte, 16))
+
+	// The outer View holds the lock until the inner one has run, which
+	// only finishes if readers share it.
+	done := make(chan error, 1)
+	go func() {
+		done <- b.View(0, 8, func(p []byte) error {
+			inner := make(chan error, 1)
+			go func() {
+				inner <- b.View(8, 8, func(p []byte) error { return nil })
+			}()
+			select {
+			case err := <-inner:
+				return err
+			case <-time.After(5 * time.Second):
+				return errors.New("readers do not share the lock")
+			}
+		})
+	}()
+	assert(t, <-done == nil)
+}
+
+func TestConcurrentReadWrite(t *testing.T) {
+	const records = 64
+	b := NewBufferIO(make([]byte, records*8))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rec := make([]byte, 8)
+		for i := 0; i < 1000; i++ {
+			for j := range rec {
+				rec[j] = byte(i)
+			}
+			b.WriteAt(rec, int64(i%records)*8)
+		}
+	}()
+	bad := make(chan int64, 4)
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 8)
+			for i := 0; i < 1000; i++ {
+				off := int64(i%records) * 8
+				b.ReadAt(p, off)
+				if !bytes.Equal(p, bytes.Repeat(p[:1], 8)) {
+					bad <- off
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(bad)
+	for off := range bad {
+		t.Errorf("torn record at offset %d", off)
+	}
+}
+
+func TestStrictData(t *testing.T) {
+	rec := struct {
+		A uint32
+		B [6]byte
+		C uint32 `bufferio:"crc32=B,le"`
+	}{A: 1}
+	for _, strict := range []bool{false, true} {
+		b, err := NewBufferIOOptions(8, Options{StrictData: strict})
+		assert(t, err == nil)
+		copy(b.Bytes(), "untouche")
+
+		b.Seek(4, io.SeekStart)
+		assert(t, b.WriteDataLE(uint64(1)) == io.ErrShortWrite)
+		assert(t, b.WriteDataAtLE(2, &rec) == io.ErrShortWrite)
+		assert(t, b.WriteDataAtLE(6, []uint16{1, 2}) == io.ErrShortWrite)
+		assert(t, (string(b.Bytes()) == "untouche") == strict)
+		pos, _ := b.Seek(0, io.SeekCurrent)
+		assert(t, pos == 4)
+
+		assert(t, b.WriteDataAtLE(6, []uint16{0x4141}) == nil)
+		assert(t, string(b.Bytes()[6:]) == "AA")
+	}
+}
+
+func TestWriteDataScratch(t *testing.T) {
+	b := NewBufferIOMake(1 << 17)
+	for _, payload := range []string{"hello", "a much longer payload", ""} {
+		r := lenRecord{Payload: []byte(payload), Words: []uint32{7}}
+		assert(t, b.WriteDataAtLE(0, &r) == nil)
+		var back lenRecord
+		assert(t, b.ReadDataAtLE(0, &back) == nil)
+		assert(t, string(back.Payload) == payload && back.Words[0] == 7)
+		assert(t, cap(b.scratch) > 0)
+	}
+
+	// Large encodings are not kept
+	large := struct {
+		N uint32 `bufferio:"lenof=P"`
+		P []byte
+	}{P: make([]byte, maxScratch+1)}
+	assert(t, b.WriteDataAtLE(0, &large) == nil)
+	assert(t, cap(b.scratch) <= maxScratch)
+}
@@ -0,0 +1,148 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Builder composes a message at the current offset of a BufferIO with
+// chained calls. After the first failure every call is a no-op, and the
+// error is returned by Finish.
+type Builder struct {
+	b       *BufferIO
+	scratch [8]byte
+	err     error
+}
+
+func NewBuilder(b *BufferIO) *Builder {
+	return &Builder{b: b}
+}
+
+// Offset returns the offset the next write goes to.
+func (m *Builder) Offset() int64 {
+	m.b.mu.Lock()
+	defer m.b.mu.Unlock()
+	return m.b.off
+}
+
+// Finish returns the first error encountered while building.
+func (m *Builder) Finish() error {
+	return m.err
+}
+
+// Bytes appends p.
+func (m *Builder) Bytes(p []byte) *Builder {
+	if m.err != nil {
+		return m
+	}
+	_, err := m.b.Write(p)
+	if err == io.ErrShortWrite {
+		err = ErrOverrun
+	}
+	m.err = err
+	return m
+}
+
+func (m *Builder) U8(v uint8) *Builder {
+	m.scratch[0] = v
+	return m.Bytes(m.scratch[:1])
+}
+
+func (m *Builder) U16LE(v uint16) *Builder {
+	binary.LittleEndian.PutUint16(m.scratch[:], v)
+	return m.Bytes(m.scratch[:2])
+}
+
+func (m *Builder) U16BE(v uint16) *Builder {
+	binary.BigEndian.PutUint16(m.scratch[:], v)
+	return m.Bytes(m.scratch[:2])
+}
+
+func (m *Builder) U32LE(v uint32) *Builder {
+	binary.LittleEndian.PutUint32(m.scratch[:], v)
+	return m.Bytes(m.scratch[:4])
+}
+
+func (m *Builder) U32BE(v uint32) *Builder {
+	binary.BigEndian.PutUint32(m.scratch[:], v)
+	return m.Bytes(m.scratch[:4])
+}
+
+func (m *Builder) U64LE(v uint64) *Builder {
+	binary.LittleEndian.PutUint64(m.scratch[:], v)
+	return m.Bytes(m.scratch[:8])
+}
+
+func (m *Builder) U64BE(v uint64) *Builder {
+	binary.BigEndian.PutUint64(m.scratch[:], v)
+	return m.Bytes(m.scratch[:8])
+}
+
+// PadTo appends zeros until the offset is a multiple of align.
+func (m *Builder) PadTo(align int64) *Builder {
+	if m.err != nil || align <= 1 {
+		return m
+	}
+	for i := range m.scratch {
+		m.scratch[i] = 0
+	}
+	for pad := (align - m.Offset()%align) % align; pad > 0 && m.err == nil; {
+		n := pad
+		if n > int64(len(m.scratch)) {
+			n = int64(len(m.scratch))
+		}
+		m.Bytes(m.scratch[:n])
+		pad -= n
+	}
+	return m
+}
+
+// patch overwrites p at off without moving the offset
+func (m *Builder) patch(off int64, p []byte) *Builder {
+	if m.err != nil {
+		return m
+	}
+	m.b.mu.Lock()
+	defer m.b.mu.Unlock()
+	r, err := m.b.slice(off, int64(len(p)))
+	if err != nil {
+		m.err = err
+		return m
+	}
+	copy(r, p)
+	return m
+}
+
+func (m *Builder) PatchU16LE(off int64, v uint16) *Builder {
+	binary.LittleEndian.PutUint16(m.scratch[:], v)
+	return m.patch(off, m.scratch[:2])
+}
+
+func (m *Builder) PatchU16BE(off int64, v uint16) *Builder {
+	binary.BigEndian.PutUint16(m.scratch[:], v)
+	return m.patch(off, m.scratch[:2])
+}
+
+func (m *Builder) PatchU32LE(off int64, v uint32) *Builder {
+	binary.LittleEndian.PutUint32(m.scratch[:], v)
+	return m.patch(off, m.scratch[:4])
+}
+
+func (m *Builder) PatchU32BE(off int64, v uint32) *Builder {
+	binary.BigEndian.PutUint32(m.scratch[:], v)
+	return m.patch(off, m.scratch[:4])
+}
+
+func (m *Builder) PatchU64LE(off int64, v uint64) *Builder {
+	binary.LittleEndian.PutUint64(m.scratch[:], v)
+	return m.patch(off, m.scratch[:8])
+}
+
+func (m *Builder) PatchU64BE(off int64, v uint64) *Builder {
+	binary.BigEndian.PutUint64(m.scratch[:], v)
+	return m.patch(off, m.scratch[:8])
+}
@@ -0,0 +1,56 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBufferIOMake(32)
+	m := NewBuilder(b)
+
+	err := m.U8(1).
+		U32BE(0).
+		U16LE(0x0302).
+		Bytes([]byte("abc")).
+		PadTo(16).
+		U64BE(0x0102030405060708).
+		PatchU32BE(1, 24).
+		Finish()
+	assert(t, err == nil)
+	assert(t, m.Offset() == 24)
+	assert(t, bytes.Equal(b.Bytes()[:24], []byte{
+		1, 0, 0, 0, 24, 2, 3, 'a', 'b', 'c', 0, 0, 0, 0, 0, 0,
+		1, 2, 3, 4, 5, 6, 7, 8,
+	}))
+
+	// PadTo on a boundary does nothing
+	assert(t, m.PadTo(8).Finish() == nil)
+	assert(t, m.Offset() == 24)
+
+	m.U64LE(1).PatchU16BE(30, 0xaabb).PatchU64LE(0, 0)
+	assert(t, m.Finish() == nil)
+	assert(t, b.Bytes()[30] == 0xaa)
+	assert(t, b.Bytes()[4] == 0)
+}
+
+func TestBuilderErrors(t *testing.T) {
+	b := NewBufferIOMake(6)
+	m := NewBuilder(b)
+
+	// The first failure sticks and stops later writes
+	err := m.U32LE(1).U32LE(2).U8(3).Finish()
+	assert(t, err == ErrOverrun)
+	assert(t, m.Offset() == 6)
+
+	m = NewBuilder(NewBufferIOMake(6))
+	assert(t, m.PatchU32LE(4, 1).U8(1).Finish() == ErrOverrun)
+	assert(t, m.Offset() == 0)
+
+	m = NewBuilder(NewBufferIOMake(6))
+	assert(t, m.U8(1).PadTo(8).Finish() == ErrOverrun)
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ChunkHeader is the size of the header starting every chunk: the
+// little endian uint64 offset of the data that follows it. Chunks carry
+// their offset so they can be sent as separate messages over streams
+// such as gRPC or websockets, and a transfer can resume anywhere.
+const ChunkHeader = 8
+
+// SendChunks calls fn with the whole buffer cut into chunks carrying at
+// most chunkSize bytes of data each. The chunk is only valid during the
+// call.
+func (b *BufferIO) SendChunks(fn func(chunk []byte) error, chunkSize int) error {
+	_, err := b.SendChunksFrom(0, fn, chunkSize)
+	return err
+}
+
+// SendChunksFrom is like SendChunks but starts at off, to resume an
+// interrupted transfer. It returns the offset up to which chunks were
+// accepted by fn.
+func (b *BufferIO) SendChunksFrom(off int64, fn func(chunk []byte) error, chunkSize int) (int64, error) {
+	if chunkSize <= 0 {
+		return off, errors.New("invalid chunk size")
+	}
+	chunk := make([]byte, ChunkHeader+chunkSize)
+	for {
+		binary.LittleEndian.PutUint64(chunk, uint64(off))
+		n, err := b.ReadAt(chunk[ChunkHeader:], off)
+		if err != nil && err != io.EOF {
+			return off, err
+		}
+		if n > 0 {
+			if err := fn(chunk[:ChunkHeader+n]); err != nil {
+				return off, err
+			}
+			off += int64(n)
+		}
+		if err == io.EOF {
+			return off, nil
+		}
+	}
+}
+
+// ReceiveChunks writes the chunks returned by next, as produced by
+// SendChunks, until next returns io.EOF. Chunks may arrive in any order.
+// It returns the end of the last chunk written, which is where a sender
+// resumes when chunks arrive in order.
+func (b *BufferIO) ReceiveChunks(next func() ([]byte, error)) (int64, error) {
+	var end int64
+	for {
+		chunk, err := next()
+		if err == io.EOF {
+			return end, nil
+		}
+		if err != nil {
+			return end, err
+		}
+		if len(chunk) < ChunkHeader {
+			return end, ErrCorrupt
+		}
+
+		off := int64(binary.LittleEndian.Uint64(chunk))
+		data := chunk[ChunkHeader:]
+		if off < 0 || off > b.Size()-int64(len(data)) {
+			return end, ErrOverrun
+		}
+		if _, err := b.WriteAt(data, off); err != nil && len(data) > 0 {
+			return end, err
+		}
+		end = off + int64(len(data))
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// chunkQueue collects sent chunks and replays them
+type chunkQueue struct {
+	chunks [][]byte
+}
+
+func (q *chunkQueue) send(chunk []byte) error {
+	q.chunks = append(q.chunks, append([]byte(nil), chunk...))
+	return nil
+}
+
+func (q *chunkQueue) next() ([]byte, error) {
+	if len(q.chunks) == 0 {
+		return nil, io.EOF
+	}
+	c := q.chunks[0]
+	q.chunks = q.chunks[1:]
+	return c, nil
+}
+
+func TestChunks(t *testing.T) {
+	src := NewBufferIO(append([]byte(nil), big...))
+	q := &chunkQueue{}
+	assert(t, src.SendChunks(q.send, 10) == nil)
+	assert(t, len(q.chunks) == (len(big)+9)/10)
+	assert(t, len(q.chunks[0]) == ChunkHeader+10)
+
+	// Order does not matter
+	q.chunks[0], q.chunks[1] = q.chunks[1], q.chunks[0]
+	dst := NewBufferIOMake(len(big))
+	end, err := dst.ReceiveChunks(q.next)
+	assert(t, err == nil)
+	assert(t, end == int64(len(big)))
+	assert(t, bytes.Equal(dst.Bytes(), big))
+}
+
+func TestChunksResume(t *testing.T) {
+	src := NewBufferIO(append([]byte(nil), big...))
+	failed := errors.New("connection lost")
+
+	sent := 0
+	q := &chunkQueue{}
+	off, err := src.SendChunksFrom(0, func(chunk []byte) error {
+		if sent == 3 {
+			return failed
+		}
+		sent++
+		return q.send(chunk)
+	}, 8)
+	assert(t, err == failed)
+	assert(t, off == 24)
+
+	dst := NewBufferIOMake(len(big))
+	end, err := dst.ReceiveChunks(q.next)
+	assert(t, err == nil)
+	assert(t, end == off)
+
+	off, err = src.SendChunksFrom(end, q.send, 8)
+	assert(t, err == nil)
+	assert(t, off == int64(len(big)))
+	dst.ReceiveChunks(q.next)
+	assert(t, bytes.Equal(dst.Bytes(), big))
+}
+
+func TestChunksErrors(t *testing.T) {
+	dst := NewBufferIOMake(16)
+	q := &chunkQueue{chunks: [][]byte{{1, 2}}}
+	_, err := dst.ReceiveChunks(q.next)
+	assert(t, err == ErrCorrupt)
+
+	q = &chunkQueue{chunks: [][]byte{{12, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5}}}
+	_, err = dst.ReceiveChunks(q.next)
+	assert(t, err == ErrOverrun)
+
+	assert(t, dst.SendChunks(q.send, 0) != nil)
+}
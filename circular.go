@@ -0,0 +1,274 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"sync"
+)
+
+var (
+	ErrNoCircularLog = errors.New("buffer does not hold a circular log")
+	ErrCorrupt       = errors.New("corrupt record")
+	ErrTooSmall      = errors.New("buffer too small")
+)
+
+// A circular log keeps two copies of its metadata at the start of the
+// buffer, each laid out in little endian as
+//
+//	[magic uint32][crc uint32][seq uint64][head uint64][tail uint64][size uint64]
+//
+// The copy with the highest seq and a valid CRC over the bytes following
+// it wins, so a torn metadata write falls back to the previous state.
+// head and tail are positions which only ever grow; the record at
+// position p lives at p modulo size in the data region, which follows the
+// metadata. Records use the same framing as AppendRecord but may wrap
+// around the end of the data region.
+const (
+	circularMagic = 0x474c4342
+	circularSlot  = 40
+	circularMeta  = 2 * circularSlot
+)
+
+// CircularLog is safe for concurrent use. Scan holds the lock of the log
+// while calling fn, which must not use the log itself.
+type CircularLog struct {
+	mu    sync.Mutex
+	slots []byte
+	data  []byte
+	seq   uint64
+	head  uint64
+	tail  uint64
+}
+
+// NewCircularLog formats b as an empty circular log.
+func NewCircularLog(b *BufferIO) (*CircularLog, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	if b.size() < circularMeta+frameHeader+1 {
+		return nil, ErrTooSmall
+	}
+	l := &CircularLog{
+		slots: b.buf[:circularMeta],
+		data:  b.buf[circularMeta:],
+	}
+	zero(l.slots)
+	l.commit()
+	return l, nil
+}
+
+// OpenCircularLog attaches to a circular log previously formatted in b,
+// for example after restoring a snapshot or a crash. The chain of record
+// lengths from head must end exactly at tail, or the metadata is not
+// trusted; ErrCorrupt is returned when no metadata slot passes.
+func OpenCircularLog(b *BufferIO) (*CircularLog, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	if b.size() < circularMeta+frameHeader+1 {
+		return nil, ErrNoCircularLog
+	}
+	l := &CircularLog{
+		slots: b.buf[:circularMeta],
+		data:  b.buf[circularMeta:],
+	}
+
+	found, chained := false, false
+	for i := 0; i < 2; i++ {
+		slot := l.slots[i*circularSlot : (i+1)*circularSlot]
+		if binary.LittleEndian.Uint32(slot) != circularMagic ||
+			binary.LittleEndian.Uint32(slot[4:]) != crc32.Checksum(slot[8:], frameTable) {
+			continue
+		}
+		seq := binary.LittleEndian.Uint64(slot[8:])
+		head := binary.LittleEndian.Uint64(slot[16:])
+		tail := binary.LittleEndian.Uint64(slot[24:])
+		size := binary.LittleEndian.Uint64(slot[32:])
+		if size != uint64(len(l.data)) || head > tail || tail-head > size {
+			continue
+		}
+		found = true
+		if !l.chained(head, tail) {
+			continue
+		}
+		if !chained || seq > l.seq {
+			l.seq, l.head, l.tail = seq, head, tail
+			chained = true
+		}
+	}
+	if !found {
+		return nil, ErrNoCircularLog
+	}
+	if !chained {
+		return nil, ErrCorrupt
+	}
+	return l, nil
+}
+
+// chained reports whether the record lengths from head lead exactly to
+// tail, which Append relies on when evicting
+func (l *CircularLog) chained(head, tail uint64) bool {
+	for pos := head; pos < tail; {
+		if tail-pos < frameHeader {
+			return false
+		}
+		n := l.frameLen(pos)
+		if n > tail-pos-frameHeader {
+			return false
+		}
+		pos += frameHeader + n
+	}
+	return true
+}
+
+// commit persists head and tail in the older of the two metadata slots.
+func (l *CircularLog) commit() {
+	l.seq++
+	i := l.seq % 2
+	slot := l.slots[i*circularSlot : (i+1)*circularSlot]
+	binary.LittleEndian.PutUint64(slot[8:], l.seq)
+	binary.LittleEndian.PutUint64(slot[16:], l.head)
+	binary.LittleEndian.PutUint64(slot[24:], l.tail)
+	binary.LittleEndian.PutUint64(slot[32:], uint64(len(l.data)))
+	binary.LittleEndian.PutUint32(slot[4:], crc32.Checksum(slot[8:], frameTable))
+	binary.LittleEndian.PutUint32(slot, circularMagic)
+}
+
+func (l *CircularLog) ringWrite(pos uint64, p []byte) {
+	n := copy(l.data[pos%uint64(len(l.data)):], p)
+	copy(l.data, p[n:])
+}
+
+func (l *CircularLog) ringRead(pos uint64, p []byte) {
+	n := copy(p, l.data[pos%uint64(len(l.data)):])
+	copy(p[n:], l.data)
+}
+
+// ringSlice returns n bytes at pos, sharing memory with the log when they
+// do not wrap and copied into scratch otherwise.
+func (l *CircularLog) ringSlice(pos uint64, n int, scratch *[]byte) []byte {
+	start := pos % uint64(len(l.data))
+	if start+uint64(n) <= uint64(len(l.data)) {
+		return l.data[start : start+uint64(n) : start+uint64(n)]
+	}
+	if cap(*scratch) < n {
+		*scratch = make([]byte, n)
+	}
+	p := (*scratch)[:n]
+	l.ringRead(pos, p)
+	return p
+}
+
+func (l *CircularLog) frameLen(pos uint64) uint64 {
+	var header [4]byte
+	l.ringRead(pos, header[:])
+	return uint64(binary.LittleEndian.Uint32(header[:]))
+}
+
+// Append adds p to the log, discarding the oldest records to make room
+// when needed.
+func (l *CircularLog) Append(p []byte) error {
+	need := uint64(frameHeader) + uint64(len(p))
+	if need > uint64(len(l.data)) {
+		return ErrRecordTooLarge
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Persist the eviction before the old records are overwritten
+	evicted := false
+	for uint64(len(l.data))-(l.tail-l.head) < need {
+		l.head += frameHeader + l.frameLen(l.head)
+		evicted = true
+	}
+	if evicted {
+		l.commit()
+	}
+
+	var header [frameHeader]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(p)))
+	binary.LittleEndian.PutUint32(header[4:], frameSum(header[:], p))
+	l.ringWrite(l.tail, header[:])
+	l.ringWrite(l.tail+frameHeader, p)
+
+	l.tail += need
+	l.commit()
+	return nil
+}
+
+// Scan calls fn with every record from the oldest to the newest until fn
+// returns false. Payloads are only valid during the call. ErrCorrupt is
+// returned when a record fails validation.
+func (l *CircularLog) Scan(fn func(payload []byte) bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var scratch []byte
+	for pos := l.head; pos < l.tail; {
+		if l.tail-pos < frameHeader {
+			return ErrCorrupt
+		}
+		var header [frameHeader]byte
+		l.ringRead(pos, header[:])
+		n := uint64(binary.LittleEndian.Uint32(header[:]))
+		if n > l.tail-pos-frameHeader {
+			return ErrCorrupt
+		}
+
+		payload := l.ringSlice(pos+frameHeader, int(n), &scratch)
+		if frameSum(header[:], payload) != binary.LittleEndian.Uint32(header[4:]) {
+			return ErrCorrupt
+		}
+		if !fn(payload) {
+			return nil
+		}
+		pos += frameHeader + n
+	}
+	return nil
+}
+
+// Head and Tail return the positions of the oldest record and of the
+// next record to be written. They only ever grow.
+func (l *CircularLog) Head() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+func (l *CircularLog) Tail() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tail
+}
+
+// Used returns the number of data bytes occupied by records.
+func (l *CircularLog) Used() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.tail - l.head)
+}
+
+// Wraps returns how many times writing has wrapped around the end of the
+// data region.
+func (l *CircularLog) Wraps() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tail / uint64(len(l.data))
+}
+
+// Capacity returns the size of the data region.
+func (l *CircularLog) Capacity() int64 {
+	return int64(len(l.data))
+}
@@ -0,0 +1,171 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func circularRecords(t *testing.T, l *CircularLog) [][]byte {
+	var records [][]byte
+	err := l.Scan(func(payload []byte) bool {
+		records = append(records, append([]byte(nil), payload...))
+		return true
+	})
+	assert(t, err == nil)
+	return records
+}
+
+func TestCircularLogTooSmall(t *testing.T) {
+	_, err := NewCircularLog(NewBufferIOMake(circularMeta + frameHeader))
+	assert(t, err == ErrTooSmall)
+
+	_, err = OpenCircularLog(NewBufferIOMake(circularMeta + 64))
+	assert(t, err == ErrNoCircularLog)
+}
+
+func TestCircularLogAppend(t *testing.T) {
+	// Room for exactly three 8 byte records
+	bio := NewBufferIOMake(circularMeta + 3*(frameHeader+8))
+	l, err := NewCircularLog(bio)
+	assert(t, err == nil)
+	assert(t, l.Capacity() == 48)
+	assert(t, len(circularRecords(t, l)) == 0)
+
+	for i := byte(0); i < 3; i++ {
+		assert(t, l.Append(bytes.Repeat([]byte{i}, 8)) == nil)
+	}
+	assert(t, l.Used() == 48)
+	assert(t, l.Wraps() == 1)
+	assert(t, len(circularRecords(t, l)) == 3)
+
+	// Evicts the oldest record
+	assert(t, l.Append(bytes.Repeat([]byte{3}, 8)) == nil)
+	records := circularRecords(t, l)
+	assert(t, len(records) == 3)
+	assert(t, records[0][0] == 1)
+	assert(t, records[2][0] == 3)
+
+	// A record wrapping around the end of the data region
+	assert(t, l.Append([]byte{4, 4, 4}) == nil)
+	assert(t, l.Append(bytes.Repeat([]byte{5}, 20)) == nil)
+	records = circularRecords(t, l)
+	assert(t, len(records) == 2)
+	assert(t, bytes.Equal(records[0], []byte{4, 4, 4}))
+	assert(t, bytes.Equal(records[1], bytes.Repeat([]byte{5}, 20)))
+	assert(t, l.Tail()-l.Head() == uint64(l.Used()))
+
+	assert(t, l.Append(make([]byte, 41)) == ErrRecordTooLarge)
+
+	// Early stop
+	count := 0
+	l.Scan(func(payload []byte) bool {
+		count++
+		return false
+	})
+	assert(t, count == 1)
+}
+
+func TestCircularLogReopen(t *testing.T) {
+	bio := NewBufferIOMake(circularMeta + 100)
+	l, _ := NewCircularLog(bio)
+	for i := 0; i < 20; i++ {
+		l.Append([]byte{byte(i), byte(i), byte(i)})
+	}
+
+	// Restore from a snapshot of the memory
+	snapshot := append([]byte(nil), bio.Bytes()...)
+	r, err := OpenCircularLog(NewBufferIO(snapshot))
+	assert(t, err == nil)
+	assert(t, r.Head() == l.Head())
+	assert(t, r.Tail() == l.Tail())
+	assert(t, r.Wraps() == l.Wraps())
+
+	have := circularRecords(t, r)
+	want := circularRecords(t, l)
+	assert(t, len(have) == len(want))
+	assert(t, have[len(have)-1][0] == 19)
+
+	// Appending continues where the original left off
+	assert(t, r.Append([]byte{20}) == nil)
+	have = circularRecords(t, r)
+	assert(t, have[len(have)-1][0] == 20)
+}
+
+func TestCircularLogTornMetadata(t *testing.T) {
+	bio := NewBufferIOMake(circularMeta + 100)
+	l, _ := NewCircularLog(bio)
+	l.Append([]byte{1})
+	l.Append([]byte{2})
+
+	// Tear the latest metadata slot, the previous state survives
+	bio.buf[(l.seq%2)*circularSlot+20] ^= 0xff
+	r, err := OpenCircularLog(bio)
+	assert(t, err == nil)
+	records := circularRecords(t, r)
+	assert(t, len(records) == 1)
+	assert(t, records[0][0] == 1)
+
+	// Both slots gone
+	bio.buf[((l.seq+1)%2)*circularSlot+20] ^= 0xff
+	_, err = OpenCircularLog(bio)
+	assert(t, err == ErrNoCircularLog)
+}
+
+func TestCircularLogCorrupt(t *testing.T) {
+	bio := NewBufferIOMake(circularMeta + 100)
+	l, _ := NewCircularLog(bio)
+	l.Append([]byte{1, 2, 3})
+	l.Append([]byte{4, 5, 6})
+
+	bio.buf[circularMeta+2*frameHeader+3+1] ^= 0xff
+	count := 0
+	err := l.Scan(func(payload []byte) bool {
+		count++
+		return true
+	})
+	assert(t, err == ErrCorrupt)
+	assert(t, count == 1)
+}
+
+func TestCircularLogCorruptLength(t *testing.T) {
+	bio := NewBufferIOMake(circularMeta + 100)
+	l, _ := NewCircularLog(bio)
+	l.Append([]byte{1, 2, 3})
+	l.Append([]byte{4, 5, 6})
+
+	// A length reaching past the tail would let eviction overtake it
+	bio.buf[circularMeta] = 200
+	_, err := OpenCircularLog(bio)
+	assert(t, err == ErrCorrupt)
+
+	bio.buf[circularMeta] = 2
+	_, err = OpenCircularLog(bio)
+	assert(t, err == ErrCorrupt)
+
+	bio.buf[circularMeta] = 3
+	r, err := OpenCircularLog(bio)
+	assert(t, err == nil)
+	assert(t, len(circularRecords(t, r)) == 2)
+}
+
+func TestCircularLogConcurrent(t *testing.T) {
+	l, _ := NewCircularLog(NewBufferIOMake(circularMeta + 256))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g byte) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				assert(t, l.Append(bytes.Repeat([]byte{g}, 5)) == nil)
+				assert(t, l.Scan(func([]byte) bool { return true }) == nil)
+			}
+		}(byte(g))
+	}
+	wg.Wait()
+	assert(t, l.Used() <= l.Capacity())
+}
@@ -0,0 +1,135 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClosedOperations(t *testing.T) {
+	bio := NewBufferIOMake(128)
+	view := bio.View32(binary.BigEndian)
+	assert(t, bio.Close() == nil)
+
+	buf := make([]byte, 4)
+	var v uint32
+
+	_, err := bio.WriteAt(buf, 0)
+	assert(t, err == ErrClosed)
+	_, err = bio.Write(buf)
+	assert(t, err == ErrClosed)
+	assert(t, bio.WriteDataLE(v) == ErrClosed)
+	_, err = bio.ReadAt(buf, 0)
+	assert(t, err == ErrClosed)
+	_, err = bio.Read(buf)
+	assert(t, err == ErrClosed)
+	assert(t, bio.ReadDataLE(&v) == ErrClosed)
+	_, err = bio.Seek(0, os.SEEK_SET)
+	assert(t, err == ErrClosed)
+	assert(t, bio.ShiftLeft(0, 1, 1) == ErrClosed)
+	assert(t, bio.WriteMasked(0, buf, buf) == ErrClosed)
+	assert(t, bio.ApplyPatchesAtomic(nil) == nil)
+	assert(t, bio.ApplyPatchesAtomic([]Patch{{Data: buf}}).(*PatchError).Err == ErrClosed)
+	assert(t, bio.AppendRecord(buf) == ErrClosed)
+	assert(t, bio.Resize(10) == ErrClosed)
+	assert(t, bio.SignTrailer(macKey) == ErrClosed)
+	assert(t, bio.VerifyTrailer(macKey) == ErrClosed)
+	_, err = bio.Seal(sealKey, nil)
+	assert(t, err == ErrClosed)
+	_, err = bio.Export(&bytes.Buffer{})
+	assert(t, err == ErrClosed)
+	_, err = bio.Import(bytes.NewReader(src))
+	assert(t, err == ErrClosed)
+	assert(t, bio.ExportCompressed(&bytes.Buffer{}, Gzip{}) == ErrClosed)
+	_, err = NewCircularLog(bio)
+	assert(t, err == ErrClosed)
+	assert(t, bio.Close() == ErrClosed)
+
+	defer func() {
+		assert(t, recover() == ErrInvalidView)
+	}()
+	view.Get(0)
+}
+
+func TestClosedDebugPoison(t *testing.T) {
+	for _, opts := range []Options{
+		{Backing: BackingHeap, Debug: true},
+		{Backing: BackingPool, Pool: NewPool(), Debug: true},
+		{Backing: BackingArena, Arena: NewArena(64), Debug: true},
+	} {
+		bio, err := NewBufferIOOptions(64, opts)
+		assert(t, err == nil)
+		stale := bio.Bytes()
+		assert(t, bio.Close() == nil)
+		assert(t, bytes.Equal(stale, bytes.Repeat([]byte{PoisonByte}, 64)))
+
+		// Poisoned memory is not recycled
+		if opts.Pool != nil {
+			assert(t, opts.Pool.Stats().Idle == 0)
+		}
+	}
+}
+
+func TestClosedDebugMmap(t *testing.T) {
+	bio, err := NewBufferIOOptions(4096, Options{Backing: BackingMmap, Debug: true})
+	if err == ErrUnsupported {
+		t.Skip(err)
+	}
+	assert(t, err == nil)
+	stale := bio.Bytes()
+	stale[0] = 1
+	assert(t, bio.Close() == nil)
+
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		assert(t, recover() != nil)
+	}()
+	t.Log(stale[0])
+}
+
+func TestLeakCheck(t *testing.T) {
+	leaks := make(chan string, 10)
+	LeakLog = func(format string, v ...interface{}) {
+		leaks <- fmt.Sprintf(format, v...)
+	}
+	defer func() { LeakLog = log.Printf }()
+
+	pool := NewPool()
+	opts := Options{Backing: BackingPool, Pool: pool, LeakCheck: true}
+
+	// Closed buffers are not reported
+	bio, _ := NewBufferIOOptions(64, opts)
+	bio.Close()
+
+	func() {
+		NewBufferIOOptions(100, opts)
+	}()
+
+	for i := 0; i < 20; i++ {
+		runtime.GC()
+		select {
+		case msg := <-leaks:
+			assert(t, strings.Contains(msg, "100 bytes"))
+			assert(t, strings.Contains(msg, "TestLeakCheck"))
+			assert(t, len(leaks) == 0)
+
+			// The memory is not taken back, as it may still be in use
+			assert(t, pool.Stats().Puts == 1)
+			return
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	t.Error("leak not reported")
+}
@@ -0,0 +1,477 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrChecksum = errors.New("checksum mismatch")
+
+// Struct fields passed to WriteData and ReadData may carry a bufferio
+// tag holding comma separated options:
+//
+//	crc32=Name	the field is a uint32 holding the Castagnoli CRC-32 of
+//			the encoding of the earlier field Name. WriteData
+//			fills it in and ReadData verifies it.
+//	lenof=Name	the field is an unsigned integer holding the encoded
+//			size in bytes of the field Name. WriteData fills it in
+//			and ReadData uses it to bound the field.
+//	le, be		the field is little or big endian whatever the order
+//			passed to WriteData and ReadData, for formats mixing
+//			byte orders within one record. The computed fields
+//			above are stored in their own order as well.
+//	offset=N	the field starts N bytes into the record, after zeros
+//			filling the gap from the field before it.
+//	pad=N		N zeros follow the field.
+//	len=N		the field is a string or []byte stored in exactly N
+//			bytes, padded with NULs. ReadData drops the padding of
+//			strings and keeps that of byte slices.
+//	skip, -		the field is not encoded at all.
+//
+// A tagged struct may hold slices of fixed size values as long as a
+// lenof field before them records their size. Fields implementing
+// encoding.BinaryMarshaler are encoded through it, and make the struct
+// count as tagged. Tags are honoured on the
+// fields of the top level struct only.
+
+// EncodedSize returns the number of bytes WriteData would write for v
+// in order, or the error WriteData would return for it. The size of
+// fixed size values is computed without encoding them; tagged structs
+// and slices of them are encoded to learn it.
+func EncodedSize(order binary.ByteOrder, v interface{}) (int, error) {
+	_, n, err := encodeVariable(order, v)
+	return int(n), err
+}
+
+// structPlan is the parsed layout of a tagged struct type
+type structPlan struct {
+	fields []planField
+	crcs   []planCRC
+	size   int // encoded size, or -1 when it depends on slices
+}
+
+type planField struct {
+	name  string
+	blank bool
+	size  int // encoded size, -1 for a slice
+	elem  int // element size of a slice
+	lenOf int // field whose size this field holds, or -1
+	lenBy int // field holding the size of this one, or -1
+
+	order   binary.ByteOrder // from an le or be option, or nil
+	offset  int              // from an offset option, or -1
+	pad     int              // zero bytes after the field
+	fixed   bool             // a string or []byte of size bytes, from len
+	skip    bool             // not encoded at all
+	marshal bool             // encoded through MarshalBinary
+}
+
+// orderOf returns the byte order of f, def unless a tag overrides it
+func (f *planField) orderOf(def binary.ByteOrder) binary.ByteOrder {
+	if f.order != nil {
+		return f.order
+	}
+	return def
+}
+
+type planCRC struct {
+	field, target int
+}
+
+var plans = struct {
+	sync.RWMutex
+	m map[reflect.Type]*structPlan
+}{m: make(map[reflect.Type]*structPlan)}
+
+// planOf returns the plan for the type of data, or nil if it is not a
+// struct with bufferio tags
+func planOf(data interface{}) (*structPlan, error) {
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	t := v.Type()
+
+	plans.RLock()
+	p, ok := plans.m[t]
+	plans.RUnlock()
+	if ok {
+		return p, nil
+	}
+
+	// Concurrent first uses may build the plan twice, which is harmless
+	p, err := newStructPlan(t)
+	if err != nil {
+		return nil, err
+	}
+	plans.Lock()
+	plans.m[t] = p
+	plans.Unlock()
+	return p, nil
+}
+
+func newStructPlan(t reflect.Type) (*structPlan, error) {
+	p := &structPlan{}
+	tagged, unsupported := false, false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("bufferio")
+		pf := planField{name: f.Name, blank: f.Name == "_", lenOf: -1, lenBy: -1, offset: -1}
+		pf.marshal = !pf.blank && isMarshaler(f.Type)
+		if _, ok := tagValue(tag, "skip"); ok || tag == "-" {
+			pf.skip, pf.marshal = true, false
+		} else if v, ok := tagValue(tag, "len"); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("bufferio: invalid len %q on field %s", v, f.Name)
+			}
+			if !pf.marshal && f.Type.Kind() != reflect.String && f.Type != reflect.TypeOf([]byte(nil)) {
+				return nil, fmt.Errorf("bufferio: len field %s is not a string, []byte or BinaryMarshaler", f.Name)
+			}
+			pf.size, pf.fixed = n, true
+		} else if pf.marshal {
+			// Without a fixed size a lenof field must bound it, as for slices
+			pf.size, pf.elem = binary.Size(reflect.Zero(f.Type).Interface()), 1
+		} else if f.Type.Kind() == reflect.Slice {
+			pf.size = -1
+			pf.elem = binary.Size(reflect.Zero(f.Type.Elem()).Interface())
+			unsupported = unsupported || pf.elem <= 0
+		} else {
+			pf.size = binary.Size(reflect.Zero(f.Type).Interface())
+			unsupported = unsupported || pf.size < 0
+		}
+		p.fields = append(p.fields, pf)
+		if tag != "" || pf.marshal {
+			tagged = true
+		}
+	}
+	if !tagged {
+		// binary encodes these, or reports why it cannot
+		return nil, nil
+	}
+	if unsupported {
+		return nil, ErrInvalidType
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("bufferio")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		for _, opt := range strings.Split(tag, ",") {
+			key, value := opt, ""
+			if i := strings.Index(opt, "="); i >= 0 {
+				key, value = opt[:i], opt[i+1:]
+			}
+			if p.fields[i].skip && key != "skip" {
+				return nil, fmt.Errorf("bufferio: skipped field %s takes no other options", f.Name)
+			}
+			target := p.field(value)
+			switch key {
+			case "skip", "len":
+				// handled above
+			case "offset", "pad":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("bufferio: invalid %s %q on field %s", key, value, f.Name)
+				}
+				if key == "offset" {
+					p.fields[i].offset = n
+				} else {
+					p.fields[i].pad = n
+				}
+			case "le", "be":
+				if value != "" {
+					return nil, fmt.Errorf("bufferio: unknown tag option %q on field %s", opt, f.Name)
+				}
+				order := binary.ByteOrder(binary.LittleEndian)
+				if key == "be" {
+					order = binary.BigEndian
+				}
+				if p.fields[i].order != nil && p.fields[i].order != order {
+					return nil, fmt.Errorf("bufferio: field %s is both le and be", f.Name)
+				}
+				p.fields[i].order = order
+			case "crc32":
+				if f.Type.Kind() != reflect.Uint32 {
+					return nil, fmt.Errorf("bufferio: crc32 field %s is not a uint32", f.Name)
+				}
+				if target < 0 || target >= i {
+					return nil, fmt.Errorf("bufferio: crc32 field %s does not follow field %q", f.Name, value)
+				}
+				p.crcs = append(p.crcs, planCRC{field: i, target: target})
+			case "lenof":
+				switch f.Type.Kind() {
+				case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				default:
+					return nil, fmt.Errorf("bufferio: lenof field %s is not unsigned", f.Name)
+				}
+				if target < 0 || target == i || p.fields[target].blank {
+					return nil, fmt.Errorf("bufferio: lenof field %s names no field %q", f.Name, value)
+				}
+				if p.fields[target].marshal && !p.fields[target].fixed {
+					// MarshalBinary may return any size
+					p.fields[target].size = -1
+				}
+				if p.fields[target].size < 0 {
+					if target < i || p.fields[target].lenBy >= 0 {
+						return nil, fmt.Errorf("bufferio: lenof field %s must come first and alone", f.Name)
+					}
+					p.fields[target].lenBy = i
+				}
+				p.fields[i].lenOf = target
+			default:
+				return nil, fmt.Errorf("bufferio: unknown tag option %q on field %s", opt, f.Name)
+			}
+		}
+	}
+
+	for _, f := range p.fields {
+		if f.size < 0 && f.lenBy < 0 {
+			return nil, fmt.Errorf("bufferio: no lenof field for %s", f.name)
+		}
+		switch {
+		case p.size < 0:
+		case f.size < 0 || f.offset >= 0 && p.size > f.offset:
+			p.size = -1
+		case f.skip:
+		default:
+			if f.offset >= 0 {
+				p.size = f.offset
+			}
+			p.size += f.size + f.pad
+		}
+	}
+	return p, nil
+}
+
+// fixedSize is dataSize for values which may be tagged structs, whose
+// layout differs from that of encoding/binary
+func fixedSize(data interface{}) int {
+	if n, ok, err := unmarshalSize(data); ok {
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	p, err := planOf(data)
+	switch {
+	case err != nil:
+		return -1
+	case p != nil:
+		return p.size
+	}
+	return dataSize(data)
+}
+
+// tagValue returns the value of the option key in tag and whether it is
+// present
+func tagValue(tag, key string) (string, bool) {
+	for _, opt := range strings.Split(tag, ",") {
+		k, v := opt, ""
+		if i := strings.Index(opt, "="); i >= 0 {
+			k, v = opt[:i], opt[i+1:]
+		}
+		if k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (p *structPlan) field(name string) int {
+	for i, f := range p.fields {
+		if f.name == name && !f.blank && !f.skip {
+			return i
+		}
+	}
+	return -1
+}
+
+// encode writes v field by field and fills in the computed fields,
+// reusing the memory of dst when it is large enough
+func (p *structPlan) encode(dst []byte, order binary.ByteOrder, v reflect.Value) ([]byte, error) {
+	buf := bytes.NewBuffer(dst[:0])
+	e := &streamEncoder{w: buf, order: order}
+	starts := make([]int, len(p.fields))
+	ends := make([]int, len(p.fields))
+	for i, f := range p.fields {
+		if f.offset >= 0 {
+			if buf.Len() > f.offset {
+				return nil, fmt.Errorf("bufferio: field %s at offset %d overlaps the fields before it", f.name, f.offset)
+			}
+			e.zeros(f.offset - buf.Len())
+		}
+		starts[i] = buf.Len()
+		switch {
+		case f.skip:
+		case f.blank:
+			e.zeros(f.size)
+		case f.marshal:
+			enc, err := marshal(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			if f.fixed && len(enc) > f.size || !f.fixed && f.size >= 0 && len(enc) != f.size {
+				return nil, fmt.Errorf("bufferio: MarshalBinary of %s returned %d bytes, not %d",
+					f.name, len(enc), f.size)
+			}
+			e.write(enc)
+			if f.fixed {
+				e.zeros(f.size - len(enc))
+			}
+		case f.fixed:
+			fv := v.Field(i)
+			var s []byte
+			if fv.Kind() == reflect.String {
+				s = []byte(fv.String())
+			} else {
+				s = fv.Bytes()
+			}
+			if len(s) > f.size {
+				return nil, fmt.Errorf("bufferio: %s is longer than its len of %d", f.name, f.size)
+			}
+			e.write(s)
+			e.zeros(f.size - len(s))
+		default:
+			e.order = f.orderOf(order)
+			e.value(v.Field(i))
+		}
+		ends[i] = buf.Len()
+		e.zeros(f.pad)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	enc := buf.Bytes()
+	for i, f := range p.fields {
+		if f.lenOf < 0 {
+			continue
+		}
+		n := uint64(ends[f.lenOf] - starts[f.lenOf])
+		if !putUint(enc[starts[i]:ends[i]], f.orderOf(order), n) {
+			return nil, fmt.Errorf("bufferio: %s is too long for lenof field %s",
+				p.fields[f.lenOf].name, f.name)
+		}
+	}
+	for _, c := range p.crcs {
+		p.fields[c.field].orderOf(order).PutUint32(enc[starts[c.field]:],
+			crc32.Checksum(enc[starts[c.target]:ends[c.target]], frameTable))
+	}
+	return enc, nil
+}
+
+// decode reads the struct v points to from enc, bounding slices by
+// their lenof fields and verifying checksums. It returns the number of
+// bytes used.
+func (p *structPlan) decode(enc []byte, order binary.ByteOrder, v reflect.Value) (int, error) {
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return 0, ErrInvalidType
+	}
+	v = v.Elem()
+
+	starts := make([]int, len(p.fields))
+	ends := make([]int, len(p.fields))
+	off := 0
+	for i, f := range p.fields {
+		if f.offset >= 0 {
+			if off > f.offset {
+				return 0, ErrCorrupt
+			}
+			if f.offset > len(enc) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			off = f.offset
+		}
+		starts[i] = off
+		size := f.size
+		if size < 0 {
+			n := v.Field(f.lenBy).Uint()
+			if n%uint64(f.elem) != 0 {
+				return 0, ErrCorrupt
+			}
+			if n > uint64(len(enc)-off) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			size = int(n)
+		}
+		if size > len(enc)-off {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		if !f.blank && !f.skip {
+			fv := v.Field(i)
+			raw := enc[off : off+size]
+			switch {
+			case f.marshal:
+				if err := unmarshal(fv, raw); err != nil {
+					return 0, err
+				}
+			case f.fixed && fv.Kind() == reflect.String:
+				fv.SetString(string(bytes.TrimRight(raw, "\x00")))
+			case f.fixed:
+				fv.SetBytes(append([]byte(nil), raw...))
+			default:
+				target := fv.Addr().Interface()
+				if f.size < 0 {
+					fv.Set(reflect.MakeSlice(fv.Type(), size/f.elem, size/f.elem))
+					target = fv.Interface()
+				}
+				if err := binary.Read(bytes.NewReader(raw), f.orderOf(order), target); err != nil {
+					return 0, err
+				}
+			}
+		}
+		off += size
+		ends[i] = off
+		if f.pad > len(enc)-off {
+			return 0, io.ErrUnexpectedEOF
+		}
+		off += f.pad
+	}
+
+	for i, f := range p.fields {
+		if f.lenOf >= 0 && v.Field(i).Uint() != uint64(ends[f.lenOf]-starts[f.lenOf]) {
+			return 0, ErrCorrupt
+		}
+	}
+	for _, c := range p.crcs {
+		if p.fields[c.field].orderOf(order).Uint32(enc[starts[c.field]:]) !=
+			crc32.Checksum(enc[starts[c.target]:ends[c.target]], frameTable) {
+			return 0, ErrChecksum
+		}
+	}
+	return off, nil
+}
+
+// putUint stores n in the width of p, reporting whether it fits
+func putUint(p []byte, order binary.ByteOrder, n uint64) bool {
+	switch len(p) {
+	case 1:
+		p[0] = byte(n)
+	case 2:
+		order.PutUint16(p, uint16(n))
+	case 4:
+		order.PutUint32(p, uint32(n))
+	case 8:
+		order.PutUint64(p, n)
+	}
+	return len(p) == 8 || n < 1<<(8*uint(len(p)))
+}
@@ -0,0 +1,358 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+type crcHeader struct {
+	Magic  [4]byte
+	Header struct {
+		Version uint16
+		Length  uint32
+	}
+	HeaderCRC uint32 `bufferio:"crc32=Header"`
+	MagicCRC  uint32 `bufferio:"crc32=Magic"`
+}
+
+func TestCodecCRC(t *testing.T) {
+	h := crcHeader{Magic: [4]byte{'H', 'D', 'R', '1'}}
+	h.Header.Version = 2
+	h.Header.Length = 1000
+
+	b := NewBufferIOMake(64)
+	assert(t, b.WriteDataBE(&h) == nil)
+	want := crc32.Checksum(b.Bytes()[4:10], crc32.MakeTable(crc32.Castagnoli))
+	assert(t, binary.BigEndian.Uint32(b.Bytes()[10:]) == want)
+	assert(t, h.HeaderCRC == 0)
+
+	var back crcHeader
+	b.Seek(0, 0)
+	assert(t, b.ReadDataBE(&back) == nil)
+	assert(t, back.Header == h.Header)
+	assert(t, back.HeaderCRC == want)
+
+	// Damage to either region is caught, and the offset stays put
+	b.Seek(0, 0)
+	b.Bytes()[5] ^= 1
+	assert(t, b.ReadDataBE(&back) == ErrChecksum)
+	b.Bytes()[5] ^= 1
+	b.Bytes()[0] ^= 1
+	assert(t, b.ReadDataBE(&back) == ErrChecksum)
+	b.Bytes()[0] ^= 1
+	assert(t, b.ReadDataBE(&back) == nil)
+
+	// Values work as well as pointers
+	b.Seek(0, 0)
+	assert(t, b.WriteDataLE(h) == nil)
+	b.Seek(0, 0)
+	assert(t, b.ReadDataLE(&back) == nil)
+	assert(t, back.MagicCRC != 0)
+}
+
+func TestCodecBadTags(t *testing.T) {
+	b := NewBufferIOMake(64)
+
+	var notUint32 struct {
+		A   uint32
+		CRC uint16 `bufferio:"crc32=A"`
+	}
+	assert(t, b.WriteDataLE(&notUint32) != nil)
+
+	var later struct {
+		CRC uint32 `bufferio:"crc32=A"`
+		A   uint32
+	}
+	assert(t, b.WriteDataLE(&later) != nil)
+	assert(t, b.ReadDataLE(&later) != nil)
+
+	var unknown struct {
+		A uint32 `bufferio:"bogus"`
+	}
+	assert(t, b.WriteDataLE(&unknown) != nil)
+	pos, _ := b.Seek(0, 1)
+	assert(t, pos == 0)
+}
+
+type lenRecord struct {
+	Kind       uint8
+	PayloadLen uint16 `bufferio:"lenof=Payload"`
+	WordsLen   uint8  `bufferio:"lenof=Words"`
+	Payload    []byte
+	Words      []uint32
+	TrailerLen uint32 `bufferio:"lenof=Trailer"`
+	Trailer    [3]uint16
+	CRC        uint32 `bufferio:"crc32=Payload"`
+}
+
+func TestCodecLenOf(t *testing.T) {
+	r := lenRecord{Kind: 9, Payload: []byte("hello"), Words: []uint32{1, 2}}
+
+	b := NewBufferIOMake(64)
+	assert(t, b.WriteDataLE(&r) == nil)
+	pos, _ := b.Seek(0, 1)
+	assert(t, pos == 1+2+1+5+8+4+6+4)
+	p := b.Bytes()
+	assert(t, binary.LittleEndian.Uint16(p[1:]) == 5)
+	assert(t, p[3] == 8)
+	assert(t, string(p[4:9]) == "hello")
+	assert(t, binary.LittleEndian.Uint32(p[17:]) == 6)
+
+	var back lenRecord
+	b.Seek(0, 0)
+	assert(t, b.ReadDataLE(&back) == nil)
+	assert(t, back.Kind == 9)
+	assert(t, string(back.Payload) == "hello")
+	assert(t, len(back.Words) == 2 && back.Words[1] == 2)
+	assert(t, back.PayloadLen == 5)
+	assert(t, back.TrailerLen == 6)
+
+	// Empty slices encode as zero lengths
+	b = NewBufferIOMake(64)
+	assert(t, b.WriteDataBE(&lenRecord{}) == nil)
+	b.Seek(0, 0)
+	assert(t, b.ReadDataBE(&back) == nil)
+	assert(t, len(back.Payload) == 0)
+}
+
+func TestCodecLenOfBounds(t *testing.T) {
+	r := lenRecord{Payload: []byte("hello"), Words: []uint32{1, 2}}
+	b := NewBufferIOMake(64)
+	assert(t, b.WriteDataLE(&r) == nil)
+	b.Seek(0, 0)
+	var back lenRecord
+
+	// Lengths running past the buffer
+	b.Bytes()[1] = 60
+	assert(t, b.ReadDataLE(&back) == io.ErrUnexpectedEOF)
+	b.Bytes()[1] = 5
+
+	// Lengths which are not whole elements
+	b.Bytes()[3] = 7
+	assert(t, b.ReadDataLE(&back) == ErrCorrupt)
+	b.Bytes()[3] = 8
+
+	// Lengths of fixed fields must match
+	b.Bytes()[17] = 7
+	assert(t, b.ReadDataLE(&back) == ErrCorrupt)
+	b.Bytes()[17] = 6
+	assert(t, b.ReadDataLE(&back) == nil)
+
+	assert(t, b.ReadDataLE(back) == ErrInvalidType)
+
+	// Slices too long for their length field
+	var small struct {
+		N uint8 `bufferio:"lenof=P"`
+		P []byte
+	}
+	small.P = make([]byte, 256)
+	b = NewBufferIOMake(512)
+	assert(t, b.WriteDataLE(&small) != nil)
+	small.P = small.P[:255]
+	assert(t, b.WriteDataLE(&small) == nil)
+}
+
+func TestCodecLenOfBadTags(t *testing.T) {
+	b := NewBufferIOMake(64)
+
+	var after struct {
+		P []byte
+		N uint8 `bufferio:"lenof=P"`
+	}
+	assert(t, b.WriteDataLE(&after) != nil)
+
+	var missing struct {
+		N uint8 `bufferio:"lenof=Q"`
+		P []byte
+	}
+	assert(t, b.WriteDataLE(&missing) != nil)
+
+	var signed struct {
+		N int8 `bufferio:"lenof=P"`
+		P []byte
+	}
+	assert(t, b.WriteDataLE(&signed) != nil)
+
+	var unbounded struct {
+		N uint8 `bufferio:"lenof=P"`
+		P []byte
+		Q []byte
+	}
+	assert(t, b.WriteDataLE(&unbounded) != nil)
+
+	var twice struct {
+		N uint8 `bufferio:"lenof=P"`
+		M uint8 `bufferio:"lenof=P"`
+		P []byte
+	}
+	assert(t, b.WriteDataLE(&twice) != nil)
+
+	var strings struct {
+		N uint8 `bufferio:"lenof=S"`
+		S string
+	}
+	assert(t, b.WriteDataLE(&strings) == ErrInvalidType)
+}
+
+type mixedRecord struct {
+	Magic   uint32
+	Legacy  uint32 `bufferio:"le"`
+	Count   uint16 `bufferio:"lenof=Items,le"`
+	Items   []uint16
+	Trailer uint64
+	CRC     uint32 `bufferio:"crc32=Items,le"`
+}
+
+func TestCodecFieldOrder(t *testing.T) {
+	r := mixedRecord{Magic: 0x01020304, Legacy: 0x01020304, Items: []uint16{0x0102}, Trailer: 7}
+
+	b := NewBufferIOMake(64)
+	assert(t, b.WriteDataBE(&r) == nil)
+	p := b.Bytes()
+	assert(t, binary.BigEndian.Uint32(p[0:]) == 0x01020304)
+	assert(t, binary.LittleEndian.Uint32(p[4:]) == 0x01020304)
+	assert(t, binary.LittleEndian.Uint16(p[8:]) == 2)
+	assert(t, binary.BigEndian.Uint16(p[10:]) == 0x0102)
+	assert(t, binary.BigEndian.Uint64(p[12:]) == 7)
+	assert(t, binary.LittleEndian.Uint32(p[20:]) == crc32.Checksum(p[10:12], crc32.MakeTable(crc32.Castagnoli)))
+
+	var back mixedRecord
+	b.Seek(0, 0)
+	assert(t, b.ReadDataBE(&back) == nil)
+	assert(t, back.Magic == r.Magic && back.Legacy == r.Legacy)
+	assert(t, len(back.Items) == 1 && back.Items[0] == 0x0102)
+	assert(t, back.Trailer == 7)
+
+	// Tagged fields keep their order under the other default
+	b.Seek(0, 0)
+	assert(t, b.WriteDataLE(&r) == nil)
+	assert(t, binary.LittleEndian.Uint32(p[0:]) == 0x01020304)
+	assert(t, binary.LittleEndian.Uint32(p[4:]) == 0x01020304)
+
+	var both struct {
+		A uint32 `bufferio:"le,be"`
+	}
+	assert(t, b.WriteDataLE(&both) != nil)
+	var valued struct {
+		A uint32 `bufferio:"le=A"`
+	}
+	assert(t, b.WriteDataLE(&valued) != nil)
+}
+
+func TestEncodedSize(t *testing.T) {
+	r := lenRecord{Payload: []byte("hello"), Words: []uint32{1, 2}}
+	values := []interface{}{
+		uint16(1), &r, r, []uint32{1, 2, 3}, [3]int64{}, &crcHeader{},
+		mixedRecord{Items: []uint16{1}},
+	}
+	for _, v := range values {
+		n, err := EncodedSize(binary.BigEndian, v)
+		assert(t, err == nil)
+		b := NewBufferIOMake(64)
+		assert(t, b.WriteDataBE(v) == nil)
+		pos, _ := b.Seek(0, io.SeekCurrent)
+		assert(t, int64(n) == pos)
+	}
+
+	_, err := EncodedSize(binary.LittleEndian, "string")
+	assert(t, err != nil)
+	var bad struct {
+		A uint32 `bufferio:"bogus"`
+	}
+	_, err = EncodedSize(binary.LittleEndian, &bad)
+	assert(t, err != nil)
+}
+
+type diskHeader struct {
+	Magic   [4]byte
+	Version uint16         `bufferio:"be,pad=2"`
+	Name    string         `bufferio:"len=8"`
+	Label   []byte         `bufferio:"len=4"`
+	Cache   map[string]int `bufferio:"-"`
+	Scratch []string       `bufferio:"skip"`
+	Size    uint64         `bufferio:"offset=24"`
+	CRC     uint32         `bufferio:"crc32=Name,offset=36"`
+}
+
+func TestCodecLayout(t *testing.T) {
+	h := diskHeader{Magic: [4]byte{'D', 'I', 'S', 'K'}, Version: 3, Name: "root", Label: []byte{1, 2},
+		Cache: map[string]int{"x": 1}, Size: 1 << 40}
+
+	b := NewBufferIOMake(64)
+	assert(t, b.WriteDataLE(&h) == nil)
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 40)
+	n, err := EncodedSize(binary.LittleEndian, &h)
+	assert(t, err == nil && n == 40)
+
+	p := b.Bytes()
+	assert(t, string(p[:4]) == "DISK")
+	assert(t, binary.BigEndian.Uint16(p[4:]) == 3)
+	assert(t, p[6] == 0 && p[7] == 0)
+	assert(t, string(p[8:16]) == "root\x00\x00\x00\x00")
+	assert(t, string(p[16:20]) == "\x01\x02\x00\x00")
+	assert(t, binary.LittleEndian.Uint64(p[24:]) == 1<<40)
+	assert(t, binary.LittleEndian.Uint32(p[36:]) == crc32.Checksum(p[8:16], crc32.MakeTable(crc32.Castagnoli)))
+
+	back := diskHeader{Cache: map[string]int{"kept": 1}}
+	b.Seek(0, io.SeekStart)
+	assert(t, b.ReadDataLE(&back) == nil)
+	assert(t, back.Version == 3 && back.Name == "root" && back.Size == 1<<40)
+	assert(t, string(back.Label) == "\x01\x02\x00\x00")
+	assert(t, back.Cache["kept"] == 1 && back.Scratch == nil)
+
+	// Cursors and ReadDataMulti check bounds against the layout
+	assert(t, fixedSize(&back) == 40)
+	c, err := NewCursor(b, 0, 40)
+	assert(t, err == nil)
+	assert(t, c.ReadDataLE(&back) == nil && c.Remaining() == 0)
+	b.Seek(0, io.SeekStart)
+	assert(t, b.ReadDataMulti(binary.LittleEndian, &back, new(uint64)) == nil)
+
+	// Values too long for their len are refused
+	h.Name = "much too long"
+	assert(t, b.WriteDataLE(&h) != nil)
+
+	// Truncated records
+	r := NewBufferIO(append([]byte(nil), p[:30]...))
+	assert(t, r.ReadDataLE(&back) == io.ErrUnexpectedEOF)
+}
+
+func TestCodecLayoutBadTags(t *testing.T) {
+	b := NewBufferIOMake(64)
+
+	var overlap struct {
+		A uint64
+		B uint32 `bufferio:"offset=4"`
+	}
+	assert(t, b.WriteDataLE(&overlap) != nil)
+
+	var badLen struct {
+		A uint32 `bufferio:"len=4"`
+	}
+	assert(t, b.WriteDataLE(&badLen) != nil)
+
+	var badPad struct {
+		A uint32 `bufferio:"pad=x"`
+	}
+	assert(t, b.WriteDataLE(&badPad) != nil)
+
+	var skipped struct {
+		A uint32 `bufferio:"skip,le"`
+	}
+	assert(t, b.WriteDataLE(&skipped) != nil)
+
+	var crcSkipped struct {
+		A uint32 `bufferio:"skip"`
+		C uint32 `bufferio:"crc32=A"`
+	}
+	assert(t, b.WriteDataLE(&crcSkipped) != nil)
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 0)
+}
@@ -0,0 +1,42 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import "bytes"
+
+// CompareAt compares the len(p) bytes at off with p, as bytes.Compare
+// does. A range running past the end of the buffer compares only the
+// bytes that are present, so it sorts before p. A closed buffer holds
+// no bytes.
+func (b *BufferIO) CompareAt(off int64, p []byte) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return bytes.Compare(b.window(off, int64(len(p))), p)
+}
+
+// EqualAt reports whether the len(p) bytes at off equal p.
+func (b *BufferIO) EqualAt(off int64, p []byte) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	w := b.window(off, int64(len(p)))
+	return len(w) == len(p) && bytes.Equal(w, p)
+}
+
+// window returns up to n bytes at off, without copying unless the
+// buffer is backed by a reader
+func (b *BufferIO) window(off, n int64) []byte {
+	if b.usable() != nil || off < 0 || off >= b.length() {
+		return nil
+	}
+	if n > b.length()-off {
+		n = b.length() - off
+	}
+	if b.from != nil {
+		p := make([]byte, n)
+		k, _ := b.readAt(p, off)
+		return p[:k]
+	}
+	return b.buf[off : off+n]
+}
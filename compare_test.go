@@ -0,0 +1,43 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+)
+
+func TestCompareAt(t *testing.T) {
+	b := NewBufferIO([]byte("abcdef"))
+
+	assert(t, b.CompareAt(0, []byte("abc")) == 0)
+	assert(t, b.CompareAt(1, []byte("bcd")) == 0)
+	assert(t, b.CompareAt(1, []byte("bce")) < 0)
+	assert(t, b.CompareAt(1, []byte("bcc")) > 0)
+	assert(t, b.CompareAt(0, nil) == 0)
+
+	// Short ranges sort first
+	assert(t, b.CompareAt(4, []byte("efg")) < 0)
+	assert(t, b.CompareAt(6, []byte("a")) < 0)
+	assert(t, b.CompareAt(-1, []byte("a")) < 0)
+
+	assert(t, b.Close() == nil)
+	assert(t, b.CompareAt(0, []byte("a")) < 0)
+}
+
+func TestEqualAt(t *testing.T) {
+	b := NewBufferIO([]byte("abcdef"))
+
+	assert(t, b.EqualAt(0, []byte("abcdef")))
+	assert(t, b.EqualAt(3, []byte("de")))
+	assert(t, !b.EqualAt(3, []byte("dd")))
+	assert(t, !b.EqualAt(4, []byte("efg")))
+	assert(t, !b.EqualAt(7, []byte("a")))
+	assert(t, b.EqualAt(2, nil))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		b.EqualAt(1, []byte("bc"))
+	})
+	assert(t, allocs == 0)
+}
@@ -0,0 +1,192 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	ErrBadSnapshot  = errors.New("not a compressed snapshot")
+	ErrWrongCodec   = errors.New("snapshot uses a different codec")
+	ErrUnknownCodec = errors.New("no codec registered under that name")
+)
+
+// Codec provides streaming compression for snapshots and compressed
+// frames. Gzip is built in; other algorithms such as zstd, lz4 or snappy
+// can be plugged in by implementing this interface, without this package
+// depending on them.
+type Codec interface {
+	// Name identifies the algorithm in snapshot headers.
+	Name() string
+	Compress(w io.Writer) (io.WriteCloser, error)
+	Decompress(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{"gzip": Gzip{}}
+)
+
+// RegisterCodec makes c available by its name to imports which are not
+// given a codec. Registering a name again replaces the earlier codec.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	codecs[c.Name()] = c
+	codecsMu.Unlock()
+}
+
+// LookupCodec returns the codec registered under name.
+func LookupCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	c, ok := codecs[name]
+	codecsMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownCodec
+	}
+	return c, nil
+}
+
+// resolveCodec returns the codec for data written by the codec called
+// name. When c is nil it comes from the registry; otherwise it must be c.
+func resolveCodec(name string, c Codec) (Codec, error) {
+	if c == nil {
+		return LookupCodec(name)
+	}
+	if c.Name() != name {
+		return nil, ErrWrongCodec
+	}
+	return c, nil
+}
+
+// Gzip compresses with compress/gzip at the given level. The zero value
+// uses the default level.
+type Gzip struct {
+	Level int
+}
+
+func (g Gzip) Name() string {
+	return "gzip"
+}
+
+func (g Gzip) Compress(w io.Writer) (io.WriteCloser, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+func (g Gzip) Decompress(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// A compressed snapshot starts with the header
+//
+//	["BIOZ"][name length uint8][name][size uint64]
+//
+// with size in little endian, followed by the compressed stream.
+var snapshotMagic = []byte("BIOZ")
+
+// ExportCompressed writes the buffer to w as a compressed snapshot.
+func (b *BufferIO) ExportCompressed(w io.Writer, c Codec) error {
+	name := c.Name()
+	if len(name) > 255 {
+		return errors.New("codec name too long")
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.usable(); err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, len(snapshotMagic)+1+len(name)+8)
+	header = append(header, snapshotMagic...)
+	header = append(header, byte(len(name)))
+	header = append(header, name...)
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(b.length()))
+	header = append(header, size[:]...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	zw, err := c.Compress(w)
+	if err != nil {
+		return err
+	}
+	var data io.Reader = bytes.NewReader(b.buf)
+	if b.from != nil {
+		data = b.section(0)
+	}
+	if _, err := io.Copy(zw, data); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// ImportCompressed decompresses a snapshot written by ExportCompressed
+// straight into the start of the buffer and returns its size. The
+// snapshot must fit in the buffer, and its stream must end, with any
+// trailer of the codec intact, right after size bytes. A nil c selects
+// the registered codec named in the snapshot.
+func (b *BufferIO) ImportCompressed(r io.Reader, c Codec) (int64, error) {
+	var magic [5]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return 0, ErrBadSnapshot
+	}
+	if string(magic[:4]) != string(snapshotMagic) {
+		return 0, ErrBadSnapshot
+	}
+	rest := make([]byte, int(magic[4])+8)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, ErrBadSnapshot
+	}
+	c, err := resolveCodec(string(rest[:magic[4]]), c)
+	if err != nil {
+		return 0, err
+	}
+	size := binary.LittleEndian.Uint64(rest[magic[4]:])
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.memory(); err != nil {
+		return 0, err
+	}
+
+	if size > uint64(b.size()) {
+		return 0, ErrOverrun
+	}
+
+	zr, err := c.Decompress(r)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	n, err := io.ReadFull(zr, b.buf[:size])
+	if err == nil {
+		// Reading to the end lets the codec check its trailer, such as
+		// the CRC of gzip
+		var extra [1]byte
+		switch _, err = io.ReadFull(zr, extra[:]); err {
+		case io.EOF:
+			err = nil
+		case nil:
+			err = ErrBadSnapshot
+		}
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = ErrBadSnapshot
+	}
+	return int64(n), err
+}
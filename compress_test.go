@@ -0,0 +1,127 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// deflate is a Codec implemented outside of the package
+type deflate struct{}
+
+func (deflate) Name() string {
+	return "deflate"
+}
+
+func (deflate) Compress(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.BestSpeed)
+}
+
+func (deflate) Decompress(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+func testCompressed(t *testing.T, c Codec) {
+	bio := sparseBuffer()
+	var out bytes.Buffer
+	assert(t, bio.ExportCompressed(&out, c) == nil)
+	assert(t, int64(out.Len()) < bio.Size()/8)
+
+	restored := NewBufferIOMake(int(bio.Size()) + 10)
+	n, err := restored.ImportCompressed(bytes.NewReader(out.Bytes()), c)
+	assert(t, err == nil)
+	assert(t, n == bio.Size())
+	assert(t, bytes.Equal(restored.buf[:n], bio.Bytes()))
+
+	// Does not fit
+	small := NewBufferIOMake(100)
+	_, err = small.ImportCompressed(bytes.NewReader(out.Bytes()), c)
+	assert(t, err == ErrOverrun)
+
+	// Truncated stream
+	_, err = restored.ImportCompressed(bytes.NewReader(out.Bytes()[:out.Len()-20]), c)
+	assert(t, err != nil)
+}
+
+func TestCompressedGzip(t *testing.T) {
+	testCompressed(t, Gzip{})
+	testCompressed(t, Gzip{Level: 1})
+}
+
+func TestCompressedPluggable(t *testing.T) {
+	testCompressed(t, deflate{})
+}
+
+func TestImportCompressedHeader(t *testing.T) {
+	bio := NewBufferIO(big)
+	var out bytes.Buffer
+	bio.ExportCompressed(&out, Gzip{})
+
+	_, err := bio.ImportCompressed(bytes.NewReader(out.Bytes()), deflate{})
+	assert(t, err == ErrWrongCodec)
+
+	_, err = bio.ImportCompressed(bytes.NewReader([]byte("BIOX\x04gzip")), Gzip{})
+	assert(t, err == ErrBadSnapshot)
+
+	_, err = bio.ImportCompressed(bytes.NewReader([]byte("BIOZ\x04gz")), Gzip{})
+	assert(t, err == ErrBadSnapshot)
+
+	_, err = bio.ImportCompressed(bytes.NewReader(nil), Gzip{})
+	assert(t, err == ErrBadSnapshot)
+
+	// The gzip trailer is checked, and the stream must end at the size
+	dst := NewBufferIOMake(len(big))
+	crc := append([]byte(nil), out.Bytes()...)
+	crc[len(crc)-8] ^= 1
+	for _, bad := range [][]byte{crc, out.Bytes()[:out.Len()-4]} {
+		n, err := dst.ImportCompressed(bytes.NewReader(bad), Gzip{})
+		assert(t, n == int64(len(big)) && err != nil)
+	}
+	var long bytes.Buffer
+	NewBufferIO(append(append([]byte(nil), big...), 1)).ExportCompressed(&long, Gzip{})
+	binary.LittleEndian.PutUint64(long.Bytes()[9:], uint64(len(big)))
+	_, err = dst.ImportCompressed(bytes.NewReader(long.Bytes()), Gzip{})
+	assert(t, err == ErrBadSnapshot)
+
+	// Streams straight from a reader
+	restored := NewBufferIOMake(len(big))
+	_, err = restored.ImportCompressed(ioutil.NopCloser(&out), Gzip{})
+	assert(t, err == nil)
+	assert(t, bytes.Equal(restored.buf, big))
+}
+
+func TestCodecRegistry(t *testing.T) {
+	c, err := LookupCodec("gzip")
+	assert(t, err == nil && c.Name() == "gzip")
+	_, err = LookupCodec("deflate")
+	assert(t, err == ErrUnknownCodec)
+
+	bio := sparseBuffer()
+	var out bytes.Buffer
+	assert(t, bio.ExportCompressed(&out, deflate{}) == nil)
+	restored := NewBufferIOMake(int(bio.Size()))
+	_, err = restored.ImportCompressed(bytes.NewReader(out.Bytes()), nil)
+	assert(t, err == ErrUnknownCodec)
+
+	RegisterCodec(deflate{})
+	defer func() {
+		codecsMu.Lock()
+		delete(codecs, "deflate")
+		codecsMu.Unlock()
+	}()
+	n, err := restored.ImportCompressed(bytes.NewReader(out.Bytes()), nil)
+	assert(t, err == nil && n == bio.Size())
+	assert(t, bytes.Equal(restored.Bytes(), bio.Bytes()))
+
+	out.Reset()
+	assert(t, bio.ExportSeekable(&out, deflate{}, 4096) == nil)
+	s, err := NewSeekableReader(bytes.NewReader(out.Bytes()), int64(out.Len()), nil)
+	assert(t, err == nil && s.Size() == bio.Size())
+}
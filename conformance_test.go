@@ -0,0 +1,138 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// fileLike is what generic code uses of an *os.File
+type fileLike interface {
+	io.ReadWriteSeeker
+	io.ReaderAt
+	io.WriterAt
+}
+
+// sameError reports whether two errors agree as far as generic code
+// can tell: both nil, both io.EOF, or both some other failure.
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return (a == io.EOF) == (b == io.EOF)
+}
+
+// TestFileConformance runs the same random operations against a file and
+// a growable buffer and checks that they behave alike.
+func TestFileConformance(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+	bio := NewBufferIOGrow(0)
+	targets := []fileLike{f, bio}
+
+	whences := []int{io.SeekStart, io.SeekCurrent, io.SeekEnd}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		op := rnd.Intn(5)
+		n := rnd.Intn(40)
+		off := int64(rnd.Intn(300) - 20)
+		whence := whences[rnd.Intn(len(whences))]
+		data := make([]byte, n)
+		rnd.Read(data)
+
+		var counts [2]int64
+		var errs [2]error
+		var reads [2][]byte
+		for j, target := range targets {
+			p := make([]byte, n)
+			var c int
+			switch op {
+			case 0:
+				c, errs[j] = target.Write(data)
+			case 1:
+				c, errs[j] = target.Read(p)
+			case 2:
+				var pos int64
+				pos, errs[j] = target.Seek(off, whence)
+				c = int(pos)
+			case 3:
+				c, errs[j] = target.ReadAt(p, off)
+			case 4:
+				c, errs[j] = target.WriteAt(data, off)
+			}
+			counts[j] = int64(c)
+			if op == 1 || op == 3 {
+				reads[j] = p[:c]
+			}
+		}
+
+		if !sameError(errs[0], errs[1]) {
+			t.Fatalf("op %d %d: file error %v, buffer error %v", i, op, errs[0], errs[1])
+		}
+		if errs[0] == nil && counts[0] != counts[1] {
+			t.Fatalf("op %d %d: file count %d, buffer count %d", i, op, counts[0], counts[1])
+		}
+		if (op == 1 || op == 3) && !bytes.Equal(reads[0], reads[1]) {
+			t.Fatalf("op %d %d: reads differ", i, op)
+		}
+
+		fpos, _ := f.Seek(0, io.SeekCurrent)
+		bpos, _ := bio.Seek(0, io.SeekCurrent)
+		if fpos != bpos {
+			t.Fatalf("op %d %d: file at %d, buffer at %d", i, op, fpos, bpos)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(f.Name())
+	assert(t, err == nil)
+	assert(t, bytes.Equal(contents, bio.Bytes()))
+}
+
+func TestSeekBeyondEnd(t *testing.T) {
+	bio := NewBufferIOMake(8)
+	pos, err := bio.Seek(20, io.SeekStart)
+	assert(t, err == nil && pos == 20)
+	_, err = bio.Read(make([]byte, 1))
+	assert(t, err == io.EOF)
+	_, err = bio.Write([]byte("x"))
+	assert(t, err == io.ErrShortWrite)
+	var v uint32
+	assert(t, bio.ReadDataLE(&v) == io.EOF)
+
+	_, err = bio.Seek(-21, io.SeekCurrent)
+	assert(t, err != nil)
+	pos, _ = bio.Seek(0, io.SeekCurrent)
+	assert(t, pos == 20)
+
+	// Growable buffers fill the gap like files do
+	bio.SetGrowable(true)
+	_, err = bio.Write([]byte("x"))
+	assert(t, err == nil)
+	assert(t, bio.Size() == 21)
+	assert(t, bytes.Equal(bio.Bytes()[8:], append(make([]byte, 12), 'x')))
+}
+
+func TestSeekEndGrowable(t *testing.T) {
+	bio := NewBufferIO(append([]byte(nil), src...))
+	pos, err := bio.Seek(-2, os.SEEK_END)
+	assert(t, err == nil && pos == int64(len(src))-2)
+	var v uint16
+	assert(t, bio.ReadDataBE(&v) == nil)
+	assert(t, v == binary.BigEndian.Uint16(src[len(src)-2:]))
+
+	// Typed writes past the end of a growable buffer zero fill the gap
+	bio.SetGrowable(true)
+	pos, err = bio.Seek(3, os.SEEK_END)
+	assert(t, err == nil && pos == int64(len(src))+3)
+	assert(t, bio.WriteDataLE(uint32(0xffffffff)) == nil)
+	assert(t, bio.Size() == int64(len(src))+7)
+	assert(t, bytes.Equal(bio.Bytes()[len(src):len(src)+3], []byte{0, 0, 0}))
+}
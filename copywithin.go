@@ -0,0 +1,24 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+// CopyWithin copies n bytes from src to dst inside the buffer. The
+// ranges may overlap; the result is as if the source had first been
+// copied aside.
+func (b *BufferIO) CopyWithin(dst, src, n int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from, err := b.slice(src, n)
+	if err != nil {
+		return err
+	}
+	to, err := b.slice(dst, n)
+	if err != nil {
+		return err
+	}
+	copy(to, from) // copy handles overlap like memmove
+	return nil
+}
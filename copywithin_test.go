@@ -0,0 +1,41 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+)
+
+func TestCopyWithin(t *testing.T) {
+	b := NewBufferIO([]byte("0123456789"))
+
+	assert(t, b.CopyWithin(6, 0, 3) == nil)
+	assert(t, string(b.Bytes()) == "0123450129")
+
+	// Overlapping forwards and backwards
+	b = NewBufferIO([]byte("0123456789"))
+	assert(t, b.CopyWithin(2, 0, 6) == nil)
+	assert(t, string(b.Bytes()) == "0101234589")
+
+	b = NewBufferIO([]byte("0123456789"))
+	assert(t, b.CopyWithin(0, 2, 6) == nil)
+	assert(t, string(b.Bytes()) == "2345676789")
+
+	assert(t, b.CopyWithin(0, 0, 0) == nil)
+	assert(t, b.CopyWithin(10, 0, 0) == nil)
+}
+
+func TestCopyWithinBounds(t *testing.T) {
+	b := NewBufferIO([]byte("0123456789"))
+
+	assert(t, b.CopyWithin(0, 8, 3) == ErrOverrun)
+	assert(t, b.CopyWithin(8, 0, 3) == ErrOverrun)
+	assert(t, b.CopyWithin(-1, 0, 3) == ErrOverrun)
+	assert(t, b.CopyWithin(0, 0, -1) == ErrOverrun)
+	assert(t, string(b.Bytes()) == "0123456789")
+
+	assert(t, b.Close() == nil)
+	assert(t, b.CopyWithin(0, 1, 1) == ErrClosed)
+}
@@ -0,0 +1,314 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Cursor reads and writes a region of a buffer with an offset of its
+// own, leaving the offset of the buffer alone. Any number of cursors may
+// walk one buffer at once, sharing its memory rather than copying it.
+// Offsets are relative to the start of the region, so a parser handed a
+// Cursor does not need to know where its input lives. A Cursor is a
+// small value: copying it saves the parsing state, and assigning the
+// copy back restores it.
+//
+// Cursors read through the buffer, which locks for each call, but a
+// single Cursor is not safe for concurrent use.
+type Cursor struct {
+	b     *BufferIO
+	start int64
+	off   int64
+	limit int64
+}
+
+// NewCursor returns a cursor over the n bytes of b at off.
+func NewCursor(b *BufferIO, off, n int64) (Cursor, error) {
+	if off < 0 || n < 0 || off > b.Size()-n {
+		return Cursor{}, ErrOverrun
+	}
+	return Cursor{b: b, start: off, limit: n}, nil
+}
+
+// Cursor returns a cursor over the whole of b as it is now. Growing the
+// buffer later does not extend the cursor.
+func (b *BufferIO) Cursor() Cursor {
+	return Cursor{b: b, limit: b.Size()}
+}
+
+// Offset returns the position of the cursor within its region.
+func (c *Cursor) Offset() int64 {
+	return c.off
+}
+
+// SetOffset moves the cursor to off within its region.
+func (c *Cursor) SetOffset(off int64) error {
+	if off < 0 || off > c.limit {
+		return ErrOverrun
+	}
+	c.off = off
+	return nil
+}
+
+// Len returns the size of the region.
+func (c *Cursor) Len() int64 {
+	return c.limit
+}
+
+// Remaining returns the number of bytes left to read.
+func (c *Cursor) Remaining() int64 {
+	return c.limit - c.off
+}
+
+// take checks that n more bytes are available
+func (c *Cursor) take(n int64) error {
+	switch {
+	case n < 0:
+		return errors.New("negative count")
+	case c.off == c.limit && n > 0:
+		return io.EOF
+	case n > c.limit-c.off:
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// Read implements io.Reader over the rest of the region.
+func (c *Cursor) Read(p []byte) (int, error) {
+	if c.off >= c.limit {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.limit-c.off {
+		p = p[:c.limit-c.off]
+	}
+	n, err := c.b.ReadAt(p, c.start+c.off)
+	c.off += int64(n)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+// Write writes p at the cursor and moves past it. Writes do not extend
+// the region: a write running past its end is cut short and returns
+// io.ErrShortWrite.
+func (c *Cursor) Write(p []byte) (int, error) {
+	short := int64(len(p)) > c.limit-c.off
+	if short {
+		p = p[:c.limit-c.off]
+	}
+	n, err := c.b.WriteAt(p, c.start+c.off)
+	c.off += int64(n)
+	if err == nil && short {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// Seek sets the position within the region like Seek of BufferIO, with
+// io.SeekEnd relative to the end of the region. Unlike a buffer, a
+// cursor cannot be moved outside its region.
+func (c *Cursor) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.off
+	case io.SeekEnd:
+		offset += c.limit
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if err := c.SetOffset(offset); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// Bytes returns a copy of the next n bytes.
+func (c *Cursor) Bytes(n int64) ([]byte, error) {
+	if err := c.take(n); err != nil {
+		return nil, err
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(c, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Skip moves past the next n bytes.
+func (c *Cursor) Skip(n int64) error {
+	if err := c.take(n); err != nil {
+		return err
+	}
+	c.off += n
+	return nil
+}
+
+// Sub returns a cursor over the next n bytes and moves c past them.
+func (c *Cursor) Sub(n int64) (Cursor, error) {
+	if err := c.take(n); err != nil {
+		return Cursor{}, err
+	}
+	sub := Cursor{b: c.b, start: c.start + c.off, limit: n}
+	c.off += n
+	return sub, nil
+}
+
+// Nested reads length prefixed content, as written by WriteNested, and
+// returns a cursor over it.
+func (c *Cursor) Nested() (Cursor, error) {
+	saved := *c
+	n, err := c.Uint32LE()
+	if err != nil {
+		return Cursor{}, err
+	}
+	sub, err := c.Sub(int64(n))
+	if err != nil {
+		*c = saved
+	}
+	return sub, err
+}
+
+// ReadData decodes data like ReadData of BufferIO and moves past it.
+// Data running past the end of the region is an error, after which data
+// may be partly filled in.
+func (c *Cursor) ReadData(order binary.ByteOrder, data interface{}) error {
+	if n := int64(fixedSize(data)); n >= 0 {
+		if err := c.take(n); err != nil {
+			return err
+		}
+	}
+	c.b.mu.RLock()
+	n, err := c.b.readData(order, c.start+c.off, data)
+	c.b.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if n > c.limit-c.off {
+		return io.ErrUnexpectedEOF
+	}
+	c.off += n
+	return nil
+}
+
+func (c *Cursor) ReadDataLE(data interface{}) error {
+	return c.ReadData(binary.LittleEndian, data)
+}
+
+func (c *Cursor) ReadDataBE(data interface{}) error {
+	return c.ReadData(binary.BigEndian, data)
+}
+
+// WriteData encodes data like WriteData of BufferIO and moves past it.
+// Data which does not fit in the rest of the region is not written and
+// returns io.ErrShortWrite.
+func (c *Cursor) WriteData(order binary.ByteOrder, data interface{}) error {
+	enc, n, err := encodeVariable(order, data)
+	if err != nil {
+		return err
+	}
+	if n > c.limit-c.off {
+		return io.ErrShortWrite
+	}
+	c.b.mu.Lock()
+	if enc == nil {
+		_, err = c.b.writeData(order, c.start+c.off, data)
+	} else {
+		_, err = c.b.writeAt(enc, c.start+c.off)
+	}
+	c.b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	c.off += n
+	return nil
+}
+
+func (c *Cursor) WriteDataLE(data interface{}) error {
+	return c.WriteData(binary.LittleEndian, data)
+}
+
+func (c *Cursor) WriteDataBE(data interface{}) error {
+	return c.WriteData(binary.BigEndian, data)
+}
+
+// fixed reads the next len(p) bytes into p
+func (c *Cursor) fixed(p []byte) error {
+	if err := c.take(int64(len(p))); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(c, p)
+	return err
+}
+
+// Uint8 reads a byte.
+func (c *Cursor) Uint8() (uint8, error) {
+	var p [1]byte
+	if err := c.fixed(p[:]); err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// Uint16LE reads a little endian uint16.
+func (c *Cursor) Uint16LE() (uint16, error) {
+	var p [2]byte
+	if err := c.fixed(p[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(p[:]), nil
+}
+
+// Uint16BE reads a big endian uint16.
+func (c *Cursor) Uint16BE() (uint16, error) {
+	var p [2]byte
+	if err := c.fixed(p[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(p[:]), nil
+}
+
+// Uint32LE reads a little endian uint32.
+func (c *Cursor) Uint32LE() (uint32, error) {
+	var p [4]byte
+	if err := c.fixed(p[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(p[:]), nil
+}
+
+// Uint32BE reads a big endian uint32.
+func (c *Cursor) Uint32BE() (uint32, error) {
+	var p [4]byte
+	if err := c.fixed(p[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(p[:]), nil
+}
+
+// Uint64LE reads a little endian uint64.
+func (c *Cursor) Uint64LE() (uint64, error) {
+	var p [8]byte
+	if err := c.fixed(p[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(p[:]), nil
+}
+
+// Uint64BE reads a big endian uint64.
+func (c *Cursor) Uint64BE() (uint64, error) {
+	var p [8]byte
+	if err := c.fixed(p[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(p[:]), nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	b := NewBufferIOMake(64)
+	m := NewBuilder(b)
+	m.U8(7).U16BE(0x0102).U32LE(0x03040506).U64BE(9)
+	assert(t, m.Finish() == nil)
+	b.Seek(5, os.SEEK_SET)
+
+	c, err := NewCursor(b, 0, 15)
+	assert(t, err == nil)
+	assert(t, c.Len() == 15)
+	v8, err := c.Uint8()
+	assert(t, err == nil && v8 == 7)
+	v16, err := c.Uint16BE()
+	assert(t, err == nil && v16 == 0x0102)
+
+	// Copies save and restore the position
+	saved := c
+	v32, err := c.Uint32LE()
+	assert(t, err == nil && v32 == 0x03040506)
+	c = saved
+	v32, err = c.Uint32BE()
+	assert(t, err == nil && v32 == 0x06050403)
+
+	v64, err := c.Uint64BE()
+	assert(t, err == nil && v64 == 9)
+	assert(t, c.Remaining() == 0)
+	_, err = c.Uint8()
+	assert(t, err == io.EOF)
+
+	// The buffer offset is untouched
+	pos, _ := b.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 5)
+
+	_, err = NewCursor(b, 60, 5)
+	assert(t, err == ErrOverrun)
+}
+
+func TestCursorLimits(t *testing.T) {
+	b := NewBufferIO(append([]byte(nil), big...))
+	c, err := NewCursor(b, 4, 6)
+	assert(t, err == nil)
+
+	_, err = c.Uint64LE()
+	assert(t, err == io.ErrUnexpectedEOF)
+	assert(t, c.Offset() == 0)
+
+	var v [2]uint16
+	assert(t, c.ReadData(binary.LittleEndian, &v) == nil)
+	assert(t, v[0] == binary.LittleEndian.Uint16(big[4:]))
+	assert(t, c.ReadData(binary.LittleEndian, &v) == io.ErrUnexpectedEOF)
+
+	assert(t, c.SetOffset(7) == ErrOverrun)
+	assert(t, c.SetOffset(1) == nil)
+	p, err := c.Bytes(3)
+	assert(t, err == nil && string(p) == string(big[5:8]))
+	assert(t, c.Skip(3) == io.ErrUnexpectedEOF)
+	assert(t, c.Skip(2) == nil)
+
+	c.SetOffset(0)
+	data, err := ioutil.ReadAll(&c)
+	assert(t, err == nil && string(data) == string(big[4:10]))
+}
+
+func TestCursorNested(t *testing.T) {
+	b := NewBufferIOMake(64)
+	assert(t, b.WriteNested(func(inner *BufferIO) error {
+		return inner.WriteNested(func(inner *BufferIO) error {
+			_, err := inner.Write([]byte("deep"))
+			return err
+		})
+	}) == nil)
+
+	c, err := NewCursor(b, 0, b.Size())
+	assert(t, err == nil)
+	outer, err := c.Nested()
+	assert(t, err == nil && outer.Len() == 8)
+	assert(t, c.Offset() == 12)
+	inner, err := outer.Nested()
+	assert(t, err == nil)
+	p, err := inner.Bytes(inner.Remaining())
+	assert(t, err == nil && string(p) == "deep")
+
+	// A length running past the region leaves the cursor alone
+	b.PutUint32LEAt(12, 1000)
+	_, err = c.Nested()
+	assert(t, err == io.ErrUnexpectedEOF)
+	assert(t, c.Offset() == 12)
+
+	sub, err := c.Sub(4)
+	assert(t, err == nil && sub.Len() == 4)
+	assert(t, c.Offset() == 16)
+}
+
+func TestCursorWrite(t *testing.T) {
+	b := NewBufferIOMake(16)
+	c := b.Cursor()
+	assert(t, c.Len() == 16)
+
+	hdr, err := c.Sub(4)
+	assert(t, err == nil)
+	assert(t, hdr.WriteDataBE(uint32(0x01020304)) == nil)
+	assert(t, hdr.WriteDataBE(uint8(5)) == io.ErrShortWrite)
+	n, err := c.Write([]byte("payload"))
+	assert(t, n == 7 && err == nil)
+	assert(t, c.WriteDataLE(uint64(1)) == io.ErrShortWrite)
+	assert(t, c.Offset() == 11)
+	n, err = c.Write([]byte("trailer"))
+	assert(t, n == 5 && err == io.ErrShortWrite)
+	assert(t, string(b.Bytes()[:16]) == "\x01\x02\x03\x04payloadtrail")
+
+	pos, err := c.Seek(-12, io.SeekEnd)
+	assert(t, pos == 4 && err == nil)
+	pos, err = c.Seek(3, io.SeekCurrent)
+	assert(t, pos == 7 && err == nil)
+	_, err = c.Seek(17, io.SeekStart)
+	assert(t, err == ErrOverrun)
+	_, err = c.Seek(-1, io.SeekStart)
+	assert(t, err == ErrOverrun)
+
+	c.Seek(0, io.SeekStart)
+	var v uint32
+	assert(t, c.ReadDataBE(&v) == nil && v == 0x01020304)
+	pos, _ = b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 0)
+}
+
+func TestCursorsConcurrent(t *testing.T) {
+	const sections, size = 8, 64
+	b := NewBufferIOMake(sections * size)
+
+	var wg sync.WaitGroup
+	for i := 0; i < sections; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := NewCursor(b, int64(i*size), size)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for j := 0; j < size/4; j++ {
+				if err := c.WriteDataLE(uint32(i<<8 | j)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			c.SetOffset(0)
+			for j := 0; j < size/4; j++ {
+				v, err := c.Uint32LE()
+				if err != nil || v != uint32(i<<8|j) {
+					t.Errorf("section %d word %d: %x, %v", i, j, v, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,43 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+)
+
+// ReadDataAt decodes data from off like ReadData, without using or
+// moving the offset of the buffer.
+func (b *BufferIO) ReadDataAt(order binary.ByteOrder, off int64, data interface{}) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, err := b.readData(order, off, data)
+	return err
+}
+
+func (b *BufferIO) ReadDataAtLE(off int64, data interface{}) error {
+	return b.ReadDataAt(binary.LittleEndian, off, data)
+}
+
+func (b *BufferIO) ReadDataAtBE(off int64, data interface{}) error {
+	return b.ReadDataAt(binary.BigEndian, off, data)
+}
+
+// WriteDataAt encodes data at off like WriteData, without using or
+// moving the offset of the buffer.
+func (b *BufferIO) WriteDataAt(order binary.ByteOrder, off int64, data interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := b.writeData(order, off, data)
+	return err
+}
+
+func (b *BufferIO) WriteDataAtLE(off int64, data interface{}) error {
+	return b.WriteDataAt(binary.LittleEndian, off, data)
+}
+
+func (b *BufferIO) WriteDataAtBE(off int64, data interface{}) error {
+	return b.WriteDataAt(binary.BigEndian, off, data)
+}
@@ -0,0 +1,58 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+type superblock struct {
+	Magic  uint32
+	Blocks uint64
+	Flags  uint16
+}
+
+func TestDataAt(t *testing.T) {
+	bio := NewBufferIOMake(64)
+	bio.Seek(3, os.SEEK_SET)
+
+	sb := superblock{Magic: 0xef53, Blocks: 1 << 40, Flags: 7}
+	assert(t, bio.WriteDataAtLE(16, &sb) == nil)
+	assert(t, bio.WriteDataAtBE(40, uint32(0x01020304)) == nil)
+	assert(t, binary.LittleEndian.Uint32(bio.Bytes()[16:]) == 0xef53)
+	assert(t, bytes.Equal(bio.Bytes()[40:44], []byte{1, 2, 3, 4}))
+
+	var back superblock
+	assert(t, bio.ReadDataAtLE(16, &back) == nil)
+	assert(t, back == sb)
+	var v uint32
+	assert(t, bio.ReadDataAtBE(40, &v) == nil)
+	assert(t, v == 0x01020304)
+
+	pos, _ := bio.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 3)
+
+	assert(t, bio.ReadDataAtLE(60, &back) != nil)
+	assert(t, bio.ReadDataAtLE(-1, &v) == ErrOverrun)
+	assert(t, bio.ReadDataAtLE(65, &v) == io.EOF)
+	assert(t, bio.WriteDataAtLE(-1, v) == ErrOverrun)
+	assert(t, bio.WriteDataAtLE(64, v) == io.ErrShortWrite)
+}
+
+func TestDataAtBacked(t *testing.T) {
+	mem := NewBufferIOMake(32)
+	sb := superblock{Magic: 1, Blocks: 2, Flags: 3}
+	assert(t, mem.WriteDataAtBE(8, sb) == nil)
+
+	bio := NewBufferIOFrom(bytes.NewReader(mem.Bytes()), 32)
+	var back superblock
+	assert(t, bio.ReadDataAtBE(8, &back) == nil)
+	assert(t, back == sb)
+	assert(t, bio.WriteDataAtBE(0, sb) == ErrReadOnly)
+}
@@ -0,0 +1,144 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrDeadline is returned by reads and writes which run past a deadline.
+// Like the errors of net.Conn, its Timeout method reports true.
+var ErrDeadline error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "deadline exceeded" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// SetDeadline sets both the read and the write deadline, as
+// SetReadDeadline and SetWriteDeadline do.
+func (b *BufferIO) SetDeadline(t time.Time) error {
+	return b.setDeadline(t, true, true)
+}
+
+// SetReadDeadline bounds the reads a buffer backed by a ReaderAt makes
+// through it, with the semantics of net.Conn: reads still running at t,
+// and all reads after it, fail with ErrDeadline, whose
+// Timeout method reports true. A zero t removes the deadline. A read
+// which times out is left to finish in the background into memory of
+// its own, so the slice of the caller is never written afterwards.
+//
+// Other buffers return ErrUnsupported. Memory never blocks, and a page
+// fault on a file mapping cannot be abandoned, so there is nothing a
+// deadline could bound.
+func (b *BufferIO) SetReadDeadline(t time.Time) error {
+	return b.setDeadline(t, true, false)
+}
+
+// SetWriteDeadline bounds the writes a buffer backed by a ReaderAt makes
+// through it as SetReadDeadline bounds reads. A write which times out
+// may still reach the ReaderAt afterwards.
+func (b *BufferIO) SetWriteDeadline(t time.Time) error {
+	return b.setDeadline(t, false, true)
+}
+
+func (b *BufferIO) setDeadline(t time.Time, read, write bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return err
+	}
+	if b.from == nil {
+		return ErrUnsupported
+	}
+	d, ok := b.from.(*deadlineReaderAt)
+	if !ok {
+		d = &deadlineReaderAt{r: b.from}
+		b.from = d
+	}
+	d.mu.Lock()
+	if read {
+		d.read = t
+	}
+	if write {
+		d.write = t
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+// deadlineReaderAt runs the reads and writes of r against deadlines.
+// It stays in place once set, so windows of the buffer share it.
+type deadlineReaderAt struct {
+	r io.ReaderAt
+
+	mu    sync.Mutex
+	read  time.Time
+	write time.Time
+}
+
+func (d *deadlineReaderAt) deadlines() (read, write time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.read, d.write
+}
+
+func (d *deadlineReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	t, _ := d.deadlines()
+	if t.IsZero() {
+		return d.r.ReadAt(p, off)
+	}
+	q := make([]byte, len(p))
+	n, err := within(t, func() (int, error) {
+		return d.r.ReadAt(q, off)
+	})
+	copy(p, q[:n])
+	return n, err
+}
+
+func (d *deadlineReaderAt) WriteAt(p []byte, off int64) (int, error) {
+	w, ok := d.r.(io.WriterAt)
+	if !ok {
+		return 0, ErrReadOnly
+	}
+	_, t := d.deadlines()
+	if t.IsZero() {
+		return w.WriteAt(p, off)
+	}
+	q := append([]byte(nil), p...)
+	return within(t, func() (int, error) {
+		return w.WriteAt(q, off)
+	})
+}
+
+// within runs op until the deadline t, returning ErrDeadline
+// if it is still running then
+func within(t time.Time, op func() (int, error)) (int, error) {
+	wait := t.Sub(time.Now())
+	if wait <= 0 {
+		return 0, ErrDeadline
+	}
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := op()
+		done <- result{n, err}
+	}()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-timer.C:
+		return 0, ErrDeadline
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedReaderAt blocks every read and write until gate is closed
+type gatedReaderAt struct {
+	mu   sync.Mutex
+	data []byte
+	gate chan struct{}
+}
+
+func (g *gatedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return copy(p, g.data[off:]), nil
+}
+
+func (g *gatedReaderAt) WriteAt(p []byte, off int64) (int, error) {
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return copy(g.data[off:], p), nil
+}
+
+func TestDeadlines(t *testing.T) {
+	g := &gatedReaderAt{data: append([]byte(nil), big...), gate: make(chan struct{})}
+	bio := NewBufferIOFrom(g, int64(len(big)))
+	assert(t, bio.SetDeadline(time.Now().Add(20*time.Millisecond)) == nil)
+
+	p := make([]byte, 8)
+	n, err := bio.ReadAt(p, 0)
+	assert(t, n == 0 && err == ErrDeadline)
+	assert(t, err.(net.Error).Timeout())
+	_, err = bio.WriteAt(src, 0)
+	assert(t, err == ErrDeadline)
+
+	// The abandoned read finishes into memory of its own
+	close(g.gate)
+	time.Sleep(10 * time.Millisecond)
+	assert(t, bytes.Equal(p, make([]byte, 8)))
+
+	// Past deadlines fail at once, and clearing them restores the reader
+	_, err = bio.ReadAt(p, 0)
+	assert(t, err == ErrDeadline)
+	assert(t, bio.SetReadDeadline(time.Time{}) == nil)
+	n, err = bio.ReadAt(p, 8)
+	assert(t, n == 8 && err == nil && bytes.Equal(p, big[8:16]))
+	_, err = bio.WriteAt(src, 0)
+	assert(t, err == ErrDeadline)
+
+	// Deadlines in the future leave operations alone
+	assert(t, bio.SetWriteDeadline(time.Now().Add(time.Minute)) == nil)
+	assert(t, bio.SetReadDeadline(time.Now().Add(time.Minute)) == nil)
+	_, err = bio.WriteAt(src, 0)
+	assert(t, err == nil)
+	g.mu.Lock()
+	assert(t, bytes.Equal(g.data[:len(src)], src))
+	g.mu.Unlock()
+	var v uint64
+	assert(t, bio.ReadDataLE(&v) == nil)
+
+	ro := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	assert(t, ro.SetDeadline(time.Now().Add(time.Minute)) == nil)
+	_, err = ro.WriteAt(src, 0)
+	assert(t, err == ErrReadOnly)
+
+	assert(t, NewBufferIOMake(8).SetDeadline(time.Now()) == ErrUnsupported)
+	bio.Close()
+	assert(t, bio.SetDeadline(time.Now()) == ErrClosed)
+}
@@ -0,0 +1,329 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+)
+
+// WriteData and ReadData encode straight into and out of the memory of
+// the buffer. Common fixed size types are handled without reflection;
+// everything else is walked with reflect, still without going through an
+// intermediate buffer. Only the error cases, and buffers which are not
+// backed by memory, fall back to encoding/binary.
+
+// dataSize returns the encoded size of data, or -1 if it has no fixed
+// size encoding.
+func dataSize(data interface{}) int {
+	switch data := data.(type) {
+	case bool, int8, uint8, *bool, *int8, *uint8:
+		return 1
+	case int16, uint16, *int16, *uint16:
+		return 2
+	case int32, uint32, float32, *int32, *uint32, *float32:
+		return 4
+	case int64, uint64, float64, *int64, *uint64, *float64:
+		return 8
+	case []byte:
+		return len(data)
+	case []uint16:
+		return 2 * len(data)
+	case []uint32:
+		return 4 * len(data)
+	case []uint64:
+		return 8 * len(data)
+	}
+	return binary.Size(data)
+}
+
+// putData encodes data, of dataSize len(p), into p.
+func putData(p []byte, order binary.ByteOrder, data interface{}) {
+	switch v := data.(type) {
+	case bool:
+		p[0] = 0
+		if v {
+			p[0] = 1
+		}
+	case *bool:
+		p[0] = 0
+		if *v {
+			p[0] = 1
+		}
+	case int8:
+		p[0] = byte(v)
+	case *int8:
+		p[0] = byte(*v)
+	case uint8:
+		p[0] = v
+	case *uint8:
+		p[0] = *v
+	case int16:
+		order.PutUint16(p, uint16(v))
+	case *int16:
+		order.PutUint16(p, uint16(*v))
+	case uint16:
+		order.PutUint16(p, v)
+	case *uint16:
+		order.PutUint16(p, *v)
+	case int32:
+		order.PutUint32(p, uint32(v))
+	case *int32:
+		order.PutUint32(p, uint32(*v))
+	case uint32:
+		order.PutUint32(p, v)
+	case *uint32:
+		order.PutUint32(p, *v)
+	case float32:
+		order.PutUint32(p, math.Float32bits(v))
+	case *float32:
+		order.PutUint32(p, math.Float32bits(*v))
+	case int64:
+		order.PutUint64(p, uint64(v))
+	case *int64:
+		order.PutUint64(p, uint64(*v))
+	case uint64:
+		order.PutUint64(p, v)
+	case *uint64:
+		order.PutUint64(p, *v)
+	case float64:
+		order.PutUint64(p, math.Float64bits(v))
+	case *float64:
+		order.PutUint64(p, math.Float64bits(*v))
+	case []byte:
+		copy(p, v)
+	case []uint16:
+		for i, x := range v {
+			order.PutUint16(p[2*i:], x)
+		}
+	case []uint32:
+		for i, x := range v {
+			order.PutUint32(p[4*i:], x)
+		}
+	case []uint64:
+		for i, x := range v {
+			order.PutUint64(p[8*i:], x)
+		}
+	default:
+		putValue(p, order, reflect.Indirect(reflect.ValueOf(data)))
+	}
+}
+
+// getData decodes p, of dataSize len(p), into data. It returns false
+// when data cannot be decoded into, such as a value which is not a
+// pointer.
+func getData(p []byte, order binary.ByteOrder, data interface{}) bool {
+	switch v := data.(type) {
+	case *bool:
+		*v = p[0] != 0
+	case *int8:
+		*v = int8(p[0])
+	case *uint8:
+		*v = p[0]
+	case *int16:
+		*v = int16(order.Uint16(p))
+	case *uint16:
+		*v = order.Uint16(p)
+	case *int32:
+		*v = int32(order.Uint32(p))
+	case *uint32:
+		*v = order.Uint32(p)
+	case *float32:
+		*v = math.Float32frombits(order.Uint32(p))
+	case *int64:
+		*v = int64(order.Uint64(p))
+	case *uint64:
+		*v = order.Uint64(p)
+	case *float64:
+		*v = math.Float64frombits(order.Uint64(p))
+	case []byte:
+		copy(v, p)
+	case []uint16:
+		for i := range v {
+			v[i] = order.Uint16(p[2*i:])
+		}
+	case []uint32:
+		for i := range v {
+			v[i] = order.Uint32(p[4*i:])
+		}
+	case []uint64:
+		for i := range v {
+			v[i] = order.Uint64(p[8*i:])
+		}
+	default:
+		rv := reflect.ValueOf(data)
+		switch rv.Kind() {
+		case reflect.Ptr:
+			getValue(p, order, rv.Elem())
+		case reflect.Slice:
+			getValue(p, order, rv)
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// putValue encodes v into p in the layout of encoding/binary and returns
+// the number of bytes used.
+func putValue(p []byte, order binary.ByteOrder, v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return copy(p, v.Bytes())
+		}
+		n := 0
+		for i := 0; i < v.Len(); i++ {
+			n += putValue(p[n:], order, v.Index(i))
+		}
+		return n
+
+	case reflect.Struct:
+		t := v.Type()
+		n := 0
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).Name == "_" {
+				size := binary.Size(reflect.Zero(t.Field(i).Type).Interface())
+				zero(p[n : n+size])
+				n += size
+				continue
+			}
+			n += putValue(p[n:], order, v.Field(i))
+		}
+		return n
+
+	case reflect.Bool:
+		p[0] = 0
+		if v.Bool() {
+			p[0] = 1
+		}
+		return 1
+
+	case reflect.Int8:
+		p[0] = byte(v.Int())
+		return 1
+	case reflect.Int16:
+		order.PutUint16(p, uint16(v.Int()))
+		return 2
+	case reflect.Int32:
+		order.PutUint32(p, uint32(v.Int()))
+		return 4
+	case reflect.Int64:
+		order.PutUint64(p, uint64(v.Int()))
+		return 8
+
+	case reflect.Uint8:
+		p[0] = byte(v.Uint())
+		return 1
+	case reflect.Uint16:
+		order.PutUint16(p, uint16(v.Uint()))
+		return 2
+	case reflect.Uint32:
+		order.PutUint32(p, uint32(v.Uint()))
+		return 4
+	case reflect.Uint64:
+		order.PutUint64(p, v.Uint())
+		return 8
+
+	case reflect.Float32:
+		order.PutUint32(p, math.Float32bits(float32(v.Float())))
+		return 4
+	case reflect.Float64:
+		order.PutUint64(p, math.Float64bits(v.Float()))
+		return 8
+
+	case reflect.Complex64:
+		x := v.Complex()
+		order.PutUint32(p, math.Float32bits(float32(real(x))))
+		order.PutUint32(p[4:], math.Float32bits(float32(imag(x))))
+		return 8
+	case reflect.Complex128:
+		x := v.Complex()
+		order.PutUint64(p, math.Float64bits(real(x)))
+		order.PutUint64(p[8:], math.Float64bits(imag(x)))
+		return 16
+	}
+	panic("bufferio: no fixed size encoding for " + v.Type().String())
+}
+
+// getValue decodes p into v in the layout of encoding/binary and returns
+// the number of bytes used. Blank struct fields are skipped.
+func getValue(p []byte, order binary.ByteOrder, v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return copy(v.Bytes(), p)
+		}
+		n := 0
+		for i := 0; i < v.Len(); i++ {
+			n += getValue(p[n:], order, v.Index(i))
+		}
+		return n
+
+	case reflect.Struct:
+		t := v.Type()
+		n := 0
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).Name == "_" {
+				n += binary.Size(reflect.Zero(t.Field(i).Type).Interface())
+				continue
+			}
+			n += getValue(p[n:], order, v.Field(i))
+		}
+		return n
+
+	case reflect.Bool:
+		v.SetBool(p[0] != 0)
+		return 1
+
+	case reflect.Int8:
+		v.SetInt(int64(int8(p[0])))
+		return 1
+	case reflect.Int16:
+		v.SetInt(int64(int16(order.Uint16(p))))
+		return 2
+	case reflect.Int32:
+		v.SetInt(int64(int32(order.Uint32(p))))
+		return 4
+	case reflect.Int64:
+		v.SetInt(int64(order.Uint64(p)))
+		return 8
+
+	case reflect.Uint8:
+		v.SetUint(uint64(p[0]))
+		return 1
+	case reflect.Uint16:
+		v.SetUint(uint64(order.Uint16(p)))
+		return 2
+	case reflect.Uint32:
+		v.SetUint(uint64(order.Uint32(p)))
+		return 4
+	case reflect.Uint64:
+		v.SetUint(order.Uint64(p))
+		return 8
+
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(order.Uint32(p))))
+		return 4
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(order.Uint64(p)))
+		return 8
+
+	case reflect.Complex64:
+		v.SetComplex(complex(
+			float64(math.Float32frombits(order.Uint32(p))),
+			float64(math.Float32frombits(order.Uint32(p[4:]))),
+		))
+		return 8
+	case reflect.Complex128:
+		v.SetComplex(complex(
+			math.Float64frombits(order.Uint64(p)),
+			math.Float64frombits(order.Uint64(p[8:])),
+		))
+		return 16
+	}
+	panic("bufferio: no fixed size encoding for " + v.Type().String())
+}
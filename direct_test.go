@@ -0,0 +1,94 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+type directStruct struct {
+	A bool
+	B int8
+	C uint16
+	_ [3]byte
+	D int32
+	E [2]uint64
+	F float32
+	G float64
+	H complex64
+	I complex128
+	J struct {
+		K int16
+		L [2]bool
+	}
+}
+
+func directValues() []interface{} {
+	s := directStruct{A: true, B: -2, C: 0x1234, D: -5, E: [2]uint64{1, 2}, F: 1.5, G: -2.25, H: 1 + 2i, I: 3 - 4i}
+	s.J.K = -300
+	s.J.L[1] = true
+	return []interface{}{
+		true, int8(-1), uint8(2), int16(-3), uint16(4), int32(-5), uint32(6),
+		int64(-7), uint64(8), float32(9.5), float64(-10.5),
+		[]byte{1, 2, 3}, []uint16{4, 5}, []uint32{6, 7}, []uint64{8, 9},
+		[]int32{-1, 2}, [3]uint16{1, 2, 3}, &s, s,
+	}
+}
+
+func TestWriteDataDirect(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, v := range directValues() {
+			var want bytes.Buffer
+			assert(t, binary.Write(&want, order, v) == nil)
+
+			bio := NewBufferIOMake(want.Len() + 3)
+			bio.Seek(1, 0)
+			assert(t, bio.WriteData(order, v) == nil)
+			assert(t, bytes.Equal(bio.Bytes()[1:1+want.Len()], want.Bytes()))
+			pos, _ := bio.Seek(0, 1)
+			assert(t, pos == int64(1+want.Len()))
+
+			// Read it back into a fresh value of the same type
+			bio.Seek(1, 0)
+			rv := reflect.ValueOf(v)
+			var out reflect.Value
+			if rv.Kind() == reflect.Slice {
+				out = reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+				assert(t, bio.ReadData(order, out.Interface()) == nil)
+			} else {
+				out = reflect.New(reflect.Indirect(rv).Type())
+				assert(t, bio.ReadData(order, out.Interface()) == nil)
+				out = out.Elem()
+			}
+			assert(t, reflect.DeepEqual(out.Interface(), reflect.Indirect(rv).Interface()))
+		}
+	}
+}
+
+func TestReadDataDirectErrors(t *testing.T) {
+	bio := NewBufferIO([]byte{1, 2, 3})
+	var v uint32
+	assert(t, bio.ReadDataLE(&v) != nil)
+	assert(t, bio.ReadDataLE(uint16(0)) != nil)
+	assert(t, bio.WriteDataLE(int(1)) != nil)
+}
+
+func TestDataAllocations(t *testing.T) {
+	var s directStruct
+	var v uint32
+	bio := NewBufferIOMake(4096)
+	allocs := testing.AllocsPerRun(100, func() {
+		bio.Reset()
+		bio.WriteDataLE(uint32(1))
+		bio.WriteDataLE(&s)
+		bio.Reset()
+		bio.ReadDataLE(&v)
+		bio.ReadDataLE(&s)
+	})
+	assert(t, allocs == 0)
+}
@@ -0,0 +1,216 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Encoder writes values at the offset of a BufferIO in a fixed byte
+// order. After the first failure every call is a no-op, so format code
+// can make a run of calls and check Err once at the end. Each value is
+// written whole or not at all.
+type Encoder struct {
+	b       *BufferIO
+	order   binary.ByteOrder
+	scratch [binary.MaxVarintLen64]byte
+	err     error
+}
+
+func NewEncoder(b *BufferIO, order binary.ByteOrder) *Encoder {
+	return &Encoder{b: b, order: order}
+}
+
+// Err returns the first error encountered while encoding.
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+func (e *Encoder) write(p []byte) {
+	if e.err == nil {
+		e.err = e.b.writeWhole(p)
+	}
+}
+
+func (e *Encoder) Uint8(v uint8) {
+	e.scratch[0] = v
+	e.write(e.scratch[:1])
+}
+
+func (e *Encoder) Uint16(v uint16) {
+	e.order.PutUint16(e.scratch[:], v)
+	e.write(e.scratch[:2])
+}
+
+func (e *Encoder) Uint32(v uint32) {
+	e.order.PutUint32(e.scratch[:], v)
+	e.write(e.scratch[:4])
+}
+
+func (e *Encoder) Uint64(v uint64) {
+	e.order.PutUint64(e.scratch[:], v)
+	e.write(e.scratch[:8])
+}
+
+func (e *Encoder) Int8(v int8)   { e.Uint8(uint8(v)) }
+func (e *Encoder) Int16(v int16) { e.Uint16(uint16(v)) }
+func (e *Encoder) Int32(v int32) { e.Uint32(uint32(v)) }
+func (e *Encoder) Int64(v int64) { e.Uint64(uint64(v)) }
+
+func (e *Encoder) Float32(v float32) { e.Uint32(math.Float32bits(v)) }
+func (e *Encoder) Float64(v float64) { e.Uint64(math.Float64bits(v)) }
+
+// Bool writes 1 for true and 0 for false.
+func (e *Encoder) Bool(v bool) {
+	var c uint8
+	if v {
+		c = 1
+	}
+	e.Uint8(c)
+}
+
+func (e *Encoder) Uvarint(v uint64) {
+	e.write(e.scratch[:binary.PutUvarint(e.scratch[:], v)])
+}
+
+func (e *Encoder) Varint(v int64) {
+	e.write(e.scratch[:binary.PutVarint(e.scratch[:], v)])
+}
+
+// Bytes writes p as is, with no length.
+func (e *Encoder) Bytes(p []byte) {
+	e.write(p)
+}
+
+// String writes s as is, with no length.
+func (e *Encoder) String(s string) {
+	e.write([]byte(s))
+}
+
+// Data writes v as WriteData does, in the order of the encoder.
+func (e *Encoder) Data(v interface{}) {
+	if e.err == nil {
+		e.err = e.b.WriteData(e.order, v)
+	}
+}
+
+// Decoder reads values from the offset of a BufferIO in a fixed byte
+// order, the counterpart of Encoder. After the first failure every call
+// returns the zero value and leaves the offset alone, and Err returns
+// the failure.
+type Decoder struct {
+	b       *BufferIO
+	order   binary.ByteOrder
+	scratch [8]byte
+	err     error
+}
+
+func NewDecoder(b *BufferIO, order binary.ByteOrder) *Decoder {
+	return &Decoder{b: b, order: order}
+}
+
+// Err returns the first error encountered while decoding.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// read fills p from the offset and moves past it, clearing p on failure
+func (d *Decoder) read(p []byte) []byte {
+	if d.err == nil {
+		d.err = d.b.readNext(p)
+	}
+	if d.err != nil {
+		for i := range p {
+			p[i] = 0
+		}
+	}
+	return p
+}
+
+func (d *Decoder) Uint8() uint8 {
+	return d.read(d.scratch[:1])[0]
+}
+
+func (d *Decoder) Uint16() uint16 {
+	return d.order.Uint16(d.read(d.scratch[:2]))
+}
+
+func (d *Decoder) Uint32() uint32 {
+	return d.order.Uint32(d.read(d.scratch[:4]))
+}
+
+func (d *Decoder) Uint64() uint64 {
+	return d.order.Uint64(d.read(d.scratch[:8]))
+}
+
+func (d *Decoder) Int8() int8   { return int8(d.Uint8()) }
+func (d *Decoder) Int16() int16 { return int16(d.Uint16()) }
+func (d *Decoder) Int32() int32 { return int32(d.Uint32()) }
+func (d *Decoder) Int64() int64 { return int64(d.Uint64()) }
+
+func (d *Decoder) Float32() float32 { return math.Float32frombits(d.Uint32()) }
+func (d *Decoder) Float64() float64 { return math.Float64frombits(d.Uint64()) }
+
+// Bool reads a byte, true unless it is 0.
+func (d *Decoder) Bool() bool {
+	return d.Uint8() != 0
+}
+
+func (d *Decoder) Uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := d.b.ReadUvarint()
+	d.err = err
+	return v
+}
+
+func (d *Decoder) Varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := d.b.ReadVarint()
+	d.err = err
+	return v
+}
+
+// Bytes reads n bytes into a new slice, nil after a failure.
+func (d *Decoder) Bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	p, err := d.b.ReadBytesFixed(n)
+	if err != nil {
+		d.err = err
+		return nil
+	}
+	return p
+}
+
+// String reads n bytes as a string.
+func (d *Decoder) String(n int) string {
+	return string(d.Bytes(n))
+}
+
+// Data reads into v as ReadData does, in the order of the decoder.
+func (d *Decoder) Data(v interface{}) {
+	if d.err == nil {
+		d.err = d.b.ReadData(d.order, v)
+	}
+}
+
+// readNext fills p from the offset and moves past it, leaving the
+// offset alone if p cannot be filled
+func (b *BufferIO) readNext(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	if err := b.readWhole(p, b.off, false); err != nil {
+		return err
+	}
+	b.off += int64(len(p))
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestEncoderDecoder(t *testing.T) {
+	b := NewBufferIOMake(64)
+	enc := NewEncoder(b, binary.BigEndian)
+	enc.Uint8(1)
+	enc.Uint16(0x0203)
+	enc.Uint32(0x04050607)
+	enc.Int64(-2)
+	enc.Float32(1.5)
+	enc.Bool(true)
+	enc.Uvarint(300)
+	enc.Varint(-3)
+	enc.Bytes([]byte{0xaa, 0xbb})
+	enc.String("hi")
+	enc.Data(struct{ A, B uint16 }{1, 2})
+	assert(t, enc.Err() == nil)
+	assert(t, bytes.Equal(b.Bytes()[:7], []byte{1, 2, 3, 4, 5, 6, 7}))
+
+	b.Seek(0, 0)
+	dec := NewDecoder(b, binary.BigEndian)
+	assert(t, dec.Uint8() == 1)
+	assert(t, dec.Uint16() == 0x0203)
+	assert(t, dec.Uint32() == 0x04050607)
+	assert(t, dec.Int64() == -2)
+	assert(t, dec.Float32() == 1.5)
+	assert(t, dec.Bool())
+	assert(t, dec.Uvarint() == 300)
+	assert(t, dec.Varint() == -3)
+	assert(t, bytes.Equal(dec.Bytes(2), []byte{0xaa, 0xbb}))
+	assert(t, dec.String(2) == "hi")
+	var v struct{ A, B uint16 }
+	dec.Data(&v)
+	assert(t, dec.Err() == nil)
+	assert(t, v.A == 1 && v.B == 2)
+}
+
+func TestEncoderSticky(t *testing.T) {
+	b := NewBufferIOMake(6)
+	enc := NewEncoder(b, binary.LittleEndian)
+
+	// The failed write leaves nothing behind and stops later writes
+	enc.Uint32(1)
+	enc.Uint32(2)
+	enc.Uint8(3)
+	assert(t, enc.Err() == io.ErrShortWrite)
+	off, _ := b.Seek(0, 1)
+	assert(t, off == 4)
+	assert(t, b.Bytes()[4] == 0)
+
+	b.Seek(0, 0)
+	dec := NewDecoder(b, binary.LittleEndian)
+	assert(t, dec.Uint32() == 1)
+	assert(t, dec.Uint32() == 0)
+	assert(t, dec.Uint8() == 0)
+	assert(t, dec.Bytes(1) == nil)
+	assert(t, dec.Uvarint() == 0)
+	assert(t, dec.Err() == io.ErrUnexpectedEOF)
+	off, _ = b.Seek(0, 1)
+	assert(t, off == 4)
+
+	// At the end of the buffer the error is io.EOF
+	b.Seek(0, 2)
+	dec = NewDecoder(b, binary.LittleEndian)
+	dec.Uint16()
+	assert(t, dec.Err() == io.EOF)
+}
@@ -0,0 +1,109 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+	"os"
+)
+
+// Blocks of this size which only hold zeros are exported to files as
+// holes.
+const sparseBlock = 4096
+
+// Export writes the contents of the buffer to w. When w is an *os.File
+// the data is written at the current file offset, and blocks of zeros
+// are skipped over, or punched out where the file already has data, so
+// that a mostly empty buffer produces a sparse file. The file offset is
+// left at the end of the exported data.
+func (b *BufferIO) Export(w io.Writer) (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.usable(); err != nil {
+		return 0, err
+	}
+
+	if b.from != nil {
+		return io.Copy(w, io.NewSectionReader(b.from, 0, b.fromSize))
+	}
+	if f, ok := w.(*os.File); ok {
+		return b.exportFile(f)
+	}
+	n, err := w.Write(b.buf)
+	return int64(n), err
+}
+
+// Import fills the buffer from r, starting at its beginning, until the
+// buffer is full or r is exhausted. It returns the number of bytes read.
+func (b *BufferIO) Import(r io.Reader) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.memory(); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(r, b.buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return int64(n), err
+}
+
+func isZero(p []byte) bool {
+	for _, c := range p {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BufferIO) exportFile(f *os.File) (int64, error) {
+	start, err := f.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	existing := fi.Size()
+
+	for off := int64(0); off < b.size(); off += sparseBlock {
+		end := off + sparseBlock
+		if end > b.size() {
+			end = b.size()
+		}
+		block := b.buf[off:end]
+		pos := start + off
+
+		if isZero(block) {
+			if pos >= existing {
+				continue
+			}
+			// Old file data has to be cleared
+			if pos+int64(len(block)) > existing {
+				block = block[:existing-pos]
+			}
+			if punchHole(f, pos, int64(len(block))) == nil {
+				continue
+			}
+		}
+		if _, err := f.WriteAt(block, pos); err != nil {
+			return off, err
+		}
+	}
+
+	end := start + b.size()
+	if end > existing {
+		if err := f.Truncate(end); err != nil {
+			return b.size(), err
+		}
+	}
+	if _, err := f.Seek(end, os.SEEK_SET); err != nil {
+		return b.size(), err
+	}
+	return b.size(), nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestExportFileSparse(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+
+	bio := sparseBuffer()
+	_, err := bio.Export(f)
+	assert(t, err == nil)
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(f.Fd()), &st); err != nil {
+		t.Fatal(err)
+	}
+	// Only a handful of blocks hold data
+	if st.Blocks*512 > 8*sparseBlock {
+		t.Errorf("file uses %d bytes on disk for %d bytes of data",
+			st.Blocks*512, bio.Size())
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func tempFile(t *testing.T) *os.File {
+	f, err := ioutil.TempFile("", "bufferio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func removeFile(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+func sparseBuffer() *BufferIO {
+	bio := NewBufferIOMake(64*sparseBlock + 100)
+	bio.WriteAt(src, 0)
+	bio.WriteAt(src, 20*sparseBlock+7)
+	bio.WriteAt(src, bio.Size()-int64(len(src)))
+	return bio
+}
+
+func TestExportWriter(t *testing.T) {
+	bio := NewBufferIO(big)
+	var out bytes.Buffer
+	n, err := bio.Export(&out)
+	assert(t, err == nil)
+	assert(t, n == int64(len(big)))
+	assert(t, bytes.Equal(out.Bytes(), big))
+}
+
+func TestImport(t *testing.T) {
+	bio := NewBufferIOMake(10)
+	n, err := bio.Import(bytes.NewReader(src))
+	assert(t, n == int64(len(src)))
+	assert(t, err == nil)
+	assert(t, bytes.Equal(bio.buf[:len(src)], src))
+
+	n, err = bio.Import(bytes.NewReader(big))
+	assert(t, n == 10)
+	assert(t, err == nil)
+	assert(t, bytes.Equal(bio.buf, big[:10]))
+}
+
+func TestExportFile(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+
+	// Exported after a header already in the file
+	f.Write([]byte("header"))
+
+	bio := sparseBuffer()
+	n, err := bio.Export(f)
+	assert(t, err == nil)
+	assert(t, n == bio.Size())
+
+	pos, _ := f.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 6+bio.Size())
+
+	data, err := ioutil.ReadFile(f.Name())
+	assert(t, err == nil)
+	assert(t, bytes.Equal(data[:6], []byte("header")))
+	assert(t, bytes.Equal(data[6:], bio.Bytes()))
+}
+
+func TestExportFileOverwrite(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+
+	// Old data under the holes must not survive
+	junk := bytes.Repeat([]byte{0xff}, 80*sparseBlock)
+	f.Write(junk)
+	f.Seek(0, os.SEEK_SET)
+
+	bio := sparseBuffer()
+	_, err := bio.Export(f)
+	assert(t, err == nil)
+
+	data, err := ioutil.ReadFile(f.Name())
+	assert(t, err == nil)
+	assert(t, len(data) == len(junk))
+	assert(t, bytes.Equal(data[:bio.Size()], bio.Bytes()))
+	assert(t, bytes.Equal(data[bio.Size():], junk[bio.Size():]))
+}
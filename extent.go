@@ -0,0 +1,231 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrNoSpace    = errors.New("no free extent is large enough")
+	ErrBadFree    = errors.New("offset is not an allocated extent")
+	ErrNoExtents  = errors.New("buffer does not hold an extent allocator")
+	ErrInvalidFit = errors.New("invalid fit policy")
+)
+
+// Fit selects the free extent an allocation is carved from.
+type Fit uint32
+
+const (
+	FirstFit Fit = iota // the lowest extent which is large enough
+	BestFit             // the smallest extent which is large enough
+)
+
+// The allocator keeps a little endian header at the start of the buffer
+//
+//	[magic uint32][fit uint32][first free extent uint64]
+//
+// Free extents form a list sorted by offset, each starting with
+// [size uint64][next uint64]; a next of 0 ends the list. Allocated
+// extents start with [size uint64] and the caller gets the offset of the
+// bytes after it. Sizes include the 8 byte header and are multiples of 8.
+const (
+	extentMagic  = 0x54584542
+	extentHeader = 16
+	extentUnit   = 8
+	extentMin    = 16
+)
+
+// ExtentAllocator manages variable sized allocations within a buffer,
+// keeping all of its state in the buffer. It is not safe for concurrent
+// use.
+type ExtentAllocator struct {
+	buf []byte
+	fit Fit
+}
+
+// NewExtentAllocator formats b as a single free extent.
+func NewExtentAllocator(b *BufferIO, fit Fit) (*ExtentAllocator, error) {
+	if fit != FirstFit && fit != BestFit {
+		return nil, ErrInvalidFit
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	buf := b.buf[:len(b.buf)&^(extentUnit-1)]
+	if len(buf) < extentHeader+extentMin {
+		return nil, ErrTooSmall
+	}
+	a := &ExtentAllocator{buf: buf, fit: fit}
+	binary.LittleEndian.PutUint32(buf[4:], uint32(fit))
+	a.setNext(0, extentHeader)
+	a.setSize(extentHeader, int64(len(buf)-extentHeader))
+	a.setNext(extentHeader, 0)
+	binary.LittleEndian.PutUint32(buf, extentMagic)
+	return a, nil
+}
+
+// OpenExtentAllocator attaches to an allocator previously formatted in b.
+// The free list is checked first, and one which strays outside the
+// buffer, overlaps itself or loops gives ErrCorrupt.
+func OpenExtentAllocator(b *BufferIO) (*ExtentAllocator, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	buf := b.buf[:len(b.buf)&^(extentUnit-1)]
+	if len(buf) < extentHeader+extentMin || binary.LittleEndian.Uint32(buf) != extentMagic {
+		return nil, ErrNoExtents
+	}
+	fit := Fit(binary.LittleEndian.Uint32(buf[4:]))
+	if fit != FirstFit && fit != BestFit {
+		return nil, ErrNoExtents
+	}
+	a := &ExtentAllocator{buf: buf, fit: fit}
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// check walks the free list, requiring every extent to be aligned, fit
+// in the buffer and start past the end of the one before it. Offsets
+// therefore only grow, so a list which loops fails too.
+func (a *ExtentAllocator) check() error {
+	limit := int64(len(a.buf))
+	end := int64(extentHeader)
+	for cur := a.next(0); cur != 0; cur = a.next(cur) {
+		if cur < end || cur%extentUnit != 0 || cur > limit-extentMin {
+			return ErrCorrupt
+		}
+		size := a.size(cur)
+		if size < extentMin || size%extentUnit != 0 || size > limit-cur {
+			return ErrCorrupt
+		}
+		end = cur + size
+	}
+	return nil
+}
+
+func (a *ExtentAllocator) size(at int64) int64 {
+	return int64(binary.LittleEndian.Uint64(a.buf[at:]))
+}
+
+func (a *ExtentAllocator) setSize(at, size int64) {
+	binary.LittleEndian.PutUint64(a.buf[at:], uint64(size))
+}
+
+// next returns the free extent after the one at at. The list head lives
+// in the header, so next(0) is the first free extent.
+func (a *ExtentAllocator) next(at int64) int64 {
+	return int64(binary.LittleEndian.Uint64(a.buf[at+8:]))
+}
+
+func (a *ExtentAllocator) setNext(at, next int64) {
+	binary.LittleEndian.PutUint64(a.buf[at+8:], uint64(next))
+}
+
+// Alloc returns the offset of n bytes of unused space.
+func (a *ExtentAllocator) Alloc(n int64) (int64, error) {
+	if n < 0 || n > int64(len(a.buf)) {
+		return 0, ErrNoSpace
+	}
+	need := (n + extentUnit + extentUnit - 1) &^ (extentUnit - 1)
+	if need < extentMin {
+		need = extentMin
+	}
+
+	var prev, at int64 = -1, -1
+	for p, cur := int64(0), a.next(0); cur != 0; p, cur = cur, a.next(cur) {
+		size := a.size(cur)
+		if size < need || (at >= 0 && size >= a.size(at)) {
+			continue
+		}
+		prev, at = p, cur
+		if a.fit == FirstFit || size == need {
+			break
+		}
+	}
+	if at < 0 {
+		return 0, ErrNoSpace
+	}
+
+	size := a.size(at)
+	if size-need >= extentMin {
+		rest := at + need
+		a.setSize(rest, size-need)
+		a.setNext(rest, a.next(at))
+		a.setNext(prev, rest)
+	} else {
+		need = size
+		a.setNext(prev, a.next(at))
+	}
+	a.setSize(at, need)
+	return at + extentUnit, nil
+}
+
+// Free returns the extent allocated at off, merging it with free
+// neighbours. Offsets which were not returned by Alloc, or have already
+// been freed, give ErrBadFree.
+func (a *ExtentAllocator) Free(off int64) error {
+	at := off - extentUnit
+	if at < extentHeader || at%extentUnit != 0 || at > int64(len(a.buf))-extentMin {
+		return ErrBadFree
+	}
+	size := a.size(at)
+	if size < extentMin || size%extentUnit != 0 || size > int64(len(a.buf))-at {
+		return ErrBadFree
+	}
+
+	prev, cur := int64(0), a.next(0)
+	for cur != 0 && cur < at {
+		prev, cur = cur, a.next(cur)
+	}
+	if (prev != 0 && prev+a.size(prev) > at) || (cur != 0 && at+size > cur) {
+		return ErrBadFree
+	}
+
+	a.setNext(at, cur)
+	a.setNext(prev, at)
+	if cur != 0 && at+size == cur {
+		size += a.size(cur)
+		a.setSize(at, size)
+		a.setNext(at, a.next(cur))
+	}
+	if prev != 0 && prev+a.size(prev) == at {
+		a.setSize(prev, a.size(prev)+size)
+		a.setNext(prev, a.next(at))
+	}
+	return nil
+}
+
+// Available returns the total size of the free extents and the size of
+// the largest, the most a single Alloc can get being 8 bytes less.
+func (a *ExtentAllocator) Available() (total, largest int64) {
+	for cur := a.next(0); cur != 0; cur = a.next(cur) {
+		size := a.size(cur)
+		total += size
+		if size > largest {
+			largest = size
+		}
+	}
+	return total, largest
+}
+
+// Extents returns the number of free extents.
+func (a *ExtentAllocator) Extents() int {
+	n := 0
+	for cur := a.next(0); cur != 0; cur = a.next(cur) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+)
+
+func TestExtentAllocator(t *testing.T) {
+	b := NewBufferIOMake(1024 + extentHeader)
+	a, err := NewExtentAllocator(b, FirstFit)
+	assert(t, err == nil)
+	total, largest := a.Available()
+	assert(t, total == 1024 && largest == 1024)
+
+	x, err := a.Alloc(10)
+	assert(t, err == nil)
+	assert(t, x == extentHeader+8)
+	y, _ := a.Alloc(100)
+	z, _ := a.Alloc(1)
+	assert(t, y == x+24)
+	assert(t, z == y+112)
+	total, _ = a.Available()
+	assert(t, total == 1024-24-112-16)
+
+	// Allocations do not overlap
+	b.WriteAt(make([]byte, 100), y)
+	assert(t, b.EqualAt(x-8, []byte{24, 0, 0, 0, 0, 0, 0, 0}))
+
+	// Freeing coalesces neighbours back into one extent
+	assert(t, a.Free(y) == nil)
+	assert(t, a.Extents() == 2)
+	assert(t, a.Free(x) == nil)
+	assert(t, a.Extents() == 2)
+	assert(t, a.Free(z) == nil)
+	assert(t, a.Extents() == 1)
+	total, largest = a.Available()
+	assert(t, total == 1024 && largest == 1024)
+
+	_, err = a.Alloc(1024)
+	assert(t, err == ErrNoSpace)
+	w, err := a.Alloc(1016)
+	assert(t, err == nil)
+	assert(t, a.Extents() == 0)
+	_, err = a.Alloc(1)
+	assert(t, err == ErrNoSpace)
+	assert(t, a.Free(w) == nil)
+}
+
+func TestExtentAllocatorFit(t *testing.T) {
+	for _, fit := range []Fit{FirstFit, BestFit} {
+		a, _ := NewExtentAllocator(NewBufferIOMake(4096), fit)
+		var offs []int64
+		for _, n := range []int64{200, 8, 64, 8, 8, 8} {
+			off, err := a.Alloc(n)
+			assert(t, err == nil)
+			offs = append(offs, off)
+		}
+		// Leave holes of 208 and 72 bytes
+		a.Free(offs[0])
+		a.Free(offs[2])
+
+		off, err := a.Alloc(60)
+		assert(t, err == nil)
+		if fit == FirstFit {
+			assert(t, off == offs[0])
+		} else {
+			assert(t, off == offs[2])
+		}
+	}
+
+	_, err := NewExtentAllocator(NewBufferIOMake(4096), Fit(9))
+	assert(t, err == ErrInvalidFit)
+}
+
+func TestExtentAllocatorBadFree(t *testing.T) {
+	a, _ := NewExtentAllocator(NewBufferIOMake(1024), FirstFit)
+	x, _ := a.Alloc(32)
+	y, _ := a.Alloc(32)
+
+	assert(t, a.Free(x) == nil)
+	assert(t, a.Free(x) == ErrBadFree)
+	assert(t, a.Free(y+4) == ErrBadFree)
+	assert(t, a.Free(0) == ErrBadFree)
+	assert(t, a.Free(4096) == ErrBadFree)
+	assert(t, a.Free(y) == nil)
+	assert(t, a.Extents() == 1)
+}
+
+func TestExtentAllocatorOpen(t *testing.T) {
+	b := NewBufferIOMake(1024)
+	_, err := OpenExtentAllocator(b)
+	assert(t, err == ErrNoExtents)
+
+	a, _ := NewExtentAllocator(b, BestFit)
+	x, _ := a.Alloc(100)
+	a.Alloc(100)
+	a.Free(x)
+
+	c, err := OpenExtentAllocator(NewBufferIO(append([]byte(nil), b.Bytes()...)))
+	assert(t, err == nil)
+	assert(t, c.fit == BestFit)
+	assert(t, c.Extents() == 2)
+	off, err := c.Alloc(100)
+	assert(t, err == nil)
+	assert(t, off == x)
+
+	_, err = NewExtentAllocator(NewBufferIOMake(24), FirstFit)
+	assert(t, err == ErrTooSmall)
+}
+
+func TestExtentAllocatorOpenCorrupt(t *testing.T) {
+	b := NewBufferIOMake(1024)
+	a, _ := NewExtentAllocator(b, FirstFit)
+	x, _ := a.Alloc(100)
+	a.Alloc(100)
+	a.Free(x)
+	first, second := a.next(0), a.next(a.next(0))
+
+	for _, corrupt := range []func(c *ExtentAllocator){
+		func(c *ExtentAllocator) { c.setNext(second, first) },         // loop
+		func(c *ExtentAllocator) { c.setNext(first, first) },          // self loop
+		func(c *ExtentAllocator) { c.setNext(0, 1<<40) },              // outside the buffer
+		func(c *ExtentAllocator) { c.setNext(0, first+4) },            // misaligned
+		func(c *ExtentAllocator) { c.setNext(0, 8) },                  // inside the header
+		func(c *ExtentAllocator) { c.setSize(first, 1<<40) },          // too large
+		func(c *ExtentAllocator) { c.setSize(second, 8) },             // too small
+		func(c *ExtentAllocator) { c.setSize(first, second-first+8) }, // overlapping
+	} {
+		c := &ExtentAllocator{buf: append([]byte(nil), b.Bytes()...)}
+		corrupt(c)
+		_, err := OpenExtentAllocator(NewBufferIO(c.buf))
+		assert(t, err == ErrCorrupt)
+	}
+
+	// An exhausted list is valid
+	c := &ExtentAllocator{buf: append([]byte(nil), b.Bytes()...)}
+	c.setNext(0, 0)
+	_, err := OpenExtentAllocator(NewBufferIO(c.buf))
+	assert(t, err == nil)
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrCanceled = errors.New("transfer canceled")
+
+// fillChunk is the amount FillFrom reads from its source at a time
+const fillChunk = 64 << 10
+
+// FillFrom copies n bytes at srcOff of r into the buffer at dstOff. The
+// destination range must lie within the buffer, unless the buffer is
+// growable, in which case it grows to hold it. A source which ends
+// early returns io.ErrUnexpectedEOF.
+func (b *BufferIO) FillFrom(r io.ReaderAt, srcOff, dstOff, n int64) error {
+	return b.FillFromFunc(r, srcOff, dstOff, n, nil)
+}
+
+// FillFromFunc is FillFrom which calls progress, unless it is nil, with
+// the number of bytes copied so far after each chunk. Returning false
+// stops the copy with ErrCanceled. The source is read without holding
+// the lock of the buffer, so other methods, and r itself if it is a
+// buffer, may be used meanwhile. On error the chunks before the failure
+// have been copied.
+func (b *BufferIO) FillFromFunc(r io.ReaderAt, srcOff, dstOff, n int64, progress func(done int64) bool) error {
+	if srcOff < 0 || dstOff < 0 || n < 0 {
+		return ErrOverrun
+	}
+	b.mu.Lock()
+	err := b.fillCheck(dstOff + n)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	chunk := DefaultPool.Get(fillChunk)
+	defer DefaultPool.Put(chunk)
+	for done := int64(0); done < n; {
+		p := chunk
+		if int64(len(p)) > n-done {
+			p = p[:n-done]
+		}
+		c, err := r.ReadAt(p, srcOff+done)
+		if err == io.EOF && c == len(p) {
+			err = nil
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if c == 0 && err == nil {
+			err = io.ErrNoProgress
+		}
+		if c > 0 {
+			b.mu.Lock()
+			_, werr := b.writeAt(p[:c], dstOff+done)
+			b.mu.Unlock()
+			if werr != nil {
+				return werr
+			}
+			done += int64(c)
+		}
+		if err != nil {
+			return err
+		}
+		if progress != nil && !progress(done) {
+			return ErrCanceled
+		}
+	}
+	return nil
+}
+
+// fillCheck makes sure the buffer holds end bytes
+func (b *BufferIO) fillCheck(end int64) error {
+	if err := b.usable(); err != nil {
+		return err
+	}
+	switch {
+	case b.from != nil:
+		return ErrReadOnly
+	case end <= b.size():
+		return nil
+	case b.grow:
+		return b.growTo(end)
+	}
+	return ErrOverrun
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFillFrom(t *testing.T) {
+	src := make([]byte, 3*fillChunk+100)
+	for i := range src {
+		src[i] = byte(i * 7)
+	}
+
+	b := NewBufferIOMake(len(src))
+	assert(t, b.FillFrom(bytes.NewReader(src), 10, 5, int64(len(src)-20)) == nil)
+	assert(t, bytes.Equal(b.Bytes()[5:len(src)-15], src[10:len(src)-10]))
+	assert(t, b.Bytes()[4] == 0)
+
+	// Other buffers, including itself, are sources too
+	assert(t, b.FillFrom(b, 5, 0, 10) == nil)
+	assert(t, bytes.Equal(b.Bytes()[:10], src[10:20]))
+
+	assert(t, b.FillFrom(bytes.NewReader(src), 0, 10, int64(len(src))) == ErrOverrun)
+	assert(t, b.FillFrom(bytes.NewReader(src), -1, 0, 1) == ErrOverrun)
+	assert(t, b.FillFrom(bytes.NewReader(src[:8]), 0, 0, 10) == io.ErrUnexpectedEOF)
+	assert(t, bytes.Equal(b.Bytes()[:8], src[:8]))
+	assert(t, b.FillFrom(bytes.NewReader(src), 0, 0, 0) == nil)
+
+	g := NewBufferIOGrow(0)
+	assert(t, g.FillFrom(bytes.NewReader(src), 0, 4, 100) == nil)
+	assert(t, g.Size() == 104 && bytes.Equal(g.Bytes()[4:], src[:100]))
+
+	backed := NewBufferIOFrom(bytes.NewReader(src), 10)
+	assert(t, backed.FillFrom(bytes.NewReader(src), 0, 0, 1) == ErrReadOnly)
+}
+
+func TestFillFromProgress(t *testing.T) {
+	src := bytes.Repeat([]byte("x"), 3*fillChunk)
+	b := NewBufferIOMake(len(src))
+
+	var seen []int64
+	assert(t, b.FillFromFunc(bytes.NewReader(src), 0, 0, int64(len(src)), func(done int64) bool {
+		seen = append(seen, done)
+		return true
+	}) == nil)
+	assert(t, len(seen) == 3 && seen[2] == int64(len(src)))
+
+	b = NewBufferIOMake(len(src))
+	err := b.FillFromFunc(bytes.NewReader(src), 0, 0, int64(len(src)), func(done int64) bool {
+		return done < fillChunk
+	})
+	assert(t, err == ErrCanceled)
+	assert(t, b.Bytes()[fillChunk-1] == 'x' && b.Bytes()[fillChunk] == 0)
+
+	// Short reads carry on from where they stopped
+	b = NewBufferIOMake(100)
+	assert(t, b.FillFrom(readerAtFunc(func(p []byte, off int64) (int, error) {
+		return iotest.OneByteReader(bytes.NewReader(src[off:])).Read(p)
+	}), 0, 0, 100) == nil)
+	assert(t, bytes.Equal(b.Bytes(), src[:100]))
+}
+
+type readerAtFunc func(p []byte, off int64) (int, error)
+
+func (f readerAtFunc) ReadAt(p []byte, off int64) (int, error) { return f(p, off) }
+
+func TestFillFromNoProgress(t *testing.T) {
+	b := NewBufferIOMake(10)
+	assert(t, b.FillFrom(readerAtFunc(func(p []byte, off int64) (int, error) {
+		return 0, nil
+	}), 0, 0, 10) == io.ErrNoProgress)
+}
@@ -0,0 +1,35 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// FindData returns the offset of the first occurrence of the encoding
+// of v, as WriteData would write it, at or after from. It returns -1
+// when there is none.
+func (b *BufferIO) FindData(order binary.ByteOrder, v interface{}, from int64) (int64, error) {
+	pattern, err := encodeData(order, v)
+	if err != nil {
+		return -1, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if err := b.memory(); err != nil {
+		return -1, err
+	}
+	if from < 0 || from > b.size() {
+		return -1, ErrOverrun
+	}
+	i := bytes.Index(b.buf[from:], pattern)
+	if i < 0 {
+		return -1, nil
+	}
+	return from + int64(i), nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+type findHeader struct {
+	Magic   [4]byte
+	Version uint16
+}
+
+func TestFindData(t *testing.T) {
+	b := NewBufferIOMake(64)
+	h := findHeader{Magic: [4]byte{'B', 'I', 'O', 'H'}, Version: 3}
+
+	b.Seek(10, 0)
+	assert(t, b.WriteDataLE(&h) == nil)
+	b.Seek(40, 0)
+	assert(t, b.WriteDataLE(&h) == nil)
+
+	off, err := b.FindData(binary.LittleEndian, &h, 0)
+	assert(t, err == nil)
+	assert(t, off == 10)
+	off, err = b.FindData(binary.LittleEndian, &h, 11)
+	assert(t, err == nil)
+	assert(t, off == 40)
+	off, err = b.FindData(binary.LittleEndian, &h, 41)
+	assert(t, err == nil)
+	assert(t, off == -1)
+
+	// The byte order is part of the pattern
+	off, err = b.FindData(binary.BigEndian, &h, 0)
+	assert(t, err == nil)
+	assert(t, off == -1)
+
+	off, err = b.FindData(binary.BigEndian, uint16(0x0300), 0)
+	assert(t, err == nil)
+	assert(t, off == 14)
+}
+
+func TestFindDataErrors(t *testing.T) {
+	b := NewBufferIOMake(16)
+
+	_, err := b.FindData(binary.LittleEndian, uint8(0), 17)
+	assert(t, err == ErrOverrun)
+	_, err = b.FindData(binary.LittleEndian, uint8(0), -1)
+	assert(t, err == ErrOverrun)
+	_, err = b.FindData(binary.LittleEndian, "string", 0)
+	assert(t, err != nil)
+
+	assert(t, b.Close() == nil)
+	_, err = b.FindData(binary.LittleEndian, uint8(0), 0)
+	assert(t, err == ErrClosed)
+}
@@ -0,0 +1,49 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+)
+
+// FlushRange writes the n bytes of the buffer at off to w at the same
+// offset, as when checkpointing part of a buffer mirroring a file. A
+// range outside the buffer returns ErrOverrun without writing.
+func (b *BufferIO) FlushRange(w io.WriterAt, off, n int64) error {
+	return b.FlushRangeAt(w, off, n, off)
+}
+
+// FlushRangeAt is FlushRange writing to w at woff instead. The buffer is
+// read locked while w is written, so w must not write to b itself; use
+// CopyWithin for that.
+func (b *BufferIO) FlushRangeAt(w io.WriterAt, off, n, woff int64) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.usable(); err != nil {
+		return err
+	}
+	if off < 0 || n < 0 || woff < 0 || off > b.length() || n > b.length()-off {
+		return ErrOverrun
+	}
+
+	if b.from != nil {
+		_, err := io.Copy(&offsetWriter{w: w, off: woff}, io.NewSectionReader(b.from, off, n))
+		return err
+	}
+	_, err := w.WriteAt(b.buf[off:off+n], woff)
+	return err
+}
+
+// offsetWriter adapts a WriterAt to sequential writes from off
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.off)
+	o.off += int64(n)
+	return n, err
+}
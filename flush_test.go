@@ -0,0 +1,44 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFlushRange(t *testing.T) {
+	f, err := ioutil.TempFile("", "flush")
+	assert(t, err == nil)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	b := NewBufferIO([]byte("0123456789"))
+	assert(t, b.FlushRange(f, 2, 3) == nil)
+	assert(t, b.FlushRangeAt(f, 7, 3, 0) == nil)
+	data, err := ioutil.ReadFile(f.Name())
+	assert(t, err == nil && string(data) == "78934")
+
+	assert(t, b.FlushRange(f, 8, 3) == ErrOverrun)
+	assert(t, b.FlushRange(f, -1, 1) == ErrOverrun)
+	assert(t, b.FlushRangeAt(f, 0, 1, -1) == ErrOverrun)
+	assert(t, b.FlushRange(f, 10, 0) == nil)
+
+	// Other buffers are WriterAts too
+	dst := NewBufferIOMake(10)
+	assert(t, b.FlushRange(dst, 4, 4) == nil)
+	assert(t, string(dst.Bytes()[4:8]) == "4567")
+	assert(t, b.FlushRangeAt(dst, 0, 10, 5) == io.ErrShortWrite)
+
+	backed := NewBufferIOFrom(bytes.NewReader([]byte("abcdef")), 6)
+	assert(t, backed.FlushRangeAt(dst, 1, 3, 0) == nil)
+	assert(t, string(dst.Bytes()[:3]) == "bcd")
+
+	b.Close()
+	assert(t, b.FlushRange(f, 0, 1) == ErrClosed)
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// FramePrefix is the encoding of the length in front of each frame
+// written by WriteFrame.
+type FramePrefix int
+
+const (
+	FrameUint32 FramePrefix = iota
+	FrameUint16
+	FrameUvarint
+)
+
+// FrameFormat describes the length prefix of frames. Order applies to
+// the fixed width prefixes and is little endian when nil. The zero
+// value is a little endian uint32 prefix.
+type FrameFormat struct {
+	Prefix FramePrefix
+	Order  binary.ByteOrder
+}
+
+// SetFrameFormat sets the length prefix of WriteFrame and ReadFrame.
+func (b *BufferIO) SetFrameFormat(f FrameFormat) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.frame = f
+}
+
+func (b *BufferIO) FrameFormat() FrameFormat {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.frame
+}
+
+func (f FrameFormat) order() binary.ByteOrder {
+	if f.Order == nil {
+		return binary.LittleEndian
+	}
+	return f.Order
+}
+
+// WriteFrame writes p after its length at the offset and moves past
+// it. The frame is written whole or not at all, returning
+// io.ErrShortWrite when it does not fit, and a p too long for the
+// prefix returns ErrRecordTooLarge.
+func (b *BufferIO) WriteFrame(p []byte) error {
+	f := b.FrameFormat()
+	var h [binary.MaxVarintLen64]byte
+	var n int
+	switch f.Prefix {
+	case FrameUint32, FrameUint16:
+		n = 4
+		if f.Prefix == FrameUint16 {
+			n = 2
+		}
+		if !putUint(h[:n], f.order(), uint64(len(p))) {
+			return ErrRecordTooLarge
+		}
+	case FrameUvarint:
+		n = binary.PutUvarint(h[:], uint64(len(p)))
+	default:
+		return errors.New("invalid frame prefix")
+	}
+	enc := make([]byte, n+len(p))
+	copy(enc, h[:n])
+	copy(enc[n:], p)
+	return b.writeWhole(enc)
+}
+
+// ReadFrame reads a frame written by WriteFrame at the offset and moves
+// past it. At the end of the buffer it returns io.EOF. A partial frame,
+// whose prefix or payload is cut short by the end of the buffer, returns
+// io.ErrUnexpectedEOF and leaves the offset alone, so the frame can be
+// read again once the rest of it has been written.
+func (b *BufferIO) ReadFrame() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+
+	var h [4]byte
+	var size uint64
+	var n int
+	switch b.frame.Prefix {
+	case FrameUint32, FrameUint16:
+		n = 4
+		if b.frame.Prefix == FrameUint16 {
+			n = 2
+		}
+		if err := b.readWhole(h[:n], b.off, false); err != nil {
+			return nil, err
+		}
+		if n == 2 {
+			size = uint64(b.frame.order().Uint16(h[:]))
+		} else {
+			size = uint64(b.frame.order().Uint32(h[:]))
+		}
+	case FrameUvarint:
+		v, err := b.varint()
+		if err != nil {
+			return nil, err
+		}
+		if size, n = binary.Uvarint(v); n <= 0 {
+			return nil, varintError(n, len(v))
+		}
+	default:
+		return nil, errors.New("invalid frame prefix")
+	}
+
+	if size > uint64(b.length()-b.off-int64(n)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p := make([]byte, size)
+	if err := b.readWhole(p, b.off+int64(n), true); err != nil {
+		return nil, err
+	}
+	b.off += int64(n) + int64(size)
+	return p, nil
+}
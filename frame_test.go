@@ -0,0 +1,97 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+	formats := []struct {
+		f      FrameFormat
+		header []byte
+	}{
+		{FrameFormat{}, []byte{3, 0, 0, 0}},
+		{FrameFormat{Prefix: FrameUint32, Order: binary.BigEndian}, []byte{0, 0, 0, 3}},
+		{FrameFormat{Prefix: FrameUint16}, []byte{3, 0}},
+		{FrameFormat{Prefix: FrameUint16, Order: binary.BigEndian}, []byte{0, 3}},
+		{FrameFormat{Prefix: FrameUvarint}, []byte{3}},
+	}
+	for _, test := range formats {
+		b := NewBufferIOMake(32)
+		b.SetFrameFormat(test.f)
+		assert(t, b.WriteFrame([]byte("abc")) == nil)
+		assert(t, b.WriteFrame(nil) == nil)
+		assert(t, bytes.Equal(b.Bytes()[:len(test.header)], test.header))
+
+		end, _ := b.Seek(0, 1)
+		b.Seek(0, 0)
+		p, err := b.ReadFrame()
+		assert(t, err == nil && string(p) == "abc")
+		p, err = b.ReadFrame()
+		assert(t, err == nil && len(p) == 0)
+		off, _ := b.Seek(0, 1)
+		assert(t, off == end)
+	}
+}
+
+func TestFramePartial(t *testing.T) {
+	b := NewBufferIOMake(16)
+	assert(t, b.WriteFrame([]byte("hello")) == nil)
+
+	// A frame cut short reads as partial and is left in place
+	part := NewBufferIO(append([]byte(nil), b.Bytes()[:7]...))
+	_, err := part.ReadFrame()
+	assert(t, err == io.ErrUnexpectedEOF)
+	off, _ := part.Seek(0, 1)
+	assert(t, off == 0)
+	part = NewBufferIO(append([]byte(nil), b.Bytes()[:2]...))
+	_, err = part.ReadFrame()
+	assert(t, err == io.ErrUnexpectedEOF)
+	part.Seek(0, 2)
+	_, err = part.ReadFrame()
+	assert(t, err == io.EOF)
+
+	// A growing buffer reads the frame once it is complete
+	g := NewBufferIOGrow(0)
+	g.SetFrameFormat(FrameFormat{Prefix: FrameUvarint})
+	g.Write([]byte{200, 1})
+	g.Write(make([]byte, 100))
+	g.Seek(0, 0)
+	_, err = g.ReadFrame()
+	assert(t, err == io.ErrUnexpectedEOF)
+	g.Seek(0, 2)
+	g.Write(make([]byte, 100))
+	g.Seek(0, 0)
+	p, err := g.ReadFrame()
+	assert(t, err == nil && len(p) == 200)
+
+	g.Write([]byte{0x80})
+	g.Seek(-1, 1)
+	_, err = g.ReadFrame()
+	assert(t, err == io.ErrUnexpectedEOF)
+}
+
+func TestFrameErrors(t *testing.T) {
+	b := NewBufferIOMake(8)
+	assert(t, b.WriteFrame(make([]byte, 5)) == io.ErrShortWrite)
+	off, _ := b.Seek(0, 1)
+	assert(t, off == 0)
+
+	b.SetFrameFormat(FrameFormat{Prefix: FrameUint16})
+	assert(t, b.WriteFrame(make([]byte, 1<<16)) == ErrRecordTooLarge)
+
+	b.SetFrameFormat(FrameFormat{Prefix: FrameUvarint})
+	b2 := NewBufferIO(bytes.Repeat([]byte{0xff}, 12))
+	b2.SetFrameFormat(b.FrameFormat())
+	_, err := b2.ReadFrame()
+	assert(t, err == ErrVarintOverflow)
+
+	opts, err := NewBufferIOOptions(8, Options{Frame: FrameFormat{Prefix: FrameUint16}})
+	assert(t, err == nil && opts.FrameFormat().Prefix == FrameUint16)
+}
@@ -0,0 +1,147 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Framed records are stored back to back as
+//
+//	[length uint32][crc uint32][payload]
+//
+// in little endian, where crc is the Castagnoli CRC-32 of the length
+// field followed by the payload. A frame with a zero length and checksum
+// marks the end of the records.
+const frameHeader = 8
+
+var frameTable = crc32.MakeTable(crc32.Castagnoli)
+
+func frameSum(length []byte, payload []byte) uint32 {
+	return crc32.Update(crc32.Checksum(length[:4], frameTable), frameTable, payload)
+}
+
+// AppendRecord writes p as a framed record at the current offset and
+// advances past it. Nothing is written if the frame does not fit.
+func (b *BufferIO) AppendRecord(p []byte) error {
+	if int64(len(p)) > int64(^uint32(0)) {
+		return ErrRecordTooLarge
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	if err := b.memory(); err != nil {
+		return err
+	}
+
+	if b.off+frameHeader+int64(len(p)) > b.size() {
+		return ErrOverrun
+	}
+
+	frame := b.buf[b.off:]
+	binary.LittleEndian.PutUint32(frame, uint32(len(p)))
+	binary.LittleEndian.PutUint32(frame[4:], frameSum(frame, p))
+	copy(frame[frameHeader:], p)
+	b.off += frameHeader + int64(len(p))
+	return nil
+}
+
+// frameAt returns the payload of a valid frame at off, or false when the
+// frame is torn, corrupt, or marks the end of the records. The buffer
+// must have memory of its own.
+func (b *BufferIO) frameAt(off int64) ([]byte, bool) {
+	if off+frameHeader > b.size() {
+		return nil, false
+	}
+	length := int64(binary.LittleEndian.Uint32(b.buf[off:]))
+	sum := binary.LittleEndian.Uint32(b.buf[off+4:])
+	if length == 0 && sum == 0 {
+		return nil, false
+	}
+
+	start := off + frameHeader
+	if length > b.size()-start {
+		return nil, false
+	}
+	payload := b.buf[start : start+length : start+length]
+	if frameSum(b.buf[off:], payload) != sum {
+		return nil, false
+	}
+	return payload, true
+}
+
+// ScanRecords calls fn with each valid framed record from the start of
+// the buffer, stopping cleanly at the first torn or invalid frame, or
+// when fn returns false. It returns the offset just past the last record
+// passed to fn. The payloads share memory with the buffer. Buffers backed
+// by a ReaderAt return ErrUnsupported.
+func (b *BufferIO) ScanRecords(fn func(off int64, payload []byte) bool) (int64, error) {
+	var off int64
+	for {
+		b.mu.RLock()
+		if err := b.memory(); err != nil {
+			b.mu.RUnlock()
+			return off, err
+		}
+		payload, ok := b.frameAt(off)
+		b.mu.RUnlock()
+		if !ok {
+			return off, nil
+		}
+		next := off + frameHeader + int64(len(payload))
+		if !fn(off, payload) {
+			return next, nil
+		}
+		off = next
+	}
+}
+
+// Recovery describes the state of the framed records found by Recover.
+type Recovery struct {
+	Records int   // number of valid records
+	End     int64 // offset just past the last valid record
+	Torn    int64 // bytes of leftover data after End
+	Zeroed  bool  // whether the torn tail was cleared
+}
+
+// Recover scans the framed records after a crash, finds the last
+// complete one and moves the offset just past it so that AppendRecord
+// continues from there. Any data after it, such as a partially written
+// frame, is reported as torn. With zeroTail set the torn bytes are
+// cleared, which keeps stale frames from reappearing behind records
+// appended later. Buffers backed by a ReaderAt return ErrUnsupported.
+func (b *BufferIO) Recover(zeroTail bool) (Recovery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+
+	var r Recovery
+	if err := b.memory(); err != nil {
+		return r, err
+	}
+	for {
+		payload, ok := b.frameAt(r.End)
+		if !ok {
+			break
+		}
+		r.End += frameHeader + int64(len(payload))
+		r.Records++
+	}
+
+	last := b.size()
+	for last > r.End && b.buf[last-1] == 0 {
+		last--
+	}
+	r.Torn = last - r.End
+	if zeroTail && r.Torn > 0 {
+		zero(b.buf[r.End:last])
+		r.Zeroed = true
+	}
+
+	b.off = r.End
+	return r, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func scanAll(bio *BufferIO) ([][]byte, int64) {
+	var records [][]byte
+	end, _ := bio.ScanRecords(func(off int64, payload []byte) bool {
+		records = append(records, payload)
+		return true
+	})
+	return records, end
+}
+
+func TestAppendRecord(t *testing.T) {
+	bio := NewBufferIOMake(64)
+
+	assert(t, bio.AppendRecord(src) == nil)
+	assert(t, bio.off == frameHeader+int64(len(src)))
+	assert(t, bio.AppendRecord([]byte{9}) == nil)
+	assert(t, bio.off == 2*frameHeader+int64(len(src))+1)
+
+	// Does not fit, nothing is written
+	off := bio.off
+	assert(t, bio.AppendRecord(big) == ErrOverrun)
+	assert(t, bio.off == off)
+	for _, c := range bio.buf[off:] {
+		assert(t, c == 0)
+	}
+
+	records, end := scanAll(bio)
+	assert(t, len(records) == 2)
+	assert(t, bytes.Equal(records[0], src))
+	assert(t, bytes.Equal(records[1], []byte{9}))
+	assert(t, end == off)
+}
+
+func TestScanRecordsStops(t *testing.T) {
+	bio := NewBufferIOMake(128)
+	bio.AppendRecord(src)
+	second := bio.off
+	bio.AppendRecord(src)
+	third := bio.off
+	bio.AppendRecord(src)
+
+	// Early stop
+	count := 0
+	end, err := bio.ScanRecords(func(off int64, payload []byte) bool {
+		count++
+		return false
+	})
+	assert(t, err == nil)
+	assert(t, count == 1)
+	assert(t, end == second)
+
+	// Corrupt payload in the third record
+	bio.buf[third+frameHeader] ^= 0xff
+	records, end := scanAll(bio)
+	assert(t, len(records) == 2)
+	assert(t, end == third)
+
+	// Torn length in the second record
+	bio.buf[second] = 0xff
+	records, end = scanAll(bio)
+	assert(t, len(records) == 1)
+	assert(t, end == second)
+
+	// Frame running past the end of the buffer
+	bio = NewBufferIOMake(frameHeader + 4)
+	bio.AppendRecord([]byte{1, 2, 3, 4})
+	bio.buf[0] = 5
+	records, end = scanAll(bio)
+	assert(t, len(records) == 0)
+	assert(t, end == 0)
+
+	// Empty payloads are valid records
+	bio = NewBufferIOMake(frameHeader)
+	assert(t, bio.AppendRecord(nil) == nil)
+	records, end = scanAll(bio)
+	assert(t, len(records) == 1)
+	assert(t, end == frameHeader)
+}
+
+func TestRecover(t *testing.T) {
+	bio := NewBufferIOMake(128)
+	bio.AppendRecord(src)
+	bio.AppendRecord(src)
+	end := bio.off
+	bio.AppendRecord(src)
+	bio.AppendRecord([]byte{1})
+	tail := bio.off
+
+	// Tear the third record as if the crash happened mid write
+	bio.buf[end+frameHeader+2] = 0
+	bio.Reset()
+
+	r, err := bio.Recover(false)
+	assert(t, err == nil)
+	assert(t, r.Records == 2)
+	assert(t, r.End == end)
+	assert(t, r.Torn == tail-end)
+	assert(t, !r.Zeroed)
+	assert(t, bio.off == end)
+	assert(t, bio.buf[tail-1] == 1)
+
+	r, _ = bio.Recover(true)
+	assert(t, r.Records == 2)
+	assert(t, r.Torn == tail-end)
+	assert(t, r.Zeroed)
+	for _, c := range bio.buf[end:] {
+		assert(t, c == 0)
+	}
+
+	// Appending continues after the last good record
+	assert(t, bio.AppendRecord([]byte{7, 7}) == nil)
+	records, _ := scanAll(bio)
+	assert(t, len(records) == 3)
+	assert(t, bytes.Equal(records[2], []byte{7, 7}))
+
+	// Clean log
+	r, _ = bio.Recover(true)
+	assert(t, r.Records == 3)
+	assert(t, r.Torn == 0)
+	assert(t, !r.Zeroed)
+
+	// Empty buffer
+	r, _ = NewBufferIOMake(0).Recover(true)
+	assert(t, r.Records == 0)
+	assert(t, r.End == 0)
+}
+
+func TestFramedUnsupported(t *testing.T) {
+	bio := NewBufferIOFrom(bytes.NewReader(make([]byte, 64)), 64)
+	bio.Seek(8, 0)
+	_, err := bio.Recover(true)
+	assert(t, err == ErrUnsupported)
+	assert(t, bio.off == 8)
+	_, err = bio.ScanRecords(func(int64, []byte) bool { return true })
+	assert(t, err == ErrUnsupported)
+
+	bio = NewBufferIOMake(64)
+	bio.AppendRecord(src)
+	bio.Close()
+	_, err = bio.Recover(false)
+	assert(t, err == ErrClosed)
+	_, err = bio.ScanRecords(func(int64, []byte) bool { return true })
+	assert(t, err == ErrClosed)
+}
@@ -0,0 +1,76 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"sync"
+)
+
+// Group flushes related buffers in dependency order, such as data
+// before the metadata pointing at it. Buffers are added in stages: the
+// members of a stage are flushed in parallel, and a stage is only
+// flushed once every earlier stage has been flushed without error, so a
+// failure never leaves a later stage on disk describing an earlier one
+// which is not. A Group is itself a Flusher and can be a member of
+// another.
+type Group struct {
+	mu     sync.Mutex
+	stages [][]Flusher
+}
+
+// NewGroup returns a group whose first stage holds fs.
+func NewGroup(fs ...Flusher) *Group {
+	g := &Group{}
+	return g.Then(fs...)
+}
+
+// Then adds a stage holding fs, flushed after all earlier stages.
+func (g *Group) Then(fs ...Flusher) *Group {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(fs) > 0 {
+		g.stages = append(g.stages, append([]Flusher(nil), fs...))
+	}
+	return g
+}
+
+// Flush flushes the stages in order and returns the first error, after
+// which no later stage is flushed. Flushes of the same group run one at
+// a time.
+func (g *Group) Flush() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, stage := range g.stages {
+		if err := flushAll(stage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushAll flushes fs in parallel and returns the first error in the
+// order of fs
+func flushAll(fs []Flusher) error {
+	if len(fs) == 1 {
+		return fs[0].Flush()
+	}
+	errs := make([]error, len(fs))
+	var wg sync.WaitGroup
+	for i, f := range fs {
+		wg.Add(1)
+		go func(i int, f Flusher) {
+			defer wg.Done()
+			errs[i] = f.Flush()
+		}(i, f)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// flushLog records the order flushes happen in
+type flushLog struct {
+	mu    sync.Mutex
+	order []string
+}
+
+type loggedFlusher struct {
+	log  *flushLog
+	name string
+	err  error
+}
+
+func (f *loggedFlusher) Flush() error {
+	f.log.mu.Lock()
+	defer f.log.mu.Unlock()
+	f.log.order = append(f.log.order, f.name)
+	return f.err
+}
+
+func TestGroupOrder(t *testing.T) {
+	log := &flushLog{}
+	data1 := &loggedFlusher{log: log, name: "data"}
+	data2 := &loggedFlusher{log: log, name: "data"}
+	meta := &loggedFlusher{log: log, name: "meta"}
+	super := &loggedFlusher{log: log, name: "super"}
+
+	g := NewGroup(data1, data2).Then(meta).Then().Then(super)
+	assert(t, g.Flush() == nil)
+	assert(t, len(log.order) == 4)
+	assert(t, log.order[0] == "data" && log.order[1] == "data")
+	assert(t, log.order[2] == "meta" && log.order[3] == "super")
+
+	// Groups nest as members of a stage
+	log.order = nil
+	outer := NewGroup(NewGroup(data1).Then(meta)).Then(super)
+	assert(t, outer.Flush() == nil)
+	assert(t, len(log.order) == 3 && log.order[2] == "super")
+
+	// Buffers are flushers too
+	assert(t, NewGroup(NewBufferIOMake(8)).Then(NewBufferIOMake(8)).Flush() == nil)
+}
+
+func TestGroupFailure(t *testing.T) {
+	log := &flushLog{}
+	failed := errors.New("disk full")
+	data := &loggedFlusher{log: log, name: "data", err: failed}
+	other := &loggedFlusher{log: log, name: "other"}
+	meta := &loggedFlusher{log: log, name: "meta"}
+
+	// A failed stage stops the stages depending on it
+	g := NewGroup(other, data).Then(meta)
+	assert(t, g.Flush() == failed)
+	assert(t, len(log.order) == 2)
+	for _, name := range log.order {
+		assert(t, name != "meta")
+	}
+
+	data.err = nil
+	log.order = nil
+	assert(t, g.Flush() == nil)
+	assert(t, len(log.order) == 3 && log.order[2] == "meta")
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+)
+
+// NewBufferIOGrow returns an empty growable buffer with room for nbytes
+// before it has to reallocate.
+func NewBufferIOGrow(nbytes int) *BufferIO {
+	return &BufferIO{buf: make([]byte, 0, nbytes), grow: true}
+}
+
+// SetGrowable controls whether writes past the end extend the buffer
+// instead of failing with ErrOverrun. Any gap between the old end and
+// the write is zero filled. Heap buffers grow their capacity by
+// doubling; mapped buffers and those of an Options.Allocator are
+// resized to the exact size, and other backings cannot grow and report
+// ErrUnsupported.
+//
+// Growing may move the memory, with the same consequences as Resize.
+// Buffers sharing the memory of another, such as windows, would stop
+// writing through to it, so making them growable returns ErrUnsupported.
+func (b *BufferIO) SetGrowable(grow bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if grow && b.parent != nil {
+		return ErrUnsupported
+	}
+	b.grow = grow
+	return nil
+}
+
+// Growable reports whether the buffer grows on writes past its end.
+func (b *BufferIO) Growable() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.grow
+}
+
+// Len returns the size of the buffer: the bytes written so far for a
+// growable buffer, as Size.
+func (b *BufferIO) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return int(b.length())
+}
+
+// Cap returns how large the buffer can grow to before it has to
+// reallocate, which is its size for buffers with no spare capacity.
+func (b *BufferIO) Cap() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.from != nil {
+		return int(b.fromSize)
+	}
+	return cap(b.buf)
+}
+
+// Grow makes room for n more bytes after the end without changing the
+// size, as bytes.Buffer.Grow does, so growable buffers absorb that much
+// appending without reallocating. The capacity at least doubles when it
+// has to grow. Only heap buffers which do not share the memory of
+// another keep spare capacity; others report ErrUnsupported unless the
+// room is already there.
+func (b *BufferIO) Grow(n int) error {
+	if n < 0 {
+		return errors.New("negative count")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return err
+	}
+	if b.from != nil {
+		return ErrUnsupported
+	}
+	if int64(n) <= int64(cap(b.buf))-b.size() {
+		return nil
+	}
+	if b.backing != BackingHeap || b.parent != nil {
+		return ErrUnsupported
+	}
+	b.reserve(b.size() + int64(n))
+	return nil
+}
+
+// reserve makes the capacity of the heap buffer at least n bytes,
+// doubling it at the least
+func (b *BufferIO) reserve(n int64) {
+	if n <= int64(cap(b.buf)) {
+		return
+	}
+	c := 2 * int64(cap(b.buf))
+	if c < n {
+		c = n
+	}
+	buf := make([]byte, len(b.buf), c)
+	copy(buf, b.buf)
+	b.buf = buf
+	b.moved()
+}
+
+// growTo extends the buffer to n bytes
+func (b *BufferIO) growTo(n int64) error {
+	if b.parent != nil {
+		return ErrUnsupported
+	}
+	if b.backing != BackingHeap {
+		return b.resize(n)
+	}
+
+	b.reserve(n)
+	// Spare capacity of a slice given to NewBufferIO may hold data
+	old := len(b.buf)
+	b.buf = b.buf[:n]
+	zero(b.buf[old:])
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestGrowable(t *testing.T) {
+	b := NewBufferIOGrow(4)
+	assert(t, b.Growable())
+	assert(t, b.Size() == 0)
+
+	for i := 0; i < 10; i++ {
+		n, err := b.Write([]byte("abc"))
+		assert(t, err == nil && n == 3)
+	}
+	assert(t, b.Size() == 30)
+	assert(t, bytes.Equal(b.Bytes(), bytes.Repeat([]byte("abc"), 10)))
+
+	// Writes past the end zero fill the gap
+	n, err := b.WriteAt([]byte("xy"), 40)
+	assert(t, err == nil && n == 2)
+	assert(t, b.Size() == 42)
+	assert(t, bytes.Equal(b.Bytes()[30:], []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00xy")))
+
+	assert(t, b.WriteDataLE(uint64(1)) == nil)
+	assert(t, b.Size() == 42)
+
+	b.SetGrowable(false)
+	_, err = b.WriteAt([]byte("x"), 42)
+	assert(t, err == io.ErrShortWrite)
+}
+
+func TestGrowableSpareCapacity(t *testing.T) {
+	backing := []byte("0123456789")
+	b := NewBufferIO(backing[:4])
+	b.SetGrowable(true)
+
+	// Growing into the capacity of the original slice clears it
+	_, err := b.WriteAt([]byte("z"), 6)
+	assert(t, err == nil)
+	assert(t, string(b.Bytes()) == "0123\x00\x00z")
+}
+
+func TestGrowableBackings(t *testing.T) {
+	for _, backing := range []Backing{BackingMmap, BackingPool} {
+		b, err := NewBufferIOOptions(100, Options{Backing: backing})
+		if err == ErrUnsupported {
+			continue
+		}
+		assert(t, err == nil)
+		b.SetGrowable(true)
+		n, err := b.WriteAt([]byte("abc"), 100)
+		if backing == BackingMmap {
+			assert(t, err == nil && n == 3)
+			assert(t, b.Size() == 103)
+		} else {
+			assert(t, err == ErrUnsupported)
+		}
+		b.Close()
+	}
+}
+
+func TestGrowReserve(t *testing.T) {
+	b := NewBufferIOGrow(0)
+	assert(t, b.Len() == 0 && b.Cap() == 0)
+
+	// Reserving leaves the size alone
+	assert(t, b.Grow(100) == nil)
+	assert(t, b.Len() == 0 && b.Cap() == 100)
+	b.Write(bytes.Repeat([]byte("x"), 60))
+	mem := &b.buf[0]
+	b.Write(bytes.Repeat([]byte("y"), 40))
+	assert(t, &b.buf[0] == mem)
+	assert(t, b.Len() == 100 && b.Cap() == 100)
+
+	// The capacity at least doubles
+	assert(t, b.Grow(1) == nil)
+	assert(t, b.Cap() == 200 && b.Len() == 100)
+	assert(t, b.Grow(500) == nil)
+	assert(t, b.Cap() == 600)
+	assert(t, bytes.Equal(b.Bytes()[60:], bytes.Repeat([]byte("y"), 40)))
+
+	// Room which is there already is fine for any backing
+	assert(t, b.Grow(0) == nil && b.Cap() == 600)
+	pool, _ := NewBufferIOOptions(16, Options{Backing: BackingPool, Pool: NewPool()})
+	assert(t, pool.Grow(0) == nil)
+	assert(t, pool.Grow(1<<20) == ErrUnsupported)
+	assert(t, b.Grow(-1) != nil)
+
+	from := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	assert(t, from.Len() == len(big) && from.Cap() == len(big))
+	assert(t, from.Grow(1) == ErrUnsupported)
+}
+
+func TestGrowWindow(t *testing.T) {
+	b := NewBufferIOGrow(16)
+	b.Write(bytes.Repeat([]byte("x"), 16))
+	w, err := b.Window(0, 8)
+	assert(t, err == nil)
+
+	// A window cannot grow away from the memory of its parent
+	assert(t, w.Grow(64) == ErrUnsupported)
+	assert(t, w.SetGrowable(true) == ErrUnsupported)
+	assert(t, !w.Growable())
+	assert(t, w.SetGrowable(false) == nil)
+	_, err = w.WriteAt([]byte("y"), 8)
+	assert(t, err == io.ErrShortWrite)
+
+	// and its writes still reach the parent
+	_, err = w.WriteAt([]byte("ab"), 6)
+	assert(t, err == nil)
+	assert(t, string(b.Bytes()[6:8]) == "ab")
+}
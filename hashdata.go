@@ -0,0 +1,146 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"math"
+	"reflect"
+)
+
+var ErrInvalidType = errors.New("invalid type for binary encoding")
+
+// HashData feeds h the encoding WriteData would produce for v. Plain
+// values are fed one field at a time, so their encoding is never held
+// in memory; tagged structs and BinaryMarshalers are encoded first.
+func HashData(h hash.Hash, order binary.ByteOrder, v interface{}) error {
+	plan, err := planOf(v)
+	if err != nil {
+		return err
+	}
+	if _, ok := v.(encoding.BinaryMarshaler); ok || plan != nil {
+		enc, err := encodeData(order, v)
+		if err != nil {
+			return err
+		}
+		_, err = h.Write(enc)
+		return err
+	}
+	if binary.Size(v) < 0 {
+		return ErrInvalidType
+	}
+	e := &streamEncoder{w: h, order: order}
+	e.value(reflect.Indirect(reflect.ValueOf(v)))
+	return e.err
+}
+
+// streamEncoder writes values in the layout of encoding/binary
+type streamEncoder struct {
+	w       io.Writer
+	order   binary.ByteOrder
+	scratch [8]byte
+	err     error
+}
+
+func (e *streamEncoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+func (e *streamEncoder) zeros(n int) {
+	for i := range e.scratch {
+		e.scratch[i] = 0
+	}
+	for ; n > len(e.scratch); n -= len(e.scratch) {
+		e.write(e.scratch[:])
+	}
+	e.write(e.scratch[:n])
+}
+
+func (e *streamEncoder) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			e.write(v.Bytes())
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			e.value(v.Index(i))
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).Name == "_" {
+				e.zeros(binary.Size(reflect.Zero(t.Field(i).Type).Interface()))
+				continue
+			}
+			e.value(v.Field(i))
+		}
+
+	case reflect.Bool:
+		e.scratch[0] = 0
+		if v.Bool() {
+			e.scratch[0] = 1
+		}
+		e.write(e.scratch[:1])
+
+	case reflect.Int8:
+		e.scratch[0] = byte(v.Int())
+		e.write(e.scratch[:1])
+	case reflect.Int16:
+		e.order.PutUint16(e.scratch[:], uint16(v.Int()))
+		e.write(e.scratch[:2])
+	case reflect.Int32:
+		e.order.PutUint32(e.scratch[:], uint32(v.Int()))
+		e.write(e.scratch[:4])
+	case reflect.Int64:
+		e.order.PutUint64(e.scratch[:], uint64(v.Int()))
+		e.write(e.scratch[:8])
+
+	case reflect.Uint8:
+		e.scratch[0] = byte(v.Uint())
+		e.write(e.scratch[:1])
+	case reflect.Uint16:
+		e.order.PutUint16(e.scratch[:], uint16(v.Uint()))
+		e.write(e.scratch[:2])
+	case reflect.Uint32:
+		e.order.PutUint32(e.scratch[:], uint32(v.Uint()))
+		e.write(e.scratch[:4])
+	case reflect.Uint64:
+		e.order.PutUint64(e.scratch[:], v.Uint())
+		e.write(e.scratch[:8])
+
+	case reflect.Float32:
+		e.order.PutUint32(e.scratch[:], math.Float32bits(float32(v.Float())))
+		e.write(e.scratch[:4])
+	case reflect.Float64:
+		e.order.PutUint64(e.scratch[:], math.Float64bits(v.Float()))
+		e.write(e.scratch[:8])
+
+	case reflect.Complex64:
+		x := v.Complex()
+		e.order.PutUint32(e.scratch[:], math.Float32bits(float32(real(x))))
+		e.write(e.scratch[:4])
+		e.order.PutUint32(e.scratch[:], math.Float32bits(float32(imag(x))))
+		e.write(e.scratch[:4])
+	case reflect.Complex128:
+		x := v.Complex()
+		e.order.PutUint64(e.scratch[:], math.Float64bits(real(x)))
+		e.write(e.scratch[:8])
+		e.order.PutUint64(e.scratch[:], math.Float64bits(imag(x)))
+		e.write(e.scratch[:8])
+
+	default:
+		if e.err == nil {
+			e.err = ErrInvalidType
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+type hashRecord struct {
+	Flag  bool
+	A     int8
+	B     int16
+	_     [3]byte
+	C     uint32
+	D     int64
+	F     float32
+	G     float64
+	Z     complex64
+	Tags  [2]uint16
+	Inner struct {
+		X uint8
+		Y [3]int32
+	}
+}
+
+func TestHashData(t *testing.T) {
+	r := hashRecord{Flag: true, A: -2, B: 300, C: 0xdeadbeef, D: -1 << 40,
+		F: 1.5, G: -2.25, Z: complex(1, -1), Tags: [2]uint16{7, 9}}
+	r.Inner.X = 4
+	r.Inner.Y = [3]int32{-1, 0, 1}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var want bytes.Buffer
+		assert(t, binary.Write(&want, order, &r) == nil)
+		sum := sha256.Sum256(want.Bytes())
+
+		h := sha256.New()
+		assert(t, HashData(h, order, &r) == nil)
+		assert(t, bytes.Equal(h.Sum(nil), sum[:]))
+
+		// Values and pointers hash the same
+		h.Reset()
+		assert(t, HashData(h, order, r) == nil)
+		assert(t, bytes.Equal(h.Sum(nil), sum[:]))
+	}
+
+	// Slices, including byte slices
+	var want bytes.Buffer
+	words := []uint32{1, 2, 3}
+	binary.Write(&want, binary.LittleEndian, words)
+	binary.Write(&want, binary.LittleEndian, src)
+	h := sha256.New()
+	assert(t, HashData(h, binary.LittleEndian, words) == nil)
+	assert(t, HashData(h, binary.LittleEndian, src) == nil)
+	sum := sha256.Sum256(want.Bytes())
+	assert(t, bytes.Equal(h.Sum(nil), sum[:]))
+}
+
+func TestHashDataInvalid(t *testing.T) {
+	h := sha256.New()
+	assert(t, HashData(h, binary.LittleEndian, "string") == ErrInvalidType)
+	assert(t, HashData(h, binary.LittleEndian, struct{ P *int }{}) == ErrInvalidType)
+	assert(t, HashData(h, binary.LittleEndian, 1) == ErrInvalidType)
+	assert(t, h.Size() == sha256.Size)
+}
+
+type taggedHashRecord struct {
+	A uint16 `bufferio:"le"`
+	B uint32
+	C uint32 `bufferio:"crc32=B"`
+}
+
+func TestHashDataMatchesWriteData(t *testing.T) {
+	when := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, v := range []interface{}{
+		&taggedHashRecord{A: 1, B: 0x01020304},
+		&marshalRecord{ID: testUUID{1, 2, 3, 4}, Color: 1, When: when, Count: 9},
+		testUUID{5, 6},
+		&hashRecord{C: 7},
+	} {
+		b := NewBufferIOMake(128)
+		b.Seek(3, 0)
+		assert(t, b.WriteDataBE(v) == nil)
+		end, _ := b.Seek(0, 1)
+		written := b.Bytes()[3:end]
+
+		h := sha256.New()
+		assert(t, HashData(h, binary.BigEndian, v) == nil)
+		sum := sha256.Sum256(written)
+		assert(t, bytes.Equal(h.Sum(nil), sum[:]))
+
+		off, err := b.FindData(binary.BigEndian, v, 0)
+		assert(t, err == nil && off == 3)
+	}
+}
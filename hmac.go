@@ -0,0 +1,84 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+// MACSize is the size of the HMAC-SHA256 tags produced by Sign.
+const MACSize = sha256.Size
+
+var ErrAuth = errors.New("message authentication failed")
+
+func mac(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Sign returns the HMAC-SHA256 of the buffer contents under key. Unlike
+// a checksum it can only be produced by holders of the key, so it detects
+// deliberate tampering as well as corruption. Buffers backed by a
+// ReaderAt return ErrUnsupported.
+func (b *BufferIO) Sign(key []byte) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.memory(); err != nil {
+		return nil, err
+	}
+	return mac(key, b.buf), nil
+}
+
+// Verify checks that tag is the HMAC of the buffer contents under key
+// and returns ErrAuth when it is not.
+func (b *BufferIO) Verify(key, tag []byte) error {
+	sum, err := b.Sign(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sum, tag) {
+		return ErrAuth
+	}
+	return nil
+}
+
+// SignTrailer uses the last MACSize bytes of the buffer as a trailer
+// holding the HMAC of the bytes before it.
+func (b *BufferIO) SignTrailer(key []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.memory(); err != nil {
+		return err
+	}
+
+	if b.size() < MACSize {
+		return ErrTooSmall
+	}
+	body := b.buf[:len(b.buf)-MACSize]
+	copy(b.buf[len(body):], mac(key, body))
+	return nil
+}
+
+// VerifyTrailer checks the trailer written by SignTrailer and returns
+// ErrAuth when it does not match the contents.
+func (b *BufferIO) VerifyTrailer(key []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.memory(); err != nil {
+		return err
+	}
+
+	if b.size() < MACSize {
+		return ErrTooSmall
+	}
+	body := b.buf[:len(b.buf)-MACSize]
+	if !hmac.Equal(mac(key, body), b.buf[len(body):]) {
+		return ErrAuth
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"testing"
+)
+
+var macKey = []byte("secret key")
+
+func TestSignVerify(t *testing.T) {
+	bio := NewBufferIO(append([]byte(nil), big...))
+	tag, err := bio.Sign(macKey)
+	assert(t, err == nil)
+	assert(t, len(tag) == MACSize)
+	assert(t, bio.Verify(macKey, tag) == nil)
+	assert(t, bio.Verify([]byte("other key"), tag) == ErrAuth)
+	assert(t, bio.Verify(macKey, tag[:10]) == ErrAuth)
+
+	bio.buf[3] ^= 1
+	assert(t, bio.Verify(macKey, tag) == ErrAuth)
+
+	backed := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	_, err = backed.Sign(macKey)
+	assert(t, err == ErrUnsupported)
+	assert(t, backed.Verify(macKey, tag) == ErrUnsupported)
+	assert(t, backed.SignTrailer(macKey) == ErrUnsupported)
+	assert(t, backed.VerifyTrailer(macKey) == ErrUnsupported)
+
+	bio.Close()
+	_, err = bio.Sign(macKey)
+	assert(t, err == ErrClosed)
+	assert(t, bio.Verify(macKey, tag) == ErrClosed)
+}
+
+func TestSignTrailer(t *testing.T) {
+	bio := NewBufferIOMake(len(big) + MACSize)
+	bio.Write(big)
+
+	assert(t, bio.SignTrailer(macKey) == nil)
+	assert(t, bio.VerifyTrailer(macKey) == nil)
+	assert(t, bio.VerifyTrailer([]byte("other key")) == ErrAuth)
+
+	// Tampering with the body or the trailer is detected
+	bio.buf[0] ^= 1
+	assert(t, bio.VerifyTrailer(macKey) == ErrAuth)
+	bio.buf[0] ^= 1
+	bio.buf[len(bio.buf)-1] ^= 1
+	assert(t, bio.VerifyTrailer(macKey) == ErrAuth)
+
+	small := NewBufferIOMake(MACSize - 1)
+	assert(t, small.SignTrailer(macKey) == ErrTooSmall)
+	assert(t, small.VerifyTrailer(macKey) == ErrTooSmall)
+}
@@ -0,0 +1,187 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// HTTPReaderAt reads a remote object lazily with HTTP range requests.
+// Fetched data is cached block by block, so every byte is downloaded at
+// most once, and only the blocks read are ever held in memory.
+type HTTPReaderAt struct {
+	client    *http.Client
+	url       string
+	blockSize int64
+	size      int64
+
+	mu     sync.Mutex
+	blocks map[int64][]byte
+}
+
+// NewHTTPReaderAt returns a reader for url, fetching blockSize bytes at
+// a time. The size of the object is learnt with a HEAD request. A nil
+// client uses http.DefaultClient.
+func NewHTTPReaderAt(client *http.Client, url string, blockSize int) (*HTTPReaderAt, error) {
+	if blockSize <= 0 {
+		return nil, errors.New("invalid block size")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HEAD %s: %s", url, resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("HEAD %s: unknown size", url)
+	}
+
+	return &HTTPReaderAt{
+		client:    client,
+		url:       url,
+		blockSize: int64(blockSize),
+		size:      resp.ContentLength,
+		blocks:    make(map[int64][]byte),
+	}, nil
+}
+
+// NewBufferIOHTTP returns a read only buffer over the remote object at
+// url, backed by an HTTPReaderAt, so that large objects can be parsed
+// with the usual methods while only the ranges read are downloaded.
+func NewBufferIOHTTP(client *http.Client, url string, blockSize int) (*BufferIO, error) {
+	h, err := NewHTTPReaderAt(client, url, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return NewBufferIOFrom(h, h.Size()), nil
+}
+
+// Size returns the size of the remote object.
+func (h *HTTPReaderAt) Size() int64 {
+	return h.size
+}
+
+// ReadAt fetches the blocks covering p which have not been read before,
+// one request per run of missing blocks, and copies p from the cache.
+// The cache is not locked during requests, so reads of cached blocks
+// never wait for the network.
+func (h *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= h.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > h.size {
+		end = h.size
+	}
+
+	first, last := off/h.blockSize, (end+h.blockSize-1)/h.blockSize
+	if err := h.fetch(first, last); err != nil {
+		return 0, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for i := first; i < last; i++ {
+		block := h.blocks[i]
+		if i == first {
+			block = block[off-i*h.blockSize:]
+		}
+		n += copy(p[n:end-off], block)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch makes sure blocks [first, last) are cached
+func (h *HTTPReaderAt) fetch(first, last int64) error {
+	for _, run := range h.missing(first, last) {
+		data, err := h.get(run[0]*h.blockSize, run[1]*h.blockSize)
+		if err != nil {
+			return err
+		}
+
+		// Another read may have fetched some of the blocks meanwhile
+		h.mu.Lock()
+		for i := run[0]; i < run[1]; i++ {
+			if _, ok := h.blocks[i]; ok {
+				continue
+			}
+			start := (i - run[0]) * h.blockSize
+			end := start + h.blockSize
+			if end > int64(len(data)) {
+				end = int64(len(data))
+			}
+			h.blocks[i] = data[start:end:end]
+		}
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+// missing returns the runs of blocks in [first, last) not cached yet
+func (h *HTTPReaderAt) missing(first, last int64) [][2]int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var runs [][2]int64
+	for i := first; i < last; {
+		if _, ok := h.blocks[i]; ok {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < last {
+			if _, ok := h.blocks[j]; ok {
+				break
+			}
+			j++
+		}
+		runs = append(runs, [2]int64{i, j})
+		i = j
+	}
+	return runs
+}
+
+// get downloads [start, end)
+func (h *HTTPReaderAt) get(start, end int64) ([]byte, error) {
+	if end > h.size {
+		end = h.size
+	}
+	req, err := http.NewRequest("GET", h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("GET %s: %s", h.url, resp.Status)
+	}
+	data := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
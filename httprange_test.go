@@ -0,0 +1,139 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func rangeServer(content []byte, gets *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			atomic.AddInt32(gets, 1)
+		}
+		http.ServeContent(w, r, "object", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func TestHTTPReaderAt(t *testing.T) {
+	content := bytes.Repeat(big, 10)
+	var gets int32
+	srv := rangeServer(content, &gets)
+	defer srv.Close()
+
+	h, err := NewHTTPReaderAt(nil, srv.URL, 64)
+	assert(t, err == nil)
+	assert(t, h.Size() == int64(len(content)))
+
+	p := make([]byte, 100)
+	n, err := h.ReadAt(p, 10)
+	assert(t, err == nil)
+	assert(t, n == 100)
+	assert(t, bytes.Equal(p, content[10:110]))
+	assert(t, atomic.LoadInt32(&gets) == 1)
+
+	// Cached blocks are not fetched again
+	n, err = h.ReadAt(p[:50], 64)
+	assert(t, err == nil && n == 50)
+	assert(t, bytes.Equal(p[:50], content[64:114]))
+	assert(t, atomic.LoadInt32(&gets) == 1)
+
+	// Only the missing blocks of a range are requested
+	all := make([]byte, len(content))
+	n, err = h.ReadAt(all, 0)
+	assert(t, err == nil)
+	assert(t, n == len(content))
+	assert(t, bytes.Equal(all, content))
+	assert(t, atomic.LoadInt32(&gets) == 2)
+
+	n, err = h.ReadAt(p, int64(len(content))-10)
+	assert(t, n == 10)
+	assert(t, err == io.EOF)
+	_, err = h.ReadAt(p, int64(len(content)))
+	assert(t, err == io.EOF)
+}
+
+func TestHTTPReaderAtErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := NewHTTPReaderAt(nil, srv.URL, 64)
+	assert(t, err != nil)
+	_, err = NewHTTPReaderAt(nil, srv.URL, 0)
+	assert(t, err != nil)
+
+	// Servers ignoring ranges are reported
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(big)
+	}))
+	defer plain.Close()
+	h, err := NewHTTPReaderAt(nil, plain.URL, 16)
+	assert(t, err == nil)
+	_, err = h.ReadAt(make([]byte, 4), 0)
+	assert(t, err != nil)
+}
+
+func TestBufferIOHTTP(t *testing.T) {
+	content := bytes.Repeat(big, 10)
+	var gets int32
+	srv := rangeServer(content, &gets)
+	defer srv.Close()
+
+	b, err := NewBufferIOHTTP(nil, srv.URL, 64)
+	assert(t, err == nil)
+	assert(t, b.Size() == int64(len(content)) && b.buf == nil)
+
+	b.Seek(70, io.SeekStart)
+	var v uint32
+	assert(t, b.ReadDataLE(&v) == nil)
+	assert(t, v == binary.LittleEndian.Uint32(content[70:]))
+	assert(t, atomic.LoadInt32(&gets) == 1)
+
+	_, err = b.Write([]byte("x"))
+	assert(t, err == ErrReadOnly)
+}
+
+func TestHTTPReaderAtConcurrent(t *testing.T) {
+	content := bytes.Repeat(big, 10)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests beyond the first block hang until released
+		if r.Method == "GET" && r.Header.Get("Range") != "bytes=0-63" {
+			<-release
+		}
+		http.ServeContent(w, r, "object", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	h, err := NewHTTPReaderAt(nil, srv.URL, 64)
+	assert(t, err == nil)
+	p := make([]byte, 8)
+	_, err = h.ReadAt(p, 0)
+	assert(t, err == nil)
+
+	go h.ReadAt(make([]byte, 8), 200)
+	time.Sleep(10 * time.Millisecond)
+
+	// Cached blocks are read while the other request is outstanding
+	done := make(chan error)
+	go func() {
+		_, err := h.ReadAt(p, 8)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert(t, err == nil && bytes.Equal(p, content[8:16]))
+	case <-time.After(5 * time.Second):
+		t.Fatal("cached read waited for a request")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"sync"
+)
+
+// Iterate calls fn with successive chunks of at most chunk bytes of a
+// snapshot of the buffer as it was when Iterate was called, until fn
+// returns false. Each chunk is copied while holding the read lock, and
+// fn and everything it does run without the lock, so slow consumers
+// such as background serialization never block writers. The snapshot
+// is copy on write: the first method to take the write lock during the
+// iteration, before doing anything else, copies the part of the buffer
+// not yet iterated, and the rest of the chunks come from that copy.
+// Writes, resizes and even Close therefore never show up in the chunks.
+// Memory written without the lock, through views, windows, BytesUnsafe
+// and the structures kept inside the buffer, is not covered. The chunks
+// are only valid during the call.
+func (b *BufferIO) Iterate(chunk int, fn func(off int64, p []byte) bool) error {
+	if chunk <= 0 {
+		return errors.New("invalid chunk size")
+	}
+
+	b.mu.Lock()
+	if err := b.memory(); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	s := &snapshot{b: b, size: b.size()}
+	b.mu.cow = append(b.mu.cow, s)
+	b.mu.Unlock()
+
+	var p []byte
+	for s.off < s.size {
+		n := s.size - s.off
+		if n > int64(chunk) {
+			n = int64(chunk)
+		}
+		if int64(cap(p)) < n {
+			p = make([]byte, n)
+		}
+		p = p[:n]
+
+		b.mu.RLock()
+		if s.copied != nil {
+			copy(p, s.copied[s.off-s.base:])
+		} else {
+			copy(p, b.buf[s.off:])
+		}
+		off := s.off
+		s.off += n
+		b.mu.RUnlock()
+
+		if !fn(off, p) {
+			break
+		}
+	}
+	// The next writer drops the finished snapshot
+	b.mu.RLock()
+	s.off = s.size
+	b.mu.RUnlock()
+	return nil
+}
+
+// bufferLock is the lock of a buffer. Taking it for writing first saves
+// the snapshots of the iterations in progress.
+type bufferLock struct {
+	sync.RWMutex
+	cow []*snapshot
+}
+
+func (l *bufferLock) Lock() {
+	l.RWMutex.Lock()
+	if len(l.cow) == 0 {
+		return
+	}
+	for _, s := range l.cow {
+		s.save()
+	}
+	l.cow = nil
+}
+
+// snapshot is an iteration of the buffer b in progress. off, the start
+// of the next chunk, moves under the read lock of b, and save runs under
+// the write lock.
+type snapshot struct {
+	b      *BufferIO
+	size   int64
+	off    int64
+	base   int64  // offset of copied in the buffer
+	copied []byte // the rest of the snapshot once saved
+}
+
+// save copies the part of the snapshot still to be iterated
+func (s *snapshot) save() {
+	if s.copied != nil || s.off >= s.size {
+		return
+	}
+	s.base = s.off
+	s.copied = append([]byte(nil), s.b.buf[s.off:s.size]...)
+}
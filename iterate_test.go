@@ -0,0 +1,108 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestIterate(t *testing.T) {
+	b := NewBufferIO(append([]byte(nil), big...))
+
+	var got bytes.Buffer
+	var offs []int64
+	assert(t, b.Iterate(7, func(off int64, p []byte) bool {
+		offs = append(offs, off)
+		got.Write(p)
+		return true
+	}) == nil)
+	assert(t, bytes.Equal(got.Bytes(), big))
+	assert(t, offs[1] == 7)
+	assert(t, len(offs) == (len(big)+6)/7)
+
+	n := 0
+	b.Iterate(1, func(off int64, p []byte) bool {
+		n++
+		return n < 3
+	})
+	assert(t, n == 3)
+
+	assert(t, b.Iterate(0, nil) != nil)
+	b.Close()
+	assert(t, b.Iterate(1, nil) == ErrClosed)
+}
+
+func TestIterateResized(t *testing.T) {
+	// The snapshot keeps the size and contents the buffer had when the
+	// iteration started
+	for _, size := range []int64{12, 20} {
+		b := NewBufferIO(append([]byte(nil), big...))
+		var got bytes.Buffer
+		assert(t, b.Iterate(8, func(off int64, p []byte) bool {
+			got.Write(p)
+			if off == 0 {
+				b.Resize(size)
+				b.WriteAt([]byte{0xff}, 9)
+			}
+			return true
+		}) == nil)
+		assert(t, bytes.Equal(got.Bytes(), big))
+	}
+
+	b := NewBufferIO(append([]byte(nil), big...))
+	var got bytes.Buffer
+	assert(t, b.Iterate(8, func(off int64, p []byte) bool {
+		b.Close()
+		got.Write(p)
+		return true
+	}) == nil)
+	assert(t, bytes.Equal(got.Bytes(), big))
+}
+
+func TestIterateConsistent(t *testing.T) {
+	b := NewBufferIOMake(64 << 10)
+	fill := func(v byte) {
+		b.WriteAt(bytes.Repeat([]byte{v}, 64<<10), 0)
+	}
+	fill(1)
+
+	// Writers keep rewriting the whole buffer with one value, so every
+	// snapshot must hold a single value throughout
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := byte(2); ; v++ {
+			select {
+			case <-done:
+				return
+			default:
+				fill(v)
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		var first byte
+		n := 0
+		b.Iterate(4096, func(off int64, p []byte) bool {
+			if off == 0 {
+				first = p[0]
+			}
+			n += len(p)
+			if bytes.Count(p, []byte{first}) != len(p) {
+				t.Error("inconsistent snapshot")
+				return false
+			}
+			return true
+		})
+		assert(t, n == 64<<10)
+	}
+	close(done)
+	wg.Wait()
+}
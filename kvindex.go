@@ -0,0 +1,209 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+)
+
+var (
+	ErrNoIndex   = errors.New("buffer does not hold an index")
+	ErrIndexFull = errors.New("index is full")
+	ErrKeySize   = errors.New("key or value has the wrong size")
+)
+
+// An index is a hash table with linear probing stored in the buffer. A
+// little endian header
+//
+//	[magic uint32][key size uint32][value size uint32][buckets uint32][count uint32]
+//
+// is followed by the buckets, each holding [state uint8][key][value].
+// Deleted buckets stay as tombstones so probe chains are not broken;
+// Put reuses them.
+const (
+	kvMagic  = 0x49564b42
+	kvHeader = 20
+
+	kvEmpty   = 0
+	kvUsed    = 1
+	kvDeleted = 2
+)
+
+// KVIndex maps fixed size keys to fixed size values inside a buffer, so
+// an index can be persisted along with the data it describes. It is not
+// safe for concurrent use.
+type KVIndex struct {
+	header  []byte
+	buckets []byte
+	key     int
+	value   int
+	n       int
+	count   int
+}
+
+// NewKVIndex formats b as an empty index for keys and values of the
+// given sizes, using all of b for buckets.
+func NewKVIndex(b *BufferIO, keySize, valueSize int) (*KVIndex, error) {
+	if keySize <= 0 || valueSize < 0 {
+		return nil, ErrKeySize
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	n := (len(b.buf) - kvHeader) / (1 + keySize + valueSize)
+	if n < 1 || int64(n) > int64(^uint32(0)) {
+		return nil, ErrTooSmall
+	}
+	x := newKVIndex(b.buf, keySize, valueSize, n)
+	zero(b.buf[:kvHeader+n*x.bucketSize()])
+	h := x.header
+	binary.LittleEndian.PutUint32(h[4:], uint32(keySize))
+	binary.LittleEndian.PutUint32(h[8:], uint32(valueSize))
+	binary.LittleEndian.PutUint32(h[12:], uint32(n))
+	binary.LittleEndian.PutUint32(h, kvMagic)
+	return x, nil
+}
+
+// OpenKVIndex attaches to an index previously formatted in b.
+func OpenKVIndex(b *BufferIO) (*KVIndex, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	if len(b.buf) < kvHeader || binary.LittleEndian.Uint32(b.buf) != kvMagic {
+		return nil, ErrNoIndex
+	}
+	keySize := int64(binary.LittleEndian.Uint32(b.buf[4:]))
+	valueSize := int64(binary.LittleEndian.Uint32(b.buf[8:]))
+	n := int64(binary.LittleEndian.Uint32(b.buf[12:]))
+	count := int64(binary.LittleEndian.Uint32(b.buf[16:]))
+	// a corrupt header can make the buckets' total size overflow, so
+	// divide instead
+	size := int64(len(b.buf))
+	if keySize == 0 || n == 0 || count > n ||
+		n > (size-kvHeader)/(1+keySize+valueSize) {
+		return nil, ErrNoIndex
+	}
+	x := newKVIndex(b.buf, int(keySize), int(valueSize), int(n))
+	x.count = int(count)
+	return x, nil
+}
+
+func newKVIndex(buf []byte, keySize, valueSize, n int) *KVIndex {
+	x := &KVIndex{key: keySize, value: valueSize, n: n}
+	x.header = buf[:kvHeader]
+	x.buckets = buf[kvHeader : kvHeader+n*x.bucketSize()]
+	return x
+}
+
+func (x *KVIndex) bucketSize() int {
+	return 1 + x.key + x.value
+}
+
+func (x *KVIndex) bucket(i int) []byte {
+	size := x.bucketSize()
+	return x.buckets[i*size : (i+1)*size]
+}
+
+func (x *KVIndex) setCount(count int) {
+	x.count = count
+	binary.LittleEndian.PutUint32(x.header[16:], uint32(count))
+}
+
+// find returns the bucket holding key, or the bucket Put should use for
+// it and false
+func (x *KVIndex) find(key []byte) (int, bool) {
+	h := fnv.New32a()
+	h.Write(key)
+	start := int(h.Sum32() % uint32(x.n))
+
+	free := -1
+	for probe := 0; probe < x.n; probe++ {
+		i := (start + probe) % x.n
+		bk := x.bucket(i)
+		switch bk[0] {
+		case kvEmpty:
+			if free < 0 {
+				free = i
+			}
+			return free, false
+		case kvDeleted:
+			if free < 0 {
+				free = i
+			}
+		default:
+			if bytes.Equal(bk[1:1+x.key], key) {
+				return i, true
+			}
+		}
+	}
+	return free, false
+}
+
+// Put stores value under key, replacing any previous value.
+func (x *KVIndex) Put(key, value []byte) error {
+	if len(key) != x.key || len(value) != x.value {
+		return ErrKeySize
+	}
+	i, found := x.find(key)
+	if i < 0 {
+		return ErrIndexFull
+	}
+	bk := x.bucket(i)
+	copy(bk[1+x.key:], value)
+	if !found {
+		copy(bk[1:], key)
+		bk[0] = kvUsed
+		x.setCount(x.count + 1)
+	}
+	return nil
+}
+
+// Get returns a copy of the value stored under key.
+func (x *KVIndex) Get(key []byte) ([]byte, bool) {
+	if len(key) != x.key {
+		return nil, false
+	}
+	i, found := x.find(key)
+	if !found {
+		return nil, false
+	}
+	return append([]byte(nil), x.bucket(i)[1+x.key:]...), true
+}
+
+// Delete removes key, reporting whether it was present.
+func (x *KVIndex) Delete(key []byte) bool {
+	if len(key) != x.key {
+		return false
+	}
+	i, found := x.find(key)
+	if !found {
+		return false
+	}
+	bk := x.bucket(i)
+	zero(bk[1:])
+	bk[0] = kvDeleted
+	x.setCount(x.count - 1)
+	return true
+}
+
+// Len returns the number of keys stored.
+func (x *KVIndex) Len() int {
+	return x.count
+}
+
+// Capacity returns the number of buckets.
+func (x *KVIndex) Capacity() int {
+	return x.n
+}
@@ -0,0 +1,114 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func kvKey(i int) []byte {
+	k := make([]byte, 8)
+	binary.LittleEndian.PutUint64(k, uint64(i))
+	return k
+}
+
+func TestKVIndex(t *testing.T) {
+	b := NewBufferIOMake(kvHeader + 64*(1+8+4))
+	x, err := NewKVIndex(b, 8, 4)
+	assert(t, err == nil)
+	assert(t, x.Capacity() == 64)
+
+	for i := 0; i < 40; i++ {
+		assert(t, x.Put(kvKey(i), []byte{byte(i), 0, 0, 1}) == nil)
+	}
+	assert(t, x.Len() == 40)
+
+	v, ok := x.Get(kvKey(7))
+	assert(t, ok)
+	assert(t, v[0] == 7)
+	_, ok = x.Get(kvKey(100))
+	assert(t, !ok)
+
+	// Replacing keeps the count
+	assert(t, x.Put(kvKey(7), []byte{70, 0, 0, 0}) == nil)
+	assert(t, x.Len() == 40)
+	v, _ = x.Get(kvKey(7))
+	assert(t, v[0] == 70)
+
+	assert(t, x.Delete(kvKey(3)))
+	assert(t, !x.Delete(kvKey(3)))
+	assert(t, x.Len() == 39)
+	_, ok = x.Get(kvKey(3))
+	assert(t, !ok)
+
+	// Keys probing past tombstones are still found
+	for i := 0; i < 40; i++ {
+		_, ok := x.Get(kvKey(i))
+		assert(t, ok == (i != 3))
+	}
+
+	assert(t, x.Put([]byte("short"), []byte{0, 0, 0, 0}) == ErrKeySize)
+	assert(t, x.Put(kvKey(1), []byte{0}) == ErrKeySize)
+}
+
+func TestKVIndexFull(t *testing.T) {
+	b := NewBufferIOMake(kvHeader + 4*(1+8))
+	x, err := NewKVIndex(b, 8, 0)
+	assert(t, err == nil)
+
+	for i := 0; i < 4; i++ {
+		assert(t, x.Put(kvKey(i), nil) == nil)
+	}
+	assert(t, x.Put(kvKey(4), nil) == ErrIndexFull)
+
+	// Tombstones are reused
+	assert(t, x.Delete(kvKey(2)))
+	assert(t, x.Put(kvKey(4), nil) == nil)
+	_, ok := x.Get(kvKey(4))
+	assert(t, ok)
+	assert(t, x.Len() == 4)
+}
+
+func TestKVIndexOpen(t *testing.T) {
+	b := NewBufferIOMake(4096)
+	_, err := OpenKVIndex(b)
+	assert(t, err == ErrNoIndex)
+
+	x, _ := NewKVIndex(b, 8, 8)
+	for i := 0; i < 100; i++ {
+		x.Put(kvKey(i), kvKey(i*i))
+	}
+	x.Delete(kvKey(50))
+
+	// Reopen from a copy of the memory
+	y, err := OpenKVIndex(NewBufferIO(append([]byte(nil), b.Bytes()...)))
+	assert(t, err == nil)
+	assert(t, y.Len() == 99)
+	assert(t, y.Capacity() == x.Capacity())
+	v, ok := y.Get(kvKey(9))
+	assert(t, ok)
+	assert(t, binary.LittleEndian.Uint64(v) == 81)
+	_, ok = y.Get(kvKey(50))
+	assert(t, !ok)
+
+	_, err = NewKVIndex(NewBufferIOMake(kvHeader+8), 8, 8)
+	assert(t, err == ErrTooSmall)
+	_, err = NewKVIndex(b, 0, 8)
+	assert(t, err == ErrKeySize)
+
+	// Headers claiming more than the buffer holds are rejected
+	_, err = OpenKVIndex(NewBufferIO(append([]byte(nil), b.Bytes()[:2048]...)))
+	assert(t, err == ErrNoIndex)
+
+	// as are headers whose buckets overflow when multiplied
+	corrupt := make([]byte, 1<<20)
+	copy(corrupt, b.Bytes()[:kvHeader])
+	binary.LittleEndian.PutUint32(corrupt[4:], 0xffffffff)
+	binary.LittleEndian.PutUint32(corrupt[8:], 0xffffffff)
+	binary.LittleEndian.PutUint32(corrupt[12:], 4294901768)
+	_, err = OpenKVIndex(NewBufferIO(corrupt))
+	assert(t, err == ErrNoIndex)
+}
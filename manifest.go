@@ -0,0 +1,266 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var ErrHashUnavailable = errors.New("hash function not linked into the binary")
+
+// A Manifest lists the digest of every chunk of a buffer, so a copy of
+// its contents stored elsewhere can be checked chunk by chunk without
+// importing it again.
+type Manifest struct {
+	Hash      crypto.Hash
+	ChunkSize int
+	Entries   []ManifestEntry
+}
+
+// ManifestEntry is the digest of Length bytes at Offset.
+type ManifestEntry struct {
+	Offset int64
+	Length int
+	Digest []byte
+}
+
+// ExportManifest digests the buffer in chunks of chunkSize bytes with
+// h, whose implementation must be linked in, as by importing
+// crypto/sha256. The last chunk may be short.
+func (b *BufferIO) ExportManifest(chunkSize int, h crypto.Hash) (*Manifest, error) {
+	if chunkSize <= 0 || int64(chunkSize) > int64(^uint32(0)) {
+		return nil, errors.New("invalid chunk size")
+	}
+	if !h.Available() {
+		return nil, ErrHashUnavailable
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Hash: h, ChunkSize: chunkSize}
+	p := make([]byte, chunkSize)
+	for off := int64(0); off < b.length(); off += int64(chunkSize) {
+		n, err := b.readAt(p, off)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		m.Entries = append(m.Entries, ManifestEntry{
+			Offset: off,
+			Length: n,
+			Digest: digest(h, p[:n]),
+		})
+	}
+	return m, nil
+}
+
+// VerifyAgainstManifest reads r, which holds the contents the manifest
+// was exported from, and checks each chunk against its entry as it
+// arrives. On the first chunk which differs it returns the offset of
+// that chunk and ErrChecksum; a chunk cut short by the end of r returns
+// io.ErrUnexpectedEOF instead. Data in r beyond the last entry is
+// reported as a mismatch at the end of the manifest. Otherwise the
+// offset returned is the number of bytes verified.
+func (m *Manifest) VerifyAgainstManifest(r io.Reader) (int64, error) {
+	if !m.Hash.Available() {
+		return 0, ErrHashUnavailable
+	}
+	p := make([]byte, m.ChunkSize)
+	var off int64
+	for _, e := range m.Entries {
+		if e.Offset != off || e.Length < 0 || e.Length > m.ChunkSize {
+			return off, ErrCorrupt
+		}
+		n, err := io.ReadFull(r, p[:e.Length])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return off, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return off, err
+		}
+		if !bytes.Equal(digest(m.Hash, p[:n]), e.Digest) {
+			return off, ErrChecksum
+		}
+		off += int64(n)
+	}
+	if n, _ := r.Read(p[:1]); n > 0 {
+		return off, ErrChecksum
+	}
+	return off, nil
+}
+
+// Root returns the root of a Merkle tree over the digests of the
+// entries, pairing neighbours level by level and carrying an odd one
+// up unchanged. A root kept somewhere trusted vouches for a manifest
+// stored alongside a copy, which then vouches for each chunk.
+func (m *Manifest) Root() ([]byte, error) {
+	if !m.Hash.Available() {
+		return nil, ErrHashUnavailable
+	}
+	level := make([][]byte, len(m.Entries))
+	for i, e := range m.Entries {
+		level[i] = e.Digest
+	}
+	if len(level) == 0 {
+		return digest(m.Hash, nil), nil
+	}
+	for len(level) > 1 {
+		next := level[:0:0]
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			d := m.Hash.New()
+			d.Write(level[i])
+			d.Write(level[i+1])
+			next = append(next, d.Sum(nil))
+		}
+		level = next
+	}
+	return level[0], nil
+}
+
+// Salvage lists the chunks of an import which were placed in the buffer
+// and those which were not because their digest did not match or r
+// ended before them.
+type Salvage struct {
+	Recovered []ManifestEntry
+	Corrupt   []ManifestEntry
+}
+
+// ImportVerified fills the buffer from r, which holds the contents m
+// was exported from, checking each chunk against m before writing it at
+// its offset. Without salvage the import stops at the first bad chunk
+// with ErrChecksum, or io.ErrUnexpectedEOF when r ends early. With
+// salvage bad chunks are skipped, leaving that part of the buffer as it
+// was, and the import continues. Either way the returned Salvage says
+// which chunks were placed.
+func (b *BufferIO) ImportVerified(r io.Reader, m *Manifest, salvage bool) (*Salvage, error) {
+	if !m.Hash.Available() {
+		return nil, ErrHashUnavailable
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.memory(); err != nil {
+		return nil, err
+	}
+
+	s := &Salvage{}
+	p := make([]byte, m.ChunkSize)
+	var off int64
+	ended := false
+	for _, e := range m.Entries {
+		if e.Offset != off || e.Length < 0 || e.Length > m.ChunkSize {
+			return s, ErrCorrupt
+		}
+		if e.Offset > b.size()-int64(e.Length) {
+			return s, ErrOverrun
+		}
+		off += int64(e.Length)
+
+		if ended {
+			s.Corrupt = append(s.Corrupt, e)
+			continue
+		}
+		_, err := io.ReadFull(r, p[:e.Length])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if !salvage {
+				return s, io.ErrUnexpectedEOF
+			}
+			// r is done, so no later chunk arrives either
+			ended = true
+			s.Corrupt = append(s.Corrupt, e)
+			continue
+		}
+		if err != nil {
+			return s, err
+		}
+		if !bytes.Equal(digest(m.Hash, p[:e.Length]), e.Digest) {
+			if !salvage {
+				return s, ErrChecksum
+			}
+			s.Corrupt = append(s.Corrupt, e)
+			continue
+		}
+		if _, err := b.writeAt(p[:e.Length], e.Offset); err != nil {
+			return s, err
+		}
+		s.Recovered = append(s.Recovered, e)
+	}
+	return s, nil
+}
+
+// MarshalBinary encodes the manifest as
+//
+//	[hash uint32][chunk size uint32][entries uint32][digest size uint32]
+//	entries * [offset uint64][length uint32][digest]
+//
+// in little endian.
+func (m *Manifest) MarshalBinary() ([]byte, error) {
+	if !m.Hash.Available() {
+		return nil, ErrHashUnavailable
+	}
+	ds := m.Hash.Size()
+	p := make([]byte, 16, 16+(12+ds)*len(m.Entries))
+	binary.LittleEndian.PutUint32(p, uint32(m.Hash))
+	binary.LittleEndian.PutUint32(p[4:], uint32(m.ChunkSize))
+	binary.LittleEndian.PutUint32(p[8:], uint32(len(m.Entries)))
+	binary.LittleEndian.PutUint32(p[12:], uint32(ds))
+	var h [12]byte
+	for _, e := range m.Entries {
+		if len(e.Digest) != ds {
+			return nil, ErrCorrupt
+		}
+		binary.LittleEndian.PutUint64(h[:], uint64(e.Offset))
+		binary.LittleEndian.PutUint32(h[8:], uint32(e.Length))
+		p = append(p, h[:]...)
+		p = append(p, e.Digest...)
+	}
+	return p, nil
+}
+
+// UnmarshalBinary decodes a manifest encoded by MarshalBinary.
+func (m *Manifest) UnmarshalBinary(p []byte) error {
+	if len(p) < 16 {
+		return ErrCorrupt
+	}
+	h := crypto.Hash(binary.LittleEndian.Uint32(p))
+	if !h.Available() {
+		return ErrHashUnavailable
+	}
+	n := int64(binary.LittleEndian.Uint32(p[8:]))
+	ds := int(binary.LittleEndian.Uint32(p[12:]))
+	if ds != h.Size() || int64(len(p)) != 16+int64(12+ds)*n {
+		return ErrCorrupt
+	}
+	m.Hash = h
+	m.ChunkSize = int(binary.LittleEndian.Uint32(p[4:]))
+	m.Entries = make([]ManifestEntry, n)
+	p = p[16:]
+	for i := range m.Entries {
+		m.Entries[i] = ManifestEntry{
+			Offset: int64(binary.LittleEndian.Uint64(p)),
+			Length: int(binary.LittleEndian.Uint32(p[8:])),
+			Digest: append([]byte(nil), p[12:12+ds]...),
+		}
+		p = p[12+ds:]
+	}
+	return nil
+}
+
+func digest(h crypto.Hash, p []byte) []byte {
+	d := h.New()
+	d.Write(p)
+	return d.Sum(nil)
+}
@@ -0,0 +1,136 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"io"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	b := NewBufferIO(append([]byte(nil), big...))
+	m, err := b.ExportManifest(8, crypto.SHA256)
+	assert(t, err == nil)
+	assert(t, len(m.Entries) == (len(big)+7)/8)
+	last := m.Entries[len(m.Entries)-1]
+	assert(t, last.Offset+int64(last.Length) == int64(len(big)))
+	sum := sha256.Sum256(big[8:16])
+	assert(t, bytes.Equal(m.Entries[1].Digest, sum[:]))
+
+	n, err := m.VerifyAgainstManifest(bytes.NewReader(big))
+	assert(t, err == nil && n == int64(len(big)))
+
+	// The first differing chunk is reported
+	bad := append([]byte(nil), big...)
+	bad[20]++
+	n, err = m.VerifyAgainstManifest(bytes.NewReader(bad))
+	assert(t, err == ErrChecksum && n == 16)
+
+	n, err = m.VerifyAgainstManifest(bytes.NewReader(big[:13]))
+	assert(t, err == io.ErrUnexpectedEOF && n == 8)
+
+	n, err = m.VerifyAgainstManifest(bytes.NewReader(append(big, 0)))
+	assert(t, err == ErrChecksum && n == int64(len(big)))
+
+	// Backed buffers are digested through their reader
+	from := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	fm, err := from.ExportManifest(8, crypto.SHA256)
+	assert(t, err == nil && len(fm.Entries) == len(m.Entries))
+	assert(t, bytes.Equal(fm.Entries[len(fm.Entries)-1].Digest, last.Digest))
+
+	_, err = b.ExportManifest(0, crypto.SHA256)
+	assert(t, err != nil)
+	_, err = b.ExportManifest(8, crypto.Hash(0))
+	assert(t, err == ErrHashUnavailable)
+}
+
+func TestManifestMarshal(t *testing.T) {
+	b := NewBufferIO(append([]byte(nil), big...))
+	m, _ := b.ExportManifest(16, crypto.SHA256)
+	p, err := m.MarshalBinary()
+	assert(t, err == nil && len(p) == 16+len(m.Entries)*(12+sha256.Size))
+
+	var saved Manifest
+	assert(t, saved.UnmarshalBinary(p) == nil)
+	assert(t, saved.Hash == crypto.SHA256 && saved.ChunkSize == 16)
+	assert(t, len(saved.Entries) == len(m.Entries))
+	n, err := saved.VerifyAgainstManifest(bytes.NewReader(big))
+	assert(t, err == nil && n == int64(len(big)))
+
+	assert(t, saved.UnmarshalBinary(p[:len(p)-1]) == ErrCorrupt)
+	assert(t, saved.UnmarshalBinary(p[:10]) == ErrCorrupt)
+
+	// Reordered entries are refused
+	saved.Entries[0], saved.Entries[1] = saved.Entries[1], saved.Entries[0]
+	_, err = saved.VerifyAgainstManifest(bytes.NewReader(big))
+	assert(t, err == ErrCorrupt)
+}
+
+func TestManifestRoot(t *testing.T) {
+	b := NewBufferIO(append([]byte(nil), big...))
+	m, _ := b.ExportManifest(8, crypto.SHA256)
+	root, err := m.Root()
+	assert(t, err == nil && len(root) == sha256.Size)
+
+	// The root changes with any chunk
+	bad := append([]byte(nil), big...)
+	bad[len(bad)-1]++
+	other, _ := NewBufferIO(bad).ExportManifest(8, crypto.SHA256)
+	oroot, _ := other.Root()
+	assert(t, !bytes.Equal(root, oroot))
+
+	one := &Manifest{Hash: crypto.SHA256, Entries: m.Entries[:1]}
+	r, _ := one.Root()
+	assert(t, bytes.Equal(r, m.Entries[0].Digest))
+	three := &Manifest{Hash: crypto.SHA256, Entries: m.Entries[:3]}
+	r, _ = three.Root()
+	h := sha256.New()
+	h.Write(m.Entries[0].Digest)
+	h.Write(m.Entries[1].Digest)
+	pair := h.Sum(nil)
+	h.Reset()
+	h.Write(pair)
+	h.Write(m.Entries[2].Digest)
+	assert(t, bytes.Equal(r, h.Sum(nil)))
+}
+
+func TestImportVerified(t *testing.T) {
+	m, _ := NewBufferIO(append([]byte(nil), big...)).ExportManifest(8, crypto.SHA256)
+
+	b := NewBufferIOMake(len(big))
+	s, err := b.ImportVerified(bytes.NewReader(big), m, false)
+	assert(t, err == nil && bytes.Equal(b.Bytes(), big))
+	assert(t, len(s.Recovered) == len(m.Entries) && len(s.Corrupt) == 0)
+
+	// The first bad chunk stops a strict import
+	bad := append([]byte(nil), big...)
+	bad[10]++
+	b = NewBufferIOMake(len(big))
+	s, err = b.ImportVerified(bytes.NewReader(bad), m, false)
+	assert(t, err == ErrChecksum && len(s.Recovered) == 1)
+	assert(t, isZero(b.Bytes()[8:]))
+
+	// Salvage skips it and reports it
+	s, err = b.ImportVerified(bytes.NewReader(bad), m, true)
+	assert(t, err == nil)
+	assert(t, len(s.Corrupt) == 1 && s.Corrupt[0].Offset == 8)
+	assert(t, len(s.Recovered) == len(m.Entries)-1)
+	assert(t, isZero(b.Bytes()[8:16]))
+	assert(t, bytes.Equal(b.Bytes()[16:], big[16:]))
+
+	// A truncated copy leaves the missing chunks corrupt
+	b = NewBufferIOMake(len(big))
+	_, err = b.ImportVerified(bytes.NewReader(big[:20]), m, false)
+	assert(t, err == io.ErrUnexpectedEOF)
+	s, err = b.ImportVerified(bytes.NewReader(big[:20]), m, true)
+	assert(t, err == nil && len(s.Recovered) == 2)
+	assert(t, len(s.Corrupt) == len(m.Entries)-2 && s.Corrupt[0].Offset == 16)
+
+	_, err = NewBufferIOMake(8).ImportVerified(bytes.NewReader(big), m, true)
+	assert(t, err == ErrOverrun)
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// Values implementing encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, such as UUIDs and timestamps, are encoded
+// by WriteData and decoded by ReadData through those methods, whether
+// passed directly or as fields of a struct. Decoding needs the size of
+// the encoding, which comes from a len or lenof tag on a field, or else
+// from the fixed encoding/binary size of the type. A len field passes
+// its padding to UnmarshalBinary as well. WriteData
+// checks that MarshalBinary returns that many bytes, and types without
+// such a size can only be read as fields sized by tags.
+
+var (
+	marshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// isMarshaler reports whether values of t encode through methods
+func isMarshaler(t reflect.Type) bool {
+	p := reflect.PtrTo(t)
+	return t.Implements(marshalerType) || p.Implements(marshalerType) ||
+		p.Implements(unmarshalerType)
+}
+
+// marshal returns the encoding of v through its MarshalBinary method
+func marshal(v reflect.Value) ([]byte, error) {
+	if !v.Type().Implements(marshalerType) {
+		if !v.CanAddr() {
+			c := reflect.New(v.Type()).Elem()
+			c.Set(v)
+			v = c
+		}
+		v = v.Addr()
+	}
+	m, ok := v.Interface().(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, ErrInvalidType
+	}
+	return m.MarshalBinary()
+}
+
+// unmarshal decodes p into v, which must be addressable, through its
+// UnmarshalBinary method
+func unmarshal(v reflect.Value, p []byte) error {
+	u, ok := v.Addr().Interface().(encoding.BinaryUnmarshaler)
+	if !ok {
+		return ErrInvalidType
+	}
+	return u.UnmarshalBinary(p)
+}
+
+// marshalData returns the encoding of data when it encodes through
+// MarshalBinary, checked against its fixed size if it has one
+func marshalData(data interface{}) ([]byte, bool, error) {
+	m, ok := data.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, false, nil
+	}
+	enc, err := m.MarshalBinary()
+	if err != nil {
+		return nil, true, err
+	}
+	if n := binary.Size(data); n >= 0 && len(enc) != n {
+		return nil, true, fmt.Errorf("bufferio: MarshalBinary of %T returned %d bytes, not its size of %d",
+			data, len(enc), n)
+	}
+	return enc, true, nil
+}
+
+// unmarshalSize returns the number of bytes UnmarshalBinary of data
+// takes, when data decodes through it
+func unmarshalSize(data interface{}) (int, bool, error) {
+	if _, ok := data.(encoding.BinaryUnmarshaler); !ok {
+		return 0, false, nil
+	}
+	n := binary.Size(data)
+	if n < 0 {
+		return 0, true, ErrInvalidType
+	}
+	return n, true, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// testUUID is stored reversed, to tell MarshalBinary from encoding/binary
+type testUUID [4]byte
+
+func (u testUUID) MarshalBinary() ([]byte, error) {
+	return []byte{u[3], u[2], u[1], u[0]}, nil
+}
+
+func (u *testUUID) UnmarshalBinary(p []byte) error {
+	if len(p) != 4 {
+		return errors.New("bad uuid")
+	}
+	*u = testUUID{p[3], p[2], p[1], p[0]}
+	return nil
+}
+
+// testColor encodes as its name
+type testColor uint8
+
+var testColors = []string{"red", "green"}
+
+func (c testColor) MarshalBinary() ([]byte, error) {
+	return []byte(testColors[c]), nil
+}
+
+func (c *testColor) UnmarshalBinary(p []byte) error {
+	for i, s := range testColors {
+		if s == string(p) {
+			*c = testColor(i)
+			return nil
+		}
+	}
+	return errors.New("bad color")
+}
+
+func TestMarshalerDirect(t *testing.T) {
+	b := NewBufferIOMake(16)
+	u := testUUID{1, 2, 3, 4}
+	assert(t, b.WriteDataLE(u) == nil)
+	assert(t, string(b.Bytes()[:4]) == "\x04\x03\x02\x01")
+	n, err := EncodedSize(binary.LittleEndian, u)
+	assert(t, err == nil && n == 4)
+
+	var back testUUID
+	b.Seek(0, io.SeekStart)
+	assert(t, b.ReadDataLE(&back) == nil && back == u)
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 4)
+
+	// Marshaled sizes must match fixed sizes
+	assert(t, b.WriteDataLE(testColor(1)) != nil)
+	b.Seek(14, io.SeekStart)
+	assert(t, b.ReadDataLE(&back) == io.ErrUnexpectedEOF)
+
+	// Types without a fixed size cannot be read on their own
+	var when time.Time
+	assert(t, b.ReadDataLE(&when) == ErrInvalidType)
+}
+
+type marshalRecord struct {
+	ID       testUUID
+	ColorLen uint8 `bufferio:"lenof=Color"`
+	Color    testColor
+	When     time.Time `bufferio:"len=15"`
+	Count    uint16
+}
+
+func TestMarshalerFields(t *testing.T) {
+	when := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := marshalRecord{ID: testUUID{1, 2, 3, 4}, Color: 1, When: when, Count: 9}
+
+	b := NewBufferIOMake(64)
+	assert(t, b.WriteDataBE(r) == nil)
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 4+1+5+15+2)
+	p := b.Bytes()
+	assert(t, string(p[:4]) == "\x04\x03\x02\x01")
+	assert(t, p[4] == 5 && string(p[5:10]) == "green")
+
+	var back marshalRecord
+	b.Seek(0, io.SeekStart)
+	assert(t, b.ReadDataBE(&back) == nil)
+	assert(t, back.ID == r.ID && back.Color == 1 && back.Count == 9)
+	assert(t, back.When.Equal(when))
+
+	// Errors from UnmarshalBinary are returned
+	p[5] = 'G'
+	b.Seek(0, io.SeekStart)
+	assert(t, b.ReadDataBE(&back) != nil)
+	pos, _ = b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 0)
+
+	var unbounded struct {
+		Color testColor
+	}
+	assert(t, b.WriteDataBE(&unbounded) != nil)
+}
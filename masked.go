@@ -0,0 +1,32 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+)
+
+var ErrMaskLength = errors.New("value and mask lengths differ")
+
+// WriteMasked updates the bits set in mask at off with the matching bits
+// of value, leaving all other bits untouched. The read-modify-write is
+// atomic with respect to other operations on the buffer.
+func (b *BufferIO) WriteMasked(off int64, value, mask []byte) error {
+	if len(value) != len(mask) {
+		return ErrMaskLength
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.slice(off, int64(len(value)))
+	if err != nil {
+		return err
+	}
+	for i := range r {
+		r[i] = r[i]&^mask[i] | value[i]&mask[i]
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWriteMasked(t *testing.T) {
+	bio := NewBufferIO([]byte{0xff, 0x00, 0xaa, 0x55})
+
+	err := bio.WriteMasked(1, []byte{0xff, 0x0f}, []byte{0xf0, 0x0f})
+	assert(t, err == nil)
+	assert(t, bio.buf[0] == 0xff)
+	assert(t, bio.buf[1] == 0xf0)
+	assert(t, bio.buf[2] == 0xaf)
+	assert(t, bio.buf[3] == 0x55)
+
+	// Clearing bits
+	err = bio.WriteMasked(0, []byte{0x00}, []byte{0x81})
+	assert(t, err == nil)
+	assert(t, bio.buf[0] == 0x7e)
+
+	assert(t, bio.WriteMasked(0, []byte{1}, []byte{1, 2}) == ErrMaskLength)
+	assert(t, bio.WriteMasked(3, []byte{1, 2}, []byte{1, 2}) == ErrOverrun)
+	assert(t, bio.buf[3] == 0x55)
+}
+
+func TestWriteMaskedConcurrent(t *testing.T) {
+	bio := NewBufferIOMake(8)
+
+	// Each goroutine owns one bit of every byte
+	var wg sync.WaitGroup
+	for bit := uint(0); bit < 8; bit++ {
+		wg.Add(1)
+		go func(bit uint) {
+			defer wg.Done()
+			mask := make([]byte, 8)
+			value := make([]byte, 8)
+			for i := range mask {
+				mask[i] = 1 << bit
+			}
+			for n := 0; n < 1000; n++ {
+				for i := range value {
+					value[i] = byte(n%2) << bit
+				}
+				bio.WriteMasked(0, value, mask)
+			}
+			for i := range value {
+				value[i] = 1 << bit
+			}
+			bio.WriteMasked(0, value, mask)
+		}(bit)
+	}
+	wg.Wait()
+
+	for _, c := range bio.Bytes() {
+		assert(t, c == 0xff)
+	}
+}
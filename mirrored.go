@@ -0,0 +1,151 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// DivergenceError is returned by verifying reads of a Mirrored buffer
+// when replicas hold different data for the range read.
+type DivergenceError struct {
+	Off      int64
+	Len      int
+	Replicas []int // replicas which differ from the first one read
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("replicas %v diverge in %d bytes at offset %d",
+		e.Replicas, e.Len, e.Off)
+}
+
+// Mirrored writes to every replica and reads from any of them. With
+// verification enabled reads compare all replicas instead.
+type Mirrored struct {
+	replicas []BufferReaderWriter
+	next     uint32
+	verify   int32
+}
+
+func NewMirrored(replicas ...BufferReaderWriter) (*Mirrored, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("no replicas")
+	}
+	return &Mirrored{replicas: replicas}, nil
+}
+
+// SetVerify turns verify on read on or off.
+func (m *Mirrored) SetVerify(verify bool) {
+	v := int32(0)
+	if verify {
+		v = 1
+	}
+	atomic.StoreInt32(&m.verify, v)
+}
+
+// ReadAt reads from the replicas in turn, falling back to the next one
+// when a replica fails. When verifying, every replica is read and a
+// *DivergenceError is returned, along with the data of the first
+// replica which could be read, if they do not all agree. Replicas
+// which fail count as diverging.
+func (m *Mirrored) ReadAt(p []byte, off int64) (int, error) {
+	if atomic.LoadInt32(&m.verify) != 0 {
+		return m.readVerify(p, off)
+	}
+
+	start := int(atomic.AddUint32(&m.next, 1) % uint32(len(m.replicas)))
+	var n int
+	var err error
+	for i := 0; i < len(m.replicas); i++ {
+		r := m.replicas[(start+i)%len(m.replicas)]
+		n, err = r.ReadAt(p, off)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+func (m *Mirrored) readVerify(p []byte, off int64) (int, error) {
+	// The first replica which reads is compared with the others, and
+	// those failing before it count as diverging
+	ref := -1
+	var n int
+	var err error
+	for i, r := range m.replicas {
+		n, err = r.ReadAt(p, off)
+		if err == nil || err == io.EOF {
+			ref = i
+			break
+		}
+	}
+	if ref < 0 {
+		return n, err
+	}
+
+	divergence := &DivergenceError{Off: off, Len: n}
+	for i := 0; i < ref; i++ {
+		divergence.Replicas = append(divergence.Replicas, i)
+	}
+	other := make([]byte, len(p))
+	for i := ref + 1; i < len(m.replicas); i++ {
+		on, oerr := m.replicas[i].ReadAt(other, off)
+		if on != n || !bytes.Equal(other[:on], p[:n]) || (oerr == nil) != (err == nil) {
+			divergence.Replicas = append(divergence.Replicas, i)
+		}
+	}
+	if len(divergence.Replicas) > 0 {
+		return n, divergence
+	}
+	return n, err
+}
+
+// WriteAt writes p to all replicas in parallel. It returns the smallest
+// count written and the first error.
+func (m *Mirrored) WriteAt(p []byte, off int64) (int, error) {
+	counts := make([]int, len(m.replicas))
+	errs := make([]error, len(m.replicas))
+
+	var wg sync.WaitGroup
+	for i, r := range m.replicas {
+		wg.Add(1)
+		go func(i int, r BufferReaderWriter) {
+			defer wg.Done()
+			counts[i], errs[i] = r.WriteAt(p, off)
+		}(i, r)
+	}
+	wg.Wait()
+
+	n := len(p)
+	var err error
+	for i := range m.replicas {
+		if counts[i] < n {
+			n = counts[i]
+		}
+		if err == nil {
+			err = errs[i]
+		}
+	}
+	return n, err
+}
+
+// Flush flushes every replica which is a Flusher and returns the first
+// error.
+func (m *Mirrored) Flush() error {
+	var err error
+	for _, r := range m.replicas {
+		if f, ok := r.(Flusher); ok {
+			if ferr := f.Flush(); err == nil {
+				err = ferr
+			}
+		}
+	}
+	return err
+}
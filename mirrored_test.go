@@ -0,0 +1,127 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// failingReader fails every read
+type failingReader struct {
+	*BufferIO
+}
+
+func (f failingReader) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errors.New("replica offline")
+}
+
+func TestMirroredWrite(t *testing.T) {
+	a, b := NewBufferIOMake(16), NewBufferIOMake(16)
+	m, err := NewMirrored(a, b)
+	assert(t, err == nil)
+
+	n, err := m.WriteAt(src, 4)
+	assert(t, n == len(src))
+	assert(t, err == nil)
+	assert(t, bytes.Equal(a.buf[4:12], src))
+	assert(t, bytes.Equal(b.buf[4:12], src))
+
+	// The smallest replica limits the write
+	m, _ = NewMirrored(NewBufferIOMake(16), NewBufferIOMake(8))
+	n, err = m.WriteAt(src, 4)
+	assert(t, n == 4)
+	assert(t, err == io.ErrShortWrite)
+
+	_, err = NewMirrored()
+	assert(t, err != nil)
+}
+
+func TestMirroredRead(t *testing.T) {
+	a := NewBufferIO(append([]byte(nil), big...))
+	b := NewBufferIO(append([]byte(nil), big...))
+	m, _ := NewMirrored(failingReader{a}, b)
+
+	// Falls back past the failing replica, whichever is tried first
+	for i := 0; i < 4; i++ {
+		buf := make([]byte, 8)
+		n, err := m.ReadAt(buf, 8)
+		assert(t, n == 8)
+		assert(t, err == nil)
+		assert(t, bytes.Equal(buf, big[8:16]))
+	}
+
+	m, _ = NewMirrored(failingReader{a}, failingReader{b})
+	_, err := m.ReadAt(make([]byte, 8), 0)
+	assert(t, err != nil)
+}
+
+func TestMirroredVerify(t *testing.T) {
+	a := NewBufferIO(append([]byte(nil), big...))
+	b := NewBufferIO(append([]byte(nil), big...))
+	c := NewBufferIO(append([]byte(nil), big...))
+	m, _ := NewMirrored(a, b, c)
+	m.SetVerify(true)
+
+	buf := make([]byte, 8)
+	n, err := m.ReadAt(buf, 0)
+	assert(t, n == 8)
+	assert(t, err == nil)
+
+	c.buf[3] ^= 0xff
+	n, err = m.ReadAt(buf, 0)
+	assert(t, n == 8)
+	derr, ok := err.(*DivergenceError)
+	assert(t, ok)
+	assert(t, len(derr.Replicas) == 1)
+	assert(t, derr.Replicas[0] == 2)
+	assert(t, derr.Off == 0)
+	assert(t, derr.Len == 8)
+	assert(t, derr.Error() == "replicas [2] diverge in 8 bytes at offset 0")
+	assert(t, bytes.Equal(buf, big[:8]))
+
+	// Ranges where they agree are fine
+	_, err = m.ReadAt(buf, 8)
+	assert(t, err == nil)
+
+	m.SetVerify(false)
+	_, err = m.ReadAt(buf, 0)
+	assert(t, err == nil)
+
+	// A failing first replica falls back to the others
+	m, _ = NewMirrored(failingReader{a}, b, c)
+	m.SetVerify(true)
+	n, err = m.ReadAt(buf, 8)
+	assert(t, n == 8 && bytes.Equal(buf, big[8:16]))
+	derr, ok = err.(*DivergenceError)
+	assert(t, ok && len(derr.Replicas) == 1 && derr.Replicas[0] == 0)
+
+	m, _ = NewMirrored(failingReader{a}, failingReader{b})
+	m.SetVerify(true)
+	_, err = m.ReadAt(buf, 0)
+	assert(t, err != nil && err.Error() == "replica offline")
+}
+
+func TestMirroredNextWraps(t *testing.T) {
+	a := NewBufferIO(append([]byte(nil), big...))
+	m, _ := NewMirrored(a, a, a)
+	m.next = 1<<32 - 2
+	for i := 0; i < 4; i++ {
+		_, err := m.ReadAt(make([]byte, 8), 0)
+		assert(t, err == nil)
+	}
+}
+
+func TestMirroredFlush(t *testing.T) {
+	a := &flushCounter{BufferIO: NewBufferIOMake(8)}
+	b := &flushCounter{BufferIO: NewBufferIOMake(8), err: errors.New("failed")}
+	m, _ := NewMirrored(a, NewBufferIOMake(8), b)
+
+	assert(t, m.Flush() == b.err)
+	assert(t, a.flushes == 1)
+	assert(t, b.flushes == 1)
+}
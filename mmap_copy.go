@@ -0,0 +1,58 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd || (linux && s390x)
+// +build darwin dragonfly freebsd netbsd openbsd linux,s390x
+
+package bufferio
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func mmapAnon(n int) ([]byte, error) {
+	if n == 0 {
+		return []byte{}, nil
+	}
+	return syscall.Mmap(-1, 0, n,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+func munmapAnon(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return syscall.Munmap(b)
+}
+
+// protectNone makes the mapping inaccessible instead of unmapping it, so
+// the address range is never reused and stale accesses fault.
+func protectNone(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MPROTECT,
+		uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), syscall.PROT_NONE)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// mremapAnon has no kernel support here, so the contents are copied into
+// a new mapping.
+func mremapAnon(old []byte, n int) ([]byte, error) {
+	buf, err := mmapAnon(n)
+	if err != nil {
+		return nil, err
+	}
+	copy(buf, old)
+	if err := munmapAnon(old); err != nil {
+		munmapAnon(buf)
+		return nil, err
+	}
+	return buf, nil
+}
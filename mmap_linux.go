@@ -0,0 +1,84 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && !s390x
+// +build linux,!s390x
+
+package bufferio
+
+import (
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+// The mappings are managed with raw system calls rather than
+// syscall.Mmap, which only knows how to unmap the exact slices it handed
+// out and so cannot follow a mapping moved by mremap.
+
+const mremapMayMove = 1
+
+func mappedBytes(addr uintptr, n int) []byte {
+	var b []byte
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	hdr.Data = addr
+	hdr.Len = n
+	hdr.Cap = n
+	return b
+}
+
+func mappedAddr(b []byte) uintptr {
+	return uintptr(unsafe.Pointer(&b[0]))
+}
+
+func mmapAnon(n int) ([]byte, error) {
+	if n == 0 {
+		return []byte{}, nil
+	}
+	addr, _, errno := syscall.Syscall6(sysMmap, 0, uintptr(n),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_ANON|syscall.MAP_PRIVATE,
+		^uintptr(0), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return mappedBytes(addr, n), nil
+}
+
+func munmapAnon(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, mappedAddr(b), uintptr(len(b)), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// protectNone makes the mapping inaccessible instead of unmapping it, so
+// the address range is never reused and stale accesses fault.
+func protectNone(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return syscall.Mprotect(b, syscall.PROT_NONE)
+}
+
+// mremapAnon resizes the mapping, in place when the kernel can and by
+// moving it otherwise. The old slice must not be used afterwards.
+func mremapAnon(old []byte, n int) ([]byte, error) {
+	if len(old) == 0 {
+		return mmapAnon(n)
+	}
+	if n == 0 {
+		return []byte{}, munmapAnon(old)
+	}
+	addr, _, errno := syscall.Syscall6(syscall.SYS_MREMAP, mappedAddr(old),
+		uintptr(len(old)), uintptr(n), mremapMayMove, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return mappedBytes(addr, n), nil
+}
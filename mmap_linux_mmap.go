@@ -0,0 +1,14 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && !386 && !arm && !mips && !mipsle && !s390x
+// +build linux,!386,!arm,!mips,!mipsle,!s390x
+
+package bufferio
+
+import (
+	"syscall"
+)
+
+const sysMmap = syscall.SYS_MMAP
@@ -0,0 +1,24 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package bufferio
+
+func mmapAnon(n int) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+func munmapAnon(b []byte) error {
+	return ErrUnsupported
+}
+
+func mremapAnon(old []byte, n int) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+func protectNone(b []byte) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,153 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// NewBufferIOMmap returns a buffer of size bytes which is a shared
+// mapping of the file at path, created if needed and extended to size
+// when shorter. Writes to the buffer go straight to the page cache of the
+// file, with no copy in between; Flush waits for them to reach the disk.
+//
+// Resize maps the file again at the new size, extending it first when
+// shorter. The file is never truncated, so data past the mapping is
+// kept, and shows through once the buffer grows over it. Close flushes,
+// unmaps and closes the file.
+func NewBufferIOMmap(path string, size int64) (*BufferIO, error) {
+	if size < 0 || int64(int(size)) != size {
+		return nil, errors.New("invalid size")
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() < size {
+		if err := f.Truncate(size); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	buf, err := mmapFile(f, int(size))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	b := &BufferIO{buf: buf, backing: BackingFile, file: f}
+	b.release = func(buf []byte) error {
+		err := msyncFile(buf)
+		if serr := f.Sync(); err == nil {
+			err = serr
+		}
+		if uerr := munmapFile(buf); err == nil {
+			err = uerr
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	return b, nil
+}
+
+// remapFile maps n bytes of the file in place of the current mapping.
+// The file is extended first when shorter, so the mapping never reaches
+// past its end, but never truncated: whatever it holds past the old
+// mapping, written before or by an earlier larger mapping, belongs to
+// the file and shows up in the grown buffer.
+func (b *BufferIO) remapFile(n int) error {
+	f := b.file
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < int64(n) {
+		if err := f.Truncate(int64(n)); err != nil {
+			return err
+		}
+	}
+	buf, err := mmapFile(f, n)
+	if err != nil {
+		return err
+	}
+	if err := munmapFile(b.buf); err != nil {
+		munmapFile(buf)
+		return err
+	}
+	b.buf = buf
+	return nil
+}
+
+// sendFile writes the unread part of a file mapped buffer to w from the
+// file, which shares its pages with the mapping. The file offset is only
+// ever used here, with the buffer locked.
+func (b *BufferIO) sendFile(w io.ReaderFrom) (int64, error) {
+	if _, err := b.file.Seek(b.off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	rest := b.size() - b.off
+	n, err := w.ReadFrom(&io.LimitedReader{R: b.file, N: rest})
+	if err == nil && n < rest {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// Flush writes changes made to a file mapped buffer back to the file and
+// waits for them, and the size of the file, to reach stable storage.
+// Buffers backed by a ReaderAt call its Sync or Flush method, if it has
+// one, such as that of an *os.File. Flush does nothing for other
+// backings.
+func (b *BufferIO) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return err
+	}
+	switch {
+	case b.from != nil:
+		return syncBacking(b.from)
+	case b.backing == BackingFile:
+		if err := msyncFile(b.buf); err != nil {
+			return err
+		}
+		return b.file.Sync()
+	}
+	return nil
+}
+
+// Barrier orders writes on stable storage: every write made before it
+// is durable before any write made after it. The buffer stays locked
+// until the writes before it are flushed as by Flush, so a write from
+// another goroutine is either before the barrier or waits for it, as
+// journaling needs between a record and the commit which refers to it.
+func (b *BufferIO) Barrier() error {
+	return b.Flush()
+}
+
+// syncBacking flushes a ReaderAt which backs a buffer
+func syncBacking(r io.ReaderAt) error {
+	if d, ok := r.(*deadlineReaderAt); ok {
+		r = d.r
+	}
+	switch s := r.(type) {
+	case interface{ Sync() error }:
+		return s.Sync()
+	case Flusher:
+		return s.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || openbsd
+// +build darwin dragonfly freebsd linux openbsd
+
+package bufferio
+
+import (
+	"syscall"
+)
+
+const (
+	sysMsync = syscall.SYS_MSYNC
+	msSync   = syscall.MS_SYNC
+)
@@ -0,0 +1,12 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+// Package syscall lacks msync on NetBSD, where it is __msync13, and
+// MS_SYNC on some architectures.
+const (
+	sysMsync = 277
+	msSync   = 0x4
+)
@@ -0,0 +1,169 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestBufferIOMmap(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+
+	bio, err := NewBufferIOMmap(f.Name(), 8192)
+	if err == ErrUnsupported {
+		t.Skip("file mappings not supported")
+	}
+	assert(t, err == nil)
+	assert(t, bio.Size() == 8192)
+
+	_, err = bio.WriteAt(src, 4096)
+	assert(t, err == nil)
+	assert(t, bio.WriteDataLE(uint64(42)) == nil)
+	assert(t, bio.Barrier() == nil)
+
+	data, err := ioutil.ReadFile(f.Name())
+	assert(t, err == nil)
+	assert(t, len(data) == 8192)
+	assert(t, bytes.Equal(data[4096:4096+len(src)], src))
+	assert(t, data[0] == 42)
+
+	assert(t, bio.Close() == nil)
+	assert(t, bio.Flush() == ErrClosed)
+
+	bio, err = NewBufferIOMmap(f.Name(), 4096+int64(len(src)))
+	assert(t, err == nil)
+	assert(t, bytes.Equal(bio.Bytes()[4096:], src))
+	assert(t, bio.Close() == nil)
+
+	fi, err := os.Stat(f.Name())
+	assert(t, err == nil)
+	assert(t, fi.Size() == 8192)
+}
+
+func TestBufferIOMmapResize(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+
+	// The file is longer than the mapping, and growth must keep what
+	// lies past it
+	assert(t, ioutil.WriteFile(f.Name(), bytes.Repeat([]byte{0xff}, 8192), 0644) == nil)
+	bio, err := NewBufferIOMmap(f.Name(), 4096)
+	if err == ErrUnsupported {
+		t.Skip("file mappings not supported")
+	}
+	assert(t, err == nil)
+	_, err = bio.WriteAt(src, 0)
+	assert(t, err == nil)
+
+	assert(t, bio.Resize(16384) == nil)
+	assert(t, bio.Size() == 16384)
+	assert(t, bytes.Equal(bio.Bytes()[:len(src)], src))
+	assert(t, bytes.Count(bio.Bytes()[4096:8192], []byte{0xff}) == 4096)
+	assert(t, bytes.Count(bio.Bytes()[8192:], []byte{0}) == 8192)
+	_, err = bio.WriteAt(src, 16384-int64(len(src)))
+	assert(t, err == nil)
+	assert(t, bio.Flush() == nil)
+	fi, err := os.Stat(f.Name())
+	assert(t, err == nil)
+	assert(t, fi.Size() == 16384)
+
+	// Shrinking leaves the file alone
+	assert(t, bio.Resize(4) == nil)
+	assert(t, bio.Size() == 4)
+	assert(t, bytes.Equal(bio.Bytes(), src[:4]))
+	assert(t, bio.Resize(0) == nil)
+	assert(t, bio.Resize(20) == nil)
+	assert(t, bytes.Equal(bio.Bytes()[:len(src)], src))
+	assert(t, bio.Close() == nil)
+
+	data, err := ioutil.ReadFile(f.Name())
+	assert(t, err == nil)
+	assert(t, len(data) == 16384)
+	assert(t, bytes.Equal(data[16384-len(src):], src))
+}
+
+func TestBufferIOMmapWriteTo(t *testing.T) {
+	f := tempFile(t)
+	defer removeFile(f)
+	bio, err := NewBufferIOMmap(f.Name(), 64<<10)
+	if err == ErrUnsupported {
+		t.Skip("file mappings not supported")
+	}
+	assert(t, err == nil)
+	defer bio.Close()
+	data := bytes.Repeat(big, (64<<10)/len(big)+1)[:64<<10]
+	copy(bio.Bytes(), data)
+
+	// Writes through the mapping are what the file hands over
+	var out bytes.Buffer
+	bio.Seek(100, io.SeekStart)
+	n, err := bio.WriteTo(&out)
+	assert(t, err == nil && n == int64(len(data)-100))
+	assert(t, bytes.Equal(out.Bytes(), data[100:]))
+	n, err = bio.WriteTo(&out)
+	assert(t, n == 0 && err == nil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no loopback network")
+	}
+	defer l.Close()
+	got := make(chan []byte)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			got <- nil
+			return
+		}
+		p, _ := ioutil.ReadAll(c)
+		c.Close()
+		got <- p
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	assert(t, err == nil)
+	bio.Seek(0, io.SeekStart)
+	n, err = bio.WriteTo(c)
+	assert(t, err == nil && n == int64(len(data)))
+	c.Close()
+	assert(t, bytes.Equal(<-got, data))
+}
+
+func TestFlushHeap(t *testing.T) {
+	assert(t, NewBufferIOMake(10).Flush() == nil)
+	assert(t, NewBufferIOMake(10).Barrier() == nil)
+}
+
+// syncingReaderAt counts the calls to Sync
+type syncingReaderAt struct {
+	*bytes.Reader
+	syncs int
+}
+
+func (s *syncingReaderAt) Sync() error {
+	s.syncs++
+	return nil
+}
+
+func TestFlushBacked(t *testing.T) {
+	r := &syncingReaderAt{Reader: bytes.NewReader(src)}
+	bio := NewBufferIOFrom(r, int64(len(src)))
+	assert(t, bio.Flush() == nil && r.syncs == 1)
+	assert(t, bio.Barrier() == nil && r.syncs == 2)
+
+	f := tempFile(t)
+	defer removeFile(f)
+	bio = NewBufferIOFrom(f, 0)
+	assert(t, bio.Barrier() == nil)
+	f.Close()
+	assert(t, bio.Flush() != nil)
+
+	assert(t, NewBufferIOFrom(bytes.NewReader(src), 8).Flush() == nil)
+}
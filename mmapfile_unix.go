@@ -0,0 +1,41 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package bufferio
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mmapFile(f *os.File, n int) ([]byte, error) {
+	if n == 0 {
+		return []byte{}, nil
+	}
+	return syscall.Mmap(int(f.Fd()), 0, n,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func munmapFile(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return syscall.Munmap(b)
+}
+
+func msyncFile(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(sysMsync,
+		uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), msSync)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
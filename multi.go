@@ -0,0 +1,181 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// DataError reports the value of a multi value call which failed.
+type DataError struct {
+	Index int
+	Off   int64 // where the value starts, -1 if it cannot be encoded
+	Err   error
+}
+
+func (e *DataError) Error() string {
+	return "value " + strconv.Itoa(e.Index) + " at offset " +
+		strconv.FormatInt(e.Off, 10) + ": " + e.Err.Error()
+}
+
+// ReadDataMulti decodes dests one after the other from the current
+// offset, as successive ReadData calls would, under a single lock. The
+// offset only moves, past all of them, when every value decodes. When
+// they all have a fixed size the bounds are checked once, before any is
+// decoded; otherwise a failure leaves the earlier dests filled in. Errors
+// are a *DataError naming the failing value.
+func (b *BufferIO) ReadDataMulti(order binary.ByteOrder, dests ...interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	if err := b.usable(); err != nil {
+		return err
+	}
+
+	var total int64
+	for _, d := range dests {
+		n := int64(fixedSize(d))
+		if n < 0 {
+			total = -1
+			break
+		}
+		total += n
+	}
+	if total > b.length()-b.off {
+		// Find the first value which does not fit, for the error
+		off := b.off
+		for i, d := range dests {
+			n := int64(fixedSize(d))
+			if n > b.length()-off {
+				return &DataError{Index: i, Off: off, Err: io.ErrUnexpectedEOF}
+			}
+			off += n
+		}
+	}
+
+	off := b.off
+	for i, d := range dests {
+		n, err := b.readData(order, off, d)
+		if err != nil {
+			return &DataError{Index: i, Off: off, Err: err}
+		}
+		off += n
+	}
+	b.off = off
+	return nil
+}
+
+// WriteDataMulti encodes srcs back to back at the current offset, as
+// successive WriteData calls would, and advances past them. Their total
+// size is worked out first, so the buffer grows once if it is growable,
+// and otherwise either all of them are written or, when they do not fit,
+// none are. Errors are a *DataError naming the failing value.
+func (b *BufferIO) WriteDataMulti(order binary.ByteOrder, srcs ...interface{}) error {
+	// Values without a fixed size are encoded up front to learn it
+	sizes := make([]int64, len(srcs))
+	encs := make([][]byte, len(srcs))
+	var total int64
+	for i, src := range srcs {
+		enc, n, err := encodeVariable(order, src)
+		if err != nil {
+			return &DataError{Index: i, Off: -1, Err: err}
+		}
+		encs[i], sizes[i] = enc, n
+		total += n
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	if err := b.usable(); err != nil {
+		return err
+	}
+	end := b.off + total
+	if b.grow && b.from == nil && end > b.size() {
+		if err := b.growTo(end); err != nil {
+			return err
+		}
+	}
+	if end > b.length() {
+		off := b.off
+		for i, n := range sizes {
+			if off+n > b.length() {
+				return &DataError{Index: i, Off: off, Err: io.ErrShortWrite}
+			}
+			off += n
+		}
+	}
+
+	if b.from != nil {
+		all := make([]byte, 0, total)
+		for i, src := range srcs {
+			if encs[i] == nil {
+				start := len(all)
+				all = all[:start+int(sizes[i])]
+				putData(all[start:], order, src)
+			} else {
+				all = append(all, encs[i]...)
+			}
+		}
+		n, err := b.writeAt(all, b.off)
+		b.off += int64(n)
+		return err
+	}
+
+	off := b.off
+	for i, src := range srcs {
+		if encs[i] == nil {
+			putData(b.buf[off:off+sizes[i]], order, src)
+		} else {
+			copy(b.buf[off:], encs[i])
+		}
+		off += sizes[i]
+	}
+	b.off = off
+	return nil
+}
+
+// encodeVariable returns the encoding of src and its size when src has
+// no fixed size and so must be encoded to learn it, or a nil encoding
+// and the size when it has one.
+func encodeVariable(order binary.ByteOrder, src interface{}) ([]byte, int64, error) {
+	if enc, ok, err := marshalData(src); ok {
+		return enc, int64(len(enc)), err
+	}
+	plan, err := planOf(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	if plan != nil {
+		enc, err := plan.encode(nil, order, reflect.Indirect(reflect.ValueOf(src)))
+		return enc, int64(len(enc)), err
+	}
+	if n := dataSize(src); n >= 0 {
+		return nil, int64(n), nil
+	}
+	// Let encoding/binary report why it cannot be encoded
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, order, src); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), int64(buf.Len()), nil
+}
+
+// encodeData returns the bytes WriteData would write for src
+func encodeData(order binary.ByteOrder, src interface{}) ([]byte, error) {
+	enc, n, err := encodeVariable(order, src)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		enc = make([]byte, n)
+		putData(enc, order, src)
+	}
+	return enc, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+type multiHeader struct {
+	Count uint16
+	Flags uint16
+}
+
+func TestReadDataMulti(t *testing.T) {
+	b := NewBufferIOMake(32)
+	m := NewBuilder(b)
+	m.U16LE(2).U16LE(9).U32LE(10).U32LE(11).U16LE(20).U16LE(21)
+	assert(t, m.Finish() == nil)
+	b.Seek(0, os.SEEK_SET)
+
+	var h multiHeader
+	first := make([]uint32, 2)
+	second := make([]uint16, 2)
+	assert(t, b.ReadDataMulti(binary.LittleEndian, &h, first, second) == nil)
+	assert(t, h.Count == 2 && h.Flags == 9)
+	assert(t, first[0] == 10 && first[1] == 11)
+	assert(t, second[0] == 20 && second[1] == 21)
+	pos, _ := b.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 16)
+
+	// Nothing is decoded when the values do not all fit
+	var x, y uint64
+	b.Seek(20, os.SEEK_SET)
+	x = 1
+	err := b.ReadDataMulti(binary.LittleEndian, &x, &y, &h)
+	de, ok := err.(*DataError)
+	assert(t, ok && de.Index == 1 && de.Off == 28 && de.Err == io.ErrUnexpectedEOF)
+	assert(t, x == 1)
+	pos, _ = b.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 20)
+
+	// Invalid values are reported by index
+	b.Seek(8, os.SEEK_SET)
+	err = b.ReadDataMulti(binary.LittleEndian, &x, y)
+	de, ok = err.(*DataError)
+	assert(t, ok && de.Index == 1 && de.Off == 16)
+	pos, _ = b.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 8)
+}
+
+func TestWriteDataMulti(t *testing.T) {
+	b := NewBufferIOMake(16)
+	b.Seek(2, os.SEEK_SET)
+	h := multiHeader{Count: 2, Flags: 1}
+	rec := lenRecord{Payload: []byte("hi")}
+	assert(t, b.WriteDataMulti(binary.BigEndian, &h, []uint16{7, 8}) == nil)
+	assert(t, binary.BigEndian.Uint16(b.Bytes()[2:]) == 2)
+	assert(t, binary.BigEndian.Uint16(b.Bytes()[8:]) == 8)
+	pos, _ := b.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 10)
+
+	// Nothing is written when the values do not all fit
+	err := b.WriteDataMulti(binary.BigEndian, uint32(0xffffffff), &rec)
+	de, ok := err.(*DataError)
+	assert(t, ok && de.Index == 1 && de.Off == 14 && de.Err == io.ErrShortWrite)
+	assert(t, b.Bytes()[10] == 0)
+	pos, _ = b.Seek(0, os.SEEK_CUR)
+	assert(t, pos == 10)
+
+	// Invalid values are caught before anything is written
+	err = b.WriteDataMulti(binary.BigEndian, uint8(1), int(2))
+	de, ok = err.(*DataError)
+	assert(t, ok && de.Index == 1)
+	assert(t, b.Bytes()[10] == 0)
+}
+
+func TestWriteDataMultiGrow(t *testing.T) {
+	b := NewBufferIOGrow(0)
+	rec := lenRecord{Kind: 3, Payload: []byte("hello"), Words: []uint32{1}}
+	assert(t, b.WriteDataMulti(binary.LittleEndian, uint16(1), &rec, [2]uint8{4, 5}) == nil)
+
+	b.Seek(0, os.SEEK_SET)
+	var v uint16
+	var back lenRecord
+	var tail [2]uint8
+	assert(t, b.ReadDataMulti(binary.LittleEndian, &v, &back, &tail) == nil)
+	assert(t, v == 1 && back.Kind == 3 && string(back.Payload) == "hello")
+	assert(t, tail[1] == 5)
+	pos, _ := b.Seek(0, os.SEEK_CUR)
+	assert(t, pos == b.Size())
+}
@@ -0,0 +1,111 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+)
+
+// nestedHeader is the little endian uint32 length before nested content
+const nestedHeader = 4
+
+// WriteNested reserves a length prefix at the current offset and calls
+// fn with a BufferIO over the rest of the buffer. Once fn returns, the
+// number of bytes it wrote (its final offset) is patched into the prefix
+// and the offset moves past the nested content. The nested buffer
+// shares memory with b and must not be used after fn returns.
+//
+// A growable buffer may move its memory while it grows, so fn gets a
+// growable buffer of its own instead, whose content is copied into b
+// after fn returns.
+func (b *BufferIO) WriteNested(fn func(*BufferIO) error) error {
+	b.mu.Lock()
+	b.claim()
+	if b.grow {
+		err := b.usable()
+		b.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		return b.writeNestedCopy(fn)
+	}
+	start := b.off
+	r, err := b.slice(start, b.size()-start)
+	if err == nil && len(r) < nestedHeader {
+		err = ErrOverrun
+	}
+	var nested *BufferIO
+	if err == nil {
+		nested = NewBufferIO(r[nestedHeader:len(r):len(r)])
+		b.adopt(nested)
+	}
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(nested); err != nil {
+		return err
+	}
+	n := nested.off
+	if n > int64(^uint32(0)) {
+		return ErrRecordTooLarge
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, err := b.slice(start, nestedHeader+n)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(h, uint32(n))
+	b.off = start + nestedHeader + n
+	return nil
+}
+
+// writeNestedCopy is WriteNested for growable buffers, with fn writing
+// into a buffer of its own
+func (b *BufferIO) writeNestedCopy(fn func(*BufferIO) error) error {
+	nested := NewBufferIOGrow(0)
+	if err := fn(nested); err != nil {
+		return err
+	}
+	n := nested.off
+	if n > int64(^uint32(0)) {
+		return ErrRecordTooLarge
+	}
+
+	// the offset may have been left past what was written
+	p := make([]byte, nestedHeader+n)
+	binary.LittleEndian.PutUint32(p, uint32(n))
+	copy(p[nestedHeader:], nested.buf)
+	return b.writeWhole(p)
+}
+
+// ReadNested reads the length prefixed content at the current offset,
+// as written by WriteNested, and returns a BufferIO bounded to it. The
+// offset moves past the content. Like a Window, the nested buffer
+// shares memory with b and fails with ErrInvalidView or ErrClosed once
+// b is resized, grown or closed.
+func (b *BufferIO) ReadNested() (*BufferIO, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+
+	h, err := b.slice(b.off, nestedHeader)
+	if err != nil {
+		return nil, err
+	}
+	n := int64(binary.LittleEndian.Uint32(h))
+	r, err := b.slice(b.off+nestedHeader, n)
+	if err != nil {
+		return nil, err
+	}
+	b.off += nestedHeader + n
+	b.shared()
+	nested := NewBufferIO(r)
+	b.adopt(nested)
+	return nested, nil
+}
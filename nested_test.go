@@ -0,0 +1,106 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestNested(t *testing.T) {
+	b := NewBufferIOMake(64)
+	b.Write([]byte("hd"))
+
+	err := b.WriteNested(func(n *BufferIO) error {
+		n.Write([]byte("outer"))
+		return n.WriteNested(func(n *BufferIO) error {
+			_, err := n.Write([]byte("inner"))
+			return err
+		})
+	})
+	assert(t, err == nil)
+	b.Write([]byte("tl"))
+	assert(t, bytes.Equal(b.Bytes()[:22],
+		[]byte("hd\x0e\x00\x00\x00outer\x05\x00\x00\x00innertl")))
+
+	b.Seek(2, 0)
+	outer, err := b.ReadNested()
+	assert(t, err == nil)
+	assert(t, outer.Size() == 14)
+	p := make([]byte, 2)
+	b.Read(p)
+	assert(t, string(p) == "tl")
+
+	outer.Seek(5, 0)
+	inner, err := outer.ReadNested()
+	assert(t, err == nil)
+	assert(t, string(inner.Bytes()) == "inner")
+
+	// Nested buffers are bounded
+	_, err = inner.WriteAt([]byte("x"), 5)
+	assert(t, err == io.ErrShortWrite)
+}
+
+func TestNestedErrors(t *testing.T) {
+	b := NewBufferIOMake(16)
+	failed := errors.New("failed")
+
+	assert(t, b.WriteNested(func(n *BufferIO) error { return failed }) == failed)
+	pos, _ := b.Seek(0, 1)
+	assert(t, pos == 0)
+
+	b.Seek(13, 0)
+	assert(t, b.WriteNested(func(n *BufferIO) error { return nil }) == ErrOverrun)
+
+	// A length pointing past the end
+	b.WriteAt([]byte{13, 0, 0, 0}, 0)
+	b.Seek(0, 0)
+	_, err := b.ReadNested()
+	assert(t, err == ErrOverrun)
+	b.WriteAt([]byte{12, 0, 0, 0}, 0)
+	n, err := b.ReadNested()
+	assert(t, err == nil)
+	assert(t, n.Size() == 12)
+}
+
+func TestNestedInvalidated(t *testing.T) {
+	b := NewBufferIOMake(16)
+	b.WriteAt([]byte{4, 0, 0, 0, 'a', 'b', 'c', 'd'}, 0)
+	n, err := b.ReadNested()
+	assert(t, err == nil)
+
+	assert(t, b.Resize(32) == nil)
+	_, err = n.ReadAt(make([]byte, 4), 0)
+	assert(t, err == ErrInvalidView)
+	b.Close()
+	_, err = n.ReadAt(make([]byte, 4), 0)
+	assert(t, err == ErrClosed)
+}
+
+func TestNestedGrowable(t *testing.T) {
+	b := NewBufferIOMake(0)
+	b.SetGrowable(true)
+	b.Write([]byte("hd"))
+
+	err := b.WriteNested(func(n *BufferIO) error {
+		n.Write([]byte("outer"))
+		return n.WriteNested(func(n *BufferIO) error {
+			_, err := n.Write(bytes.Repeat([]byte("x"), 100))
+			return err
+		})
+	})
+	assert(t, err == nil)
+	b.Write([]byte("tl"))
+	assert(t, b.Size() == 117)
+	assert(t, bytes.Equal(b.Bytes()[:15], []byte("hd\x6d\x00\x00\x00outer\x64\x00\x00\x00")))
+	assert(t, string(b.Bytes()[115:]) == "tl")
+
+	b.Seek(2, 0)
+	outer, err := b.ReadNested()
+	assert(t, err == nil)
+	assert(t, outer.Size() == 109)
+}
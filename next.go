@@ -0,0 +1,55 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"io"
+)
+
+// Next returns the next n bytes and advances the offset past them. The
+// slice is the memory of the buffer itself, as with BytesUnsafe: writes
+// to the buffer show through it, and it must not be used once the
+// buffer is resized, grown or closed. If fewer than n bytes remain Next
+// returns io.EOF at the end and io.ErrUnexpectedEOF otherwise, and the
+// offset does not move.
+func (b *BufferIO) Next(n int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	p, err := b.peek(n)
+	if err == nil {
+		b.off += int64(n)
+	}
+	return p, err
+}
+
+// Peek returns the next n bytes like Next, without advancing.
+func (b *BufferIO) Peek(n int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	return b.peek(n)
+}
+
+func (b *BufferIO) peek(n int) ([]byte, error) {
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+	switch {
+	case b.from != nil:
+		return nil, ErrUnsupported
+	case n < 0:
+		return nil, errors.New("negative count")
+	case n == 0:
+		return nil, nil
+	case b.off >= b.size():
+		return nil, io.EOF
+	case int64(n) > b.size()-b.off:
+		return nil, io.ErrUnexpectedEOF
+	}
+	b.shared()
+	return b.buf[b.off : b.off+int64(n) : b.off+int64(n)], nil
+}
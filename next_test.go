@@ -0,0 +1,47 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestNextPeek(t *testing.T) {
+	b := NewBufferIO([]byte("abcdefgh"))
+
+	p, err := b.Peek(3)
+	assert(t, err == nil && string(p) == "abc")
+	p, err = b.Next(3)
+	assert(t, err == nil && string(p) == "abc")
+	p, err = b.Peek(2)
+	assert(t, err == nil && string(p) == "de")
+
+	// The slices are the buffer memory
+	p[0] = 'D'
+	assert(t, string(b.Bytes()) == "abcDefgh")
+	assert(t, cap(p) == 2)
+
+	_, err = b.Next(6)
+	assert(t, err == io.ErrUnexpectedEOF)
+	p, err = b.Next(5)
+	assert(t, err == nil && string(p) == "Defgh")
+	_, err = b.Peek(1)
+	assert(t, err == io.EOF)
+	p, err = b.Next(0)
+	assert(t, err == nil && len(p) == 0)
+	_, err = b.Next(-1)
+	assert(t, err != nil)
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 8)
+
+	backed := NewBufferIOFrom(bytes.NewReader([]byte("abc")), 3)
+	_, err = backed.Peek(1)
+	assert(t, err == ErrUnsupported)
+	b.Close()
+	_, err = b.Next(1)
+	assert(t, err == ErrClosed)
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	mpolBind  = 2
+	mpolLocal = 4
+)
+
+// bindNUMA sets the memory policy of a mapping before any of its pages
+// are touched.
+func bindNUMA(b []byte, node int) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	var errno syscall.Errno
+	if node == NUMACurrent {
+		_, _, errno = syscall.Syscall6(syscall.SYS_MBIND,
+			uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)),
+			mpolLocal, 0, 0, 0)
+	} else {
+		if node < 0 {
+			return syscall.EINVAL
+		}
+		mask := make([]uint64, node/64+1)
+		mask[node/64] = 1 << uint(node%64)
+		_, _, errno = syscall.Syscall6(syscall.SYS_MBIND,
+			uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)),
+			mpolBind, uintptr(unsafe.Pointer(&mask[0])),
+			uintptr(len(mask)*64+1), 0)
+	}
+	if errno == syscall.ENOSYS {
+		return ErrUnsupported
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
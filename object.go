@@ -0,0 +1,212 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var ErrNoObject = errors.New("object does not exist")
+
+// ObjectBackend is the glue to an object store such as S3. Objects are
+// read in ranges but always written whole.
+type ObjectBackend interface {
+	// GetRange returns n bytes of the object key at off, fewer at the
+	// end of the object.
+	GetRange(key string, off, n int64) ([]byte, error)
+
+	// Put replaces the object key with data.
+	Put(key string, data []byte) error
+}
+
+// ObjectBuffer pages an object into memory on demand and writes it back
+// on Flush. It satisfies BufferReaderWriter and Flusher.
+type ObjectBuffer struct {
+	backend  ObjectBackend
+	key      string
+	pageSize int64
+	size     int64
+
+	mu     sync.Mutex
+	buf    *BufferIO
+	loaded []bool
+	dirty  bool
+}
+
+// NewObjectBuffer returns a buffer over the size byte object key, read
+// pageSize bytes at a time. Nothing is fetched until it is accessed.
+func NewObjectBuffer(backend ObjectBackend, key string, size int64, pageSize int) (*ObjectBuffer, error) {
+	if pageSize <= 0 || size < 0 {
+		return nil, errors.New("invalid object geometry")
+	}
+	ps := int64(pageSize)
+	return &ObjectBuffer{
+		backend:  backend,
+		key:      key,
+		pageSize: ps,
+		size:     size,
+		loaded:   make([]bool, (size+ps-1)/ps),
+	}, nil
+}
+
+// Size returns the size of the object.
+func (o *ObjectBuffer) Size() int64 {
+	return o.size
+}
+
+// load pages in the pages covering [off, end), allocating the memory for
+// the object on first use
+func (o *ObjectBuffer) load(off, end int64) error {
+	if o.buf == nil {
+		o.buf = NewBufferIOMake(int(o.size))
+	}
+	for i := off / o.pageSize; i*o.pageSize < end; i++ {
+		if o.loaded[i] {
+			continue
+		}
+		p, err := o.backend.GetRange(o.key, i*o.pageSize, o.pageSize)
+		if err != nil {
+			return err
+		}
+		if _, err := o.buf.WriteAt(p, i*o.pageSize); err != nil {
+			return err
+		}
+		o.loaded[i] = true
+	}
+	return nil
+}
+
+func (o *ObjectBuffer) span(p []byte, off int64) (int64, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	end := off + int64(len(p))
+	if end > o.Size() {
+		end = o.Size()
+	}
+	return end, nil
+}
+
+func (o *ObjectBuffer) ReadAt(p []byte, off int64) (int, error) {
+	end, err := o.span(p, off)
+	if err != nil {
+		return 0, err
+	}
+	if off >= end {
+		return 0, io.EOF
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.load(off, end); err != nil {
+		return 0, err
+	}
+	n, err := o.buf.ReadAt(p[:end-off], off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// WriteAt pages in the pages p touches, so the rest of them survives
+// the next Flush, and updates them in memory.
+func (o *ObjectBuffer) WriteAt(p []byte, off int64) (int, error) {
+	end, err := o.span(p, off)
+	if err != nil {
+		return 0, err
+	}
+	if off >= end {
+		if len(p) > 0 {
+			return 0, io.ErrShortWrite
+		}
+		return 0, nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.load(off, end); err != nil {
+		return 0, err
+	}
+	n, err := o.buf.WriteAt(p[:end-off], off)
+	if n > 0 {
+		o.dirty = true
+	}
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// Flush writes the object back if it was modified, paging in whatever
+// was never read first.
+func (o *ObjectBuffer) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.dirty {
+		return nil
+	}
+	if err := o.load(0, o.Size()); err != nil {
+		return err
+	}
+	if err := o.backend.Put(o.key, o.buf.Bytes()); err != nil {
+		return err
+	}
+	o.dirty = false
+	return nil
+}
+
+// Reclaim drops the in-memory copy of an unmodified object under
+// moderate or critical pressure. It is paged in again when next used.
+func (o *ObjectBuffer) Reclaim(level PressureLevel) int64 {
+	if level < PressureModerate {
+		return 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.dirty || o.buf == nil {
+		return 0
+	}
+	o.buf = nil
+	for i := range o.loaded {
+		o.loaded[i] = false
+	}
+	return o.size
+}
+
+// MemObjectStore is an ObjectBackend keeping objects in memory, for
+// tests and as a starting point for real stores.
+type MemObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func NewMemObjectStore() *MemObjectStore {
+	return &MemObjectStore{objects: make(map[string][]byte)}
+}
+
+func (m *MemObjectStore) GetRange(key string, off, n int64) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNoObject
+	}
+	if off < 0 || n < 0 || off > int64(len(obj)) {
+		return nil, ErrOverrun
+	}
+	if n > int64(len(obj))-off {
+		n = int64(len(obj)) - off
+	}
+	return append([]byte(nil), obj[off:off+n]...), nil
+}
+
+func (m *MemObjectStore) Put(key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = append([]byte(nil), data...)
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// countingStore counts range requests
+type countingStore struct {
+	*MemObjectStore
+	gets, puts int
+}
+
+func (c *countingStore) GetRange(key string, off, n int64) ([]byte, error) {
+	c.gets++
+	return c.MemObjectStore.GetRange(key, off, n)
+}
+
+func (c *countingStore) Put(key string, data []byte) error {
+	c.puts++
+	return c.MemObjectStore.Put(key, data)
+}
+
+func TestObjectBuffer(t *testing.T) {
+	store := &countingStore{MemObjectStore: NewMemObjectStore()}
+	content := bytes.Repeat(big, 4)
+	store.MemObjectStore.Put("obj", content)
+
+	o, err := NewObjectBuffer(store, "obj", int64(len(content)), 32)
+	assert(t, err == nil)
+	var _ BufferReaderWriter = o
+	var _ Flusher = o
+
+	p := make([]byte, 40)
+	n, err := o.ReadAt(p, 30)
+	assert(t, err == nil && n == 40)
+	assert(t, bytes.Equal(p, content[30:70]))
+	assert(t, store.gets == 3)
+
+	// Clean buffers do not flush
+	assert(t, o.Flush() == nil)
+	assert(t, store.puts == 0)
+
+	n, err = o.WriteAt([]byte("hello"), 64)
+	assert(t, err == nil && n == 5)
+	assert(t, store.gets == 3)
+	assert(t, o.Flush() == nil)
+	assert(t, store.puts == 1)
+
+	want := append([]byte(nil), content...)
+	copy(want[64:], "hello")
+	got, _ := store.MemObjectStore.GetRange("obj", 0, int64(len(want)))
+	assert(t, bytes.Equal(got, want))
+
+	n, err = o.ReadAt(p, o.Size()-4)
+	assert(t, n == 4 && err == io.EOF)
+	n, err = o.WriteAt(p, o.Size()-4)
+	assert(t, n == 4 && err == io.ErrShortWrite)
+
+	// Writes beyond the end write nothing, and empty ones succeed
+	n, err = o.WriteAt(p, o.Size()+100)
+	assert(t, n == 0 && err == io.ErrShortWrite)
+	n, err = o.WriteAt(nil, o.Size()+100)
+	assert(t, n == 0 && err == nil)
+}
+
+func TestObjectBufferMissing(t *testing.T) {
+	o, _ := NewObjectBuffer(NewMemObjectStore(), "missing", 100, 32)
+	_, err := o.ReadAt(make([]byte, 4), 0)
+	assert(t, err == ErrNoObject)
+
+	_, err = NewObjectBuffer(NewMemObjectStore(), "x", 100, 0)
+	assert(t, err != nil)
+}
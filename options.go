@@ -0,0 +1,204 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"runtime"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported on this platform")
+	ErrNoArena     = errors.New("arena backing requires Options.Arena")
+)
+
+// Backing selects where the memory of a buffer comes from.
+type Backing int
+
+const (
+	BackingHeap      Backing = iota // Go heap slice
+	BackingMmap                     // anonymous memory mapping
+	BackingPool                     // recycled from a Pool
+	BackingArena                    // carved out of an Arena
+	BackingHugePages                // anonymous mapping backed by huge pages
+	BackingFile                     // shared mapping of a file, see NewBufferIOMmap
+	BackingAllocator                // from Options.Allocator
+)
+
+// ZeroPolicy controls when recycled memory is cleared. Memory fresh from
+// the heap or the kernel is always zero.
+type ZeroPolicy int
+
+const (
+	ZeroOnAlloc ZeroPolicy = iota // clear before the buffer is handed out
+	ZeroOnClose                   // clear when the buffer is closed
+	ZeroNever                     // recycled memory may hold stale data
+)
+
+// NUMACurrent places memory on the node of the thread which first
+// touches each page, rather than on a fixed node.
+const NUMACurrent = -1
+
+// Options tune how NewBufferIOOptions allocates a buffer.
+type Options struct {
+	Backing Backing
+	Zero    ZeroPolicy
+
+	// NUMA binds the memory of mapped backings to NUMANode, which may be
+	// NUMACurrent. Other backings report ErrUnsupported.
+	NUMA     bool
+	NUMANode int
+
+	// Pool used by BackingPool. DefaultPool is used when nil.
+	Pool *Pool
+
+	// Arena used by BackingArena.
+	Arena *Arena
+
+	// Allocator supplies the memory instead of Backing when set.
+	Allocator Allocator
+
+	// Debug poisons memory on Close to expose use after free through
+	// slices obtained earlier. Mapped memory is made inaccessible and
+	// never reused, so reads fault; other memory is overwritten with
+	// PoisonByte and not recycled, except that memory of an Allocator is
+	// given back to it once poisoned. Moving or releasing the memory while
+	// a slice from BytesUnsafe may still be in use is reported through
+	// LeakLog.
+	Debug bool
+
+	// CopyBytes makes Bytes return a copy, like BytesCopy, so that the
+	// memory of the buffer is only shared through BytesUnsafe.
+	CopyBytes bool
+
+	// StrictData makes WriteData and WriteDataAt check the encoded size
+	// up front: data which does not fit is not written at all and
+	// returns io.ErrShortWrite, where otherwise the prefix that fits is
+	// written.
+	StrictData bool
+
+	// OwnerCheck reports use of the offset of the buffer by more than
+	// one goroutine through LeakLog. See SetOwnerCheck.
+	OwnerCheck bool
+
+	// ByteOrder is the order of ReadDataDefault and WriteDataDefault,
+	// such as NativeEndian. Little endian is used when nil.
+	ByteOrder binary.ByteOrder
+
+	// Frame is the length prefix of WriteFrame and ReadFrame.
+	Frame FrameFormat
+
+	// Semantics selects how reads, writes and seeks behave at the end of
+	// the buffer.
+	Semantics Semantics
+
+	// LeakCheck reports buffers which need Close but are garbage
+	// collected without it, along with the stack which created them,
+	// through LeakLog. Nothing is released then, since slices handed
+	// out by the buffer, such as by BytesUnsafe or Next, may still be in
+	// use without keeping it reachable.
+	LeakCheck bool
+}
+
+// LeakLog receives the warnings of Options.LeakCheck, Options.Debug and
+// Options.OwnerCheck.
+var LeakLog = log.Printf
+
+// PoisonByte fills the memory of closed buffers in debug mode.
+const PoisonByte = 0xdb
+
+// NewBufferIOOptions allocates a buffer of nbytes according to opts.
+// Buffers not backed by the heap should be released with Close.
+func NewBufferIOOptions(nbytes int, opts Options) (*BufferIO, error) {
+	if nbytes < 0 {
+		return nil, errors.New("negative size")
+	}
+
+	if opts.NUMA && (opts.Allocator != nil || opts.Backing != BackingMmap && opts.Backing != BackingHugePages) {
+		return nil, ErrUnsupported
+	}
+	alloc, free, err := opts.allocator()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := alloc.Alloc(nbytes)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BufferIO{buf: buf, backing: opts.Backing, debug: opts.Debug, copyBytes: opts.CopyBytes,
+		strictData: opts.StrictData, semantics: opts.Semantics, ownerCheck: opts.OwnerCheck,
+		order: opts.ByteOrder, frame: opts.Frame}
+	if opts.Allocator != nil {
+		b.backing = BackingAllocator
+		b.alloc = opts.Allocator
+	}
+	if free {
+		b.release = alloc.Free
+	}
+	if opts.Debug {
+		b.release = poison
+		if b.backing == BackingMmap || b.backing == BackingHugePages {
+			b.release = protectNone
+		}
+		if b.backing == BackingAllocator {
+			// Memory of a custom allocator may not be the garbage
+			// collector's to reclaim, so it is still freed once poisoned
+			b.release = func(p []byte) error {
+				poison(p)
+				return alloc.Free(p)
+			}
+		}
+	}
+
+	if opts.LeakCheck && b.release != nil {
+		stack := make([]byte, 4096)
+		stack = stack[:runtime.Stack(stack, false)]
+		runtime.SetFinalizer(b, func(b *BufferIO) {
+			LeakLog("bufferio: buffer of %d bytes garbage collected without Close, created at:\n%s",
+				len(b.buf), stack)
+		})
+	}
+
+	return b, nil
+}
+
+// Close releases the memory of the buffer back to where it came from.
+// Later operations on the buffer fail with ErrClosed.
+func (b *BufferIO) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+
+	var err error
+	if b.release != nil {
+		err = b.release(b.buf)
+		b.release = nil
+	}
+	b.buf = nil
+	b.from = nil
+	b.off = 0
+	b.closed = true
+	runtime.SetFinalizer(b, nil)
+	b.moved()
+	return err
+}
+
+func poison(p []byte) error {
+	for i := range p {
+		p[i] = PoisonByte
+	}
+	return nil
+}
+
+func zero(p []byte) {
+	for i := range p {
+		p[i] = 0
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+)
+
+func testBacking(t *testing.T, opts Options) {
+	bio, err := NewBufferIOOptions(4096, opts)
+	if err == ErrUnsupported {
+		t.Skipf("backing %d: %v", opts.Backing, err)
+	}
+	assert(t, err == nil)
+	assert(t, bio.Size() == 4096)
+	for i := 0; i < len(bio.buf); i++ {
+		assert(t, bio.buf[i] == 0)
+	}
+
+	n, err := bio.WriteAt(src, 4000)
+	assert(t, n == len(src))
+	assert(t, err == nil)
+	assert(t, bio.buf[4000] == src[0])
+
+	assert(t, bio.Close() == nil)
+	assert(t, bio.Size() == 0)
+	assert(t, bio.Close() == ErrClosed)
+}
+
+func TestOptionsHeap(t *testing.T) {
+	testBacking(t, Options{Backing: BackingHeap})
+}
+
+func TestOptionsMmap(t *testing.T) {
+	testBacking(t, Options{Backing: BackingMmap})
+}
+
+func TestOptionsHugePages(t *testing.T) {
+	testBacking(t, Options{Backing: BackingHugePages})
+}
+
+func TestOptionsPool(t *testing.T) {
+	testBacking(t, Options{Backing: BackingPool, Pool: NewPool()})
+	testBacking(t, Options{Backing: BackingPool})
+}
+
+func TestOptionsArena(t *testing.T) {
+	testBacking(t, Options{Backing: BackingArena, Arena: NewArena(8192)})
+
+	_, err := NewBufferIOOptions(16, Options{Backing: BackingArena})
+	assert(t, err == ErrNoArena)
+
+	_, err = NewBufferIOOptions(16, Options{
+		Backing: BackingArena,
+		Arena:   NewArena(8),
+	})
+	assert(t, err == ErrArenaFull)
+
+	bio, err := NewBufferIOOptions(16, Options{
+		Backing: BackingArena,
+		Arena:   NewArena(16),
+		Zero:    ZeroOnClose,
+	})
+	assert(t, err == nil)
+	mem := bio.buf
+	mem[0] = 0xff
+	assert(t, bio.Close() == nil)
+	assert(t, mem[0] == 0)
+}
+
+func TestOptionsZeroPolicy(t *testing.T) {
+	pool := NewPool()
+
+	// Dirty memory is handed out again when zeroing is disabled
+	bio, err := NewBufferIOOptions(64, Options{Backing: BackingPool, Pool: pool})
+	assert(t, err == nil)
+	bio.buf[0] = 0xff
+	bio.Close()
+
+	bio, err = NewBufferIOOptions(64, Options{
+		Backing: BackingPool,
+		Pool:    pool,
+		Zero:    ZeroNever,
+	})
+	assert(t, err == nil)
+	assert(t, bio.buf[0] == 0xff)
+	bio.Close()
+
+	// Cleared on allocation
+	bio, err = NewBufferIOOptions(64, Options{Backing: BackingPool, Pool: pool})
+	assert(t, err == nil)
+	assert(t, bio.buf[0] == 0)
+	bio.Close()
+
+	// Cleared on close
+	bio, err = NewBufferIOOptions(64, Options{
+		Backing: BackingPool,
+		Pool:    pool,
+		Zero:    ZeroOnClose,
+	})
+	assert(t, err == nil)
+	mem := bio.buf
+	mem[0] = 0xff
+	bio.Close()
+	assert(t, mem[0] == 0)
+}
+
+func TestOptionsInvalid(t *testing.T) {
+	_, err := NewBufferIOOptions(-1, Options{})
+	assert(t, err != nil)
+
+	_, err = NewBufferIOOptions(16, Options{Backing: Backing(100)})
+	assert(t, err != nil)
+}
+
+func TestOptionsNUMA(t *testing.T) {
+	testBacking(t, Options{Backing: BackingMmap, NUMA: true, NUMANode: NUMACurrent})
+	testBacking(t, Options{Backing: BackingMmap, NUMA: true, NUMANode: 0})
+
+	_, err := NewBufferIOOptions(16, Options{
+		Backing:  BackingMmap,
+		NUMA:     true,
+		NUMANode: -2,
+	})
+	assert(t, err != nil)
+
+	_, err = NewBufferIOOptions(16, Options{NUMA: true})
+	assert(t, err == ErrUnsupported)
+}
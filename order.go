@@ -0,0 +1,55 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// NativeEndian is the byte order of the machine, for data which never
+// leaves it.
+var NativeEndian binary.ByteOrder = nativeEndian()
+
+func nativeEndian() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// SetByteOrder sets the order used by ReadDataDefault and
+// WriteDataDefault, which is little endian until set.
+func (b *BufferIO) SetByteOrder(order binary.ByteOrder) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.order = order
+}
+
+// ByteOrder returns the order used by ReadDataDefault and
+// WriteDataDefault.
+func (b *BufferIO) ByteOrder() binary.ByteOrder {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.byteOrder()
+}
+
+func (b *BufferIO) byteOrder() binary.ByteOrder {
+	if b.order == nil {
+		return binary.LittleEndian
+	}
+	return b.order
+}
+
+// ReadDataDefault is ReadData in the byte order of the buffer.
+func (b *BufferIO) ReadDataDefault(data interface{}) error {
+	return b.ReadData(b.ByteOrder(), data)
+}
+
+// WriteDataDefault is WriteData in the byte order of the buffer.
+func (b *BufferIO) WriteDataDefault(data interface{}) error {
+	return b.WriteData(b.ByteOrder(), data)
+}
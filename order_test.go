@@ -0,0 +1,30 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+	"unsafe"
+)
+
+func TestByteOrder(t *testing.T) {
+	b := NewBufferIOMake(8)
+	assert(t, b.ByteOrder() == binary.LittleEndian)
+	assert(t, b.WriteDataDefault(uint32(0x01020304)) == nil)
+	b.SetByteOrder(binary.BigEndian)
+	assert(t, b.WriteDataDefault(uint32(0x01020304)) == nil)
+	assert(t, string(b.Bytes()) == "\x04\x03\x02\x01\x01\x02\x03\x04")
+
+	var v uint32
+	b.Seek(0, io.SeekStart)
+	assert(t, b.ReadDataDefault(&v) == nil && v == 0x04030201)
+
+	o, err := NewBufferIOOptions(4, Options{ByteOrder: NativeEndian})
+	assert(t, err == nil)
+	assert(t, o.WriteDataDefault(uint32(0x01020304)) == nil)
+	assert(t, *(*uint32)(unsafe.Pointer(&o.Bytes()[0])) == 0x01020304)
+}
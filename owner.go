@@ -0,0 +1,68 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// The offset of a buffer is shared by every goroutine using it, so two
+// goroutines parsing through Read, Write, Seek and the other methods
+// which move it interleave silently. The lock keeps each call atomic,
+// so the race detector does not notice. With Options.OwnerCheck the
+// buffer remembers the goroutine which last used the offset, and use
+// from another goroutine is reported through LeakLog, along with where
+// the previous owner took it. Ownership then passes to the new
+// goroutine, so each hand over is reported once. Goroutines which hand
+// the offset over on purpose call ReleaseOwner first.
+
+// SetOwnerCheck turns owner checking on or off, for buffers not created
+// through NewBufferIOOptions.
+func (b *BufferIO) SetOwnerCheck(on bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ownerCheck = on
+	b.owner, b.ownerStack = 0, nil
+}
+
+// ReleaseOwner gives up ownership of the offset, so that the next
+// goroutine to use it takes it over without a report.
+func (b *BufferIO) ReleaseOwner() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.owner, b.ownerStack = 0, nil
+}
+
+// claim records the calling goroutine as the owner of the offset,
+// reporting it if another goroutine owned it. It is called with the
+// lock held by every method using the offset.
+func (b *BufferIO) claim() {
+	if !b.ownerCheck {
+		return
+	}
+	stack := make([]byte, 4096)
+	stack = stack[:runtime.Stack(stack, false)]
+	id := goroutineID(stack)
+	if id == b.owner {
+		return
+	}
+	if b.owner != 0 {
+		LeakLog("bufferio: offset used by goroutine %d while owned by goroutine %d, which took it at:\n%s",
+			id, b.owner, b.ownerStack)
+	}
+	b.owner, b.ownerStack = id, stack
+}
+
+// goroutineID parses the id from the "goroutine N [" header of a stack
+func goroutineID(stack []byte) uint64 {
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
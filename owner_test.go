@@ -0,0 +1,67 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestOwnerCheck(t *testing.T) {
+	var logs []string
+	LeakLog = func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+	defer func() { LeakLog = log.Printf }()
+
+	b, err := NewBufferIOOptions(64, Options{OwnerCheck: true})
+	assert(t, err == nil)
+	b.Write([]byte("abc"))
+	b.Seek(0, 0)
+	b.ReadAt(make([]byte, 1), 0)
+
+	elsewhere(func() {
+		b.Read(make([]byte, 1))
+		b.Read(make([]byte, 1))
+	})
+	assert(t, len(logs) == 1)
+	assert(t, strings.Contains(logs[0], "TestOwnerCheck"))
+
+	// Positional methods do not use the offset, and a release hands it
+	// over quietly
+	elsewhere(func() {
+		b.ReadAt(make([]byte, 1), 0)
+		b.ReleaseOwner()
+	})
+	b.Read(make([]byte, 1))
+	assert(t, len(logs) == 1)
+
+	plain := NewBufferIOMake(8)
+	elsewhere(func() { plain.Write([]byte("x")) })
+	plain.Write([]byte("y"))
+	assert(t, len(logs) == 1)
+
+	plain.SetOwnerCheck(true)
+	plain.Write([]byte("z"))
+	elsewhere(func() { plain.Write([]byte("w")) })
+	assert(t, len(logs) == 2)
+}
+
+// elsewhere runs fn on another goroutine and waits for it
+func elsewhere(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	<-done
+}
+
+func TestGoroutineID(t *testing.T) {
+	assert(t, goroutineID([]byte("goroutine 18 [running]:\nmain.main()")) == 18)
+	assert(t, goroutineID([]byte("garbage")) == 0)
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"strconv"
+)
+
+// Patch replaces the bytes at Off with Data.
+type Patch struct {
+	Off  int64
+	Data []byte
+}
+
+// PatchError reports the patch which failed validation.
+type PatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return "patch " + strconv.Itoa(e.Index) + ": " + e.Err.Error()
+}
+
+// ApplyPatchesAtomic validates every patch before applying any of them,
+// and applies them all under one lock so readers never observe a
+// partially patched buffer. Patches are applied in order, so later ones
+// win where they overlap.
+func (b *BufferIO) ApplyPatchesAtomic(patches []Patch) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.from != nil {
+		return ErrUnsupported
+	}
+
+	for i, p := range patches {
+		if _, err := b.slice(p.Off, int64(len(p.Data))); err != nil {
+			return &PatchError{Index: i, Err: err}
+		}
+	}
+	for _, p := range patches {
+		copy(b.buf[p.Off:], p.Data)
+	}
+	return nil
+}
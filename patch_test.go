@@ -0,0 +1,77 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestApplyPatchesAtomic(t *testing.T) {
+	bio := NewBufferIOMake(8)
+
+	err := bio.ApplyPatchesAtomic([]Patch{
+		{Off: 0, Data: []byte{1, 2, 3}},
+		{Off: 6, Data: []byte{7, 8}},
+		{Off: 2, Data: []byte{9}},
+	})
+	assert(t, err == nil)
+	assert(t, bytes.Equal(bio.buf, []byte{1, 2, 9, 0, 0, 0, 7, 8}))
+
+	// Nothing is applied when any patch is out of bounds
+	err = bio.ApplyPatchesAtomic([]Patch{
+		{Off: 0, Data: []byte{0xff}},
+		{Off: 7, Data: []byte{0xff, 0xff}},
+	})
+	perr, ok := err.(*PatchError)
+	assert(t, ok)
+	assert(t, perr.Index == 1)
+	assert(t, perr.Err == ErrOverrun)
+	assert(t, perr.Error() == "patch 1: buffer overrun")
+	assert(t, bio.buf[0] == 1)
+
+	err = bio.ApplyPatchesAtomic([]Patch{{Off: -1, Data: []byte{1}}})
+	assert(t, err != nil)
+
+	assert(t, bio.ApplyPatchesAtomic(nil) == nil)
+}
+
+func TestApplyPatchesAtomicReaders(t *testing.T) {
+	bio := NewBufferIOMake(64)
+	ones := bytes.Repeat([]byte{1}, 32)
+	twos := bytes.Repeat([]byte{2}, 32)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 64)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			bio.ReadAt(buf, 0)
+			// Both halves always come from the same generation
+			assert(t, buf[0] == buf[63])
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		data := ones
+		if i%2 == 1 {
+			data = twos
+		}
+		bio.ApplyPatchesAtomic([]Patch{
+			{Off: 0, Data: data},
+			{Off: 32, Data: data},
+		})
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,244 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	minPoolClass = 6  // 64 bytes
+	maxPoolClass = 40 // 1 TiB
+)
+
+type poolEntry struct {
+	buf   []byte
+	since time.Time
+}
+
+type poolClassCounters struct {
+	gets, hits, puts, shrunk uint64
+}
+
+// Pool recycles buffer memory in power of two size classes.
+type Pool struct {
+	mu        sync.Mutex
+	free      [maxPoolClass + 1][]poolEntry
+	counters  [maxPoolClass + 1]poolClassCounters
+	drops     uint64
+	maxIdle   time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// PoolClassStats describes the traffic of one size class of a Pool.
+type PoolClassStats struct {
+	Size       int           // bytes per buffer
+	Gets       uint64        // requests served by this class
+	Hits       uint64        // requests served with recycled memory
+	Puts       uint64        // buffers returned
+	Shrunk     uint64        // idle buffers released
+	Idle       int           // buffers currently waiting in the pool
+	IdleBytes  int64         // memory held by idle buffers
+	OldestIdle time.Duration // age of the longest waiting buffer
+}
+
+// PoolStats is a snapshot of the efficiency of a Pool. Classes only holds
+// size classes which have seen any traffic, smallest first.
+type PoolStats struct {
+	Gets      uint64
+	Hits      uint64
+	Puts      uint64
+	Drops     uint64 // buffers rejected by Put because of their capacity
+	Shrunk    uint64
+	Idle      int
+	IdleBytes int64
+	Classes   []PoolClassStats
+}
+
+// HitRate returns the fraction of Get calls served with recycled memory.
+func (s PoolStats) HitRate() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Gets)
+}
+
+var DefaultPool = NewPool()
+
+func NewPool() *Pool {
+	return &Pool{now: time.Now}
+}
+
+func poolClass(n int) uint {
+	c := uint(minPoolClass)
+	for (1<<c) < n && c < maxPoolClass {
+		c++
+	}
+	return c
+}
+
+// Get returns a slice of length n. Recycled memory is not cleared.
+func (p *Pool) Get(n int) []byte {
+	c := poolClass(n)
+	if (1 << c) < n {
+		return make([]byte, n)
+	}
+
+	p.mu.Lock()
+	p.autoShrink()
+	p.counters[c].gets++
+	list := p.free[c]
+	if len(list) > 0 {
+		buf := list[len(list)-1].buf
+		list[len(list)-1] = poolEntry{}
+		p.free[c] = list[:len(list)-1]
+		p.counters[c].hits++
+		p.mu.Unlock()
+		return buf[:n]
+	}
+	p.mu.Unlock()
+
+	return make([]byte, n, 1<<c)
+}
+
+// Put returns memory obtained from Get to the pool. Slices whose
+// capacity is not a size class of the pool are dropped.
+func (p *Pool) Put(buf []byte) {
+	c := poolClass(cap(buf))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if (1 << c) != cap(buf) {
+		p.drops++
+		return
+	}
+	p.autoShrink()
+	p.counters[c].puts++
+	p.free[c] = append(p.free[c], poolEntry{
+		buf:   buf[:cap(buf)],
+		since: p.now(),
+	})
+}
+
+// SetMaxIdle makes the pool release buffers which have been idle for
+// longer than d. Idle buffers are swept while the pool is in use; a d of
+// zero disables automatic shrinking.
+func (p *Pool) SetMaxIdle(d time.Duration) {
+	p.mu.Lock()
+	p.maxIdle = d
+	p.mu.Unlock()
+}
+
+// Shrink releases every idle buffer older than maxIdle and returns how
+// many were released.
+func (p *Pool) Shrink(maxIdle time.Duration) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.shrink(maxIdle)
+}
+
+func (p *Pool) autoShrink() {
+	if p.maxIdle <= 0 {
+		return
+	}
+	now := p.now()
+	if now.Sub(p.lastSweep) < p.maxIdle/2 {
+		return
+	}
+	p.lastSweep = now
+	p.shrink(p.maxIdle)
+}
+
+func (p *Pool) shrink(maxIdle time.Duration) int {
+	now := p.now()
+	released := 0
+	for c := range p.free {
+		// Entries are appended, so the oldest ones are at the front
+		list := p.free[c]
+		i := 0
+		for i < len(list) && now.Sub(list[i].since) > maxIdle {
+			i++
+		}
+		if i == 0 {
+			continue
+		}
+		n := copy(list, list[i:])
+		for j := n; j < len(list); j++ {
+			list[j] = poolEntry{}
+		}
+		p.free[c] = list[:n]
+		p.counters[c].shrunk += uint64(i)
+		released += i
+	}
+	return released
+}
+
+// Reclaim releases idle buffers: the older half of each size class
+// under low pressure, and all of them otherwise.
+func (p *Pool) Reclaim(level PressureLevel) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var released int64
+	for c := range p.free {
+		list := p.free[c]
+		i := len(list)
+		if level == PressureLow {
+			i /= 2
+		}
+		if i == 0 {
+			continue
+		}
+		n := copy(list, list[i:])
+		for j := n; j < len(list); j++ {
+			list[j] = poolEntry{}
+		}
+		p.free[c] = list[:n]
+		p.counters[c].shrunk += uint64(i)
+		released += int64(i) << uint(c)
+	}
+	return released
+}
+
+// Stats returns a snapshot of the pool counters.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	stats := PoolStats{Drops: p.drops}
+	for c := range p.free {
+		count := p.counters[c]
+		list := p.free[c]
+		if count.gets == 0 && count.puts == 0 && len(list) == 0 {
+			continue
+		}
+
+		class := PoolClassStats{
+			Size:      1 << uint(c),
+			Gets:      count.gets,
+			Hits:      count.hits,
+			Puts:      count.puts,
+			Shrunk:    count.shrunk,
+			Idle:      len(list),
+			IdleBytes: int64(len(list)) << uint(c),
+		}
+		if len(list) > 0 {
+			class.OldestIdle = now.Sub(list[0].since)
+		}
+		stats.Classes = append(stats.Classes, class)
+
+		stats.Gets += class.Gets
+		stats.Hits += class.Hits
+		stats.Puts += class.Puts
+		stats.Shrunk += class.Shrunk
+		stats.Idle += class.Idle
+		stats.IdleBytes += class.IdleBytes
+	}
+	return stats
+}
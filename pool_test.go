@@ -0,0 +1,130 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolClass(t *testing.T) {
+	assert(t, poolClass(0) == minPoolClass)
+	assert(t, poolClass(64) == 6)
+	assert(t, poolClass(65) == 7)
+	assert(t, poolClass(4096) == 12)
+}
+
+func TestPoolGetPut(t *testing.T) {
+	pool := NewPool()
+
+	buf := pool.Get(100)
+	assert(t, len(buf) == 100)
+	assert(t, cap(buf) == 128)
+	buf[0] = 1
+	pool.Put(buf)
+
+	// Same class is recycled
+	again := pool.Get(128)
+	assert(t, len(again) == 128)
+	assert(t, &again[0] == &buf[0])
+
+	// Foreign slices are dropped
+	pool.Put(make([]byte, 100))
+	other := pool.Get(100)
+	assert(t, cap(other) == 128)
+	assert(t, &other[0] != &buf[0])
+}
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestPoolStats(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	pool := NewPool()
+	pool.now = clock.now
+
+	stats := pool.Stats()
+	assert(t, stats.Gets == 0)
+	assert(t, stats.HitRate() == 0)
+	assert(t, len(stats.Classes) == 0)
+
+	a := pool.Get(100)
+	b := pool.Get(4000)
+	pool.Put(a)
+	clock.advance(time.Second)
+	pool.Put(b)
+	clock.advance(time.Second)
+	pool.Get(128)
+	pool.Put(make([]byte, 10))
+
+	stats = pool.Stats()
+	assert(t, stats.Gets == 3)
+	assert(t, stats.Hits == 1)
+	assert(t, stats.Puts == 2)
+	assert(t, stats.Drops == 1)
+	assert(t, stats.Idle == 1)
+	assert(t, stats.IdleBytes == 4096)
+	assert(t, stats.HitRate() > 0.33 && stats.HitRate() < 0.34)
+
+	assert(t, len(stats.Classes) == 2)
+	assert(t, stats.Classes[0].Size == 128)
+	assert(t, stats.Classes[0].Gets == 2)
+	assert(t, stats.Classes[0].Hits == 1)
+	assert(t, stats.Classes[0].Idle == 0)
+	assert(t, stats.Classes[1].Size == 4096)
+	assert(t, stats.Classes[1].Idle == 1)
+	assert(t, stats.Classes[1].OldestIdle == time.Second)
+}
+
+func TestPoolShrink(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	pool := NewPool()
+	pool.now = clock.now
+
+	pool.Put(make([]byte, 64))
+	clock.advance(time.Minute)
+	fresh := make([]byte, 64)
+	pool.Put(fresh)
+
+	assert(t, pool.Shrink(30*time.Second) == 1)
+	stats := pool.Stats()
+	assert(t, stats.Idle == 1)
+	assert(t, stats.Shrunk == 1)
+
+	// The recent buffer survived
+	again := pool.Get(64)
+	assert(t, &again[0] == &fresh[0])
+}
+
+func TestPoolAutoShrink(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	pool := NewPool()
+	pool.now = clock.now
+	pool.SetMaxIdle(time.Minute)
+
+	pool.Put(make([]byte, 64))
+	pool.Put(make([]byte, 1024))
+	clock.advance(2 * time.Minute)
+
+	// Any pool activity sweeps every class
+	pool.Put(make([]byte, 256))
+	stats := pool.Stats()
+	assert(t, stats.Idle == 1)
+	assert(t, stats.Shrunk == 2)
+
+	pool.SetMaxIdle(0)
+	clock.advance(time.Hour)
+	pool.Put(make([]byte, 256))
+	assert(t, pool.Stats().Idle == 2)
+}
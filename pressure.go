@@ -0,0 +1,116 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// PressureLevel says how urgently memory should be given back.
+type PressureLevel int
+
+const (
+	PressureLow      PressureLevel = iota + 1 // shed memory which is cheap to rebuild
+	PressureModerate                          // shed everything reclaimable
+	PressureCritical                          // as moderate, and return it to the OS
+)
+
+// Reclaimer is implemented by caches which can shed memory on demand,
+// such as Pool and ObjectBuffer. Reclaim returns the bytes released.
+type Reclaimer interface {
+	Reclaim(level PressureLevel) int64
+}
+
+var (
+	reclaimersMu sync.Mutex
+	reclaimers   = map[int]Reclaimer{}
+	reclaimerID  int
+)
+
+func init() {
+	RegisterReclaimer(DefaultPool)
+}
+
+// RegisterReclaimer adds r to the reclaimers called by Pressure and
+// returns a function removing it again. DefaultPool is registered.
+func RegisterReclaimer(r Reclaimer) (unregister func()) {
+	reclaimersMu.Lock()
+	defer reclaimersMu.Unlock()
+	reclaimerID++
+	id := reclaimerID
+	reclaimers[id] = r
+	return func() {
+		reclaimersMu.Lock()
+		delete(reclaimers, id)
+		reclaimersMu.Unlock()
+	}
+}
+
+// Pressure asks every registered reclaimer to shed memory at level and
+// returns the total bytes released. Under critical pressure the freed
+// memory is also returned to the operating system.
+func Pressure(level PressureLevel) int64 {
+	reclaimersMu.Lock()
+	list := make([]Reclaimer, 0, len(reclaimers))
+	for _, r := range reclaimers {
+		list = append(list, r)
+	}
+	reclaimersMu.Unlock()
+
+	// Reclaimers take their own locks, so they are called unlocked
+	var released int64
+	for _, r := range list {
+		released += r.Reclaim(level)
+	}
+	if level >= PressureCritical {
+		debug.FreeOSMemory()
+	}
+	return released
+}
+
+// pressureLevel maps heap usage against limit to a level, or zero when
+// there is no pressure.
+func pressureLevel(heap, limit uint64) PressureLevel {
+	switch {
+	case heap >= limit:
+		return PressureCritical
+	case heap >= limit/10*9:
+		return PressureModerate
+	case heap >= limit/4*3:
+		return PressureLow
+	}
+	return 0
+}
+
+// WatchMemory samples the runtime memory stats every interval and calls
+// Pressure when the heap grows past 75%, 90% and 100% of limit bytes.
+// Calling the returned function stops the watch.
+func WatchMemory(limit uint64, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			runtime.ReadMemStats(&stats)
+			if level := pressureLevel(stats.HeapAlloc, limit); level > 0 {
+				Pressure(level)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+	"time"
+)
+
+type countingReclaimer struct {
+	calls chan PressureLevel
+}
+
+func (c countingReclaimer) Reclaim(level PressureLevel) int64 {
+	c.calls <- level
+	return 1
+}
+
+func TestPressureLevel(t *testing.T) {
+	assert(t, pressureLevel(10, 100) == 0)
+	assert(t, pressureLevel(75, 100) == PressureLow)
+	assert(t, pressureLevel(90, 100) == PressureModerate)
+	assert(t, pressureLevel(150, 100) == PressureCritical)
+}
+
+func TestPoolReclaim(t *testing.T) {
+	p := NewPool()
+	for i := 0; i < 4; i++ {
+		p.Put(make([]byte, 1024))
+	}
+	assert(t, p.Reclaim(PressureLow) == 2*1024)
+	assert(t, p.Stats().Idle == 2)
+	assert(t, p.Reclaim(PressureModerate) == 2*1024)
+	assert(t, p.Stats().Idle == 0)
+	assert(t, p.Stats().Shrunk == 4)
+}
+
+func TestObjectBufferReclaim(t *testing.T) {
+	store := NewMemObjectStore()
+	store.Put("obj", big)
+	o, err := NewObjectBuffer(store, "obj", int64(len(big)), 64)
+	assert(t, err == nil)
+	assert(t, o.Reclaim(PressureCritical) == 0)
+
+	p := make([]byte, 10)
+	_, err = o.ReadAt(p, 10)
+	assert(t, err == nil)
+	assert(t, o.Reclaim(PressureLow) == 0)
+	assert(t, o.Reclaim(PressureModerate) == int64(len(big)))
+	_, err = o.ReadAt(p, 10)
+	assert(t, err == nil && string(p) == string(big[10:20]))
+
+	// Modified objects are kept until flushed
+	_, err = o.WriteAt([]byte("x"), 0)
+	assert(t, err == nil)
+	assert(t, o.Reclaim(PressureCritical) == 0)
+	assert(t, o.Flush() == nil)
+	assert(t, o.Reclaim(PressureCritical) == int64(len(big)))
+}
+
+func TestPressure(t *testing.T) {
+	c := countingReclaimer{make(chan PressureLevel, 10)}
+	unregister := RegisterReclaimer(c)
+	assert(t, Pressure(PressureLow) >= 1)
+	assert(t, <-c.calls == PressureLow)
+	unregister()
+	Pressure(PressureLow)
+	assert(t, len(c.calls) == 0)
+}
+
+func TestWatchMemory(t *testing.T) {
+	c := countingReclaimer{make(chan PressureLevel, 100)}
+	unregister := RegisterReclaimer(c)
+	defer unregister()
+
+	stop := WatchMemory(1, time.Millisecond)
+	select {
+	case level := <-c.calls:
+		assert(t, level == PressureCritical)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pressure reported")
+	}
+	stop()
+	stop()
+}
@@ -0,0 +1,19 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	fallocKeepSize  = 0x01
+	fallocPunchHole = 0x02
+)
+
+func punchHole(f *os.File, off, n int64) error {
+	return syscall.Fallocate(int(f.Fd()), fallocKeepSize|fallocPunchHole, off, n)
+}
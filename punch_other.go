@@ -0,0 +1,16 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package bufferio
+
+import (
+	"os"
+)
+
+func punchHole(f *os.File, off, n int64) error {
+	return ErrUnsupported
+}
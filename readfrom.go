@@ -0,0 +1,143 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+)
+
+// minReadFrom is the least room ReadFrom makes when growing the buffer
+const minReadFrom = 512
+
+// ReadFrom reads from r into the buffer at the current offset until
+// io.EOF, advancing the offset past the data, and returns the number of
+// bytes read. A growable buffer grows to hold everything; any other
+// buffer fails with io.ErrShortWrite once it is full and r still has
+// data, and the byte which showed this is lost.
+func (b *BufferIO) ReadFrom(r io.Reader) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	if err := b.usable(); err != nil {
+		return 0, err
+	}
+	if b.from != nil {
+		return b.readFromStream(r)
+	}
+
+	end := b.size()
+	var total int64
+	for {
+		if b.off >= b.size() {
+			if !b.grow {
+				var probe [1]byte
+				n, err := io.ReadFull(r, probe[:])
+				if n > 0 {
+					return total, io.ErrShortWrite
+				}
+				if err == io.EOF {
+					err = nil
+				}
+				return total, err
+			}
+			room := b.size()
+			if room < minReadFrom {
+				room = minReadFrom
+			}
+			if err := b.growTo(b.off + room); err != nil {
+				return total, err
+			}
+		}
+
+		n, err := r.Read(b.buf[b.off:])
+		b.off += int64(n)
+		total += int64(n)
+		if b.off > end {
+			end = b.off
+		}
+		if err != nil {
+			// Give back the room grown but not filled
+			if end < b.size() {
+				if gerr := b.trim(end); gerr != nil {
+					return total, gerr
+				}
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			return total, err
+		}
+	}
+}
+
+// trim shrinks a buffer grown by ReadFrom back to n bytes
+func (b *BufferIO) trim(n int64) error {
+	if b.backing == BackingHeap {
+		b.buf = b.buf[:n]
+		return nil
+	}
+	return b.resize(n)
+}
+
+// readFromStream is ReadFrom for buffers backed by a reader
+func (b *BufferIO) readFromStream(r io.Reader) (int64, error) {
+	p := DefaultPool.Get(32 * 1024)
+	defer DefaultPool.Put(p)
+
+	var total int64
+	for {
+		n, err := r.Read(p)
+		if n > 0 {
+			w, werr := b.writeAt(p[:n], b.off)
+			b.off += int64(w)
+			total += int64(w)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// WriteTo writes the unread part of the buffer, from the current offset
+// to the end, to w and advances the offset past what was written. File
+// mapped buffers hand the file itself to a w which is an io.ReaderFrom,
+// so that a *net.TCPConn sends it with sendfile rather than copying it
+// through the process.
+func (b *BufferIO) WriteTo(w io.Writer) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	if err := b.usable(); err != nil {
+		return 0, err
+	}
+	if b.off >= b.length() {
+		return 0, nil
+	}
+
+	var n int64
+	var err error
+	rf, ok := w.(io.ReaderFrom)
+	switch {
+	case b.from != nil:
+		n, err = io.Copy(w, b.section(b.off))
+	case ok && b.backing == BackingFile:
+		n, err = b.sendFile(rf)
+	default:
+		var c int
+		c, err = w.Write(b.buf[b.off:])
+		n = int64(c)
+		if err == nil && n < b.size()-b.off {
+			err = io.ErrShortWrite
+		}
+	}
+	b.off += n
+	return n, err
+}
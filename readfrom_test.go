@@ -0,0 +1,65 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadFrom(t *testing.T) {
+	bio := NewBufferIOMake(len(big) + 4)
+	bio.Seek(2, os.SEEK_SET)
+	n, err := bio.ReadFrom(iotest.OneByteReader(bytes.NewReader(big)))
+	assert(t, err == nil && n == int64(len(big)))
+	assert(t, bytes.Equal(bio.Bytes()[2:2+len(big)], big))
+	pos, _ := bio.Seek(0, os.SEEK_CUR)
+	assert(t, pos == int64(2+len(big)))
+
+	// Full buffers report the data they could not take
+	small := NewBufferIOMake(4)
+	n, err = small.ReadFrom(bytes.NewReader(big))
+	assert(t, n == 4 && err == io.ErrShortWrite)
+	exact := NewBufferIOMake(4)
+	n, err = exact.ReadFrom(strings.NewReader("abcd"))
+	assert(t, n == 4 && err == nil)
+
+	// Errors of the reader are passed on
+	n, err = NewBufferIOMake(10).ReadFrom(iotest.TimeoutReader(strings.NewReader("abcdefghijkl")))
+	assert(t, n == 10 && err == iotest.ErrTimeout)
+}
+
+func TestReadFromGrowable(t *testing.T) {
+	bio := NewBufferIOGrow(0)
+	data := bytes.Repeat(big, 50)
+	n, err := io.Copy(bio, iotest.HalfReader(bytes.NewReader(data)))
+	assert(t, err == nil && n == int64(len(data)))
+	assert(t, bytes.Equal(bio.Bytes(), data))
+	assert(t, bio.Size() == int64(len(data)))
+}
+
+func TestWriteTo(t *testing.T) {
+	bio := NewBufferIO(big)
+	bio.Seek(10, os.SEEK_SET)
+	var out bytes.Buffer
+	n, err := io.Copy(&out, bio)
+	assert(t, err == nil && n == int64(len(big)-10))
+	assert(t, bytes.Equal(out.Bytes(), big[10:]))
+
+	// Drained buffers have nothing more to give
+	n, err = bio.WriteTo(&out)
+	assert(t, err == nil && n == 0)
+
+	backed := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	backed.Seek(4, os.SEEK_SET)
+	out.Reset()
+	n, err = backed.WriteTo(&out)
+	assert(t, err == nil && n == int64(len(big)-4))
+	assert(t, bytes.Equal(out.Bytes(), big[4:]))
+}
@@ -0,0 +1,126 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"sort"
+)
+
+// NumRecords returns the number of complete records of recordSize
+// bytes held in the buffer.
+func (b *BufferIO) NumRecords(recordSize int) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.numRecords(recordSize)
+}
+
+func (b *BufferIO) numRecords(recordSize int) int {
+	if recordSize <= 0 {
+		return 0
+	}
+	return int(b.length() / int64(recordSize))
+}
+
+// Record returns the i-th record of recordSize bytes, or ErrOverrun when
+// there is no such complete record. The returned slice shares memory
+// with the buffer, so buffers backed by a ReaderAt return
+// ErrUnsupported.
+func (b *BufferIO) Record(recordSize, i int) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.memory(); err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= b.numRecords(recordSize) {
+		return nil, ErrOverrun
+	}
+	return b.record(recordSize, i), nil
+}
+
+func (b *BufferIO) record(recordSize, i int) []byte {
+	off := i * recordSize
+	return b.buf[off : off+recordSize : off+recordSize]
+}
+
+// SearchRecords performs a binary search over the buffer viewed as a
+// sorted array of recordSize byte records. cmp must return a negative
+// number if the record sorts before the target, zero if it matches,
+// and a positive number if it sorts after. It returns the index of the
+// first record for which cmp is not negative, and whether that record
+// matched. cmp runs with the buffer locked and must not call its methods.
+// Closed buffers and those backed by a ReaderAt hold no records to
+// search; SearchRecordsErr reports why.
+func (b *BufferIO) SearchRecords(recordSize int, cmp func(record []byte) int) (index int, found bool) {
+	index, found, _ = b.SearchRecordsErr(recordSize, cmp)
+	return index, found
+}
+
+// SearchRecordsErr is SearchRecords which also returns the error of a
+// closed buffer, or ErrUnsupported for one backed by a ReaderAt.
+func (b *BufferIO) SearchRecordsErr(recordSize int, cmp func(record []byte) int) (index int, found bool, err error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.memory(); err != nil {
+		return 0, false, err
+	}
+
+	n := b.numRecords(recordSize)
+	lo, hi := 0, n
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if cmp(b.record(recordSize, mid)) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < n && cmp(b.record(recordSize, lo)) == 0, nil
+}
+
+type recordSorter struct {
+	b       *BufferIO
+	size    int
+	less    func(a, b []byte) bool
+	scratch []byte
+}
+
+func (r *recordSorter) Len() int {
+	return r.b.numRecords(r.size)
+}
+
+func (r *recordSorter) Less(i, j int) bool {
+	return r.less(r.b.record(r.size, i), r.b.record(r.size, j))
+}
+
+func (r *recordSorter) Swap(i, j int) {
+	a, b := r.b.record(r.size, i), r.b.record(r.size, j)
+	copy(r.scratch, a)
+	copy(a, b)
+	copy(b, r.scratch)
+}
+
+// SortRecords sorts the buffer in place, viewed as an array of
+// recordSize byte records ordered by less. A single scratch record is
+// allocated for swaps. Trailing bytes which do not make up a complete
+// record are left untouched. less runs with the buffer locked and must not
+// call its methods. Buffers backed by a ReaderAt return ErrUnsupported.
+func (b *BufferIO) SortRecords(recordSize int, less func(a, b []byte) bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.memory(); err != nil {
+		return err
+	}
+
+	if b.numRecords(recordSize) < 2 {
+		return nil
+	}
+	sort.Sort(&recordSorter{
+		b:       b,
+		size:    recordSize,
+		less:    less,
+		scratch: make([]byte, recordSize),
+	})
+	return nil
+}
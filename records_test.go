@@ -0,0 +1,144 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func makeRecords(keys []uint32, recordSize int) *BufferIO {
+	bio := NewBufferIOMake(len(keys) * recordSize)
+	for i, k := range keys {
+		binary.BigEndian.PutUint32(bio.buf[i*recordSize:], k)
+	}
+	return bio
+}
+
+func keyCmp(key uint32) func([]byte) int {
+	return func(record []byte) int {
+		k := binary.BigEndian.Uint32(record)
+		switch {
+		case k < key:
+			return -1
+		case k > key:
+			return 1
+		}
+		return 0
+	}
+}
+
+func TestNumRecords(t *testing.T) {
+	bio := NewBufferIOMake(70)
+	assert(t, bio.NumRecords(32) == 2)
+	assert(t, bio.NumRecords(70) == 1)
+	assert(t, bio.NumRecords(71) == 0)
+	assert(t, bio.NumRecords(0) == 0)
+	assert(t, bio.NumRecords(-1) == 0)
+}
+
+func TestRecord(t *testing.T) {
+	bio := NewBufferIO(big)
+	r, err := bio.Record(4, 2)
+	assert(t, err == nil)
+	assert(t, len(r) == 4)
+	assert(t, cap(r) == 4)
+	assert(t, bytes.Equal(r, big[8:12]))
+
+	_, err = bio.Record(4, len(big)/4)
+	assert(t, err == ErrOverrun)
+	_, err = bio.Record(4, -1)
+	assert(t, err == ErrOverrun)
+	_, err = bio.Record(0, 0)
+	assert(t, err == ErrOverrun)
+
+	backed := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	assert(t, backed.NumRecords(4) == len(big)/4)
+	_, err = backed.Record(4, 0)
+	assert(t, err == ErrUnsupported)
+	_, _, err = backed.SearchRecordsErr(4, keyCmp(1))
+	assert(t, err == ErrUnsupported)
+
+	bio.Close()
+	_, err = bio.Record(4, 0)
+	assert(t, err == ErrClosed)
+	_, _, err = bio.SearchRecordsErr(4, keyCmp(1))
+	assert(t, err == ErrClosed)
+	i, found := bio.SearchRecords(4, keyCmp(1))
+	assert(t, i == 0 && !found)
+}
+
+func TestSearchRecords(t *testing.T) {
+	bio := makeRecords([]uint32{2, 4, 4, 8, 16, 32}, 32)
+
+	i, found := bio.SearchRecords(32, keyCmp(8))
+	assert(t, i == 3)
+	assert(t, found)
+
+	// Duplicates return the first match
+	i, found = bio.SearchRecords(32, keyCmp(4))
+	assert(t, i == 1)
+	assert(t, found)
+
+	// Missing keys return the insertion point
+	i, found = bio.SearchRecords(32, keyCmp(5))
+	assert(t, i == 3)
+	assert(t, !found)
+
+	i, found = bio.SearchRecords(32, keyCmp(1))
+	assert(t, i == 0)
+	assert(t, !found)
+
+	i, found = bio.SearchRecords(32, keyCmp(64))
+	assert(t, i == 6)
+	assert(t, !found)
+
+	// Empty buffer
+	bio = NewBufferIOMake(0)
+	i, found = bio.SearchRecords(32, keyCmp(1))
+	assert(t, i == 0)
+	assert(t, !found)
+}
+
+func TestSortRecords(t *testing.T) {
+	keys := []uint32{32, 4, 16, 2, 8, 4}
+	bio := makeRecords(keys, 32)
+	tail := []byte{0xaa, 0xbb}
+	bio = NewBufferIO(append(bio.Bytes(), tail...))
+
+	// Tag each record so we can verify it moved as a whole
+	for i := range keys {
+		r, _ := bio.Record(32, i)
+		r[31] = byte(keys[i])
+	}
+
+	assert(t, bio.SortRecords(32, func(a, b []byte) bool {
+		return binary.BigEndian.Uint32(a) < binary.BigEndian.Uint32(b)
+	}) == nil)
+
+	want := []uint32{2, 4, 4, 8, 16, 32}
+	for i, k := range want {
+		r, err := bio.Record(32, i)
+		assert(t, err == nil)
+		assert(t, binary.BigEndian.Uint32(r) == k)
+		assert(t, r[31] == byte(k))
+	}
+	assert(t, bytes.Equal(bio.Bytes()[len(want)*32:], tail))
+
+	i, found := bio.SearchRecords(32, keyCmp(16))
+	assert(t, i == 4)
+	assert(t, found)
+
+	// Nothing to sort
+	bio = NewBufferIOMake(10)
+	assert(t, bio.SortRecords(32, func(a, b []byte) bool { return true }) == nil)
+	assert(t, bio.SortRecords(0, func(a, b []byte) bool { return true }) == nil)
+
+	from := NewBufferIOFrom(bytes.NewReader(make([]byte, 64)), 64)
+	assert(t, from.SortRecords(32, func(a, b []byte) bool { return true }) == ErrUnsupported)
+	bio.Close()
+	assert(t, bio.SortRecords(32, func(a, b []byte) bool { return true }) == ErrClosed)
+}
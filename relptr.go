@@ -0,0 +1,48 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+)
+
+// RelPtrSize is the size of a relative pointer.
+const RelPtrSize = 4
+
+// WriteRelPtr stores at off a pointer to target, encoded as a little
+// endian int32 distance from off itself. The pointer stays valid when
+// the region holding both is copied to another offset or buffer.
+func (b *BufferIO) WriteRelPtr(off, target int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.slice(off, RelPtrSize)
+	if err != nil {
+		return err
+	}
+	rel := target - off
+	if target < 0 || target >= b.size() || rel != int64(int32(rel)) {
+		return ErrOverrun
+	}
+	binary.LittleEndian.PutUint32(r, uint32(int32(rel)))
+	return nil
+}
+
+// ReadRelPtr follows the relative pointer at off and returns the offset
+// it points to. Pointers leading outside the buffer give ErrOverrun.
+func (b *BufferIO) ReadRelPtr(off int64) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.slice(off, RelPtrSize)
+	if err != nil {
+		return 0, err
+	}
+	target := off + int64(int32(binary.LittleEndian.Uint32(r)))
+	if target < 0 || target >= b.size() {
+		return 0, ErrOverrun
+	}
+	return target, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+)
+
+func TestRelPtr(t *testing.T) {
+	b := NewBufferIOMake(64)
+
+	assert(t, b.WriteRelPtr(8, 20) == nil)
+	assert(t, b.WriteRelPtr(40, 2) == nil)
+	target, err := b.ReadRelPtr(8)
+	assert(t, err == nil)
+	assert(t, target == 20)
+	target, err = b.ReadRelPtr(40)
+	assert(t, err == nil)
+	assert(t, target == 2)
+
+	// Moving the region keeps the pointer valid
+	nested := NewBufferIOMake(128)
+	nested.WriteAt(b.Bytes(), 50)
+	target, err = nested.ReadRelPtr(58)
+	assert(t, err == nil)
+	assert(t, target == 70)
+}
+
+func TestRelPtrBounds(t *testing.T) {
+	b := NewBufferIOMake(16)
+
+	assert(t, b.WriteRelPtr(13, 0) == ErrOverrun)
+	assert(t, b.WriteRelPtr(0, 16) == ErrOverrun)
+	assert(t, b.WriteRelPtr(0, -1) == ErrOverrun)
+
+	// Corrupt pointers are caught on read
+	b.WriteAt([]byte{0xf0, 0xff, 0xff, 0xff}, 4)
+	_, err := b.ReadRelPtr(4)
+	assert(t, err == ErrOverrun)
+	b.WriteAt([]byte{12, 0, 0, 0}, 4)
+	_, err = b.ReadRelPtr(4)
+	assert(t, err == ErrOverrun)
+	b.WriteAt([]byte{11, 0, 0, 0}, 4)
+	target, err := b.ReadRelPtr(4)
+	assert(t, err == nil)
+	assert(t, target == 15)
+
+	assert(t, b.Close() == nil)
+	_, err = b.ReadRelPtr(4)
+	assert(t, err == ErrClosed)
+}
@@ -0,0 +1,144 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"os"
+)
+
+var ErrInvalidView = errors.New("bufferio: view used after its buffer was resized or closed")
+
+// Resize changes the size of the buffer to n bytes, keeping the contents
+// up to the smaller of the two sizes and zero filling any growth. The
+// offset is moved back to n if it lies beyond it.
+//
+// Heap buffers are reallocated, so a slice given to NewBufferIO is no
+// longer shared afterwards. Mapped buffers are remapped, in place when
+// the kernel can, with mremap on Linux. File mappings extend the file
+// as needed but never truncate it, so growing them shows what the file
+// holds rather than zeros. Buffers of an Options.Allocator
+// are copied into a new allocation. Either way the memory may move:
+// slices previously obtained from the buffer must not be used, word
+// views panic with ErrInvalidView, and windows return it. A window
+// itself cannot be resized, and returns ErrUnsupported.
+func (b *BufferIO) Resize(n int64) error {
+	if n < 0 || int64(int(n)) != n {
+		return errors.New("invalid size")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return err
+	}
+	if b.from != nil {
+		return ErrUnsupported
+	}
+	return b.resize(n)
+}
+
+// Truncate changes the size of the buffer to n bytes like Resize, but
+// leaves heap memory where it is when it can: shrinking keeps the
+// capacity for later growth, and growth within the capacity is zero
+// filled in place. Other backings are resized as by Resize. Views are
+// invalidated either way, as their range may no longer exist.
+func (b *BufferIO) Truncate(n int64) error {
+	if n < 0 || int64(int(n)) != n {
+		return errors.New("invalid size")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return err
+	}
+	if b.from != nil {
+		return ErrUnsupported
+	}
+	if b.backing != BackingHeap || n > int64(cap(b.buf)) {
+		return b.resize(n)
+	}
+
+	old := len(b.buf)
+	b.buf = b.buf[:n]
+	if int(n) > old {
+		zero(b.buf[old:])
+	}
+	b.moved()
+	if b.off > n {
+		b.off = n
+	}
+	return nil
+}
+
+func (b *BufferIO) resize(n int64) error {
+	if n == b.size() {
+		return nil
+	}
+	// Windows and nested buffers cannot move out of their parent
+	if b.parent != nil {
+		return ErrUnsupported
+	}
+
+	switch b.backing {
+	case BackingHeap:
+		buf := make([]byte, n)
+		copy(buf, b.buf)
+		b.buf = buf
+
+	case BackingMmap, BackingHugePages:
+		old := len(b.buf)
+		buf, err := mremapAnon(b.buf, int(n))
+		if err != nil {
+			return err
+		}
+		// Mappings have page granularity, so the bytes past a shrunk
+		// end can reappear when growing again
+		if int(n) > old {
+			page := os.Getpagesize()
+			end := (old + page - 1) / page * page
+			if end > int(n) {
+				end = int(n)
+			}
+			zero(buf[old:end])
+		}
+		b.buf = buf
+		if b.backing == BackingHugePages && n > 0 {
+			adviseHugePages(buf)
+		}
+
+	case BackingFile:
+		if err := b.remapFile(int(n)); err != nil {
+			return err
+		}
+
+	case BackingAllocator:
+		buf, err := b.alloc.Alloc(int(n))
+		if err != nil {
+			return err
+		}
+		copy(buf, b.buf)
+		if len(buf) > len(b.buf) {
+			zero(buf[len(b.buf):])
+		}
+		if b.release != nil {
+			if err := b.release(b.buf); err != nil {
+				b.alloc.Free(buf)
+				return err
+			}
+		}
+		b.buf = buf
+
+	default:
+		return ErrUnsupported
+	}
+
+	b.moved()
+	if b.off > n {
+		b.off = n
+	}
+	return nil
+}
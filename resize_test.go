@@ -0,0 +1,141 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+func testResize(t *testing.T, opts Options) {
+	bio, err := NewBufferIOOptions(len(big), opts)
+	if err == ErrUnsupported {
+		t.Skipf("backing %d: %v", opts.Backing, err)
+	}
+	assert(t, err == nil)
+	defer bio.Close()
+	bio.Write(big)
+
+	// Grow across several pages
+	assert(t, bio.Resize(3*4096+5) == nil)
+	assert(t, bio.Size() == 3*4096+5)
+	assert(t, bytes.Equal(bio.buf[:len(big)], big))
+	assert(t, isZero(bio.buf[len(big):]))
+	assert(t, bio.off == int64(len(big)))
+	bio.buf[len(bio.buf)-1] = 0xff
+
+	// Shrink below the offset
+	assert(t, bio.Resize(10) == nil)
+	assert(t, bio.Size() == 10)
+	assert(t, bytes.Equal(bio.buf, big[:10]))
+	assert(t, bio.off == 10)
+
+	// Growth after shrinking is zero filled
+	assert(t, bio.Resize(4096) == nil)
+	assert(t, isZero(bio.buf[10:]))
+
+	assert(t, bio.Resize(0) == nil)
+	assert(t, bio.Size() == 0)
+	assert(t, bio.Resize(100) == nil)
+	assert(t, bio.Size() == 100)
+
+	assert(t, bio.Resize(-1) != nil)
+}
+
+func TestResizeHeap(t *testing.T) {
+	testResize(t, Options{Backing: BackingHeap})
+
+	// Buffers from NewBufferIO are heap backed
+	mem := append([]byte(nil), src...)
+	bio := NewBufferIO(mem)
+	assert(t, bio.Resize(16) == nil)
+	bio.buf[0] = 0xff
+	assert(t, mem[0] == src[0])
+	assert(t, bytes.Equal(bio.buf[1:8], src[1:]))
+}
+
+func TestResizeMmap(t *testing.T) {
+	testResize(t, Options{Backing: BackingMmap})
+}
+
+func TestResizeHugePages(t *testing.T) {
+	testResize(t, Options{Backing: BackingHugePages})
+}
+
+func TestResizeUnsupported(t *testing.T) {
+	bio, _ := NewBufferIOOptions(64, Options{Backing: BackingPool})
+	assert(t, bio.Resize(128) == ErrUnsupported)
+	assert(t, bio.Size() == 64)
+
+	// Same size is always fine
+	assert(t, bio.Resize(64) == nil)
+}
+
+func TestResizeInvalidatesViews(t *testing.T) {
+	bio := NewBufferIOMake(16)
+	view := bio.View32(binary.BigEndian)
+	view.Set(0, 1)
+
+	bio.Resize(32)
+	defer func() {
+		assert(t, recover() == ErrInvalidView)
+
+		// New views work
+		view = bio.View32(binary.BigEndian)
+		assert(t, view.Get(0) == 1)
+		assert(t, view.Len() == 8)
+	}()
+	view.Get(0)
+}
+
+func TestResizeSeek(t *testing.T) {
+	bio := NewBufferIOMake(8)
+	bio.Resize(16)
+	offset, err := bio.Seek(-1, os.SEEK_END)
+	assert(t, offset == 15)
+	assert(t, err == nil)
+}
+
+func TestTruncate(t *testing.T) {
+	bio := NewBufferIO(append([]byte(nil), big...))
+	bio.Seek(0, os.SEEK_END)
+	mem := &bio.buf[0]
+
+	// Shrinking keeps the memory and clamps the offset
+	assert(t, bio.Truncate(10) == nil)
+	assert(t, bio.Size() == 10 && bio.off == 10)
+	assert(t, &bio.buf[0] == mem)
+	assert(t, bytes.Equal(bio.buf, big[:10]))
+
+	// Growth within the capacity is zero filled in place
+	assert(t, bio.Truncate(20) == nil)
+	assert(t, &bio.buf[0] == mem)
+	assert(t, bytes.Equal(bio.buf[:10], big[:10]))
+	assert(t, isZero(bio.buf[10:]))
+
+	// Beyond it the buffer is reallocated as by Resize
+	assert(t, bio.Truncate(int64(len(big))+100) == nil)
+	assert(t, bio.Size() == int64(len(big))+100)
+	assert(t, isZero(bio.buf[10:]))
+
+	assert(t, bio.Truncate(-1) != nil)
+	from := NewBufferIOFrom(bytes.NewReader(big), int64(len(big)))
+	assert(t, from.Truncate(4) == ErrUnsupported)
+	bio.Close()
+	assert(t, bio.Truncate(4) == ErrClosed)
+}
+
+func TestTruncateMmap(t *testing.T) {
+	bio, err := NewBufferIOOptions(64, Options{Backing: BackingMmap})
+	if err == ErrUnsupported {
+		t.Skip(err)
+	}
+	defer bio.Close()
+	bio.buf[5] = 1
+	assert(t, bio.Truncate(4) == nil && bio.Size() == 4)
+	assert(t, bio.Truncate(8) == nil && isZero(bio.buf[4:]))
+}
@@ -0,0 +1,184 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Flusher is implemented by backends which buffer writes.
+type Flusher interface {
+	Flush() error
+}
+
+// RetryPolicy describes how transient errors from a backend are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first
+	// one. Values below one mean a single attempt.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. It doubles after
+	// every attempt up to MaxBackoff, when MaxBackoff is non-zero.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable classifies errors. IsTransient is used when nil.
+	Retryable func(err error) bool
+}
+
+var sleep = time.Sleep
+
+// IsTransient reports whether err is a temporary condition, such as
+// EINTR or EAGAIN, which is worth retrying.
+func IsTransient(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+	if t, ok := err.(interface {
+		Temporary() bool
+	}); ok && t.Temporary() {
+		return true
+	}
+	if t, ok := err.(interface {
+		Timeout() bool
+	}); ok && t.Timeout() {
+		return true
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// maxBackoff is where doubling the backoff stops, short of overflowing
+const maxBackoff = time.Duration(1<<63 - 1)
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		if d > maxBackoff/2 {
+			d = maxBackoff
+			break
+		}
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// Do calls op until it succeeds, fails with an error which is not
+// retryable, or the attempts are exhausted. The last error is returned.
+func (p *RetryPolicy) Do(op func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op()
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+		if d := p.backoff(attempt); d > 0 {
+			sleep(d)
+		}
+	}
+}
+
+// retryIO repeats a positional operation, resuming after the bytes
+// already transferred when an attempt fails part way.
+func (p *RetryPolicy) retryIO(buf []byte, off int64,
+	op func([]byte, int64) (int, error)) (int, error) {
+
+	total := 0
+	err := p.Do(func() error {
+		n, err := op(buf[total:], off+int64(total))
+		total += n
+		return err
+	})
+	return total, err
+}
+
+type retryReaderAt struct {
+	r      io.ReaderAt
+	policy RetryPolicy
+}
+
+// NewRetryReaderAt returns a ReaderAt which applies policy to ReadAt
+// errors of r. io.EOF is never retried. When r is also a WriterAt, so
+// is the returned value, retrying writes as NewRetryWriterAt does, and
+// likewise for Flusher, so a writable backend of NewBufferIOFrom stays
+// writable when wrapped.
+func NewRetryReaderAt(r io.ReaderAt, policy RetryPolicy) io.ReaderAt {
+	rr := &retryReaderAt{r: r, policy: policy}
+	w, ok := r.(io.WriterAt)
+	if !ok {
+		return rr
+	}
+	rw := &retryReadWriterAt{rr, &retryWriterAt{w: w, policy: policy}}
+	if _, ok := r.(Flusher); ok {
+		return &retryReadWriteFlusher{rw}
+	}
+	return rw
+}
+
+func (r *retryReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	policy := r.policy
+	policy.Retryable = func(err error) bool {
+		return err != io.EOF && r.policy.retryable(err)
+	}
+	return policy.retryIO(p, off, r.r.ReadAt)
+}
+
+type retryWriterAt struct {
+	w      io.WriterAt
+	policy RetryPolicy
+}
+
+// NewRetryWriterAt returns a WriterAt which applies policy to WriteAt
+// errors of w. When w is also a Flusher, so is the returned value.
+func NewRetryWriterAt(w io.WriterAt, policy RetryPolicy) io.WriterAt {
+	rw := &retryWriterAt{w: w, policy: policy}
+	if _, ok := w.(Flusher); ok {
+		return &retryWriteFlusher{rw}
+	}
+	return rw
+}
+
+func (w *retryWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return w.policy.retryIO(p, off, w.w.WriteAt)
+}
+
+type retryWriteFlusher struct {
+	*retryWriterAt
+}
+
+func (w *retryWriteFlusher) Flush() error {
+	return w.policy.Do(w.w.(Flusher).Flush)
+}
+
+type retryReadWriterAt struct {
+	*retryReaderAt
+	*retryWriterAt
+}
+
+type retryReadWriteFlusher struct {
+	*retryReadWriterAt
+}
+
+func (rw *retryReadWriteFlusher) Flush() error {
+	w := rw.retryWriterAt
+	return w.policy.Do(w.w.(Flusher).Flush)
+}
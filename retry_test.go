@@ -0,0 +1,208 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyBackend fails the first failures calls of each operation, after
+// transferring at most partial bytes.
+type flakyBackend struct {
+	bio      *BufferIO
+	failures int
+	partial  int
+	err      error
+	calls    int
+	flushes  int
+}
+
+func (f *flakyBackend) fail(p []byte) ([]byte, bool) {
+	f.calls++
+	if f.calls > f.failures {
+		return p, false
+	}
+	if len(p) > f.partial {
+		p = p[:f.partial]
+	}
+	return p, true
+}
+
+func (f *flakyBackend) ReadAt(p []byte, off int64) (int, error) {
+	q, failed := f.fail(p)
+	n, err := f.bio.ReadAt(q, off)
+	if failed {
+		return n, f.err
+	}
+	return n, err
+}
+
+func (f *flakyBackend) WriteAt(p []byte, off int64) (int, error) {
+	q, failed := f.fail(p)
+	n, err := f.bio.WriteAt(q, off)
+	if failed {
+		return n, f.err
+	}
+	return n, err
+}
+
+func (f *flakyBackend) Flush() error {
+	f.flushes++
+	if f.flushes <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func TestIsTransient(t *testing.T) {
+	assert(t, IsTransient(syscall.EINTR))
+	assert(t, IsTransient(syscall.EAGAIN))
+	assert(t, IsTransient(&os.PathError{Op: "read", Path: "x", Err: syscall.EINTR}))
+	assert(t, IsTransient(os.NewSyscallError("pread", syscall.EAGAIN)))
+	assert(t, !IsTransient(syscall.EBADF))
+	assert(t, !IsTransient(io.EOF))
+	assert(t, !IsTransient(errors.New("permanent")))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	assert(t, p.backoff(1) == time.Millisecond)
+	assert(t, p.backoff(2) == 2*time.Millisecond)
+	assert(t, p.backoff(3) == 4*time.Millisecond)
+	assert(t, p.backoff(4) == 5*time.Millisecond)
+	assert(t, p.backoff(100) == 5*time.Millisecond)
+
+	// Without a maximum the doubling saturates instead of overflowing
+	p.MaxBackoff = 0
+	assert(t, p.backoff(200) == maxBackoff)
+	assert(t, p.backoff(1000) > 0)
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	calls := 0
+	p := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	err := p.Do(func() error {
+		calls++
+		return syscall.EINTR
+	})
+	assert(t, err == syscall.EINTR)
+	assert(t, calls == 3)
+	assert(t, len(slept) == 2)
+	assert(t, slept[1] == 2*time.Millisecond)
+
+	// Permanent errors are not retried
+	calls = 0
+	permanent := errors.New("permanent")
+	err = p.Do(func() error {
+		calls++
+		return permanent
+	})
+	assert(t, err == permanent)
+	assert(t, calls == 1)
+
+	// Custom classification
+	calls = 0
+	p.Retryable = func(err error) bool { return err == permanent }
+	err = p.Do(func() error {
+		calls++
+		if calls < 2 {
+			return permanent
+		}
+		return nil
+	})
+	assert(t, err == nil)
+	assert(t, calls == 2)
+
+	// No policy means a single attempt
+	calls = 0
+	var none RetryPolicy
+	none.Do(func() error {
+		calls++
+		return syscall.EINTR
+	})
+	assert(t, calls == 1)
+}
+
+func TestRetryReaderAt(t *testing.T) {
+	f := &flakyBackend{
+		bio:      NewBufferIO(big),
+		failures: 2,
+		partial:  3,
+		err:      syscall.EAGAIN,
+	}
+	r := NewRetryReaderAt(f, RetryPolicy{MaxAttempts: 3})
+
+	buf := make([]byte, 10)
+	n, err := r.ReadAt(buf, 4)
+	assert(t, n == 10)
+	assert(t, err == nil)
+	for i := 0; i < len(buf); i++ {
+		assert(t, buf[i] == big[i+4])
+	}
+
+	// EOF is reported, not retried
+	f.calls, f.failures = 0, 0
+	n, err = r.ReadAt(buf, int64(len(big)+1))
+	assert(t, n == 0)
+	assert(t, err == ErrEOF)
+	assert(t, f.calls == 1)
+
+	// Writable backends stay writable behind the retries
+	f.calls, f.failures = 0, 1
+	bio := NewBufferIOFrom(NewRetryReaderAt(f, RetryPolicy{MaxAttempts: 2}), 16)
+	f.bio = NewBufferIOMake(16)
+	n, err = bio.WriteAt(src, 2)
+	assert(t, n == len(src) && err == nil)
+	assert(t, bytes.Equal(f.bio.buf[2:2+len(src)], src))
+	assert(t, bio.Flush() == nil)
+	assert(t, f.flushes == 2)
+
+	_, ok := NewRetryReaderAt(bytes.NewReader(big), RetryPolicy{}).(io.WriterAt)
+	assert(t, !ok)
+}
+
+func TestRetryWriterAt(t *testing.T) {
+	f := &flakyBackend{
+		bio:      NewBufferIOMake(16),
+		failures: 1,
+		partial:  5,
+		err:      syscall.EINTR,
+	}
+	w := NewRetryWriterAt(f, RetryPolicy{MaxAttempts: 2})
+
+	n, err := w.WriteAt(src, 2)
+	assert(t, n == len(src))
+	assert(t, err == nil)
+	for i := 0; i < len(src); i++ {
+		assert(t, f.bio.buf[i+2] == src[i])
+	}
+
+	flusher, ok := w.(Flusher)
+	assert(t, ok)
+	assert(t, flusher.Flush() == nil)
+	assert(t, f.flushes == 2)
+
+	// Attempts exhausted
+	f.calls, f.failures, f.flushes = 0, 5, 0
+	n, err = w.WriteAt(src, 0)
+	assert(t, n == len(src))
+	assert(t, err == syscall.EINTR)
+	assert(t, flusher.Flush() == syscall.EINTR)
+
+	// Plain writers are not turned into flushers. BufferIO is itself
+	// a Flusher, so it is wrapped to hide that.
+	_, ok = NewRetryWriterAt(struct{ io.WriterAt }{NewBufferIOMake(1)}, RetryPolicy{}).(Flusher)
+	assert(t, !ok)
+}
@@ -0,0 +1,45 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+)
+
+var ErrWordSize = errors.New("range is not a whole number of words")
+
+// ReverseRange reverses the order of the n bytes at off.
+func (b *BufferIO) ReverseRange(off, n int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.slice(off, n)
+	if err != nil {
+		return err
+	}
+	reverseBytes(r)
+	return nil
+}
+
+// ReverseWords reverses the order of the width byte words in the n
+// bytes at off, keeping the bytes of each word in place.
+func (b *BufferIO) ReverseWords(off, n, width int64) error {
+	if width <= 0 || n%width != 0 {
+		return ErrWordSize
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.slice(off, n)
+	if err != nil {
+		return err
+	}
+	reverseBytes(r)
+	for i := int64(0); i < n; i += width {
+		reverseBytes(r[i : i+width])
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+)
+
+func TestReverseRange(t *testing.T) {
+	b := NewBufferIO([]byte("0123456789"))
+
+	assert(t, b.ReverseRange(2, 5) == nil)
+	assert(t, string(b.Bytes()) == "0165432789")
+	assert(t, b.ReverseRange(0, 10) == nil)
+	assert(t, string(b.Bytes()) == "9872345610")
+	assert(t, b.ReverseRange(3, 0) == nil)
+
+	assert(t, b.ReverseRange(8, 3) == ErrOverrun)
+	assert(t, b.Close() == nil)
+	assert(t, b.ReverseRange(0, 1) == ErrClosed)
+}
+
+func TestReverseWords(t *testing.T) {
+	b := NewBufferIO([]byte("aabbccdd.."))
+
+	assert(t, b.ReverseWords(0, 8, 2) == nil)
+	assert(t, string(b.Bytes()) == "ddccbbaa..")
+	assert(t, b.ReverseWords(0, 8, 4) == nil)
+	assert(t, string(b.Bytes()) == "bbaaddcc..")
+
+	// A width of one is a plain reversal
+	assert(t, b.ReverseWords(0, 4, 1) == nil)
+	assert(t, string(b.Bytes()) == "aabbddcc..")
+
+	assert(t, b.ReverseWords(0, 7, 2) == ErrWordSize)
+	assert(t, b.ReverseWords(0, 8, 0) == ErrWordSize)
+	assert(t, b.ReverseWords(4, 8, 2) == ErrOverrun)
+}
@@ -0,0 +1,645 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var (
+	ErrNoDocument = errors.New("buffer does not hold a document")
+	ErrRange      = errors.New("value does not fit its field")
+)
+
+// FieldKind is the type of a schema field.
+type FieldKind uint8
+
+const (
+	KindPad FieldKind = iota // unused bytes
+	KindBool
+	KindInt8
+	KindInt16
+	KindInt32
+	KindInt64
+	KindUint8
+	KindUint16
+	KindUint32
+	KindUint64
+	KindFloat32
+	KindFloat64
+	numKinds
+)
+
+var kindSizes = [numKinds]int{1, 1, 1, 2, 4, 8, 1, 2, 4, 8, 4, 8}
+
+var kindTypes = [numKinds]reflect.Type{
+	KindPad:     reflect.TypeOf(uint8(0)),
+	KindBool:    reflect.TypeOf(false),
+	KindInt8:    reflect.TypeOf(int8(0)),
+	KindInt16:   reflect.TypeOf(int16(0)),
+	KindInt32:   reflect.TypeOf(int32(0)),
+	KindInt64:   reflect.TypeOf(int64(0)),
+	KindUint8:   reflect.TypeOf(uint8(0)),
+	KindUint16:  reflect.TypeOf(uint16(0)),
+	KindUint32:  reflect.TypeOf(uint32(0)),
+	KindUint64:  reflect.TypeOf(uint64(0)),
+	KindFloat32: reflect.TypeOf(float32(0)),
+	KindFloat64: reflect.TypeOf(float64(0)),
+}
+
+// SchemaField describes one field of a flattened record. Fields of
+// nested structs are named with dotted paths.
+type SchemaField struct {
+	Name  string
+	Kind  FieldKind
+	Count int // 0 for a scalar, the length of an array otherwise
+
+	// Enum names values of an integer field, see SetEnum
+	Enum map[string]int64
+}
+
+func (f SchemaField) size() int {
+	if f.Count == 0 {
+		return kindSizes[f.Kind]
+	}
+	return kindSizes[f.Kind] * f.Count
+}
+
+// Schema describes the layout of a record, in the order WriteData lays
+// out its fields.
+type Schema struct {
+	Fields []SchemaField
+}
+
+// SchemaOf returns the schema of the fixed size struct v.
+func SchemaOf(v interface{}) (*Schema, error) {
+	t := reflect.Indirect(reflect.ValueOf(v)).Type()
+	if t.Kind() != reflect.Struct {
+		return nil, ErrInvalidType
+	}
+	s := &Schema{}
+	if err := s.add("", t); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) add(prefix string, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := prefix + f.Name
+		if f.Name == "_" {
+			n := binary.Size(reflect.Zero(f.Type).Interface())
+			if n < 0 {
+				return ErrInvalidType
+			}
+			s.Fields = append(s.Fields, SchemaField{Name: name, Kind: KindPad, Count: n})
+			continue
+		}
+
+		ft, count := f.Type, 0
+		if ft.Kind() == reflect.Array {
+			ft, count = ft.Elem(), ft.Len()
+		}
+		if ft.Kind() == reflect.Struct && count == 0 {
+			if err := s.add(name+".", ft); err != nil {
+				return err
+			}
+			continue
+		}
+		kind, ok := schemaKind(ft.Kind())
+		if !ok {
+			return ErrInvalidType
+		}
+		s.Fields = append(s.Fields, SchemaField{Name: name, Kind: kind, Count: count})
+	}
+	return nil
+}
+
+func schemaKind(k reflect.Kind) (FieldKind, bool) {
+	switch k {
+	case reflect.Bool:
+		return KindBool, true
+	case reflect.Int8:
+		return KindInt8, true
+	case reflect.Int16:
+		return KindInt16, true
+	case reflect.Int32:
+		return KindInt32, true
+	case reflect.Int64:
+		return KindInt64, true
+	case reflect.Uint8:
+		return KindUint8, true
+	case reflect.Uint16:
+		return KindUint16, true
+	case reflect.Uint32:
+		return KindUint32, true
+	case reflect.Uint64:
+		return KindUint64, true
+	case reflect.Float32:
+		return KindFloat32, true
+	case reflect.Float64:
+		return KindFloat64, true
+	}
+	return 0, false
+}
+
+// SetEnum registers symbolic names for the values of an integer field.
+// Documents render the names and WriteDoc accepts them in place of
+// numbers. The names are stored in the document header.
+func (s *Schema) SetEnum(field string, names map[string]int64) error {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if f.Name != field || f.Kind == KindPad {
+			continue
+		}
+		if f.Kind < KindInt8 || f.Kind > KindUint64 {
+			return errors.New("enum field " + field + " is not an integer")
+		}
+		if len(names) > math.MaxUint16 {
+			return ErrInvalidType
+		}
+		if !uniqueValues(names) {
+			return errors.New("enum field " + field + " has two names for one value")
+		}
+		f.Enum = names
+		return nil
+	}
+	return errors.New("schema has no field " + field)
+}
+
+// uniqueValues reports whether no two names share a value, so that
+// every value has a single symbol
+func uniqueValues(names map[string]int64) bool {
+	seen := make(map[int64]bool, len(names))
+	for _, v := range names {
+		if seen[v] {
+			return false
+		}
+		seen[v] = true
+	}
+	return true
+}
+
+// symbol returns the name of value in f's enum
+func (f SchemaField) symbol(value interface{}) (string, bool) {
+	n, ok := enumValue(reflect.ValueOf(value))
+	if !ok {
+		return "", false
+	}
+	for name, v := range f.Enum {
+		if v == n {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func enumValue(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// Size returns the encoded size of a record.
+func (s *Schema) Size() int {
+	n := 0
+	for _, f := range s.Fields {
+		n += f.size()
+	}
+	return n
+}
+
+// Document is a record decoded through the schema stored with it,
+// without the Go type it was written from. It marshals naturally with
+// encoding/json or encoding/gob.
+type Document struct {
+	Schema    *Schema
+	BigEndian bool
+	Fields    []DocumentField
+}
+
+// DocumentField holds the value of a field. Scalars have the Go type
+// matching their kind, arrays are slices of it. Padding is omitted.
+type DocumentField struct {
+	Name  string
+	Value interface{}
+}
+
+// Get returns the value of the named field.
+func (d *Document) Get(name string) (interface{}, bool) {
+	for _, f := range d.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Symbol returns the enum name of the value of a scalar field.
+func (d *Document) Symbol(name string) (string, bool) {
+	v, ok := d.Get(name)
+	if !ok || d.Schema == nil {
+		return "", false
+	}
+	for _, f := range d.Schema.Fields {
+		if f.Name == name && f.Count == 0 {
+			return f.symbol(v)
+		}
+	}
+	return "", false
+}
+
+// String renders the document one field per line, showing enum names
+// next to the values they stand for.
+func (d *Document) String() string {
+	var buf bytes.Buffer
+	for _, f := range d.Fields {
+		fmt.Fprintf(&buf, "%s: %v", f.Name, f.Value)
+		if sym, ok := d.Symbol(f.Name); ok {
+			fmt.Fprintf(&buf, " (%s)", sym)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+func (d *Document) order() binary.ByteOrder {
+	if d.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// A document is the encoded schema followed by the record. The schema
+// is always little endian:
+//
+//	[magic "BIOD"][order 'L' or 'B'][fields uint16]
+//	fields * [kind uint8][count uint32][name length uint8][name]
+//		[enum names uint16]
+//		enum names * [value int64][name length uint8][name]
+//	[record size uint32][record]
+var documentMagic = []byte("BIOD")
+
+func (s *Schema) encode(w *bytes.Buffer, order binary.ByteOrder) error {
+	if len(s.Fields) > math.MaxUint16 {
+		return ErrInvalidType
+	}
+	w.Write(documentMagic)
+	if order == binary.BigEndian {
+		w.WriteByte('B')
+	} else {
+		w.WriteByte('L')
+	}
+	binary.Write(w, binary.LittleEndian, uint16(len(s.Fields)))
+	for _, f := range s.Fields {
+		if len(f.Name) > math.MaxUint8 || f.Kind >= numKinds {
+			return ErrInvalidType
+		}
+		w.WriteByte(byte(f.Kind))
+		binary.Write(w, binary.LittleEndian, uint32(f.Count))
+		w.WriteByte(byte(len(f.Name)))
+		w.WriteString(f.Name)
+
+		names := make([]string, 0, len(f.Enum))
+		for name := range f.Enum {
+			if len(name) > math.MaxUint8 {
+				return ErrInvalidType
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		binary.Write(w, binary.LittleEndian, uint16(len(names)))
+		for _, name := range names {
+			binary.Write(w, binary.LittleEndian, f.Enum[name])
+			w.WriteByte(byte(len(name)))
+			w.WriteString(name)
+		}
+	}
+	binary.Write(w, binary.LittleEndian, uint32(s.Size()))
+	return nil
+}
+
+func decodeSchema(r *bytes.Reader) (*Schema, binary.ByteOrder, error) {
+	var h struct {
+		Magic  [4]byte
+		Order  byte
+		Fields uint16
+	}
+	if binary.Read(r, binary.LittleEndian, &h) != nil ||
+		!bytes.Equal(h.Magic[:], documentMagic) {
+		return nil, nil, ErrNoDocument
+	}
+	var order binary.ByteOrder
+	switch h.Order {
+	case 'L':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, nil, ErrCorrupt
+	}
+
+	s := &Schema{Fields: make([]SchemaField, h.Fields)}
+	for i := range s.Fields {
+		var fh struct {
+			Kind    FieldKind
+			Count   uint32
+			NameLen uint8
+		}
+		if binary.Read(r, binary.LittleEndian, &fh) != nil || fh.Kind >= numKinds {
+			return nil, nil, ErrCorrupt
+		}
+		name, err := readName(r, fh.NameLen)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.Fields[i] = SchemaField{Name: name, Kind: fh.Kind, Count: int(fh.Count)}
+
+		var names uint16
+		if binary.Read(r, binary.LittleEndian, &names) != nil {
+			return nil, nil, ErrCorrupt
+		}
+		if names > 0 {
+			s.Fields[i].Enum = make(map[string]int64, names)
+		}
+		for ; names > 0; names-- {
+			var eh struct {
+				Value   int64
+				NameLen uint8
+			}
+			if binary.Read(r, binary.LittleEndian, &eh) != nil {
+				return nil, nil, ErrCorrupt
+			}
+			name, err := readName(r, eh.NameLen)
+			if err != nil {
+				return nil, nil, err
+			}
+			s.Fields[i].Enum[name] = eh.Value
+		}
+		if !uniqueValues(s.Fields[i].Enum) {
+			return nil, nil, ErrCorrupt
+		}
+	}
+
+	var size uint32
+	if binary.Read(r, binary.LittleEndian, &size) != nil || int(size) != s.Size() ||
+		int(size) > r.Len() {
+		return nil, nil, ErrCorrupt
+	}
+	return s, order, nil
+}
+
+func readName(r *bytes.Reader, n uint8) (string, error) {
+	name := make([]byte, n)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return "", ErrCorrupt
+	}
+	return string(name), nil
+}
+
+// WriteDocument writes the schema of v followed by v itself, encoded as
+// WriteData would, at the current offset.
+func (b *BufferIO) WriteDocument(order binary.ByteOrder, v interface{}) error {
+	s, err := SchemaOf(v)
+	if err != nil {
+		return err
+	}
+	return b.WriteDocumentSchema(order, s, v)
+}
+
+// WriteDocumentSchema is like WriteDocument but stores s, which must
+// describe the layout of v, so enum names registered on it are kept.
+func (b *BufferIO) WriteDocumentSchema(order binary.ByteOrder, s *Schema, v interface{}) error {
+	layout, err := SchemaOf(v)
+	if err != nil {
+		return err
+	}
+	if len(layout.Fields) != len(s.Fields) {
+		return errors.New("schema does not describe the value")
+	}
+	for i, f := range layout.Fields {
+		g := s.Fields[i]
+		if f.Name != g.Name || f.Kind != g.Kind || f.Count != g.Count {
+			return errors.New("schema does not describe the value")
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := s.encode(buf, order); err != nil {
+		return err
+	}
+	if err := binary.Write(buf, order, v); err != nil {
+		return err
+	}
+	return b.writeDocument(buf.Bytes())
+}
+
+// WriteDoc writes a document built by hand or decoded earlier, encoding
+// its values through its own schema. Numeric values are converted to
+// the kind of their field, enum fields accept their names, and byte
+// arrays may be given as base64 strings, the form encoding/json
+// produces.
+func (b *BufferIO) WriteDoc(d *Document) error {
+	buf := new(bytes.Buffer)
+	order := d.order()
+	if err := d.Schema.encode(buf, order); err != nil {
+		return err
+	}
+	for _, f := range d.Schema.Fields {
+		if f.Kind == KindPad {
+			buf.Write(make([]byte, f.Count))
+			continue
+		}
+		v, ok := d.Get(f.Name)
+		if !ok {
+			return errors.New("document has no field " + f.Name)
+		}
+		if err := encodeField(buf, order, f, v); err != nil {
+			return err
+		}
+	}
+	return b.writeDocument(buf.Bytes())
+}
+
+func (b *BufferIO) writeDocument(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	r, err := b.slice(b.off, int64(len(p)))
+	if err != nil {
+		return err
+	}
+	copy(r, p)
+	b.off += int64(len(p))
+	return nil
+}
+
+func encodeField(w *bytes.Buffer, order binary.ByteOrder, f SchemaField, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if s, ok := v.(string); ok && f.Kind == KindUint8 && f.Count > 0 {
+		p, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		rv = reflect.ValueOf(p)
+	}
+	out := newFieldValue(f)
+	if err := convertValue(out, rv, f.Enum); err == ErrRange {
+		return err
+	} else if err != nil {
+		return errors.New("invalid value for field " + f.Name)
+	}
+	return binary.Write(w, order, out.Interface())
+}
+
+// newFieldValue returns a settable zero value for f: a slice of Count
+// elements for arrays
+func newFieldValue(f SchemaField) reflect.Value {
+	t := kindTypes[f.Kind]
+	if f.Count > 0 {
+		return reflect.MakeSlice(reflect.SliceOf(t), f.Count, f.Count)
+	}
+	return reflect.New(t).Elem()
+}
+
+// convertValue stores v in out, converting between numeric kinds and
+// from slices or arrays of the right length. Strings are looked up in
+// enum. Numbers which out cannot hold exactly fail with ErrRange.
+func convertValue(out, v reflect.Value, enum map[string]int64) error {
+	if !v.IsValid() {
+		return ErrInvalidType
+	}
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String && out.Kind() != reflect.Slice {
+		n, ok := enum[v.String()]
+		if !ok {
+			return ErrInvalidType
+		}
+		v = reflect.ValueOf(n)
+	}
+
+	switch out.Kind() {
+	case reflect.Slice:
+		if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() != out.Len() {
+			return ErrInvalidType
+		}
+		for i := 0; i < out.Len(); i++ {
+			if err := convertValue(out.Index(i), v.Index(i), enum); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Bool:
+		if v.Kind() != reflect.Bool {
+			return ErrInvalidType
+		}
+		out.SetBool(v.Bool())
+		return nil
+	}
+
+	if !fits(out, v) {
+		return ErrRange
+	}
+	out.Set(v.Convert(out.Type()))
+	return nil
+}
+
+// fits reports whether the number v converts to the numeric out without
+// wrapping or truncation. Number kinds fit floats unless they overflow
+// a float32.
+func fits(out, v reflect.Value) bool {
+	var n int64
+	var u uint64
+	var signed bool
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, signed = v.Int(), true
+		u = uint64(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u = v.Uint()
+		n = int64(u)
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch out.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return !out.OverflowFloat(f)
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= 1<<64 {
+			return false
+		}
+		if f < 0 {
+			n, signed = int64(f), true
+			u = uint64(n)
+		} else {
+			u = uint64(f)
+			n = int64(u)
+		}
+	default:
+		return false
+	}
+
+	switch out.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return (signed || u <= math.MaxInt64) && !out.OverflowInt(n)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return (!signed || n >= 0) && !out.OverflowUint(u)
+	case reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// ReadDocument decodes the document at the current offset and moves the
+// offset past it.
+func (b *BufferIO) ReadDocument() (*Document, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+
+	if err := b.memory(); err != nil {
+		return nil, err
+	}
+	if b.off >= b.size() {
+		return nil, ErrNoDocument
+	}
+	r := bytes.NewReader(b.buf[b.off:])
+	s, order, err := decodeSchema(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Document{Schema: s, BigEndian: order == binary.BigEndian}
+	for _, f := range s.Fields {
+		v := newFieldValue(f)
+		p := v.Interface()
+		if f.Count == 0 {
+			p = v.Addr().Interface()
+		}
+		if err := binary.Read(r, order, p); err != nil {
+			return nil, ErrCorrupt
+		}
+		if f.Kind == KindPad {
+			continue
+		}
+		d.Fields = append(d.Fields, DocumentField{Name: f.Name, Value: v.Interface()})
+	}
+
+	b.off = b.size() - int64(r.Len())
+	return d, nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type docHeader struct {
+	Magic   [4]byte
+	Version uint16
+	_       [2]byte
+	Flags   struct {
+		Dirty bool
+		Level int8
+	}
+	Sizes [2]uint32
+	Ratio float64
+}
+
+func testDocHeader() *docHeader {
+	h := &docHeader{Magic: [4]byte{'D', 'O', 'C', '1'}, Version: 7,
+		Sizes: [2]uint32{100, 200}, Ratio: 0.5}
+	h.Flags.Dirty = true
+	h.Flags.Level = -3
+	return h
+}
+
+func TestSchemaOf(t *testing.T) {
+	s, err := SchemaOf(testDocHeader())
+	assert(t, err == nil)
+	assert(t, reflect.DeepEqual(s.Fields, []SchemaField{
+		{"Magic", KindUint8, 4, nil},
+		{"Version", KindUint16, 0, nil},
+		{"_", KindPad, 2, nil},
+		{"Flags.Dirty", KindBool, 0, nil},
+		{"Flags.Level", KindInt8, 0, nil},
+		{"Sizes", KindUint32, 2, nil},
+		{"Ratio", KindFloat64, 0, nil},
+	}))
+	assert(t, s.Size() == binary.Size(testDocHeader()))
+
+	_, err = SchemaOf(1)
+	assert(t, err == ErrInvalidType)
+	_, err = SchemaOf(struct{ S string }{})
+	assert(t, err == ErrInvalidType)
+	_, err = SchemaOf(struct{ A [2][2]byte }{})
+	assert(t, err == ErrInvalidType)
+}
+
+func TestDocument(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		b := NewBufferIOMake(256)
+		assert(t, b.WriteDocument(order, testDocHeader()) == nil)
+		end, _ := b.Seek(0, 1)
+
+		// The record is laid out as WriteData would
+		var want bytes.Buffer
+		binary.Write(&want, order, testDocHeader())
+		assert(t, bytes.Equal(b.Bytes()[end-int64(want.Len()):end], want.Bytes()))
+
+		b.Seek(0, 0)
+		d, err := b.ReadDocument()
+		assert(t, err == nil)
+		pos, _ := b.Seek(0, 1)
+		assert(t, pos == end)
+		assert(t, d.BigEndian == (order == binary.BigEndian))
+		assert(t, len(d.Fields) == 6)
+
+		v, ok := d.Get("Magic")
+		assert(t, ok)
+		assert(t, bytes.Equal(v.([]uint8), []byte("DOC1")))
+		v, _ = d.Get("Version")
+		assert(t, v.(uint16) == 7)
+		v, _ = d.Get("Flags.Level")
+		assert(t, v.(int8) == -3)
+		v, _ = d.Get("Sizes")
+		assert(t, reflect.DeepEqual(v, []uint32{100, 200}))
+		_, ok = d.Get("_")
+		assert(t, !ok)
+	}
+}
+
+func TestDocumentMarshal(t *testing.T) {
+	b := NewBufferIOMake(256)
+	assert(t, b.WriteDocument(binary.BigEndian, testDocHeader()) == nil)
+	b.Seek(0, 0)
+	d, err := b.ReadDocument()
+	assert(t, err == nil)
+
+	text, err := json.Marshal(d)
+	assert(t, err == nil)
+	var back Document
+	assert(t, json.Unmarshal(text, &back) == nil)
+
+	// Writing the document back reproduces the original bytes
+	c := NewBufferIOMake(256)
+	assert(t, c.WriteDoc(&back) == nil)
+	assert(t, bytes.Equal(b.Bytes(), c.Bytes()))
+
+	var raw bytes.Buffer
+	assert(t, gob.NewEncoder(&raw).Encode(d) == nil)
+	var fromGob Document
+	assert(t, gob.NewDecoder(&raw).Decode(&fromGob) == nil)
+	assert(t, reflect.DeepEqual(&fromGob, d))
+
+	missing := back
+	missing.Fields = missing.Fields[1:]
+	assert(t, c.WriteDoc(&missing) != nil)
+}
+
+func TestDocumentErrors(t *testing.T) {
+	b := NewBufferIOMake(256)
+	_, err := b.ReadDocument()
+	assert(t, err == ErrNoDocument)
+
+	assert(t, b.WriteDocument(binary.LittleEndian, testDocHeader()) == nil)
+	end, _ := b.Seek(0, 1)
+
+	// Truncated records are detected
+	c := NewBufferIO(append([]byte(nil), b.Bytes()[:end-1]...))
+	_, err = c.ReadDocument()
+	assert(t, err == ErrCorrupt)
+
+	small := NewBufferIOMake(int(end) - 1)
+	assert(t, small.WriteDocument(binary.LittleEndian, testDocHeader()) == ErrOverrun)
+	pos, _ := small.Seek(0, 1)
+	assert(t, pos == 0)
+}
+
+func TestDocumentEnum(t *testing.T) {
+	s, _ := SchemaOf(testDocHeader())
+	assert(t, s.SetEnum("Version", map[string]int64{"V6": 6, "V7": 7}) == nil)
+	assert(t, s.SetEnum("Flags.Level", map[string]int64{"ERROR": -3}) == nil)
+	assert(t, s.SetEnum("Ratio", map[string]int64{"HALF": 1}) != nil)
+	assert(t, s.SetEnum("Missing", nil) != nil)
+	assert(t, s.SetEnum("Version", map[string]int64{"A": 1, "B": 1}) != nil)
+	sym, _ := s.Fields[1].symbol(uint16(7))
+	assert(t, sym == "V7")
+
+	b := NewBufferIOMake(256)
+	assert(t, b.WriteDocumentSchema(binary.LittleEndian, s, testDocHeader()) == nil)
+	b.Seek(0, 0)
+	d, err := b.ReadDocument()
+	assert(t, err == nil)
+
+	sym, ok := d.Symbol("Version")
+	assert(t, ok && sym == "V7")
+	sym, ok = d.Symbol("Flags.Level")
+	assert(t, ok && sym == "ERROR")
+	_, ok = d.Symbol("Ratio")
+	assert(t, !ok)
+	assert(t, d.String() == "Magic: [68 79 67 49]\nVersion: 7 (V7)\n"+
+		"Flags.Dirty: true\nFlags.Level: -3 (ERROR)\nSizes: [100 200]\nRatio: 0.5\n")
+
+	// Names are accepted when encoding
+	d.Fields[1].Value = "V6"
+	c := NewBufferIOMake(256)
+	assert(t, c.WriteDoc(d) == nil)
+	c.Seek(0, 0)
+	back, err := c.ReadDocument()
+	assert(t, err == nil)
+	v, _ := back.Get("Version")
+	assert(t, v.(uint16) == 6)
+
+	d.Fields[1].Value = "V8"
+	assert(t, c.WriteDoc(d) != nil)
+
+	// The schema must describe the value
+	assert(t, b.WriteDocumentSchema(binary.LittleEndian, s, &struct{ A uint8 }{}) != nil)
+}
+
+func TestDocumentRange(t *testing.T) {
+	b := NewBufferIOMake(256)
+	assert(t, b.WriteDocument(binary.LittleEndian, testDocHeader()) == nil)
+	b.Seek(0, 0)
+	d, err := b.ReadDocument()
+	assert(t, err == nil)
+
+	write := func(field int, v interface{}) error {
+		old := d.Fields[field].Value
+		d.Fields[field].Value = v
+		defer func() { d.Fields[field].Value = old }()
+		return NewBufferIOMake(256).WriteDoc(d)
+	}
+	// Version is a uint16, Flags.Level an int8 and Sizes a [2]uint32
+	assert(t, write(1, 65535) == nil)
+	assert(t, write(1, float64(300)) == nil)
+	assert(t, write(1, 65536) == ErrRange)
+	assert(t, write(1, -1) == ErrRange)
+	assert(t, write(1, 1.5) == ErrRange)
+	assert(t, write(3, int64(-128)) == nil)
+	assert(t, write(3, uint8(128)) == ErrRange)
+	assert(t, write(3, float64(-129)) == ErrRange)
+	assert(t, write(4, []interface{}{1, uint64(1) << 32}) == ErrRange)
+	assert(t, write(4, []interface{}{1, 2}) == nil)
+	assert(t, write(5, float32(1.5)) == nil)
+	assert(t, write(1, "seven") != nil)
+
+	// Documents naming one value twice are corrupt
+	s, _ := SchemaOf(testDocHeader())
+	s.Fields[1].Enum = map[string]int64{"A": 1, "B": 1}
+	c := NewBufferIOMake(256)
+	assert(t, c.WriteDocumentSchema(binary.LittleEndian, s, testDocHeader()) == nil)
+	c.Seek(0, 0)
+	_, err = c.ReadDocument()
+	assert(t, err == ErrCorrupt)
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var ErrSealed = errors.New("sealed data is too short")
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts and authenticates the buffer contents with AES-GCM under
+// a 16, 24 or 32 byte key. aad is authenticated but not encrypted. The
+// result holds a random nonce followed by the ciphertext.
+func (b *BufferIO) Seal(key, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return b.SealAEAD(aead, aad)
+}
+
+// SealAEAD is like Seal with any AEAD, such as ChaCha20-Poly1305.
+func (b *BufferIO) SealAEAD(aead cipher.AEAD, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.memory(); err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, len(nonce), len(nonce)+len(b.buf)+aead.Overhead())
+	copy(sealed, nonce)
+	return aead.Seal(sealed, nonce, b.buf, aad), nil
+}
+
+// Open verifies and decrypts data produced by Seal into a new buffer.
+func Open(key, aad, sealed []byte) (*BufferIO, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return OpenAEAD(aead, aad, sealed)
+}
+
+// OpenAEAD is like Open with any AEAD.
+func OpenAEAD(aead cipher.AEAD, aad, sealed []byte) (*BufferIO, error) {
+	n := aead.NonceSize()
+	if len(sealed) < n+aead.Overhead() {
+		return nil, ErrSealed
+	}
+	plain, err := aead.Open(nil, sealed[:n], sealed[n:], aad)
+	if err != nil {
+		return nil, ErrAuth
+	}
+	return NewBufferIO(plain), nil
+}
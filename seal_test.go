@@ -0,0 +1,56 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"testing"
+)
+
+var sealKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestSealOpen(t *testing.T) {
+	bio := NewBufferIO(big)
+	aad := []byte("v1")
+
+	sealed, err := bio.Seal(sealKey, aad)
+	assert(t, err == nil)
+	assert(t, !bytes.Contains(sealed, big[:16]))
+
+	opened, err := Open(sealKey, aad, sealed)
+	assert(t, err == nil)
+	assert(t, bytes.Equal(opened.Bytes(), big))
+
+	// Nonces are not reused
+	again, _ := bio.Seal(sealKey, aad)
+	assert(t, !bytes.Equal(again, sealed))
+
+	_, err = Open(sealKey, []byte("v2"), sealed)
+	assert(t, err == ErrAuth)
+
+	_, err = Open(sealKey[:16], aad, sealed)
+	assert(t, err == ErrAuth)
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = Open(sealKey, aad, sealed)
+	assert(t, err == ErrAuth)
+
+	_, err = Open(sealKey, aad, sealed[:10])
+	assert(t, err == ErrSealed)
+
+	_, err = bio.Seal([]byte("short"), aad)
+	assert(t, err != nil)
+	_, err = Open([]byte("short"), aad, sealed)
+	assert(t, err != nil)
+}
+
+func TestSealEmpty(t *testing.T) {
+	sealed, err := NewBufferIOMake(0).Seal(sealKey, nil)
+	assert(t, err == nil)
+
+	opened, err := Open(sealKey, nil, sealed)
+	assert(t, err == nil)
+	assert(t, opened.Size() == 0)
+}
@@ -0,0 +1,284 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// A seekable snapshot compresses the buffer in independent frames of a
+// fixed uncompressed size, so any range can be read by decompressing
+// just the frames covering it. It is laid out in little endian as
+//
+//	["BIOK"][name length uint8][name][frame size uint32][size uint64]
+//	frames
+//	frames * [frame offset uint64][frame length uint32]
+//	[index offset uint64]["BIOK"]
+//
+// where frame offsets count from the start of the snapshot.
+var seekableMagic = []byte("BIOK")
+
+const (
+	seekableEntry   = 12
+	seekableTrailer = 12
+)
+
+// countingWriter tracks how much has been written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ExportSeekable writes the buffer to w as a seekable snapshot with
+// frames of frameSize uncompressed bytes.
+func (b *BufferIO) ExportSeekable(w io.Writer, c Codec, frameSize int) error {
+	name := c.Name()
+	if len(name) > 255 {
+		return errors.New("codec name too long")
+	}
+	if frameSize <= 0 || int64(frameSize) > int64(^uint32(0)) {
+		return errors.New("invalid frame size")
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.usable(); err != nil {
+		return err
+	}
+
+	cw := &countingWriter{w: w}
+	header := make([]byte, 0, len(seekableMagic)+1+len(name)+12)
+	header = append(header, seekableMagic...)
+	header = append(header, byte(len(name)))
+	header = append(header, name...)
+	var sizes [12]byte
+	binary.LittleEndian.PutUint32(sizes[:], uint32(frameSize))
+	binary.LittleEndian.PutUint64(sizes[4:], uint64(b.length()))
+	header = append(header, sizes[:]...)
+	if _, err := cw.Write(header); err != nil {
+		return err
+	}
+
+	var index []byte
+	var frame []byte
+	if b.from != nil {
+		frame = make([]byte, frameSize)
+	}
+	for off := int64(0); off < b.length(); off += int64(frameSize) {
+		end := off + int64(frameSize)
+		if end > b.length() {
+			end = b.length()
+		}
+		var data []byte
+		if b.from == nil {
+			data = b.buf[off:end]
+		} else {
+			data = frame[:end-off]
+			if err := b.readWhole(data, off, true); err != nil {
+				return err
+			}
+		}
+		start := cw.n
+		zw, err := c.Compress(cw)
+		if err != nil {
+			return err
+		}
+		if _, err := zw.Write(data); err != nil {
+			zw.Close()
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		if cw.n-start > int64(^uint32(0)) {
+			return errors.New("compressed frame too large")
+		}
+		var entry [seekableEntry]byte
+		binary.LittleEndian.PutUint64(entry[:], uint64(start))
+		binary.LittleEndian.PutUint32(entry[8:], uint32(cw.n-start))
+		index = append(index, entry[:]...)
+	}
+
+	var trailer [seekableTrailer]byte
+	binary.LittleEndian.PutUint64(trailer[:], uint64(cw.n))
+	copy(trailer[8:], seekableMagic)
+	if _, err := cw.Write(index); err != nil {
+		return err
+	}
+	_, err := cw.Write(trailer[:])
+	return err
+}
+
+// SeekableReader reads a seekable snapshot at random, decompressing only
+// the frames needed. The most recently used frame is cached.
+type SeekableReader struct {
+	r         io.ReaderAt
+	c         Codec
+	frameSize int64
+	size      int64
+	frames    []seekableFrame
+
+	mu     sync.Mutex
+	cached int
+	frame  []byte
+}
+
+type seekableFrame struct {
+	off int64
+	n   int64
+}
+
+// NewSeekableReader opens the size byte seekable snapshot in r. A nil c
+// selects the registered codec named in the snapshot.
+func NewSeekableReader(r io.ReaderAt, size int64, c Codec) (*SeekableReader, error) {
+	var magic [5]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil || string(magic[:4]) != string(seekableMagic) {
+		return nil, ErrBadSnapshot
+	}
+	rest := make([]byte, int(magic[4])+12)
+	if _, err := r.ReadAt(rest, 5); err != nil {
+		return nil, ErrBadSnapshot
+	}
+	c, err := resolveCodec(string(rest[:magic[4]]), c)
+	if err != nil {
+		return nil, err
+	}
+	s := &SeekableReader{
+		r:         r,
+		c:         c,
+		frameSize: int64(binary.LittleEndian.Uint32(rest[magic[4]:])),
+		size:      int64(binary.LittleEndian.Uint64(rest[magic[4]+4:])),
+		cached:    -1,
+	}
+
+	var trailer [seekableTrailer]byte
+	if size < seekableTrailer || s.frameSize == 0 || s.size < 0 {
+		return nil, ErrBadSnapshot
+	}
+	if _, err := r.ReadAt(trailer[:], size-seekableTrailer); err != nil ||
+		string(trailer[8:]) != string(seekableMagic) {
+		return nil, ErrBadSnapshot
+	}
+	indexOff := int64(binary.LittleEndian.Uint64(trailer[:]))
+	nframes := s.size / s.frameSize
+	if s.size%s.frameSize != 0 {
+		nframes++
+	}
+	// The header is untrusted, so the index must be known to fit in
+	// the snapshot before its size is computed and allocated
+	if indexOff < 0 || indexOff > size-seekableTrailer ||
+		nframes > (size-seekableTrailer-indexOff)/seekableEntry ||
+		indexOff+nframes*seekableEntry != size-seekableTrailer {
+		return nil, ErrBadSnapshot
+	}
+	index := make([]byte, nframes*seekableEntry)
+	if _, err := r.ReadAt(index, indexOff); err != nil {
+		return nil, ErrBadSnapshot
+	}
+	s.frames = make([]seekableFrame, nframes)
+	for i := range s.frames {
+		e := index[i*seekableEntry:]
+		f := seekableFrame{
+			off: int64(binary.LittleEndian.Uint64(e)),
+			n:   int64(binary.LittleEndian.Uint32(e[8:])),
+		}
+		if f.off < 0 || f.off+f.n > indexOff {
+			return nil, ErrBadSnapshot
+		}
+		s.frames[i] = f
+	}
+	return s, nil
+}
+
+// Size returns the uncompressed size of the snapshot.
+func (s *SeekableReader) Size() int64 {
+	return s.size
+}
+
+// load decompresses frame i into the cache
+func (s *SeekableReader) load(i int) error {
+	if s.cached == i {
+		return nil
+	}
+	want := s.frameSize
+	if rest := s.size - int64(i)*s.frameSize; rest < want {
+		want = rest
+	}
+	if int64(cap(s.frame)) < want {
+		s.frame = make([]byte, want)
+	}
+	s.frame = s.frame[:want]
+
+	f := s.frames[i]
+	zr, err := s.c.Decompress(io.NewSectionReader(s.r, f.off, f.n))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	if _, err := io.ReadFull(zr, s.frame); err != nil {
+		s.cached = -1
+		return ErrBadSnapshot
+	}
+	s.cached = i
+	return nil
+}
+
+func (s *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for n < len(p) && off < s.size {
+		i := int(off / s.frameSize)
+		if err := s.load(i); err != nil {
+			return n, err
+		}
+		c := copy(p[n:], s.frame[off-int64(i)*s.frameSize:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// ImportSeekable decompresses the whole size byte seekable snapshot in r
+// into the start of the buffer and returns its uncompressed size.
+func (b *BufferIO) ImportSeekable(r io.ReaderAt, size int64, c Codec) (int64, error) {
+	s, err := NewSeekableReader(r, size, c)
+	if err != nil {
+		return 0, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.memory(); err != nil {
+		return 0, err
+	}
+	if s.size > b.size() {
+		return 0, ErrOverrun
+	}
+	n, err := s.ReadAt(b.buf[:s.size], 0)
+	if err == io.EOF && int64(n) == s.size {
+		err = nil
+	}
+	return int64(n), err
+}
@@ -0,0 +1,80 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func seekableSnapshot(t *testing.T, content []byte, frameSize int) []byte {
+	var out bytes.Buffer
+	b := NewBufferIO(append([]byte(nil), content...))
+	assert(t, b.ExportSeekable(&out, Gzip{}, frameSize) == nil)
+	return out.Bytes()
+}
+
+func TestSeekable(t *testing.T) {
+	content := bytes.Repeat([]byte("seekable"), 1000)
+	snap := seekableSnapshot(t, content, 1000)
+	assert(t, len(snap) < len(content))
+
+	s, err := NewSeekableReader(bytes.NewReader(snap), int64(len(snap)), Gzip{})
+	assert(t, err == nil)
+	assert(t, s.Size() == int64(len(content)))
+
+	// Ranges within a frame and across frames
+	for _, r := range [][2]int{{0, 10}, {995, 10}, {2500, 3000}, {len(content) - 7, 7}} {
+		p := make([]byte, r[1])
+		n, err := s.ReadAt(p, int64(r[0]))
+		assert(t, err == nil)
+		assert(t, n == r[1])
+		assert(t, bytes.Equal(p, content[r[0]:r[0]+r[1]]))
+	}
+
+	p := make([]byte, 10)
+	n, err := s.ReadAt(p, int64(len(content))-4)
+	assert(t, n == 4 && err == io.EOF)
+
+	b := NewBufferIOMake(len(content) + 10)
+	n64, err := b.ImportSeekable(bytes.NewReader(snap), int64(len(snap)), Gzip{})
+	assert(t, err == nil)
+	assert(t, n64 == int64(len(content)))
+	assert(t, bytes.Equal(b.Bytes()[:len(content)], content))
+}
+
+func TestSeekableErrors(t *testing.T) {
+	snap := seekableSnapshot(t, big, 16)
+	r := bytes.NewReader(snap)
+
+	_, err := NewSeekableReader(r, int64(len(snap)), deflate{})
+	assert(t, err == ErrWrongCodec)
+	_, err = NewSeekableReader(r, int64(len(snap))-1, Gzip{})
+	assert(t, err == ErrBadSnapshot)
+	_, err = NewSeekableReader(bytes.NewReader(big), int64(len(big)), Gzip{})
+	assert(t, err == ErrBadSnapshot)
+
+	// A header claiming more frames than the index can hold
+	crafted := []byte("BIOK\x04gzip")
+	crafted = append(crafted, 1, 0, 0, 0)
+	crafted = append(crafted, 0, 0, 0, 0, 0, 0, 0, 0x40)
+	crafted = append(crafted, byte(len(crafted)), 0, 0, 0, 0, 0, 0, 0)
+	crafted = append(crafted, "BIOK"...)
+	_, err = NewSeekableReader(bytes.NewReader(crafted), int64(len(crafted)), nil)
+	assert(t, err == ErrBadSnapshot)
+
+	small := NewBufferIOMake(len(big) - 1)
+	_, err = small.ImportSeekable(r, int64(len(snap)), Gzip{})
+	assert(t, err == ErrOverrun)
+
+	// Empty buffers have no frames
+	empty := seekableSnapshot(t, nil, 16)
+	s, err := NewSeekableReader(bytes.NewReader(empty), int64(len(empty)), Gzip{})
+	assert(t, err == nil)
+	assert(t, s.Size() == 0)
+
+	assert(t, NewBufferIOMake(1).ExportSeekable(&bytes.Buffer{}, Gzip{}, 0) != nil)
+}
@@ -0,0 +1,39 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+// Semantics selects how a buffer treats reads, writes and seeks at and
+// beyond its end.
+type Semantics int
+
+const (
+	// SemanticsPOSIXFile behaves like an os.File, as documented on
+	// BufferIO: reads at the end return io.EOF, writes which do not fit
+	// write what they can and return io.ErrShortWrite, and seeking
+	// beyond the end is allowed.
+	SemanticsPOSIXFile Semantics = iota
+
+	// SemanticsStrict never lets an operation run past the end: writes
+	// which do not fit write nothing and return ErrOverrun, as do seeks
+	// beyond the end. Growable buffers still grow, but only for writes
+	// starting within them or at their end, never leaving a gap. Reads
+	// behave as for SemanticsPOSIXFile.
+	SemanticsStrict
+
+	// SemanticsLegacy keeps the behaviour of the package before it
+	// followed the standard library: short reads and writes return no
+	// error, writes at or past the end return ErrOverrun, and so do
+	// seeks to or past the end. Reads at the end return ErrEOF, which is
+	// io.EOF. Buffers backed by a ReaderAt ignore this setting.
+	SemanticsLegacy
+)
+
+// SetSemantics changes the semantics of b, for buffers not created
+// through NewBufferIOOptions.
+func (b *BufferIO) SetSemantics(s Semantics) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.semantics = s
+}
@@ -0,0 +1,67 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+	"testing"
+)
+
+func TestSemanticsStrict(t *testing.T) {
+	b, err := NewBufferIOOptions(4, Options{Semantics: SemanticsStrict})
+	assert(t, err == nil)
+
+	n, err := b.WriteAt([]byte("abcdef"), 1)
+	assert(t, n == 0 && err == ErrOverrun)
+	assert(t, b.Bytes()[1] == 0)
+	n, err = b.Write([]byte("abcd"))
+	assert(t, n == 4 && err == nil)
+	_, err = b.Write([]byte("e"))
+	assert(t, err == ErrOverrun)
+
+	_, err = b.Seek(5, io.SeekStart)
+	assert(t, err == ErrOverrun)
+	pos, err := b.Seek(0, io.SeekEnd)
+	assert(t, pos == 4 && err == nil)
+
+	p := make([]byte, 3)
+	n, err = b.ReadAt(p, 2)
+	assert(t, n == 2 && err == io.EOF)
+
+	// Growable buffers append but leave no gaps
+	g := NewBufferIOGrow(0)
+	g.SetSemantics(SemanticsStrict)
+	n, err = g.Write([]byte("abc"))
+	assert(t, n == 3 && err == nil)
+	_, err = g.WriteAt([]byte("x"), 4)
+	assert(t, err == ErrOverrun)
+	_, err = g.WriteAt([]byte("de"), 2)
+	assert(t, err == nil && string(g.Bytes()) == "abde")
+}
+
+func TestSemanticsLegacy(t *testing.T) {
+	b := NewBufferIO([]byte("abcd"))
+	b.SetSemantics(SemanticsLegacy)
+
+	n, err := b.WriteAt([]byte("xyz"), 2)
+	assert(t, n == 2 && err == nil)
+	_, err = b.WriteAt([]byte("x"), 4)
+	assert(t, err == ErrOverrun)
+
+	p := make([]byte, 3)
+	n, err = b.ReadAt(p, 2)
+	assert(t, n == 2 && err == nil)
+	_, err = b.ReadAt(p, 4)
+	assert(t, err == ErrEOF)
+
+	_, err = b.Seek(4, io.SeekStart)
+	assert(t, err == ErrOverrun)
+	pos, err := b.Seek(3, io.SeekStart)
+	assert(t, pos == 3 && err == nil)
+	n, err = b.Read(p)
+	assert(t, n == 1 && err == nil)
+	_, err = b.Read(p)
+	assert(t, err == ErrEOF)
+}
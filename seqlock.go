@@ -0,0 +1,133 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+var ErrWriterGone = errors.New("seqlock write in progress for too long")
+
+const seqLockWord = 8
+
+// seqLockPatience is how long Load waits for a write in progress before
+// deciding the writer is gone, such as a process which crashed halfway
+var seqLockPatience = time.Second
+
+// SeqLock guards a small record in a buffer with a sequence counter, so
+// any number of goroutines can read it without locking while a single
+// writer updates it. The region is laid out as a uint64 sequence in the
+// byte order of the host, followed by the bytes of the record as they
+// are; the sequence is odd while a write is in progress. Every word is
+// accessed atomically as a native uint64, so the region must be 8 byte
+// aligned and the record a multiple of 8 bytes.
+type SeqLock struct {
+	seq   *uint64
+	words []byte
+}
+
+// NewSeqLock returns a SeqLock over the 8+n bytes at off. The record is
+// left as it is, so a SeqLock can be attached to a shared buffer written
+// by another process.
+func NewSeqLock(b *BufferIO, off int64, n int) (*SeqLock, error) {
+	if n <= 0 || n%seqLockWord != 0 {
+		return nil, errors.New("record size is not a multiple of 8")
+	}
+	if err := b.RequireAlignment(off, seqLockWord); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, err := b.slice(off, seqLockWord+int64(n))
+	if err != nil {
+		return nil, err
+	}
+	return &SeqLock{
+		seq:   (*uint64)(unsafe.Pointer(&r[0])),
+		words: r[seqLockWord:],
+	}, nil
+}
+
+func (s *SeqLock) word(i int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&s.words[i]))
+}
+
+// wordBytes returns the memory of v, so records move in and out of the
+// words without any byte swapping
+func wordBytes(v *uint64) []byte {
+	return (*[seqLockWord]byte)(unsafe.Pointer(v))[:]
+}
+
+// Size returns the size of the record.
+func (s *SeqLock) Size() int {
+	return len(s.words)
+}
+
+// Seq returns the current sequence, which grows by two with every write.
+func (s *SeqLock) Seq() uint64 {
+	return atomic.LoadUint64(s.seq)
+}
+
+// Load copies a consistent version of the record into p, retrying while
+// writes overlap it. It returns the sequence of the version read. A
+// sequence which stays odd, as left by a writer which died during a
+// write, fails with ErrWriterGone.
+func (s *SeqLock) Load(p []byte) (uint64, error) {
+	var deadline time.Time
+	for {
+		seq := atomic.LoadUint64(s.seq)
+		if seq&1 != 0 {
+			if deadline.IsZero() {
+				deadline = time.Now().Add(seqLockPatience)
+			} else if time.Now().After(deadline) {
+				return seq, ErrWriterGone
+			}
+			runtime.Gosched()
+			continue
+		}
+		for i := 0; i < len(s.words) && i < len(p); i += seqLockWord {
+			v := atomic.LoadUint64(s.word(i))
+			copy(p[i:], wordBytes(&v))
+		}
+		if atomic.LoadUint64(s.seq) == seq {
+			return seq, nil
+		}
+	}
+}
+
+// Store replaces the record with p, which is zero padded or truncated
+// to the record size. Only one goroutine may call Store or Update at a
+// time.
+func (s *SeqLock) Store(p []byte) error {
+	return s.Update(func(record []byte) {
+		n := copy(record, p)
+		zero(record[n:])
+	})
+}
+
+// Update calls fn with a copy of the record and publishes the result,
+// for read-modify-write updates such as bumping counters. It fails like
+// Load when a previous writer left a write unfinished.
+func (s *SeqLock) Update(fn func(record []byte)) error {
+	record := make([]byte, len(s.words))
+	if _, err := s.Load(record); err != nil {
+		return err
+	}
+	fn(record)
+
+	atomic.AddUint64(s.seq, 1)
+	for i := 0; i < len(s.words); i += seqLockWord {
+		var v uint64
+		copy(wordBytes(&v), record[i:])
+		atomic.StoreUint64(s.word(i), v)
+	}
+	atomic.AddUint64(s.seq, 1)
+	return nil
+}
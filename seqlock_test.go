@@ -0,0 +1,112 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func alignedOffset(b *BufferIO, align int) int64 {
+	return int64(-uintptr(unsafe.Pointer(&b.Bytes()[0])) & uintptr(align-1))
+}
+
+func TestSeqLock(t *testing.T) {
+	b := NewBufferIOMake(128)
+	off := alignedOffset(b, 8)
+	s, err := NewSeqLock(b, off, 16)
+	assert(t, err == nil)
+	assert(t, s.Size() == 16)
+	assert(t, s.Seq() == 0)
+
+	assert(t, s.Store([]byte("hello")) == nil)
+	p := make([]byte, 16)
+	seq, err := s.Load(p)
+	assert(t, seq == 2 && err == nil)
+	assert(t, string(p) == "hello\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+
+	// The record lives in the buffer byte for byte
+	assert(t, b.EqualAt(off+8, []byte("hello")))
+
+	assert(t, s.Update(func(r []byte) {
+		binary.LittleEndian.PutUint64(r[8:], 42)
+	}) == nil)
+	s.Load(p)
+	assert(t, string(p[:5]) == "hello")
+	assert(t, binary.LittleEndian.Uint64(p[8:]) == 42)
+	assert(t, s.Seq() == 4)
+
+	_, err = NewSeqLock(b, off, 12)
+	assert(t, err != nil)
+	_, err = NewSeqLock(b, off+4, 8)
+	_, ok := err.(*AlignmentError)
+	assert(t, ok)
+	_, err = NewSeqLock(b, off+112, 16)
+	assert(t, err == ErrOverrun)
+}
+
+func TestSeqLockConcurrent(t *testing.T) {
+	b := NewBufferIOMake(128)
+	s, _ := NewSeqLock(b, alignedOffset(b, 8), 32)
+
+	// Every word of a version holds the same counter
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 32)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := s.Load(p); err != nil {
+					t.Error(err)
+					return
+				}
+				v := binary.LittleEndian.Uint64(p)
+				for i := 8; i < 32; i += 8 {
+					if binary.LittleEndian.Uint64(p[i:]) != v {
+						t.Error("torn read")
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for n := uint64(1); n <= 2000; n++ {
+		s.Update(func(r []byte) {
+			for i := 0; i < 32; i += 8 {
+				binary.LittleEndian.PutUint64(r[i:], n)
+			}
+		})
+	}
+	close(done)
+	wg.Wait()
+	assert(t, s.Seq() == 4000)
+}
+
+func TestSeqLockWriterGone(t *testing.T) {
+	defer func(d time.Duration) { seqLockPatience = d }(seqLockPatience)
+	seqLockPatience = 10 * time.Millisecond
+
+	b := NewBufferIOMake(128)
+	off := alignedOffset(b, 8)
+	b.PutUint64LEAt(off, 7)
+	s, err := NewSeqLock(b, off, 8)
+	assert(t, err == nil)
+
+	_, err = s.Load(make([]byte, 8))
+	assert(t, err == ErrWriterGone)
+	assert(t, s.Store([]byte("x")) == ErrWriterGone)
+	assert(t, s.Seq() == 7)
+}
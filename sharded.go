@@ -0,0 +1,120 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardedBuffer spreads appends over several AppendLogs so concurrent
+// writers rarely touch the same memory. Records keep their order within
+// a shard but not across shards.
+type ShardedBuffer struct {
+	shards []*AppendLog
+	next   uint32
+	homes  sync.Pool // *int shard of the P taking it from the pool
+}
+
+// NewShardedBuffer returns a ShardedBuffer with shards logs of shardSize
+// bytes each. A shards of zero or less uses one per GOMAXPROCS.
+func NewShardedBuffer(shards, shardSize int) *ShardedBuffer {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	s := &ShardedBuffer{shards: make([]*AppendLog, shards)}
+	for i := range s.shards {
+		s.shards[i] = NewAppendLog(NewBufferIOMake(shardSize))
+	}
+	s.homes.New = func() interface{} {
+		home := int(atomic.AddUint32(&s.next, 1) % uint32(len(s.shards)))
+		return &home
+	}
+	return s
+}
+
+// Shards returns the number of shards.
+func (s *ShardedBuffer) Shards() int {
+	return len(s.shards)
+}
+
+// Append copies p into one of the shards, moving on to the others when
+// it is full. ErrOverrun is returned once no shard has room. Appends
+// made on the same P go to the same shard, so concurrent writers do not
+// share any memory until a shard fills.
+func (s *ShardedBuffer) Append(p []byte) error {
+	home := s.homes.Get().(*int)
+	i, err := s.appendFrom(*home, p)
+	*home = i
+	s.homes.Put(home)
+	return err
+}
+
+// AppendShard is Append starting from the shard hint, modulo the number
+// of shards, for callers which have their own notion of who is writing,
+// such as the index of a worker.
+func (s *ShardedBuffer) AppendShard(hint int, p []byte) error {
+	// take the modulo before dropping the sign, as negating the most
+	// negative int leaves it negative
+	shard := hint % len(s.shards)
+	if shard < 0 {
+		shard = -shard
+	}
+	_, err := s.appendFrom(shard, p)
+	return err
+}
+
+// appendFrom appends p to the first shard from start with room, and
+// returns that shard
+func (s *ShardedBuffer) appendFrom(start int, p []byte) (int, error) {
+	err := ErrOverrun
+	for i := 0; i < len(s.shards); i++ {
+		shard := (start + i) % len(s.shards)
+		_, err = s.shards[shard].Append(p)
+		if err != ErrOverrun {
+			return shard, err
+		}
+	}
+	return start, err
+}
+
+// Iterate calls fn with the committed records of every shard in turn
+// until fn returns false. Records share memory with the shards.
+func (s *ShardedBuffer) Iterate(fn func(shard int, record []byte) bool) {
+	for i, l := range s.shards {
+		more := true
+		l.Scan(0, func(off int64, record []byte) bool {
+			more = fn(i, record)
+			return more
+		})
+		if !more {
+			return
+		}
+	}
+}
+
+// Merge appends every committed record to dst with AppendRecord, so the
+// combined records can be read back with ScanRecords. It returns the
+// number of records merged before any error.
+func (s *ShardedBuffer) Merge(dst *BufferIO) (int, error) {
+	n := 0
+	var err error
+	s.Iterate(func(shard int, record []byte) bool {
+		if err = dst.AppendRecord(record); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	return n, err
+}
+
+// Reset empties every shard. It must not run concurrently with Append.
+func (s *ShardedBuffer) Reset() {
+	for i, l := range s.shards {
+		s.shards[i] = NewAppendLog(NewBufferIO(l.buf))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+)
+
+func TestShardedBuffer(t *testing.T) {
+	s := NewShardedBuffer(4, 1024)
+	assert(t, s.Shards() == 4)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				p := make([]byte, 4)
+				binary.LittleEndian.PutUint32(p, uint32(w*1000+i))
+				if err := s.Append(p); err != nil {
+					t.Error(err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	seen := make(map[uint32]bool)
+	s.Iterate(func(shard int, record []byte) bool {
+		seen[binary.LittleEndian.Uint32(record)] = true
+		return true
+	})
+	assert(t, len(seen) == 400)
+
+	dst := NewBufferIOMake(400 * 16)
+	n, err := s.Merge(dst)
+	assert(t, err == nil)
+	assert(t, n == 400)
+	count := 0
+	dst.ScanRecords(func(off int64, payload []byte) bool {
+		assert(t, seen[binary.LittleEndian.Uint32(payload)])
+		count++
+		return true
+	})
+	assert(t, count == 400)
+
+	// Stopping early
+	count = 0
+	s.Iterate(func(shard int, record []byte) bool {
+		count++
+		return count < 10
+	})
+	assert(t, count == 10)
+
+	s.Reset()
+	count = 0
+	s.Iterate(func(shard int, record []byte) bool {
+		count++
+		return true
+	})
+	assert(t, count == 0)
+}
+
+func TestShardedBufferFull(t *testing.T) {
+	s := NewShardedBuffer(2, 16)
+	p := make([]byte, 4)
+
+	// Each shard holds two 8 byte records
+	for i := 0; i < 4; i++ {
+		assert(t, s.Append(p) == nil)
+	}
+	assert(t, s.Append(p) == ErrOverrun)
+
+	dst := NewBufferIOMake(20)
+	n, err := s.Merge(dst)
+	assert(t, n == 1)
+	assert(t, err == ErrOverrun)
+
+	assert(t, NewShardedBuffer(0, 16).Shards() > 0)
+}
+
+func TestShardedBufferHint(t *testing.T) {
+	s := NewShardedBuffer(3, 16)
+	p := make([]byte, 4)
+
+	// Writers with their own shard stay in it until it fills, with each
+	// shard holding two records
+	assert(t, s.AppendShard(4, p) == nil)
+	assert(t, s.AppendShard(-1, p) == nil)
+	assert(t, s.AppendShard(1, p) == nil)
+	counts := make([]int, 3)
+	s.Iterate(func(shard int, record []byte) bool {
+		counts[shard]++
+		return true
+	})
+	assert(t, counts[0] == 0 && counts[1] == 2 && counts[2] == 1)
+
+	// The most negative hint picks a shard like any other
+	minInt := -int(^uint(0)>>1) - 1
+	assert(t, s.AppendShard(minInt, p) == nil)
+	counts = make([]int, 3)
+	s.Iterate(func(shard int, record []byte) bool {
+		counts[shard]++
+		return true
+	})
+	assert(t, counts[0] == 0 && counts[1] == 2 && counts[2] == 2)
+
+	for i := 0; i < 2; i++ {
+		assert(t, s.AppendShard(1, p) == nil)
+	}
+	assert(t, s.AppendShard(1, p) == ErrOverrun)
+	assert(t, s.Append(p) == ErrOverrun)
+}
@@ -0,0 +1,70 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// BytesCopy returns a copy of the contents of the buffer, which stays
+// valid whatever happens to the buffer afterwards.
+func (b *BufferIO) BytesCopy() []byte {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.bytesCopy()
+}
+
+func (b *BufferIO) bytesCopy() []byte {
+	if b.usable() != nil {
+		return nil
+	}
+	if b.from != nil {
+		p := make([]byte, b.fromSize)
+		n, _ := b.readAt(p, 0)
+		return p[:n]
+	}
+	if b.buf == nil {
+		return nil
+	}
+	return append([]byte{}, b.buf...)
+}
+
+// BytesUnsafe returns the memory of the buffer itself. Writes through
+// the slice change the buffer, and the slice is unprotected by the lock
+// of the buffer. It must not be used once the buffer is resized, grown
+// or closed, which may move or release the memory.
+//
+// In debug mode the call site is recorded, and moving or releasing the
+// memory afterwards is reported through LeakLog.
+func (b *BufferIO) BytesUnsafe() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.usable() != nil {
+		return nil
+	}
+	b.shared()
+	return b.buf
+}
+
+// shared records, in debug mode, the caller handing out memory of the
+// buffer which outlives the lock
+func (b *BufferIO) shared() {
+	if b.debug {
+		stack := make([]byte, 4096)
+		b.unsafeStack = stack[:runtime.Stack(stack, false)]
+	}
+}
+
+// moved records that buf changed, invalidating views and any slice from
+// BytesUnsafe.
+func (b *BufferIO) moved() {
+	atomic.AddUint32(&b.gen, 1)
+	if b.unsafeStack != nil {
+		LeakLog("bufferio: memory moved or released while a slice from BytesUnsafe may be in use, obtained at:\n%s",
+			b.unsafeStack)
+		b.unsafeStack = nil
+	}
+}
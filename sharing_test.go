@@ -0,0 +1,61 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestBytesCopy(t *testing.T) {
+	bio := NewBufferIO(append([]byte(nil), src...))
+	p := bio.BytesCopy()
+	assert(t, bytes.Equal(p, src))
+	p[0] ^= 0xff
+	assert(t, bio.Bytes()[0] == src[0])
+
+	q := bio.BytesUnsafe()
+	q[0] ^= 0xff
+	assert(t, bio.Bytes()[0] == src[0]^0xff)
+
+	empty := NewBufferIOMake(0)
+	assert(t, empty.BytesCopy() != nil)
+}
+
+func TestCopyBytesOption(t *testing.T) {
+	bio, err := NewBufferIOOptions(8, Options{CopyBytes: true})
+	assert(t, err == nil)
+	bio.Bytes()[0] = 1
+	assert(t, bio.BytesUnsafe()[0] == 0)
+	bio.BytesUnsafe()[0] = 2
+	assert(t, bio.Bytes()[0] == 2)
+}
+
+func TestBytesUnsafeDebug(t *testing.T) {
+	var logs []string
+	LeakLog = func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+	defer func() { LeakLog = log.Printf }()
+
+	bio, err := NewBufferIOOptions(8, Options{Debug: true})
+	assert(t, err == nil)
+	bio.BytesUnsafe()
+	assert(t, bio.Resize(16) == nil)
+	assert(t, len(logs) == 1)
+	assert(t, strings.Contains(logs[0], "TestBytesUnsafeDebug"))
+
+	// Reported once per call
+	assert(t, bio.Close() == nil)
+	assert(t, len(logs) == 1)
+
+	plain := NewBufferIOMake(8)
+	plain.BytesUnsafe()
+	assert(t, plain.Close() == nil)
+	assert(t, len(logs) == 1)
+}
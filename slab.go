@@ -0,0 +1,173 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var ErrNoSlab = errors.New("buffer does not hold a slab allocator")
+
+// A slab allocator keeps a little endian header at the start of the
+// buffer
+//
+//	[magic uint32][block size uint32][blocks uint32][used uint32]
+//
+// followed by a bitmap with a set bit for every allocated block, and
+// then the blocks themselves starting at the next multiple of 8.
+const (
+	slabMagic  = 0x42414c53
+	slabHeader = 16
+)
+
+// SlabAllocator hands out fixed size blocks of a buffer, tracking them
+// in a bitmap kept in the buffer. It is not safe for concurrent use.
+type SlabAllocator struct {
+	header []byte
+	bitmap []byte
+	size   int64
+	blocks int
+	data   int64
+	used   int
+	hint   int
+}
+
+// NewSlabAllocator formats b to hold as many blocks of blockSize bytes
+// as fit, all free.
+func NewSlabAllocator(b *BufferIO, blockSize int) (*SlabAllocator, error) {
+	if blockSize <= 0 {
+		return nil, errors.New("invalid block size")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	avail := int64(len(b.buf)) - slabHeader
+	n := avail * 8 / (8*int64(blockSize) + 1)
+	for n > 0 && slabData(n)+n*int64(blockSize) > int64(len(b.buf)) {
+		n--
+	}
+	if n < 1 || n > int64(^uint32(0)) {
+		return nil, ErrTooSmall
+	}
+
+	s := newSlabAllocator(b.buf, int64(blockSize), int(n))
+	zero(s.header)
+	zero(s.bitmap)
+	binary.LittleEndian.PutUint32(s.header[4:], uint32(blockSize))
+	binary.LittleEndian.PutUint32(s.header[8:], uint32(n))
+	binary.LittleEndian.PutUint32(s.header, slabMagic)
+	return s, nil
+}
+
+// OpenSlabAllocator attaches to a slab allocator previously formatted in
+// b.
+func OpenSlabAllocator(b *BufferIO) (*SlabAllocator, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+
+	if len(b.buf) < slabHeader || binary.LittleEndian.Uint32(b.buf) != slabMagic {
+		return nil, ErrNoSlab
+	}
+	blockSize := int64(binary.LittleEndian.Uint32(b.buf[4:]))
+	n := int64(binary.LittleEndian.Uint32(b.buf[8:]))
+	used := int64(binary.LittleEndian.Uint32(b.buf[12:]))
+	// a corrupt header can make n*blockSize overflow, so divide instead
+	size := int64(len(b.buf))
+	if blockSize == 0 || n == 0 || used > n ||
+		slabData(n) > size || n > (size-slabData(n))/blockSize {
+		return nil, ErrNoSlab
+	}
+	s := newSlabAllocator(b.buf, blockSize, int(n))
+	s.used = int(used)
+	return s, nil
+}
+
+// slabData returns the offset of the first of n blocks
+func slabData(n int64) int64 {
+	return (slabHeader + (n+7)/8 + 7) &^ 7
+}
+
+func newSlabAllocator(buf []byte, blockSize int64, n int) *SlabAllocator {
+	return &SlabAllocator{
+		header: buf[:slabHeader],
+		bitmap: buf[slabHeader : slabHeader+(n+7)/8],
+		size:   blockSize,
+		blocks: n,
+		data:   slabData(int64(n)),
+	}
+}
+
+func (s *SlabAllocator) setUsed(used int) {
+	s.used = used
+	binary.LittleEndian.PutUint32(s.header[12:], uint32(used))
+}
+
+// Alloc returns the offset of a free block.
+func (s *SlabAllocator) Alloc() (int64, error) {
+	if s.used == s.blocks {
+		return 0, ErrNoSpace
+	}
+	// Search whole bitmap bytes from where the last allocation was
+	nbytes := len(s.bitmap)
+	for k := 0; k <= nbytes; k++ {
+		i := (s.hint/8 + k) % nbytes
+		if s.bitmap[i] == 0xff {
+			continue
+		}
+		for bit := uint(0); bit < 8; bit++ {
+			block := i*8 + int(bit)
+			if block >= s.blocks {
+				break
+			}
+			if s.bitmap[i]&(1<<bit) == 0 {
+				s.bitmap[i] |= 1 << bit
+				s.setUsed(s.used + 1)
+				s.hint = block
+				return s.data + int64(block)*s.size, nil
+			}
+		}
+	}
+	return 0, ErrNoSpace
+}
+
+// Free releases the block at off. Offsets which are not allocated
+// blocks give ErrBadFree.
+func (s *SlabAllocator) Free(off int64) error {
+	rel := off - s.data
+	if rel < 0 || rel%s.size != 0 || rel/s.size >= int64(s.blocks) {
+		return ErrBadFree
+	}
+	block := int(rel / s.size)
+	mask := byte(1) << uint(block%8)
+	if s.bitmap[block/8]&mask == 0 {
+		return ErrBadFree
+	}
+	s.bitmap[block/8] &^= mask
+	s.setUsed(s.used - 1)
+	return nil
+}
+
+// Used returns the number of allocated blocks.
+func (s *SlabAllocator) Used() int {
+	return s.used
+}
+
+// Blocks returns the total number of blocks.
+func (s *SlabAllocator) Blocks() int {
+	return s.blocks
+}
+
+// BlockSize returns the size of each block.
+func (s *SlabAllocator) BlockSize() int {
+	return int(s.size)
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestSlabAllocator(t *testing.T) {
+	b := NewBufferIOMake(4096)
+	s, err := NewSlabAllocator(b, 100)
+	assert(t, err == nil)
+	assert(t, s.BlockSize() == 100)
+	assert(t, s.Blocks() == 40)
+	assert(t, slabData(40)+40*100 <= 4096)
+
+	seen := make(map[int64]bool)
+	for i := 0; i < s.Blocks(); i++ {
+		off, err := s.Alloc()
+		assert(t, err == nil)
+		assert(t, !seen[off])
+		assert(t, off >= slabData(40) && off+100 <= 4096)
+		seen[off] = true
+	}
+	assert(t, s.Used() == 40)
+	_, err = s.Alloc()
+	assert(t, err == ErrNoSpace)
+
+	// Freed blocks are handed out again
+	first := slabData(40) + 700
+	assert(t, s.Free(first) == nil)
+	assert(t, s.Free(first) == ErrBadFree)
+	assert(t, s.Used() == 39)
+	off, err := s.Alloc()
+	assert(t, err == nil)
+	assert(t, off == first)
+
+	assert(t, s.Free(first+1) == ErrBadFree)
+	assert(t, s.Free(0) == ErrBadFree)
+	assert(t, s.Free(slabData(40)+4000) == ErrBadFree)
+}
+
+func TestSlabAllocatorOpen(t *testing.T) {
+	b := NewBufferIOMake(1024)
+	_, err := OpenSlabAllocator(b)
+	assert(t, err == ErrNoSlab)
+
+	s, _ := NewSlabAllocator(b, 16)
+	x, _ := s.Alloc()
+	y, _ := s.Alloc()
+	s.Free(x)
+
+	c, err := OpenSlabAllocator(NewBufferIO(append([]byte(nil), b.Bytes()...)))
+	assert(t, err == nil)
+	assert(t, c.Used() == 1)
+	assert(t, c.Blocks() == s.Blocks())
+	assert(t, c.Free(x) == ErrBadFree)
+	assert(t, c.Free(y) == nil)
+
+	// A corrupt header whose blocks overflow when multiplied is rejected
+	corrupt := append([]byte(nil), b.Bytes()...)
+	binary.LittleEndian.PutUint32(corrupt[4:], 0xffffffff)
+	binary.LittleEndian.PutUint32(corrupt[8:], 4294901768)
+	_, err = OpenSlabAllocator(NewBufferIO(corrupt))
+	assert(t, err == ErrNoSlab)
+
+	_, err = NewSlabAllocator(NewBufferIOMake(20), 16)
+	assert(t, err == ErrTooSmall)
+	_, err = NewSlabAllocator(b, 0)
+	assert(t, err != nil)
+}
@@ -0,0 +1,184 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	ErrPrefixWidth = errors.New("length prefix must be 1, 2 or 4 bytes")
+	ErrStringNUL   = errors.New("NUL byte inside a NUL terminated string")
+)
+
+// The string and byte slice helpers below read and write at the offset
+// in three layouts common in wire formats:
+//
+//	Prefixed	a length prefix of width 1, 2 or 4 bytes in order,
+//			followed by the bytes
+//	Fixed		exactly width bytes, padded with NULs
+//	Z		the bytes followed by a NUL
+//
+// Writes are whole or not at all, returning io.ErrShortWrite for data
+// which does not fit, and reads which fail leave the offset alone. Data
+// too long for its prefix or width returns ErrRecordTooLarge.
+
+// WriteBytesPrefixed writes p after its length in a prefix of width
+// bytes.
+func (b *BufferIO) WriteBytesPrefixed(order binary.ByteOrder, width int, p []byte) error {
+	if err := checkPrefix(width); err != nil {
+		return err
+	}
+	enc := make([]byte, width+len(p))
+	if !putUint(enc[:width], order, uint64(len(p))) {
+		return ErrRecordTooLarge
+	}
+	copy(enc[width:], p)
+	return b.writeWhole(enc)
+}
+
+func (b *BufferIO) WriteStringPrefixed(order binary.ByteOrder, width int, s string) error {
+	return b.WriteBytesPrefixed(order, width, []byte(s))
+}
+
+// ReadBytesPrefixed reads bytes written by WriteBytesPrefixed.
+func (b *BufferIO) ReadBytesPrefixed(order binary.ByteOrder, width int) ([]byte, error) {
+	if err := checkPrefix(width); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	var h [4]byte
+	if err := b.readWhole(h[:width], b.off, false); err != nil {
+		return nil, err
+	}
+	var n uint64
+	switch width {
+	case 1:
+		n = uint64(h[0])
+	case 2:
+		n = uint64(order.Uint16(h[:]))
+	case 4:
+		n = uint64(order.Uint32(h[:]))
+	}
+	if int64(n) > b.length()-b.off-int64(width) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p := make([]byte, n)
+	if err := b.readWhole(p, b.off+int64(width), true); err != nil {
+		return nil, err
+	}
+	b.off += int64(width) + int64(n)
+	return p, nil
+}
+
+func (b *BufferIO) ReadStringPrefixed(order binary.ByteOrder, width int) (string, error) {
+	p, err := b.ReadBytesPrefixed(order, width)
+	return string(p), err
+}
+
+// WriteBytesFixed writes p padded with NULs to width bytes.
+func (b *BufferIO) WriteBytesFixed(width int, p []byte) error {
+	if width < 0 || len(p) > width {
+		return ErrRecordTooLarge
+	}
+	enc := make([]byte, width)
+	copy(enc, p)
+	return b.writeWhole(enc)
+}
+
+func (b *BufferIO) WriteStringFixed(width int, s string) error {
+	return b.WriteBytesFixed(width, []byte(s))
+}
+
+// ReadBytesFixed reads width bytes, padding included, since NULs at the
+// end of binary data may be part of it.
+func (b *BufferIO) ReadBytesFixed(width int) ([]byte, error) {
+	if width < 0 {
+		return nil, errors.New("negative count")
+	}
+	p := make([]byte, width)
+	if err := b.readNext(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ReadStringFixed reads width bytes and drops the NUL padding after the
+// string.
+func (b *BufferIO) ReadStringFixed(width int) (string, error) {
+	p, err := b.ReadBytesFixed(width)
+	return string(bytes.TrimRight(p, "\x00")), err
+}
+
+// WriteBytesZ writes p and a terminating NUL. A NUL within p returns
+// ErrStringNUL.
+func (b *BufferIO) WriteBytesZ(p []byte) error {
+	if bytes.IndexByte(p, 0) >= 0 {
+		return ErrStringNUL
+	}
+	return b.writeWhole(append(p[:len(p):len(p)], 0))
+}
+
+func (b *BufferIO) WriteStringZ(s string) error {
+	return b.WriteBytesZ([]byte(s))
+}
+
+// ReadBytesZ reads bytes up to a NUL and moves past the NUL, which is
+// not returned. Reaching the end of the buffer first returns
+// io.ErrUnexpectedEOF.
+func (b *BufferIO) ReadBytesZ() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	var p []byte
+	var chunk [256]byte
+	for off := b.off; ; {
+		n, err := b.readAt(chunk[:], off)
+		if i := bytes.IndexByte(chunk[:n], 0); i >= 0 {
+			p = append(p, chunk[:i]...)
+			b.off = off + int64(i) + 1
+			return p, nil
+		}
+		p = append(p, chunk[:n]...)
+		off += int64(n)
+		if err == io.EOF && off > b.off {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (b *BufferIO) ReadStringZ() (string, error) {
+	p, err := b.ReadBytesZ()
+	return string(p), err
+}
+
+func checkPrefix(width int) error {
+	if width != 1 && width != 2 && width != 4 {
+		return ErrPrefixWidth
+	}
+	return nil
+}
+
+// readWhole fills p from off. At the end of the buffer it returns
+// io.EOF, unless inside is set because p continues earlier data, and
+// io.ErrUnexpectedEOF when p is cut short.
+func (b *BufferIO) readWhole(p []byte, off int64, inside bool) error {
+	n, err := b.readAt(p, off)
+	if err == io.EOF && n < len(p) && (n > 0 || inside) {
+		return io.ErrUnexpectedEOF
+	}
+	if n == len(p) {
+		return nil
+	}
+	return err
+}
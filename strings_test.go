@@ -0,0 +1,99 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStringsPrefixed(t *testing.T) {
+	b := NewBufferIOGrow(0)
+	assert(t, b.WriteStringPrefixed(binary.BigEndian, 1, "one") == nil)
+	assert(t, b.WriteStringPrefixed(binary.LittleEndian, 2, "two") == nil)
+	assert(t, b.WriteBytesPrefixed(binary.BigEndian, 4, []byte{0, 1}) == nil)
+	assert(t, b.WriteStringPrefixed(binary.BigEndian, 1, "") == nil)
+	assert(t, string(b.Bytes()) == "\x03one\x03\x00two\x00\x00\x00\x02\x00\x01\x00")
+
+	b.Seek(0, io.SeekStart)
+	s, err := b.ReadStringPrefixed(binary.BigEndian, 1)
+	assert(t, err == nil && s == "one")
+	s, err = b.ReadStringPrefixed(binary.LittleEndian, 2)
+	assert(t, err == nil && s == "two")
+	p, err := b.ReadBytesPrefixed(binary.BigEndian, 4)
+	assert(t, err == nil && bytes.Equal(p, []byte{0, 1}))
+	s, err = b.ReadStringPrefixed(binary.BigEndian, 1)
+	assert(t, err == nil && s == "")
+	_, err = b.ReadStringPrefixed(binary.BigEndian, 1)
+	assert(t, err == io.EOF)
+
+	assert(t, b.WriteStringPrefixed(binary.BigEndian, 1, strings.Repeat("x", 256)) == ErrRecordTooLarge)
+	assert(t, b.WriteStringPrefixed(binary.BigEndian, 3, "x") == ErrPrefixWidth)
+	_, err = b.ReadStringPrefixed(binary.BigEndian, 8)
+	assert(t, err == ErrPrefixWidth)
+
+	// Truncated strings fail and leave the offset alone
+	b = NewBufferIO([]byte("\x05abc"))
+	_, err = b.ReadStringPrefixed(binary.BigEndian, 1)
+	assert(t, err == io.ErrUnexpectedEOF)
+	_, err = b.ReadStringPrefixed(binary.BigEndian, 4)
+	assert(t, err == io.ErrUnexpectedEOF)
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 0)
+	assert(t, b.WriteStringPrefixed(binary.BigEndian, 1, "abcd") == io.ErrShortWrite)
+	assert(t, string(b.Bytes()) == "\x05abc")
+}
+
+func TestStringsFixed(t *testing.T) {
+	b := NewBufferIOMake(12)
+	assert(t, b.WriteStringFixed(8, "name") == nil)
+	assert(t, b.WriteStringFixed(5, "toolong") == ErrRecordTooLarge)
+	assert(t, b.WriteBytesFixed(6, []byte{1}) == io.ErrShortWrite)
+	assert(t, b.WriteBytesFixed(4, []byte{1, 0}) == nil)
+	assert(t, string(b.Bytes()) == "name\x00\x00\x00\x00\x01\x00\x00\x00")
+
+	b.Seek(0, io.SeekStart)
+	s, err := b.ReadStringFixed(8)
+	assert(t, err == nil && s == "name")
+	p, err := b.ReadBytesFixed(4)
+	assert(t, err == nil && bytes.Equal(p, []byte{1, 0, 0, 0}))
+	_, err = b.ReadBytesFixed(1)
+	assert(t, err == io.EOF)
+	b.Seek(10, io.SeekStart)
+	_, err = b.ReadStringFixed(4)
+	assert(t, err == io.ErrUnexpectedEOF)
+}
+
+func TestStringsZ(t *testing.T) {
+	long := strings.Repeat("z", 1000)
+	b := NewBufferIOGrow(0)
+	assert(t, b.WriteStringZ("abc") == nil)
+	assert(t, b.WriteStringZ("") == nil)
+	assert(t, b.WriteStringZ(long) == nil)
+	assert(t, b.WriteBytesZ([]byte{1, 2}) == nil)
+	assert(t, b.WriteStringZ("a\x00b") == ErrStringNUL)
+
+	b.Seek(0, io.SeekStart)
+	for _, want := range []string{"abc", "", long, "\x01\x02"} {
+		s, err := b.ReadStringZ()
+		assert(t, err == nil && s == want)
+	}
+	_, err := b.ReadBytesZ()
+	assert(t, err == io.EOF)
+
+	b = NewBufferIO([]byte("unterminated"))
+	_, err = b.ReadStringZ()
+	assert(t, err == io.ErrUnexpectedEOF)
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 0)
+
+	backed := NewBufferIOFrom(strings.NewReader("abc\x00def\x00"), 8)
+	backed.Seek(4, io.SeekStart)
+	s, err := backed.ReadStringZ()
+	assert(t, err == nil && s == "def")
+}
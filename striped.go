@@ -0,0 +1,173 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// BufferReaderWriter is the positional I/O every buffer backend, including
+// BufferIO and *os.File, provides.
+type BufferReaderWriter interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// Striped spreads one logical address space over several backends in
+// fixed size stripes, round robin, so that large transfers and flushes
+// run on all of them in parallel.
+type Striped struct {
+	stripe  int64
+	size    int64
+	members []BufferReaderWriter
+}
+
+// NewStriped creates a striped space of size bytes. Every member must be
+// able to hold its share, size/len(members) bytes rounded up to a whole
+// stripe.
+func NewStriped(stripeSize, size int64, members ...BufferReaderWriter) (*Striped, error) {
+	if stripeSize <= 0 || size < 0 {
+		return nil, errors.New("invalid stripe geometry")
+	}
+	if len(members) == 0 {
+		return nil, errors.New("no stripe members")
+	}
+	return &Striped{stripe: stripeSize, size: size, members: members}, nil
+}
+
+func (s *Striped) Size() int64 {
+	return s.size
+}
+
+// stripePiece is the part of a transfer which falls in one stripe.
+type stripePiece struct {
+	member int
+	off    int64
+	p      []byte
+	n      int
+	err    error
+}
+
+func (s *Striped) pieces(p []byte, off int64) []stripePiece {
+	var pieces []stripePiece
+	for len(p) > 0 {
+		k := off / s.stripe
+		within := off % s.stripe
+		n := s.stripe - within
+		if n > int64(len(p)) {
+			n = int64(len(p))
+		}
+		pieces = append(pieces, stripePiece{
+			member: int(k % int64(len(s.members))),
+			off:    (k/int64(len(s.members)))*s.stripe + within,
+			p:      p[:n],
+		})
+		p = p[n:]
+		off += n
+	}
+	return pieces
+}
+
+// run performs the pieces with one goroutine per member and returns the
+// number of bytes transferred before the first failure.
+func (s *Striped) run(pieces []stripePiece, op func(m BufferReaderWriter, piece *stripePiece)) (int, error) {
+	byMember := make(map[int][]*stripePiece)
+	for i := range pieces {
+		byMember[pieces[i].member] = append(byMember[pieces[i].member], &pieces[i])
+	}
+
+	var wg sync.WaitGroup
+	for member, list := range byMember {
+		wg.Add(1)
+		go func(m BufferReaderWriter, list []*stripePiece) {
+			defer wg.Done()
+			for _, piece := range list {
+				op(m, piece)
+				if piece.err != nil {
+					return
+				}
+			}
+		}(s.members[member], list)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, piece := range pieces {
+		total += piece.n
+		if piece.err != nil {
+			return total, piece.err
+		}
+		if piece.n < len(piece.p) {
+			return total, io.ErrShortWrite
+		}
+	}
+	return total, nil
+}
+
+func (s *Striped) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+	short := false
+	if int64(len(p)) > s.size-off {
+		p = p[:s.size-off]
+		short = true
+	}
+
+	n, err := s.run(s.pieces(p, off), func(m BufferReaderWriter, piece *stripePiece) {
+		piece.n, piece.err = m.ReadAt(piece.p, piece.off)
+		if piece.n == len(piece.p) {
+			piece.err = nil
+		} else if piece.err == nil {
+			piece.err = io.ErrUnexpectedEOF
+		}
+	})
+	if err == nil && short {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (s *Striped) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= s.size {
+		if len(p) > 0 {
+			return 0, io.ErrShortWrite
+		}
+		return 0, nil
+	}
+	short := false
+	if int64(len(p)) > s.size-off {
+		p = p[:s.size-off]
+		short = true
+	}
+
+	n, err := s.run(s.pieces(p, off), func(m BufferReaderWriter, piece *stripePiece) {
+		piece.n, piece.err = m.WriteAt(piece.p, piece.off)
+	})
+	if err == nil && short {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// Flush flushes every member which is a Flusher, in parallel, and
+// returns the first error.
+func (s *Striped) Flush() error {
+	var fs []Flusher
+	for _, m := range s.members {
+		if f, ok := m.(Flusher); ok {
+			fs = append(fs, f)
+		}
+	}
+	return flushAll(fs)
+}
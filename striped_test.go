@@ -0,0 +1,126 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type flushCounter struct {
+	*BufferIO
+	flushes int
+	err     error
+}
+
+func (f *flushCounter) Flush() error {
+	f.flushes++
+	return f.err
+}
+
+func newStripedTest(t *testing.T) (*Striped, []*BufferIO) {
+	members := []*BufferIO{
+		NewBufferIOMake(16),
+		NewBufferIOMake(16),
+		NewBufferIOMake(16),
+	}
+	s, err := NewStriped(4, 40, members[0], members[1], members[2])
+	assert(t, err == nil)
+	return s, members
+}
+
+func TestStripedLayout(t *testing.T) {
+	s, members := newStripedTest(t)
+	assert(t, s.Size() == 40)
+
+	data := make([]byte, 40)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	n, err := s.WriteAt(data, 0)
+	assert(t, n == 40)
+	assert(t, err == nil)
+
+	// Stripes are distributed round robin
+	assert(t, bytes.Equal(members[0].buf[:4], data[0:4]))
+	assert(t, bytes.Equal(members[1].buf[:4], data[4:8]))
+	assert(t, bytes.Equal(members[2].buf[:4], data[8:12]))
+	assert(t, bytes.Equal(members[0].buf[4:8], data[12:16]))
+	assert(t, bytes.Equal(members[0].buf[12:16], data[36:40]))
+
+	buf := make([]byte, 40)
+	n, err = s.ReadAt(buf, 0)
+	assert(t, n == 40)
+	assert(t, err == nil)
+	assert(t, bytes.Equal(buf, data))
+
+	// Unaligned ranges
+	buf = make([]byte, 11)
+	n, err = s.ReadAt(buf, 7)
+	assert(t, n == 11)
+	assert(t, err == nil)
+	assert(t, bytes.Equal(buf, data[7:18]))
+}
+
+func TestStripedBounds(t *testing.T) {
+	s, _ := newStripedTest(t)
+
+	buf := make([]byte, 8)
+	n, err := s.ReadAt(buf, 36)
+	assert(t, n == 4)
+	assert(t, err == io.EOF)
+
+	n, err = s.ReadAt(buf, 40)
+	assert(t, n == 0)
+	assert(t, err == io.EOF)
+
+	n, err = s.WriteAt(buf, 36)
+	assert(t, n == 4)
+	assert(t, err == io.ErrShortWrite)
+
+	n, err = s.WriteAt(buf, 40)
+	assert(t, n == 0)
+	assert(t, err == io.ErrShortWrite)
+
+	n, err = s.WriteAt(nil, 400)
+	assert(t, n == 0 && err == nil)
+
+	_, err = s.ReadAt(buf, -1)
+	assert(t, err != nil)
+
+	_, err = NewStriped(0, 10, NewBufferIOMake(1))
+	assert(t, err != nil)
+	_, err = NewStriped(4, 10)
+	assert(t, err != nil)
+}
+
+func TestStripedMemberError(t *testing.T) {
+	// The second member is too small for its share
+	s, err := NewStriped(4, 24, NewBufferIOMake(12), NewBufferIOMake(4))
+	assert(t, err == nil)
+
+	n, err := s.WriteAt(make([]byte, 24), 0)
+	assert(t, n == 12)
+	assert(t, err == io.ErrShortWrite)
+
+	n, err = s.ReadAt(make([]byte, 24), 0)
+	assert(t, n == 12)
+	assert(t, err != nil)
+}
+
+func TestStripedFlush(t *testing.T) {
+	a := &flushCounter{BufferIO: NewBufferIOMake(8)}
+	b := &flushCounter{BufferIO: NewBufferIOMake(8)}
+	s, _ := NewStriped(4, 16, a, b, NewBufferIOMake(8))
+
+	assert(t, s.Flush() == nil)
+	assert(t, a.flushes == 1)
+	assert(t, b.flushes == 1)
+
+	b.err = errors.New("flush failed")
+	assert(t, s.Flush() == b.err)
+}
@@ -0,0 +1,112 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+)
+
+// TLV records are stored back to back as
+//
+//	[tag uint32][length uint32][value]
+//
+// in the byte order of the buffer. A value may itself hold TLV records,
+// read through WindowTLV and written between BeginTLV and EndTLV.
+const tlvHeader = 8
+
+// TLV is a record read by NextTLV. Value is the memory of the buffer,
+// as with Next, and Off is where it starts.
+type TLV struct {
+	Tag   uint32
+	Value []byte
+	Off   int64
+}
+
+// WriteTLV writes a record at the offset and moves past it. The record
+// is written whole or not at all, returning io.ErrShortWrite when it
+// does not fit.
+func (b *BufferIO) WriteTLV(tag uint32, value []byte) error {
+	if int64(len(value)) > int64(^uint32(0)) {
+		return ErrRecordTooLarge
+	}
+	order := b.ByteOrder()
+	p := make([]byte, tlvHeader+len(value))
+	order.PutUint32(p, tag)
+	order.PutUint32(p[4:], uint32(len(value)))
+	copy(p[tlvHeader:], value)
+	return b.writeWhole(p)
+}
+
+// BeginTLV writes the header of a record whose value is written next,
+// such as nested records, and returns its offset for EndTLV.
+func (b *BufferIO) BeginTLV(tag uint32) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	var h [tlvHeader]byte
+	b.byteOrder().PutUint32(h[:], tag)
+	start := b.off
+	return start, b.writeNext(h[:])
+}
+
+// EndTLV sets the length of the record begun at start to cover the
+// bytes written since.
+func (b *BufferIO) EndTLV(start int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	n := b.off - start - tlvHeader
+	if start < 0 || n < 0 {
+		return ErrOverrun
+	}
+	if n > int64(^uint32(0)) {
+		return ErrRecordTooLarge
+	}
+	var h [4]byte
+	b.byteOrder().PutUint32(h[:], uint32(n))
+	_, err := b.writeAt(h[:], start+4)
+	return err
+}
+
+// NextTLV reads the record at the offset and moves past it. At the end
+// of the buffer it returns io.EOF, and for a record cut short
+// io.ErrUnexpectedEOF, leaving the offset alone. Buffers backed by a
+// ReaderAt return ErrUnsupported.
+func (b *BufferIO) NextTLV() (TLV, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	if b.from != nil {
+		return TLV{}, ErrUnsupported
+	}
+	var h [tlvHeader]byte
+	if err := b.readWhole(h[:], b.off, false); err != nil {
+		return TLV{}, err
+	}
+	order := b.byteOrder()
+	n := int64(order.Uint32(h[4:]))
+	start := b.off + tlvHeader
+	if n > b.size()-start {
+		return TLV{}, io.ErrUnexpectedEOF
+	}
+	b.shared()
+	b.off = start + n
+	return TLV{
+		Tag:   order.Uint32(h[:]),
+		Value: b.buf[start : start+n : start+n],
+		Off:   start,
+	}, nil
+}
+
+// WindowTLV returns a Window over the value of t, in the byte order of
+// b, to read or rewrite the records nested inside it.
+func (b *BufferIO) WindowTLV(t TLV) (*BufferIO, error) {
+	w, err := b.Window(t.Off, int64(len(t.Value)))
+	if err != nil {
+		return nil, err
+	}
+	w.order = b.ByteOrder()
+	return w, nil
+}
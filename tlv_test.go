@@ -0,0 +1,87 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestTLV(t *testing.T) {
+	b := NewBufferIOMake(64)
+	b.SetByteOrder(binary.BigEndian)
+	assert(t, b.WriteTLV(1, []byte("abc")) == nil)
+	assert(t, b.WriteTLV(2, nil) == nil)
+	assert(t, bytes.Equal(b.Bytes()[:11], []byte{0, 0, 0, 1, 0, 0, 0, 3, 'a', 'b', 'c'}))
+
+	b.Seek(0, 0)
+	r, err := b.NextTLV()
+	assert(t, err == nil && r.Tag == 1 && string(r.Value) == "abc" && r.Off == 8)
+
+	// Values are views of the buffer
+	b.Bytes()[8] = 'x'
+	assert(t, r.Value[0] == 'x')
+
+	r, err = b.NextTLV()
+	assert(t, err == nil && r.Tag == 2 && len(r.Value) == 0)
+	off, _ := b.Seek(0, 1)
+	assert(t, off == 19)
+}
+
+func TestTLVNested(t *testing.T) {
+	b := NewBufferIOMake(64)
+	start, err := b.BeginTLV(10)
+	assert(t, err == nil && start == 0)
+	assert(t, b.WriteTLV(11, []byte{1}) == nil)
+	assert(t, b.WriteTLV(12, []byte{2, 3}) == nil)
+	assert(t, b.EndTLV(start) == nil)
+	assert(t, b.WriteTLV(20, []byte{4}) == nil)
+
+	b.Seek(0, 0)
+	outer, err := b.NextTLV()
+	assert(t, err == nil && outer.Tag == 10 && len(outer.Value) == 2*tlvHeader+3)
+
+	w, err := b.WindowTLV(outer)
+	assert(t, err == nil)
+	r, err := w.NextTLV()
+	assert(t, err == nil && r.Tag == 11 && r.Value[0] == 1)
+	r, err = w.NextTLV()
+	assert(t, err == nil && r.Tag == 12 && bytes.Equal(r.Value, []byte{2, 3}))
+	_, err = w.NextTLV()
+	assert(t, err == io.EOF)
+
+	r, err = b.NextTLV()
+	assert(t, err == nil && r.Tag == 20)
+}
+
+func TestTLVErrors(t *testing.T) {
+	b := NewBufferIOMake(10)
+	assert(t, b.WriteTLV(1, []byte("abc")) == io.ErrShortWrite)
+	off, _ := b.Seek(0, 1)
+	assert(t, off == 0)
+
+	// A record cut short is left for later
+	assert(t, b.WriteTLV(1, []byte("ab")) == nil)
+	b.PutUint32LEAt(4, 5)
+	b.Seek(0, 0)
+	_, err := b.NextTLV()
+	assert(t, err == io.ErrUnexpectedEOF)
+	b.Seek(6, 0)
+	_, err = b.NextTLV()
+	assert(t, err == io.ErrUnexpectedEOF)
+	off, _ = b.Seek(0, 1)
+	assert(t, off == 6)
+	b.Seek(0, 2)
+	_, err = b.NextTLV()
+	assert(t, err == io.EOF)
+
+	assert(t, b.EndTLV(8) == ErrOverrun)
+
+	from := NewBufferIOFrom(bytes.NewReader(make([]byte, 16)), 16)
+	_, err = from.NextTLV()
+	assert(t, err == ErrUnsupported)
+}
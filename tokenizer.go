@@ -0,0 +1,138 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnexpected = errors.New("unexpected data")
+	ErrTruncated  = errors.New("truncated data")
+)
+
+// contextBytes is how much of the input a ParseError shows
+const contextBytes = 8
+
+// ParseError records where a Tokenizer failed and the bytes found there.
+type ParseError struct {
+	Off     int64
+	Context []byte
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("offset %d: %v (at % x)", e.Off, e.Err, e.Context)
+}
+
+// Tokenizer consumes a region of a BufferIO for hand written parsers.
+// The first failure is sticky: every later call returns it again.
+type Tokenizer struct {
+	b        *BufferIO
+	off, end int64
+	err      error
+}
+
+// NewTokenizer returns a Tokenizer over the n bytes at off.
+func NewTokenizer(b *BufferIO, off, n int64) (*Tokenizer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.slice(off, n); err != nil {
+		return nil, err
+	}
+	return &Tokenizer{b: b, off: off, end: off + n}, nil
+}
+
+// Offset returns the offset in the buffer of the next byte.
+func (t *Tokenizer) Offset() int64 {
+	return t.off
+}
+
+// Remaining returns the number of unconsumed bytes.
+func (t *Tokenizer) Remaining() int64 {
+	return t.end - t.off
+}
+
+// Err returns the first error encountered, if any.
+func (t *Tokenizer) Err() error {
+	return t.err
+}
+
+// Expect consumes magic, failing with ErrUnexpected if the input does
+// not start with it.
+func (t *Tokenizer) Expect(magic []byte) error {
+	_, err := t.take(func(r []byte) (int, error) {
+		if !bytes.HasPrefix(r, magic) {
+			if len(r) < len(magic) && bytes.HasPrefix(magic, r) {
+				return 0, ErrTruncated
+			}
+			return 0, ErrUnexpected
+		}
+		return len(magic), nil
+	})
+	return err
+}
+
+// TakeN consumes and returns the next n bytes.
+func (t *Tokenizer) TakeN(n int) ([]byte, error) {
+	return t.take(func(r []byte) (int, error) {
+		if n < 0 || n > len(r) {
+			return 0, ErrTruncated
+		}
+		return n, nil
+	})
+}
+
+// TakeUntil returns the bytes before the next delim and consumes them
+// along with the delimiter.
+func (t *Tokenizer) TakeUntil(delim byte) ([]byte, error) {
+	p, err := t.take(func(r []byte) (int, error) {
+		i := bytes.IndexByte(r, delim)
+		if i < 0 {
+			return 0, ErrTruncated
+		}
+		return i + 1, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p[:len(p)-1], nil
+}
+
+// take runs match on the remaining input and consumes the number of
+// bytes it accepts, returning a copy of them
+func (t *Tokenizer) take(match func(r []byte) (int, error)) ([]byte, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	t.b.mu.Lock()
+	defer t.b.mu.Unlock()
+
+	r, err := t.b.slice(t.off, t.end-t.off)
+	if err != nil {
+		t.err = err
+		return nil, err
+	}
+	n, err := match(r)
+	if err != nil {
+		ctx := r
+		if len(ctx) > contextBytes {
+			ctx = ctx[:contextBytes]
+		}
+		t.err = &ParseError{
+			Off:     t.off,
+			Context: append([]byte(nil), ctx...),
+			Err:     err,
+		}
+		return nil, t.err
+	}
+
+	p := append([]byte(nil), r[:n]...)
+	t.off += int64(n)
+	return p, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"testing"
+)
+
+func TestTokenizer(t *testing.T) {
+	b := NewBufferIO([]byte("xxMAGIname\x00\x01\x02\x03tail"))
+	tok, err := NewTokenizer(b, 2, b.Size()-2)
+	assert(t, err == nil)
+
+	assert(t, tok.Expect([]byte("MAGI")) == nil)
+	name, err := tok.TakeUntil(0)
+	assert(t, err == nil)
+	assert(t, string(name) == "name")
+	p, err := tok.TakeN(3)
+	assert(t, err == nil)
+	assert(t, string(p) == "\x01\x02\x03")
+	assert(t, tok.Offset() == 14)
+	assert(t, tok.Remaining() == 4)
+
+	p, err = tok.TakeN(4)
+	assert(t, err == nil)
+	assert(t, string(p) == "tail")
+	assert(t, tok.Remaining() == 0)
+	assert(t, tok.Err() == nil)
+
+	// Results are copies
+	p[0] = 'T'
+	assert(t, b.EqualAt(14, []byte("tail")))
+}
+
+func TestTokenizerErrors(t *testing.T) {
+	b := NewBufferIO([]byte("MAGIC 0123456789abcdef"))
+	tok, _ := NewTokenizer(b, 0, b.Size())
+
+	assert(t, tok.Expect([]byte("MAGIC ")) == nil)
+	err := tok.Expect([]byte("xyz"))
+	perr, ok := err.(*ParseError)
+	assert(t, ok)
+	assert(t, perr.Off == 6)
+	assert(t, perr.Err == ErrUnexpected)
+	assert(t, string(perr.Context) == "01234567")
+	assert(t, perr.Error() == "offset 6: unexpected data (at 30 31 32 33 34 35 36 37)")
+
+	// Errors are sticky
+	_, err = tok.TakeN(1)
+	assert(t, err == perr)
+	assert(t, tok.Err() == perr)
+	assert(t, tok.Offset() == 6)
+
+	tok, _ = NewTokenizer(b, 18, 4)
+	_, err = tok.TakeN(5)
+	assert(t, err.(*ParseError).Err == ErrTruncated)
+
+	tok, _ = NewTokenizer(b, 18, 4)
+	_, err = tok.TakeUntil('x')
+	assert(t, err.(*ParseError).Err == ErrTruncated)
+
+	tok, _ = NewTokenizer(b, 18, 4)
+	err = tok.Expect([]byte("cdefg"))
+	assert(t, err.(*ParseError).Err == ErrTruncated)
+
+	_, err = NewTokenizer(b, 18, 5)
+	assert(t, err == ErrOverrun)
+
+	tok, _ = NewTokenizer(b, 0, 4)
+	assert(t, b.Close() == nil)
+	_, err = tok.TakeN(1)
+	assert(t, err == ErrClosed)
+}
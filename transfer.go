@@ -0,0 +1,201 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// TransferState records the progress of a chunked transfer so that it
+// can be persisted and resumed after an interruption. Offset is where
+// the transfer continues and Sums holds the Castagnoli CRC-32 of every
+// chunk transferred so far.
+type TransferState struct {
+	Offset    int64
+	ChunkSize int
+	Sums      []uint32
+}
+
+func NewTransferState(chunkSize int) *TransferState {
+	return &TransferState{ChunkSize: chunkSize}
+}
+
+func (st *TransferState) add(p []byte) {
+	st.Sums = append(st.Sums, crc32.Checksum(p, frameTable))
+	st.Offset += int64(len(p))
+}
+
+// resume validates the state and rewinds a trailing partial chunk, so
+// transfers always continue on a chunk boundary
+func (st *TransferState) resume() error {
+	if st.ChunkSize <= 0 {
+		return errors.New("invalid chunk size")
+	}
+	cs := int64(st.ChunkSize)
+	if st.Offset < 0 || int64(len(st.Sums)) != (st.Offset+cs-1)/cs {
+		return ErrCorrupt
+	}
+	if st.Offset%cs != 0 {
+		st.Offset -= st.Offset % cs
+		st.Sums = st.Sums[:len(st.Sums)-1]
+	}
+	return nil
+}
+
+// Verify checks the chunks already transferred against the contents of
+// b. If one differs, the state is rewound to it and ErrChecksum is
+// returned, so resuming transfers it again. A trailing partial chunk is
+// always rewound.
+func (st *TransferState) Verify(b *BufferIO) error {
+	if err := st.resume(); err != nil {
+		return err
+	}
+	p := make([]byte, st.ChunkSize)
+	for i, sum := range st.Sums {
+		off := int64(i) * int64(st.ChunkSize)
+		n, err := b.ReadAt(p, off)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n < len(p) || crc32.Checksum(p, frameTable) != sum {
+			st.Offset = off
+			st.Sums = st.Sums[:i]
+			return ErrChecksum
+		}
+	}
+	return nil
+}
+
+// MarshalBinary encodes the state as
+//
+//	[offset uint64][chunk size uint32][chunks uint32][chunks * crc uint32]
+//
+// in little endian.
+func (st *TransferState) MarshalBinary() ([]byte, error) {
+	p := make([]byte, 16+4*len(st.Sums))
+	binary.LittleEndian.PutUint64(p, uint64(st.Offset))
+	binary.LittleEndian.PutUint32(p[8:], uint32(st.ChunkSize))
+	binary.LittleEndian.PutUint32(p[12:], uint32(len(st.Sums)))
+	for i, sum := range st.Sums {
+		binary.LittleEndian.PutUint32(p[16+4*i:], sum)
+	}
+	return p, nil
+}
+
+// UnmarshalBinary decodes a state encoded by MarshalBinary, rewinding a
+// trailing partial chunk.
+func (st *TransferState) UnmarshalBinary(p []byte) error {
+	if len(p) < 16 {
+		return ErrCorrupt
+	}
+	n := int(binary.LittleEndian.Uint32(p[12:]))
+	if len(p) != 16+4*n {
+		return ErrCorrupt
+	}
+	st.Offset = int64(binary.LittleEndian.Uint64(p))
+	st.ChunkSize = int(binary.LittleEndian.Uint32(p[8:]))
+	st.Sums = make([]uint32, n)
+	for i := range st.Sums {
+		st.Sums[i] = binary.LittleEndian.Uint32(p[16+4*i:])
+	}
+	return st.resume()
+}
+
+// SendChunksState sends chunks from st.Offset like SendChunksFrom,
+// recording each chunk fn accepts in st.
+func (b *BufferIO) SendChunksState(st *TransferState, fn func(chunk []byte) error) error {
+	if err := st.resume(); err != nil {
+		return err
+	}
+	_, err := b.SendChunksFrom(st.Offset, func(chunk []byte) error {
+		if err := fn(chunk); err != nil {
+			return err
+		}
+		st.add(chunk[ChunkHeader:])
+		return nil
+	}, st.ChunkSize)
+	return err
+}
+
+// ReceiveChunksState receives chunks like ReceiveChunks, recording them
+// in st. Chunks must arrive in order, starting at st.Offset.
+func (b *BufferIO) ReceiveChunksState(st *TransferState, next func() ([]byte, error)) error {
+	if err := st.resume(); err != nil {
+		return err
+	}
+	for {
+		chunk, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(chunk) < ChunkHeader {
+			return ErrCorrupt
+		}
+		if int64(binary.LittleEndian.Uint64(chunk)) != st.Offset {
+			return errors.New("chunk out of order")
+		}
+
+		data := chunk[ChunkHeader:]
+		if len(data) == 0 {
+			continue
+		}
+		if st.Offset%int64(st.ChunkSize) != 0 {
+			// Only the last chunk may be short
+			return ErrCorrupt
+		}
+		if len(data) > st.ChunkSize || st.Offset > b.Size()-int64(len(data)) {
+			return ErrOverrun
+		}
+		if _, err := b.WriteAt(data, st.Offset); err != nil {
+			return err
+		}
+		st.add(data)
+	}
+}
+
+// ExportState writes the buffer from st.Offset to w in chunks,
+// recording each written chunk in st, so an interrupted export can be
+// resumed on a writer positioned at st.Offset.
+func (b *BufferIO) ExportState(w io.Writer, st *TransferState) error {
+	return b.SendChunksState(st, func(chunk []byte) error {
+		_, err := w.Write(chunk[ChunkHeader:])
+		return err
+	})
+}
+
+// ImportState fills the buffer from r starting at st.Offset, recording
+// each chunk read in st, until r is exhausted or the buffer is full.
+func (b *BufferIO) ImportState(r io.Reader, st *TransferState) error {
+	if err := st.resume(); err != nil {
+		return err
+	}
+	p := make([]byte, st.ChunkSize)
+	for st.Offset < b.Size() {
+		want := int64(st.ChunkSize)
+		if rest := b.Size() - st.Offset; rest < want {
+			want = rest
+		}
+		n, err := io.ReadFull(r, p[:want])
+		if n > 0 {
+			if _, werr := b.WriteAt(p[:n], st.Offset); werr != nil {
+				return werr
+			}
+			st.add(p[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestTransferStateChunks(t *testing.T) {
+	src := NewBufferIO(append([]byte(nil), big...))
+	failed := errors.New("connection lost")
+
+	// The sender gets cut off after three chunks
+	send := NewTransferState(8)
+	q := &chunkQueue{}
+	sent := 0
+	err := src.SendChunksState(send, func(chunk []byte) error {
+		if sent == 3 {
+			return failed
+		}
+		sent++
+		return q.send(chunk)
+	})
+	assert(t, err == failed)
+	assert(t, send.Offset == 24)
+	assert(t, len(send.Sums) == 3)
+
+	dst := NewBufferIOMake(len(big))
+	recv := NewTransferState(8)
+	assert(t, dst.ReceiveChunksState(recv, q.next) == nil)
+	assert(t, recv.Offset == 24)
+
+	// Persist the receiver state and resume from it
+	saved, err := recv.MarshalBinary()
+	assert(t, err == nil)
+	var resumed TransferState
+	assert(t, resumed.UnmarshalBinary(saved) == nil)
+	assert(t, resumed.Verify(dst) == nil)
+
+	send = &TransferState{Offset: resumed.Offset, ChunkSize: 8, Sums: resumed.Sums}
+	assert(t, src.SendChunksState(send, q.send) == nil)
+	assert(t, dst.ReceiveChunksState(&resumed, q.next) == nil)
+	assert(t, bytes.Equal(dst.Bytes(), big))
+	assert(t, resumed.Offset == int64(len(big)))
+	assert(t, resumed.Verify(dst) == nil)
+
+	// Out of order chunks are refused
+	src.SendChunksFrom(0, q.send, 8)
+	assert(t, dst.ReceiveChunksState(NewTransferState(8), func() ([]byte, error) {
+		return q.chunks[1], nil
+	}) != nil)
+}
+
+func TestTransferStateVerify(t *testing.T) {
+	b := NewBufferIO(append([]byte(nil), big...))
+	st := NewTransferState(8)
+	var out bytes.Buffer
+	assert(t, b.ExportState(&out, st) == nil)
+	assert(t, bytes.Equal(out.Bytes(), big))
+
+	// Damage is found and the state rewound to it
+	b.Bytes()[20] ^= 1
+	assert(t, st.Verify(b) == ErrChecksum)
+	assert(t, st.Offset == 16)
+	assert(t, len(st.Sums) == 2)
+
+	var bad TransferState
+	assert(t, bad.UnmarshalBinary([]byte{1, 2}) == ErrCorrupt)
+	p, _ := (&TransferState{Offset: 100, ChunkSize: 8}).MarshalBinary()
+	assert(t, bad.UnmarshalBinary(p) == ErrCorrupt)
+}
+
+// shortReader returns EOF after n bytes
+type shortReader struct {
+	r io.Reader
+	n int
+}
+
+func (s *shortReader) Read(p []byte) (int, error) {
+	if s.n == 0 {
+		return 0, io.EOF
+	}
+	if len(p) > s.n {
+		p = p[:s.n]
+	}
+	n, err := s.r.Read(p)
+	s.n -= n
+	return n, err
+}
+
+func TestTransferStateImport(t *testing.T) {
+	b := NewBufferIOMake(len(big))
+	st := NewTransferState(8)
+
+	// The source dies part way through a chunk
+	assert(t, b.ImportState(&shortReader{bytes.NewReader(big), 21}, st) == nil)
+	assert(t, st.Offset == 21)
+
+	// Resuming rewinds to the chunk boundary
+	saved, _ := st.MarshalBinary()
+	var resumed TransferState
+	assert(t, resumed.UnmarshalBinary(saved) == nil)
+	assert(t, resumed.Offset == 16)
+	assert(t, resumed.Verify(b) == nil)
+	assert(t, b.ImportState(bytes.NewReader(big[resumed.Offset:]), &resumed) == nil)
+	assert(t, bytes.Equal(b.Bytes(), big))
+	assert(t, resumed.Offset == int64(len(big)))
+}
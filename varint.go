@@ -0,0 +1,90 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var ErrVarintOverflow = errors.New("varint overflows a 64-bit integer")
+
+// Varints use the encoding of encoding/binary and protocol buffers: 7
+// bits per byte, least significant group first. WriteVarint and
+// ReadVarint zigzag encode signed values, like the sint64 type of
+// protocol buffers, so small negative numbers stay short. Plain int64
+// fields of protocol buffers are uvarints of uint64(x).
+
+// WriteUvarint writes x as a uvarint at the offset and moves past it.
+// A varint which does not fit is not written and returns
+// io.ErrShortWrite, unless the buffer is growable.
+func (b *BufferIO) WriteUvarint(x uint64) error {
+	var p [binary.MaxVarintLen64]byte
+	return b.writeWhole(p[:binary.PutUvarint(p[:], x)])
+}
+
+// WriteVarint writes x zigzag encoded at the offset and moves past it,
+// like WriteUvarint.
+func (b *BufferIO) WriteVarint(x int64) error {
+	var p [binary.MaxVarintLen64]byte
+	return b.writeWhole(p[:binary.PutVarint(p[:], x)])
+}
+
+// ReadUvarint reads a uvarint at the offset and moves past it. It
+// returns io.EOF at the end of the buffer and io.ErrUnexpectedEOF for a
+// varint cut short by it. On error the offset does not move.
+func (b *BufferIO) ReadUvarint() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	p, err := b.varint()
+	if err != nil {
+		return 0, err
+	}
+	x, n := binary.Uvarint(p)
+	if n <= 0 {
+		return 0, varintError(n, len(p))
+	}
+	b.off += int64(n)
+	return x, nil
+}
+
+// ReadVarint reads a zigzag encoded varint like ReadUvarint.
+func (b *BufferIO) ReadVarint() (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.claim()
+	p, err := b.varint()
+	if err != nil {
+		return 0, err
+	}
+	x, n := binary.Varint(p)
+	if n <= 0 {
+		return 0, varintError(n, len(p))
+	}
+	b.off += int64(n)
+	return x, nil
+}
+
+// varint returns the bytes which may hold the varint at the offset
+func (b *BufferIO) varint() ([]byte, error) {
+	var p [binary.MaxVarintLen64]byte
+	n, err := b.readAt(p[:], b.off)
+	if err != nil && (err != io.EOF || n == 0) {
+		return nil, err
+	}
+	return p[:n], nil
+}
+
+// varintError explains a failure to decode a varint from m bytes:
+// without a final byte among the longest possible encoding it is too
+// long, otherwise it was cut short
+func varintError(n, m int) error {
+	if n == 0 && m < binary.MaxVarintLen64 {
+		return io.ErrUnexpectedEOF
+	}
+	return ErrVarintOverflow
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	uvalues := []uint64{0, 1, 127, 128, 300, math.MaxUint32, math.MaxUint64}
+	values := []int64{0, -1, 1, -64, 64, math.MinInt64, math.MaxInt64}
+
+	b := NewBufferIOGrow(0)
+	for _, x := range uvalues {
+		assert(t, b.WriteUvarint(x) == nil)
+	}
+	for _, x := range values {
+		assert(t, b.WriteVarint(x) == nil)
+	}
+
+	// The encoding is that of encoding/binary
+	var want []byte
+	var p [binary.MaxVarintLen64]byte
+	for _, x := range uvalues {
+		want = append(want, p[:binary.PutUvarint(p[:], x)]...)
+	}
+	for _, x := range values {
+		want = append(want, p[:binary.PutVarint(p[:], x)]...)
+	}
+	assert(t, bytes.Equal(b.Bytes(), want))
+
+	b.Seek(0, io.SeekStart)
+	for _, x := range uvalues {
+		v, err := b.ReadUvarint()
+		assert(t, err == nil && v == x)
+	}
+	for _, x := range values {
+		v, err := b.ReadVarint()
+		assert(t, err == nil && v == x)
+	}
+	_, err := b.ReadUvarint()
+	assert(t, err == io.EOF)
+
+	// Backed buffers read them too
+	r := NewBufferIOFrom(bytes.NewReader(want), int64(len(want)))
+	v, err := r.ReadUvarint()
+	assert(t, err == nil && v == 0)
+}
+
+func TestVarintErrors(t *testing.T) {
+	b := NewBufferIO([]byte{0x80, 0x80})
+	_, err := b.ReadUvarint()
+	assert(t, err == io.ErrUnexpectedEOF)
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 0)
+
+	b = NewBufferIO(bytes.Repeat([]byte{0xff}, 11))
+	_, err = b.ReadVarint()
+	assert(t, err == ErrVarintOverflow)
+
+	// Varints are written whole or not at all
+	b = NewBufferIOMake(4)
+	b.Seek(1, io.SeekStart)
+	assert(t, b.WriteUvarint(1<<7) == nil)
+	assert(t, b.WriteUvarint(1<<7) == io.ErrShortWrite)
+	assert(t, b.WriteVarint(-1) == nil)
+	assert(t, b.WriteVarint(0) == io.ErrShortWrite)
+	assert(t, bytes.Equal(b.Bytes(), []byte{0, 0x80, 0x01, 0x01}))
+}
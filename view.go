@@ -0,0 +1,35 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+// View calls fn with the n bytes of the buffer at off, without copying
+// them. The buffer stays locked while fn runs, so the memory cannot move
+// or be released underneath it; fn must not keep p after returning, must
+// not modify it, and must not call methods of the buffer. View returns
+// the error of fn.
+func (b *BufferIO) View(off, n int64, fn func(p []byte) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.view(off, n, fn)
+}
+
+// ViewWrite is View for callbacks which modify the bytes in place. It
+// holds the lock exclusively, where concurrent Views share it.
+func (b *BufferIO) ViewWrite(off, n int64, fn func(p []byte) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.view(off, n, fn)
+}
+
+func (b *BufferIO) view(off, n int64, fn func(p []byte) error) error {
+	if b.from != nil {
+		return ErrUnsupported
+	}
+	p, err := b.slice(off, n)
+	if err != nil {
+		return err
+	}
+	return fn(p[:n:n])
+}
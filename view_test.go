@@ -0,0 +1,43 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	bio := NewBufferIO(append([]byte(nil), src...))
+	var seen []byte
+	err := bio.View(2, 4, func(p []byte) error {
+		assert(t, cap(p) == 4)
+		seen = append(seen, p...)
+		return nil
+	})
+	assert(t, err == nil)
+	assert(t, bytes.Equal(seen, src[2:6]))
+
+	fail := errors.New("fail")
+	assert(t, bio.View(0, 1, func(p []byte) error { return fail }) == fail)
+	assert(t, bio.View(1, bio.Size(), func(p []byte) error { return nil }) == ErrOverrun)
+}
+
+func TestViewWrite(t *testing.T) {
+	bio := NewBufferIOMake(8)
+	err := bio.ViewWrite(4, 4, func(p []byte) error {
+		copy(p, "abcd")
+		return nil
+	})
+	assert(t, err == nil)
+	assert(t, string(bio.Bytes()[4:]) == "abcd")
+
+	backed := NewBufferIOFrom(bytes.NewReader(src), int64(len(src)))
+	assert(t, backed.View(0, 1, func(p []byte) error { return nil }) == ErrUnsupported)
+
+	assert(t, bio.Close() == nil)
+	assert(t, bio.ViewWrite(0, 1, func(p []byte) error { return nil }) == ErrClosed)
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Window returns a BufferIO over the length bytes of b at off, like
+// io.NewSectionReader. The window shares memory with b, so writes to
+// either are visible through the other, but it has an offset of its own
+// starting at zero and cannot read or write beyond its bounds. A window
+// of a buffer backed by a ReaderAt reads through the same ReaderAt.
+//
+// Once b is resized, grown or closed, which may move or release the
+// memory, the window fails with ErrInvalidView, or ErrClosed after b is
+// closed. The same holds for the buffers of ReadNested.
+func (b *BufferIO) Window(off, length int64) (*BufferIO, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if err := b.usable(); err != nil {
+		return nil, err
+	}
+	if off < 0 || length < 0 || off > b.length() || length > b.length()-off {
+		return nil, ErrOverrun
+	}
+	var w *BufferIO
+	if b.from != nil {
+		w = NewBufferIOFrom(io.NewSectionReader(b.from, off, length), length)
+	} else {
+		w = NewBufferIO(b.buf[off : off+length : off+length])
+	}
+	b.adopt(w)
+	return w, nil
+}
+
+// adopt ties c, which shares the memory of b, to the current memory of b
+func (b *BufferIO) adopt(c *BufferIO) {
+	c.parent = b
+	c.parentGen = atomic.LoadUint32(&b.gen)
+}
+
+// usable returns ErrClosed if the buffer is closed, and for a buffer
+// sharing the memory of another, why that memory is no longer valid
+func (b *BufferIO) usable() error {
+	if b.closed {
+		return ErrClosed
+	}
+	return b.attached()
+}
+
+// attached walks the buffers whose memory b shares, returning ErrClosed
+// if one was closed and ErrInvalidView if one moved its memory since
+func (b *BufferIO) attached() error {
+	for c := b; c.parent != nil; c = c.parent {
+		p := c.parent
+		p.mu.RLock()
+		closed, gen := p.closed, atomic.LoadUint32(&p.gen)
+		p.mu.RUnlock()
+		if closed {
+			return ErrClosed
+		}
+		if gen != c.parentGen {
+			return ErrInvalidView
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWindow(t *testing.T) {
+	b := NewBufferIO([]byte("header|payload|trailer"))
+	b.Seek(3, io.SeekStart)
+
+	w, err := b.Window(7, 7)
+	assert(t, err == nil)
+	assert(t, w.Size() == 7)
+	data, err := ioutil.ReadAll(w)
+	assert(t, err == nil && string(data) == "payload")
+
+	// Writes share memory and stay inside the window
+	n, err := w.WriteAt([]byte("PAYLOADS"), 0)
+	assert(t, n == 7 && err == io.ErrShortWrite)
+	assert(t, string(b.Bytes()) == "header|PAYLOAD|trailer")
+	// Windows nest, and the parent offset is untouched
+	inner, err := w.Window(2, 3)
+	assert(t, err == nil)
+	p := make([]byte, 3)
+	inner.ReadAt(p, 0)
+	assert(t, string(p) == "YLO")
+	pos, _ := b.Seek(0, io.SeekCurrent)
+	assert(t, pos == 3)
+
+	w.Seek(0, io.SeekStart)
+	assert(t, w.WriteDataBE(uint64(1)) == io.ErrShortWrite)
+	assert(t, string(b.Bytes()[14:]) == "|trailer")
+
+	for _, r := range [][2]int64{{-1, 2}, {0, -1}, {20, 3}, {23, 0}} {
+		_, err := b.Window(r[0], r[1])
+		assert(t, err == ErrOverrun)
+	}
+	_, err = b.Window(22, 0)
+	assert(t, err == nil)
+
+	b.Close()
+	_, err = b.Window(0, 1)
+	assert(t, err == ErrClosed)
+}
+
+func TestWindowBacked(t *testing.T) {
+	b := NewBufferIOFrom(bytes.NewReader([]byte("header|payload")), 14)
+	w, err := b.Window(7, 7)
+	assert(t, err == nil)
+	data, err := ioutil.ReadAll(w)
+	assert(t, err == nil && string(data) == "payload")
+	_, err = w.Write([]byte("x"))
+	assert(t, err == ErrReadOnly)
+}
+
+func TestWindowInvalidated(t *testing.T) {
+	b, err := NewBufferIOOptions(4096, Options{Backing: BackingMmap})
+	if err == ErrUnsupported {
+		t.Skip(err)
+	}
+	assert(t, err == nil)
+	b.WriteAt([]byte("payload"), 100)
+	w, err := b.Window(100, 7)
+	assert(t, err == nil)
+	inner, err := w.Window(0, 3)
+	assert(t, err == nil)
+
+	// Growing moves the memory out from under the window
+	assert(t, b.Resize(8192) == nil)
+	p := make([]byte, 7)
+	_, err = w.ReadAt(p, 0)
+	assert(t, err == ErrInvalidView)
+	_, err = inner.Read(p)
+	assert(t, err == ErrInvalidView)
+	assert(t, w.Bytes() == nil)
+
+	// Closing unmaps it
+	w, _ = b.Window(100, 7)
+	assert(t, b.Close() == nil)
+	_, err = w.ReadAt(p, 0)
+	assert(t, err == ErrClosed)
+	_, err = w.WriteAt(p, 0)
+	assert(t, err == ErrClosed)
+	_, err = w.Next(1)
+	assert(t, err == ErrClosed)
+	assert(t, w.Resize(3) == ErrClosed)
+}
+
+func TestWindowResize(t *testing.T) {
+	b := NewBufferIOMake(16)
+	w, _ := b.Window(4, 8)
+	assert(t, w.Resize(16) == ErrUnsupported)
+	assert(t, w.Truncate(4) == nil)
+	assert(t, w.Size() == 4)
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+)
+
+// Words32 accesses a buffer as an array of 32 bit words in a fixed byte
+// order. Out of range indexes panic, like slice indexing.
+type Words32 struct {
+	words
+}
+
+// Words64 accesses a buffer as an array of 64 bit words in a fixed byte
+// order. Out of range indexes panic, like slice indexing.
+type Words64 struct {
+	words
+}
+
+type words struct {
+	b     *BufferIO
+	gen   uint32
+	buf   []byte
+	order binary.ByteOrder
+}
+
+func (b *BufferIO) words(size int, order binary.ByteOrder) words {
+	return words{
+		b:     b,
+		gen:   b.gen,
+		buf:   b.buf[:len(b.buf)&^(size-1)],
+		order: order,
+	}
+}
+
+func (w *words) check() {
+	if atomic.LoadUint32(&w.b.gen) != w.gen || w.b.attached() != nil {
+		panic(ErrInvalidView)
+	}
+}
+
+// View32 returns a 32 bit word view sharing memory with the buffer.
+// Trailing bytes which do not make up a whole word are not accessible.
+// Accesses through the view do not take the buffer lock. Words are
+// decoded through order, so the buffer needs no particular alignment.
+func (b *BufferIO) View32(order binary.ByteOrder) *Words32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return &Words32{b.words(4, order)}
+}
+
+// View64 returns a 64 bit word view sharing memory with the buffer.
+// Trailing bytes which do not make up a whole word are not accessible.
+// Accesses through the view do not take the buffer lock. Words are
+// decoded through order, so the buffer needs no particular alignment.
+func (b *BufferIO) View64(order binary.ByteOrder) *Words64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return &Words64{b.words(8, order)}
+}
+
+func (w *Words32) Len() int {
+	return len(w.buf) / 4
+}
+
+func (w *Words32) Get(i int) uint32 {
+	w.check()
+	return w.order.Uint32(w.buf[i*4 : i*4+4])
+}
+
+func (w *Words32) Set(i int, v uint32) {
+	w.check()
+	w.order.PutUint32(w.buf[i*4:i*4+4], v)
+}
+
+func (w *Words64) Len() int {
+	return len(w.buf) / 8
+}
+
+func (w *Words64) Get(i int) uint64 {
+	w.check()
+	return w.order.Uint64(w.buf[i*8 : i*8+8])
+}
+
+func (w *Words64) Set(i int, v uint64) {
+	w.check()
+	w.order.PutUint64(w.buf[i*8:i*8+8], v)
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bufferio
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestView32(t *testing.T) {
+	bio := NewBufferIO(append([]byte(nil), src...))
+
+	be := bio.View32(binary.BigEndian)
+	assert(t, be.Len() == 2)
+	assert(t, be.Get(0) == uint32(res[0]))
+	assert(t, be.Get(1) == uint32(res[1]))
+
+	le := bio.View32(binary.LittleEndian)
+	assert(t, le.Get(0) == 0x04030201)
+
+	be.Set(1, 0xdeadbeef)
+	assert(t, bio.buf[4] == 0xde)
+	assert(t, bio.buf[7] == 0xef)
+	assert(t, le.Get(1) == 0xefbeadde)
+
+	// Partial words are not visible
+	assert(t, NewBufferIOMake(7).View32(binary.BigEndian).Len() == 1)
+}
+
+func TestView64(t *testing.T) {
+	bio := NewBufferIO(append([]byte(nil), big...))
+
+	be := bio.View64(binary.BigEndian)
+	assert(t, be.Len() == len(big)/8)
+	assert(t, be.Get(0) == 0x0102030405060708)
+
+	le := bio.View64(binary.LittleEndian)
+	assert(t, le.Get(0) == 0x0807060504030201)
+
+	le.Set(1, 0x1122334455667788)
+	assert(t, bio.buf[8] == 0x88)
+	assert(t, bio.buf[15] == 0x11)
+	assert(t, be.Get(1) == 0x8877665544332211)
+}
+
+func TestViewOutOfRange(t *testing.T) {
+	defer func() {
+		assert(t, recover() != nil)
+	}()
+	NewBufferIOMake(8).View32(binary.BigEndian).Get(2)
+}